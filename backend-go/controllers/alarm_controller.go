@@ -0,0 +1,344 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend-go/database"
+	"backend-go/models"
+	"backend-go/services"
+	"github.com/gin-gonic/gin"
+)
+
+type AlarmController struct{}
+
+// Limites da consulta ao histórico de alarmes
+const (
+	alarmHistoryDefaultLimit = 500
+	alarmHistoryMaxLimit     = 5000
+)
+
+// GetActiveAlarms handles GET /api/plc/:lockId/alarms
+// Alarmes abertos (ativos ou aguardando reconhecimento), dos mais urgentes aos menos
+func (ctrl *AlarmController) GetActiveAlarms(c *gin.Context) {
+	connector, ok := getLockConnector(c)
+	if !ok {
+		return
+	}
+
+	alarms := services.GetAlarmEngine().ActiveAlarms(connector.ID())
+	c.JSON(http.StatusOK, gin.H{
+		"lock_id": connector.ID(),
+		"alarms":  alarms,
+		"count":   len(alarms),
+	})
+}
+
+// GetAlarmHistory handles GET /api/plc/:lockId/alarms/history
+// Ocorrências ativadas entre from e to (padrão últimas 24 h), filtráveis por tag,
+// priority e state, das mais recentes às mais antigas
+func (ctrl *AlarmController) GetAlarmHistory(c *gin.Context) {
+	connector, ok := getLockConnector(c)
+	if !ok {
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		errorResponse(c, http.StatusServiceUnavailable, "ServiceUnavailableError", "Banco de dados indisponível", nil)
+		return
+	}
+
+	to, err := parseHistoryTime(c.Query("to"), time.Now())
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "to inválido (use RFC3339 ou milissegundos)", nil)
+		return
+	}
+	from, err := parseHistoryTime(c.Query("from"), to.Add(-historyDefaultRange))
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "from inválido (use RFC3339 ou milissegundos)", nil)
+		return
+	}
+
+	limit := alarmHistoryDefaultLimit
+	if value := c.Query("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 || limit > alarmHistoryMaxLimit {
+			errorResponse(c, http.StatusBadRequest, "ValidationError", "limit inválido (1 a 5000)", nil)
+			return
+		}
+	}
+
+	query := db.Model(&models.Alarm{}).
+		Where("plc_id = ? AND activated_at >= ? AND activated_at < ?", connector.ID(), from, to)
+	if tag := c.Query("tag"); tag != "" {
+		query = query.Where("tag_name = ?", tag)
+	}
+	if priority := c.Query("priority"); priority != "" {
+		query = query.Where("priority = ?", priority)
+	}
+	if state := c.Query("state"); state != "" {
+		query = query.Where("state = ?", state)
+	}
+
+	var alarms []models.Alarm
+	if err := query.Order("activated_at DESC").Limit(limit).Find(&alarms).Error; err != nil {
+		errorResponse(c, http.StatusInternalServerError, "InternalServerError", "Erro ao buscar alarmes: "+err.Error(), nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"lock_id": connector.ID(),
+		"from":    from,
+		"to":      to,
+		"alarms":  alarms,
+		"count":   len(alarms),
+	})
+}
+
+// ExportAlarmHistory handles GET /api/plc/:lockId/alarms/history/export
+// Ocorrências ativadas no período em format=csv|xlsx, filtráveis por tags, priority e state
+func (ctrl *AlarmController) ExportAlarmHistory(c *gin.Context) {
+	exportEvents(c, services.ExportAlarms)
+}
+
+// alarmActionRequest é o corpo aceito no reconhecimento e nos comentários
+type alarmActionRequest struct {
+	Comment string `json:"comment"`
+}
+
+// alarmError responde os erros de reconhecimento e comentário
+func alarmError(c *gin.Context, err error) {
+	details := gin.H{"rule_id": c.Param("ruleId")}
+	switch {
+	case errors.Is(err, services.ErrAlarmNotFound):
+		errorResponse(c, http.StatusNotFound, "NotFoundError", err.Error(), details)
+	case errors.Is(err, services.ErrAlarmNotShelved):
+		errorResponse(c, http.StatusNotFound, "NotFoundError", err.Error(), nil)
+	case errors.Is(err, services.ErrAlarmAlreadyAcked), errors.Is(err, services.ErrEmptyAlarmComment),
+		errors.Is(err, services.ErrInvalidShelve):
+		errorResponse(c, http.StatusBadRequest, "ValidationError", err.Error(), details)
+	default:
+		errorResponse(c, http.StatusInternalServerError, "InternalServerError", err.Error(), details)
+	}
+}
+
+// AckAlarm handles POST /api/plc/:lockId/alarms/:ruleId/ack
+// Reconhece o alarme aberto da regra, com comentário opcional
+func (ctrl *AlarmController) AckAlarm(c *gin.Context) {
+	connector, ok := getLockConnector(c)
+	if !ok {
+		return
+	}
+	var request alarmActionRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			errorResponse(c, http.StatusBadRequest, "ValidationError", "Dados inválidos", gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	alarm, err := services.GetAlarmEngine().Acknowledge(connector.ID(), c.Param("ruleId"), currentUsername(c), request.Comment)
+	if err != nil {
+		alarmError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"lock_id": connector.ID(),
+		"alarm":   alarm,
+	})
+}
+
+// AckAllAlarms handles POST /api/plc/:lockId/alarms/ack
+// Reconhece todos os alarmes não reconhecidos da eclusa
+func (ctrl *AlarmController) AckAllAlarms(c *gin.Context) {
+	connector, ok := getLockConnector(c)
+	if !ok {
+		return
+	}
+	var request alarmActionRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			errorResponse(c, http.StatusBadRequest, "ValidationError", "Dados inválidos", gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	alarms := services.GetAlarmEngine().AcknowledgeAll(connector.ID(), currentUsername(c), request.Comment)
+	c.JSON(http.StatusOK, gin.H{
+		"lock_id": connector.ID(),
+		"alarms":  alarms,
+		"count":   len(alarms),
+	})
+}
+
+// CommentAlarm handles POST /api/plc/:lockId/alarms/:ruleId/comments
+// Anexa um comentário do operador ao alarme aberto da regra
+func (ctrl *AlarmController) CommentAlarm(c *gin.Context) {
+	connector, ok := getLockConnector(c)
+	if !ok {
+		return
+	}
+	var request alarmActionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "Dados inválidos", gin.H{"error": err.Error()})
+		return
+	}
+
+	entry, err := services.GetAlarmEngine().Comment(connector.ID(), c.Param("ruleId"), currentUsername(c), request.Comment)
+	if err != nil {
+		alarmError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"lock_id": connector.ID(),
+		"audit":   entry,
+	})
+}
+
+// GetAlarmAudit handles GET /api/plc/:lockId/alarms/audit
+// Reconhecimentos e comentários entre from e to (padrão últimas 24 h), filtráveis por
+// alarm_id, rule_id, action e username, dos mais recentes aos mais antigos
+func (ctrl *AlarmController) GetAlarmAudit(c *gin.Context) {
+	connector, ok := getLockConnector(c)
+	if !ok {
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		errorResponse(c, http.StatusServiceUnavailable, "ServiceUnavailableError", "Banco de dados indisponível", nil)
+		return
+	}
+
+	to, err := parseHistoryTime(c.Query("to"), time.Now())
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "to inválido (use RFC3339 ou milissegundos)", nil)
+		return
+	}
+	from, err := parseHistoryTime(c.Query("from"), to.Add(-historyDefaultRange))
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "from inválido (use RFC3339 ou milissegundos)", nil)
+		return
+	}
+
+	query := db.Model(&models.AlarmAudit{}).
+		Where("plc_id = ? AND created_at >= ? AND created_at < ?", connector.ID(), from, to)
+	if alarmID := c.Query("alarm_id"); alarmID != "" {
+		query = query.Where("alarm_id = ?", alarmID)
+	}
+	if ruleID := c.Query("rule_id"); ruleID != "" {
+		query = query.Where("alarm_key = ?", connector.ID()+"/"+ruleID)
+	}
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if username := c.Query("username"); username != "" {
+		query = query.Where("username = ?", username)
+	}
+
+	var entries []models.AlarmAudit
+	if err := query.Order("created_at DESC").Limit(alarmHistoryMaxLimit).Find(&entries).Error; err != nil {
+		errorResponse(c, http.StatusInternalServerError, "InternalServerError", "Erro ao buscar auditoria: "+err.Error(), nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"lock_id": connector.ID(),
+		"from":    from,
+		"to":      to,
+		"audit":   entries,
+		"count":   len(entries),
+	})
+}
+
+// alarmShelveBody é o corpo aceito na retirada e na devolução de alarmes
+type alarmShelveBody struct {
+	RuleIDs         []string `json:"rule_ids"`
+	GroupID         *uint    `json:"group_id"`
+	DurationMinutes int      `json:"duration_minutes"`
+	Reason          string   `json:"reason"`
+}
+
+// GetShelvedAlarms handles GET /api/plc/:lockId/alarms/shelved
+// Retiradas em vigor e os alarmes abertos fora da lista principal (retirados ou suprimidos)
+func (ctrl *AlarmController) GetShelvedAlarms(c *gin.Context) {
+	connector, ok := getLockConnector(c)
+	if !ok {
+		return
+	}
+
+	engine := services.GetAlarmEngine()
+	alarms := engine.ShelvedAlarms(connector.ID())
+	c.JSON(http.StatusOK, gin.H{
+		"lock_id": connector.ID(),
+		"shelves": engine.Shelves(connector.ID()),
+		"alarms":  alarms,
+		"count":   len(alarms),
+	})
+}
+
+// ShelveAlarms handles POST /api/plc/:lockId/alarms/shelve
+// Retira regras (rule_ids) ou os alarmes dos tags de um grupo (group_id) da lista principal
+// por duration_minutes (até 24 h), com motivo obrigatório
+func (ctrl *AlarmController) ShelveAlarms(c *gin.Context) {
+	connector, ok := getLockConnector(c)
+	if !ok {
+		return
+	}
+	var body alarmShelveBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "Dados inválidos", gin.H{"error": err.Error()})
+		return
+	}
+
+	shelves, err := services.GetAlarmEngine().Shelve(services.AlarmShelveRequest{
+		LockID:   connector.ID(),
+		RuleIDs:  body.RuleIDs,
+		GroupID:  body.GroupID,
+		Duration: time.Duration(body.DurationMinutes) * time.Minute,
+		Reason:   strings.TrimSpace(body.Reason),
+		Username: currentUsername(c),
+	})
+	if err != nil {
+		alarmError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"lock_id": connector.ID(),
+		"shelves": shelves,
+		"count":   len(shelves),
+	})
+}
+
+// UnshelveAlarms handles POST /api/plc/:lockId/alarms/unshelve
+// Devolve à lista principal, antes do prazo, as regras (rule_ids) ou as do grupo (group_id)
+func (ctrl *AlarmController) UnshelveAlarms(c *gin.Context) {
+	connector, ok := getLockConnector(c)
+	if !ok {
+		return
+	}
+	var body alarmShelveBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "Dados inválidos", gin.H{"error": err.Error()})
+		return
+	}
+
+	shelves, err := services.GetAlarmEngine().Unshelve(services.AlarmShelveRequest{
+		LockID:   connector.ID(),
+		RuleIDs:  body.RuleIDs,
+		GroupID:  body.GroupID,
+		Username: currentUsername(c),
+	})
+	if err != nil {
+		alarmError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"lock_id": connector.ID(),
+		"shelves": shelves,
+		"count":   len(shelves),
+	})
+}
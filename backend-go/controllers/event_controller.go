@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend-go/database"
+	"backend-go/models"
+	"backend-go/services"
+	"github.com/gin-gonic/gin"
+)
+
+type EventController struct{}
+
+// Limites da consulta à sequência de eventos
+const (
+	eventDefaultLimit = 1000
+	eventMaxLimit     = 20000
+)
+
+// GetEvents handles GET /api/plc/:lockId/events
+// Transições dos tags digitais e de estado entre from e to (padrão últimas 24 h),
+// filtráveis por tags, na ordem em que aconteceram (order=desc inverte)
+func (ctrl *EventController) GetEvents(c *gin.Context) {
+	connector, ok := getLockConnector(c)
+	if !ok {
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		errorResponse(c, http.StatusServiceUnavailable, "ServiceUnavailableError", "Banco de dados indisponível", nil)
+		return
+	}
+
+	to, err := parseHistoryTime(c.Query("to"), time.Now())
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "to inválido (use RFC3339 ou milissegundos)", nil)
+		return
+	}
+	from, err := parseHistoryTime(c.Query("from"), to.Add(-historyDefaultRange))
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "from inválido (use RFC3339 ou milissegundos)", nil)
+		return
+	}
+	if !from.Before(to) {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "from deve ser anterior a to", nil)
+		return
+	}
+
+	limit := eventDefaultLimit
+	if value := c.Query("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 || limit > eventMaxLimit {
+			errorResponse(c, http.StatusBadRequest, "ValidationError", "limit inválido (1 a 20000)", nil)
+			return
+		}
+	}
+
+	order := "timestamp ASC, id ASC"
+	switch c.DefaultQuery("order", "asc") {
+	case "asc":
+	case "desc":
+		order = "timestamp DESC, id DESC"
+	default:
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "order inválido (use asc ou desc)", nil)
+		return
+	}
+
+	query := db.Model(&models.TagEvent{}).
+		Where("plc_id = ? AND timestamp >= ? AND timestamp < ?", connector.ID(), from, to)
+	var tags []string
+	for _, name := range strings.Split(c.Query("tags"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			tags = append(tags, name)
+		}
+	}
+	if len(tags) > 0 {
+		query = query.Where("tag_name IN ?", tags)
+	}
+
+	// Um a mais para saber se o período foi truncado
+	var events []models.TagEvent
+	if err := query.Order(order).Limit(limit + 1).Find(&events).Error; err != nil {
+		errorResponse(c, http.StatusInternalServerError, "InternalServerError", "Erro ao buscar eventos: "+err.Error(), nil)
+		return
+	}
+	truncated := len(events) > limit
+	if truncated {
+		events = events[:limit]
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"lock_id":   connector.ID(),
+		"from":      from,
+		"to":        to,
+		"events":    events,
+		"count":     len(events),
+		"truncated": truncated,
+	})
+}
+
+// ExportEvents handles GET /api/plc/:lockId/events/export
+// Mesmos filtros de GetEvents (sem limite), em format=csv|xlsx
+func (ctrl *EventController) ExportEvents(c *gin.Context) {
+	exportEvents(c, services.ExportTagEvents)
+}
+
+// exportEvents envia em fluxo os alarmes ou eventos do período como planilha.
+// Aceita tags, from/to (padrão últimas 24 h), format e locale como a exportação do
+// histórico e, para alarmes, priority e state.
+func exportEvents(c *gin.Context, kind string) {
+	connector, ok := getLockConnector(c)
+	if !ok {
+		return
+	}
+
+	to, err := parseHistoryTime(c.Query("to"), time.Now())
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "to inválido (use RFC3339 ou milissegundos)", nil)
+		return
+	}
+	from, err := parseHistoryTime(c.Query("from"), to.Add(-historyDefaultRange))
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "from inválido (use RFC3339 ou milissegundos)", nil)
+		return
+	}
+
+	locale := c.Query("locale")
+	if locale == "" {
+		locale = c.GetHeader("Accept-Language")
+	}
+	export := services.EventExport{
+		LockID:   connector.ID(),
+		Kind:     kind,
+		From:     from,
+		To:       to,
+		Priority: c.Query("priority"),
+		State:    c.Query("state"),
+		Format:   c.DefaultQuery("format", services.ExportCSV),
+		Locale:   services.ExportLocaleFor(locale),
+	}
+	for _, name := range strings.Split(c.Query("tags"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			export.Tags = append(export.Tags, name)
+		}
+	}
+	if err := export.Validate(); err != nil {
+		historyError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", export.ContentType())
+	c.Header("Content-Disposition", `attachment; filename="`+export.FileName()+`"`)
+	c.Status(http.StatusOK)
+
+	if err := services.WriteEventExport(c.Writer, export); err != nil {
+		log.Printf("❌ [%s] Exportação de %s interrompida: %v", connector.ID(), kind, err)
+	}
+}
@@ -0,0 +1,196 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend-go/services"
+	"github.com/gin-gonic/gin"
+)
+
+type HistoryController struct{}
+
+// Período padrão das consultas sem from
+const historyDefaultRange = 24 * time.Hour
+
+// parseHistoryTime aceita RFC3339 ou milissegundos desde 1970
+func parseHistoryTime(value string, fallback time.Time) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// parseHistoryBucket aceita durações do Go ("30s", "5m", "1h"), dias ("1d") ou segundos ("300")
+func parseHistoryBucket(value string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	if days, found := strings.CutSuffix(value, "d"); found {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("bucket inválido: %s", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	bucket, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("bucket inválido: %s", value)
+	}
+	return bucket, nil
+}
+
+// parseHistoryQuery lê tags, from, to, bucket e limit da query string
+func parseHistoryQuery(c *gin.Context, lockID string) (services.HistoryQuery, bool) {
+	query := services.HistoryQuery{LockID: lockID}
+
+	for _, name := range strings.Split(c.Query("tags"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			query.Tags = append(query.Tags, name)
+		}
+	}
+
+	var err error
+	if query.To, err = parseHistoryTime(c.Query("to"), time.Now()); err != nil {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "to inválido (use RFC3339 ou milissegundos)", nil)
+		return query, false
+	}
+	if query.From, err = parseHistoryTime(c.Query("from"), query.To.Add(-historyDefaultRange)); err != nil {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "from inválido (use RFC3339 ou milissegundos)", nil)
+		return query, false
+	}
+
+	if bucket := c.Query("bucket"); bucket != "" {
+		if query.Bucket, err = parseHistoryBucket(bucket); err != nil {
+			errorResponse(c, http.StatusBadRequest, "ValidationError", err.Error(), nil)
+			return query, false
+		}
+	} else {
+		query.Bucket = services.AutoHistoryBucket(query.From, query.To)
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		if query.Limit, err = strconv.Atoi(limit); err != nil {
+			errorResponse(c, http.StatusBadRequest, "ValidationError", "limit inválido", nil)
+			return query, false
+		}
+	}
+	return query, true
+}
+
+// historyError responde os erros da consulta ao histórico
+func historyError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidHistoryQuery):
+		errorResponse(c, http.StatusBadRequest, "ValidationError", err.Error(), nil)
+	case errors.Is(err, services.ErrHistoryUnavailable):
+		errorResponse(c, http.StatusServiceUnavailable, "ServiceUnavailableError", err.Error(), nil)
+	default:
+		errorResponse(c, http.StatusInternalServerError, "InternalServerError", "Erro ao consultar histórico: "+err.Error(), nil)
+	}
+}
+
+// GetHistory handles GET /api/plc/:lockId/history
+// ?tags=a,b&from=&to= com bucket (ex: 5m, 1h, 1d; padrão ~500 intervalos) devolve
+// min/max/avg/first/last/count por intervalo; com mode=raw devolve os pontos brutos,
+// reduzidos por LTTB a limit (padrão 2000) pontos por tag.
+func (ctrl *HistoryController) GetHistory(c *gin.Context) {
+	connector, ok := getLockConnector(c)
+	if !ok {
+		return
+	}
+	query, ok := parseHistoryQuery(c, connector.ID())
+	if !ok {
+		return
+	}
+
+	response := gin.H{
+		"lock_id": connector.ID(),
+		"from":    query.From,
+		"to":      query.To,
+	}
+
+	switch mode := c.DefaultQuery("mode", "buckets"); mode {
+	case "raw":
+		data, err := services.QueryHistoryRaw(query)
+		if err != nil {
+			historyError(c, err)
+			return
+		}
+		response["mode"] = mode
+		response["data"] = data
+	case "buckets":
+		data, err := services.QueryHistoryBuckets(query)
+		if err != nil {
+			historyError(c, err)
+			return
+		}
+		response["mode"] = mode
+		response["bucket_seconds"] = query.Bucket.Seconds()
+		response["data"] = data
+	default:
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "mode inválido (use buckets ou raw)", gin.H{"mode": mode})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ExportHistory handles GET /api/plc/:lockId/history/export
+// Mesmos filtros de GetHistory, com format=csv|xlsx, mode=buckets|raw e, no modo agregado,
+// stats=avg,min,max (min, max, avg, first, last, count). O idioma vem de ?locale= ou do
+// Accept-Language (padrão português: vírgula decimal e ";" no CSV). O arquivo é enviado
+// em fluxo, sem limite de pontos no modo bruto.
+func (ctrl *HistoryController) ExportHistory(c *gin.Context) {
+	connector, ok := getLockConnector(c)
+	if !ok {
+		return
+	}
+	query, ok := parseHistoryQuery(c, connector.ID())
+	if !ok {
+		return
+	}
+
+	locale := c.Query("locale")
+	if locale == "" {
+		locale = c.GetHeader("Accept-Language")
+	}
+	export := services.HistoryExport{
+		Query:  query,
+		Format: c.DefaultQuery("format", services.ExportCSV),
+		Locale: services.ExportLocaleFor(locale),
+	}
+	switch mode := c.DefaultQuery("mode", "buckets"); mode {
+	case "raw":
+		export.Raw = true
+	case "buckets":
+	default:
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "mode inválido (use buckets ou raw)", gin.H{"mode": mode})
+		return
+	}
+	for _, stat := range strings.Split(c.Query("stats"), ",") {
+		if stat = strings.TrimSpace(stat); stat != "" {
+			export.Stats = append(export.Stats, stat)
+		}
+	}
+	if err := export.Validate(); err != nil {
+		historyError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", export.ContentType())
+	c.Header("Content-Disposition", `attachment; filename="`+export.FileName()+`"`)
+	c.Status(http.StatusOK)
+
+	if err := services.WriteHistoryExport(c.Writer, export); err != nil {
+		log.Printf("❌ [%s] Exportação do histórico interrompida: %v", connector.ID(), err)
+	}
+}
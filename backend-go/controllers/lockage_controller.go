@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"backend-go/models"
+	"backend-go/services"
+	"github.com/gin-gonic/gin"
+)
+
+type LockageController struct{}
+
+// Limites da consulta aos ciclos de eclusagem
+const (
+	lockageDefaultLimit = 200
+	lockageMaxLimit     = 5000
+)
+
+// GetLockages handles GET /api/plc/:lockId/lockages
+// Ciclos encerrados iniciados entre from e to (padrão últimas 24 h), filtráveis por
+// direction e status, com os indicadores do período e o ciclo em andamento
+func (ctrl *LockageController) GetLockages(c *gin.Context) {
+	connector, ok := getLockConnector(c)
+	if !ok {
+		return
+	}
+
+	to, err := parseHistoryTime(c.Query("to"), time.Now())
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "to inválido (use RFC3339 ou milissegundos)", nil)
+		return
+	}
+	from, err := parseHistoryTime(c.Query("from"), to.Add(-historyDefaultRange))
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "from inválido (use RFC3339 ou milissegundos)", nil)
+		return
+	}
+	if !from.Before(to) {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "from deve ser anterior a to", nil)
+		return
+	}
+
+	query := services.LockageQuery{LockID: connector.ID(), From: from, To: to, Limit: lockageDefaultLimit}
+	switch query.Direction = c.Query("direction"); query.Direction {
+	case "", models.LockageUp, models.LockageDown:
+	default:
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "direction inválido (use up ou down)", nil)
+		return
+	}
+	switch query.Status = c.Query("status"); query.Status {
+	case "", models.LockageCompleted, models.LockageAborted:
+	default:
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "status inválido (use completed ou aborted)", nil)
+		return
+	}
+	if value := c.Query("limit"); value != "" {
+		if query.Limit, err = strconv.Atoi(value); err != nil || query.Limit <= 0 || query.Limit > lockageMaxLimit {
+			errorResponse(c, http.StatusBadRequest, "ValidationError", "limit inválido (1 a 5000)", nil)
+			return
+		}
+	}
+
+	engine := services.GetLockageEngine()
+	cycles, err := engine.Cycles(query)
+	if err != nil {
+		errorResponse(c, http.StatusInternalServerError, "InternalServerError", "Erro ao buscar eclusagens: "+err.Error(), nil)
+		return
+	}
+	summary, err := engine.Summary(query)
+	if err != nil {
+		errorResponse(c, http.StatusInternalServerError, "InternalServerError", "Erro ao calcular indicadores: "+err.Error(), nil)
+		return
+	}
+	if cycles == nil {
+		cycles = []models.LockageCycle{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"lock_id": connector.ID(),
+		"from":    from,
+		"to":      to,
+		"current": engine.Current(connector.ID()),
+		"cycles":  cycles,
+		"count":   len(cycles),
+		"summary": summary,
+	})
+}
+
+// GetLockage handles GET /api/plc/:lockId/lockages/:cycleId
+// Um ciclo encerrado com as suas fases
+func (ctrl *LockageController) GetLockage(c *gin.Context) {
+	connector, ok := getLockConnector(c)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("cycleId"), 10, 64)
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "cycleId inválido", nil)
+		return
+	}
+
+	cycle, err := services.GetLockageEngine().Cycle(connector.ID(), uint(id))
+	if errors.Is(err, services.ErrLockageNotFound) {
+		errorResponse(c, http.StatusNotFound, "NotFoundError", "Eclusagem não encontrada", nil)
+		return
+	}
+	if err != nil {
+		errorResponse(c, http.StatusInternalServerError, "InternalServerError", "Erro ao buscar eclusagem: "+err.Error(), nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"lock_id": connector.ID(), "cycle": cycle})
+}
@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"backend-go/models"
+	"backend-go/services"
+	"github.com/gin-gonic/gin"
+)
+
+type NotificationController struct{}
+
+// Limites da consulta à fila de notificações
+const (
+	notificationDefaultLimit = 100
+	notificationMaxLimit     = 1000
+)
+
+// GetOutbox handles GET /api/notifications/outbox
+// Itens da fila de envio, dos mais recentes aos mais antigos, filtráveis por status
+func (ctrl *NotificationController) GetOutbox(c *gin.Context) {
+	status := c.Query("status")
+	if status != "" && status != models.NotificationPending && status != models.NotificationSent && status != models.NotificationFailed {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "status inválido (use pending, sent ou failed)", nil)
+		return
+	}
+
+	limit := notificationDefaultLimit
+	if value := c.Query("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 || limit > notificationMaxLimit {
+			errorResponse(c, http.StatusBadRequest, "ValidationError", "limit inválido (1 a 1000)", nil)
+			return
+		}
+	}
+
+	items, err := services.GetNotifier().Outbox(status, limit)
+	if err != nil {
+		errorResponse(c, http.StatusInternalServerError, "InternalServerError", "Erro ao consultar fila de notificações", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"channels": services.GetNotifier().Channels(),
+		"items":    items,
+		"count":    len(items),
+	})
+}
+
+// SendTest handles POST /api/notifications/test
+// Envia uma mensagem de teste pelo canal na hora, sem passar pela fila
+func (ctrl *NotificationController) SendTest(c *gin.Context) {
+	var request struct {
+		Channel string   `json:"channel" binding:"required"`
+		To      []string `json:"to"` // opcional: substitui o "to" do canal SMTP
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "channel é obrigatório", nil)
+		return
+	}
+
+	err := services.GetNotifier().SendTest(request.Channel, request.To)
+	if errors.Is(err, services.ErrUnknownChannel) {
+		errorResponse(c, http.StatusNotFound, "NotFoundError", err.Error(), gin.H{"channel": request.Channel})
+		return
+	}
+	if err != nil {
+		errorResponse(c, http.StatusBadGateway, "ServiceUnavailableError", "Falha no envio: "+err.Error(), gin.H{"channel": request.Channel})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sent":    true,
+		"channel": request.Channel,
+	})
+}
+
+// ReloadConfig handles POST /api/notifications/reload
+func (ctrl *NotificationController) ReloadConfig(c *gin.Context) {
+	if err := services.GetNotifier().Reload(); err != nil {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "Configuração inválida: "+err.Error(), nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reloaded": true,
+		"channels": services.GetNotifier().Channels(),
+	})
+}
@@ -0,0 +1,169 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"backend-go/models"
+	"backend-go/services"
+)
+
+type PLCController struct{}
+
+// errorResponse envia um erro no formato Strapi usado pelo frontend
+func errorResponse(c *gin.Context, status int, name string, message string, details gin.H) {
+	if details == nil {
+		details = gin.H{}
+	}
+	c.JSON(status, gin.H{
+		"error": map[string]interface{}{
+			"status":  status,
+			"name":    name,
+			"message": message,
+			"details": details,
+		},
+	})
+}
+
+// getLockConnector busca o conector da eclusa do parâmetro :lockId
+func getLockConnector(c *gin.Context) (*services.S7PLCConnector, bool) {
+	connector, ok := services.GetPLCManager().Connector(c.Param("lockId"))
+	if !ok {
+		errorResponse(c, http.StatusNotFound, "NotFoundError", "Eclusa não encontrada",
+			gin.H{"lock_id": c.Param("lockId")})
+		return nil, false
+	}
+	return connector, true
+}
+
+// currentUsername retorna o usuário autenticado pelo AuthMiddleware (vazio sem autenticação)
+func currentUsername(c *gin.Context) string {
+	if user, exists := c.Get("user"); exists {
+		if u, ok := user.(models.User); ok {
+			return u.Username
+		}
+	}
+	return ""
+}
+
+// GetStatus handles GET /api/plc/status
+func (ctrl *PLCController) GetStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, services.GetPLCManager().GetStatus())
+}
+
+// GetLockStatus handles GET /api/plc/:lockId/status
+func (ctrl *PLCController) GetLockStatus(c *gin.Context) {
+	connector, ok := getLockConnector(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, connector.GetStatus())
+}
+
+// ReadTags handles GET /api/plc/:lockId/tags
+// Valor, qualidade (good, bad-comm, bad-config, stale, uncertain), hora da leitura
+// e hora da última mudança de cada tag. ?names=a,b filtra os tags.
+func (ctrl *PLCController) ReadTags(c *gin.Context) {
+	connector, ok := getLockConnector(c)
+	if !ok {
+		return
+	}
+
+	values := connector.TagValues()
+	if names := c.Query("names"); names != "" {
+		filtered := make(map[string]services.TagValue)
+		for _, name := range strings.Split(names, ",") {
+			name = strings.TrimSpace(name)
+			if value, exists := values[name]; exists {
+				filtered[name] = value
+			}
+		}
+		values = filtered
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"lock_id": connector.ID(),
+		"data":    values,
+	})
+}
+
+// ReadTag handles GET /api/plc/:lockId/tags/:tag
+func (ctrl *PLCController) ReadTag(c *gin.Context) {
+	connector, ok := getLockConnector(c)
+	if !ok {
+		return
+	}
+
+	value, exists := connector.TagValue(c.Param("tag"))
+	if !exists {
+		errorResponse(c, http.StatusNotFound, "NotFoundError", "Tag não encontrado", gin.H{"tag": c.Param("tag")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"lock_id": connector.ID(),
+		"tag":     c.Param("tag"),
+		"data":    value,
+	})
+}
+
+// ReloadConfig handles POST /api/plc/config/reload
+func (ctrl *PLCController) ReloadConfig(c *gin.Context) {
+	result, err := services.GetPLCManager().Reload()
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "Configuração inválida: "+err.Error(), nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reloaded": true,
+		"changes":  result,
+	})
+}
+
+// WriteTag handles POST /api/plc/:lockId/write
+func (ctrl *PLCController) WriteTag(c *gin.Context) {
+	connector, ok := getLockConnector(c)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Tag   string      `json:"tag" binding:"required"`
+		Value interface{} `json:"value"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil || request.Value == nil {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "Tag e valor são obrigatórios", nil)
+		return
+	}
+
+	// Limites vêm da definição do tag no conector (tabela tags ou tags.json)
+	err := connector.WriteTag(request.Tag, request.Value)
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrTagNotFound):
+		errorResponse(c, http.StatusNotFound, "NotFoundError", err.Error(), gin.H{"tag": request.Tag})
+		return
+	case errors.Is(err, services.ErrInvalidTagValue):
+		errorResponse(c, http.StatusBadRequest, "ValidationError", err.Error(), gin.H{"tag": request.Tag})
+		return
+	case errors.Is(err, services.ErrPLCNotConnected):
+		errorResponse(c, http.StatusServiceUnavailable, "ServiceUnavailableError", err.Error(), gin.H{"lock_id": connector.ID()})
+		return
+	default:
+		errorResponse(c, http.StatusBadGateway, "PLCError", "Erro ao escrever no PLC: "+err.Error(), gin.H{"tag": request.Tag})
+		return
+	}
+
+	log.Printf("👷 [%s] Comando do operador %s: %s = %v", connector.ID(), currentUsername(c), request.Tag, request.Value)
+
+	c.JSON(http.StatusOK, gin.H{
+		"lock_id": connector.ID(),
+		"tag":     request.Tag,
+		"value":   request.Value,
+		"written": true,
+	})
+}
@@ -0,0 +1,202 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"backend-go/services"
+)
+
+// SimulatorController controla o PLC simulado das eclusas com driver "simulator"
+type SimulatorController struct{}
+
+// getLockSimulator busca o simulador da eclusa do parâmetro :lockId
+func getLockSimulator(c *gin.Context) (*services.S7PLCConnector, *services.SimulatorDriver, bool) {
+	connector, ok := getLockConnector(c)
+	if !ok {
+		return nil, nil, false
+	}
+
+	sim, ok := connector.Simulator()
+	if !ok {
+		errorResponse(c, http.StatusConflict, "ConflictError", "Eclusa não usa o driver simulator",
+			gin.H{"lock_id": connector.ID()})
+		return nil, nil, false
+	}
+	return connector, sim, true
+}
+
+// GetMemory handles GET /api/plc/:lockId/simulator/memory?area=DB&db=19&start=0&size=64
+func (ctrl *SimulatorController) GetMemory(c *gin.Context) {
+	_, sim, ok := getLockSimulator(c)
+	if !ok {
+		return
+	}
+
+	area := strings.ToUpper(c.DefaultQuery("area", "DB"))
+	dbNumber, _ := strconv.Atoi(c.DefaultQuery("db", "0"))
+	start, _ := strconv.Atoi(c.DefaultQuery("start", "0"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "64"))
+
+	data, err := sim.Memory(area, dbNumber, start, size)
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", err.Error(), nil)
+		return
+	}
+
+	bytes := make([]int, len(data))
+	for i, b := range data {
+		bytes[i] = int(b)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"area":      area,
+		"db_number": dbNumber,
+		"start":     start,
+		"data":      bytes,
+	})
+}
+
+// SetMemory handles PUT /api/plc/:lockId/simulator/memory
+func (ctrl *SimulatorController) SetMemory(c *gin.Context) {
+	_, sim, ok := getLockSimulator(c)
+	if !ok {
+		return
+	}
+
+	var request services.SimulatorMemoryWrite
+	if err := c.ShouldBindJSON(&request); err != nil || request.Area == "" || len(request.Data) == 0 {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "Área e bytes são obrigatórios", nil)
+		return
+	}
+
+	data := make([]byte, len(request.Data))
+	for i, b := range request.Data {
+		if b < 0 || b > 255 {
+			errorResponse(c, http.StatusBadRequest, "ValidationError", "Bytes devem estar entre 0 e 255", gin.H{"index": i})
+			return
+		}
+		data[i] = byte(b)
+	}
+
+	area := strings.ToUpper(request.Area)
+	if err := sim.SetMemory(area, request.DBNumber, request.Start, data); err != nil {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", err.Error(), nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"written": len(data)})
+}
+
+// SetTag handles POST /api/plc/:lockId/simulator/tags
+func (ctrl *SimulatorController) SetTag(c *gin.Context) {
+	connector, _, ok := getLockSimulator(c)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Tag   string      `json:"tag" binding:"required"`
+		Value interface{} `json:"value"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil || request.Value == nil {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "Tag e valor são obrigatórios", nil)
+		return
+	}
+
+	err := connector.SetSimulatedTag(request.Tag, request.Value)
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrTagNotFound):
+		errorResponse(c, http.StatusNotFound, "NotFoundError", err.Error(), gin.H{"tag": request.Tag})
+		return
+	default:
+		errorResponse(c, http.StatusBadRequest, "ValidationError", err.Error(), gin.H{"tag": request.Tag})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"lock_id": connector.ID(),
+		"tag":     request.Tag,
+		"value":   request.Value,
+	})
+}
+
+// SetOnline handles PUT /api/plc/:lockId/simulator/online
+func (ctrl *SimulatorController) SetOnline(c *gin.Context) {
+	_, sim, ok := getLockSimulator(c)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Online *bool `json:"online"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil || request.Online == nil {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "Campo online é obrigatório", nil)
+		return
+	}
+
+	sim.SetOnline(*request.Online)
+	c.JSON(http.StatusOK, gin.H{"online": *request.Online})
+}
+
+// StartScenario handles POST /api/plc/:lockId/simulator/scenario
+// Aceita {"file": "cenario.json"} ou o próprio cenário no corpo.
+func (ctrl *SimulatorController) StartScenario(c *gin.Context) {
+	connector, _, ok := getLockSimulator(c)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		File string `json:"file"`
+		services.SimulatorScenario
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "Cenário inválido: "+err.Error(), nil)
+		return
+	}
+
+	scenario := request.SimulatorScenario
+	if request.File != "" {
+		var err error
+		scenario, err = services.LoadSimulatorScenario(request.File)
+		if err != nil {
+			errorResponse(c, http.StatusBadRequest, "ValidationError", "Erro ao carregar cenário: "+err.Error(), nil)
+			return
+		}
+	}
+	if err := scenario.Validate(); err != nil {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "Cenário inválido: "+err.Error(), nil)
+		return
+	}
+	if scenario.Name == "" {
+		scenario.Name = "api"
+	}
+
+	if err := connector.RunSimulatorScenario(scenario); err != nil {
+		errorResponse(c, http.StatusConflict, "ConflictError", err.Error(), nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"scenario": scenario.Name,
+		"steps":    len(scenario.Steps),
+		"loop":     scenario.Loop,
+	})
+}
+
+// StopScenario handles DELETE /api/plc/:lockId/simulator/scenario
+func (ctrl *SimulatorController) StopScenario(c *gin.Context) {
+	_, sim, ok := getLockSimulator(c)
+	if !ok {
+		return
+	}
+
+	sim.StopScenario()
+	c.JSON(http.StatusOK, gin.H{"stopped": true})
+}
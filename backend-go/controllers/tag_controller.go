@@ -0,0 +1,761 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"backend-go/database"
+	"backend-go/models"
+	"backend-go/services"
+	"gorm.io/gorm"
+)
+
+type TagController struct{}
+
+// tagRequest é o corpo aceito na criação/atualização de tags
+type tagRequest struct {
+	PLCID        string   `json:"plc_id"`
+	Name         string   `json:"name"`
+	Type         string   `json:"type"`
+	Offset       *float64 `json:"offset"`
+	Address      *string  `json:"address"`
+	Length       *int     `json:"length"`
+	Description  *string  `json:"description"`
+	Unit         *string  `json:"unit"`
+	MinValue     *float64 `json:"min_value"`
+	MaxValue     *float64 `json:"max_value"`
+	Scale        *float64 `json:"scale"`
+	WordOrder    *string  `json:"word_order"`
+	RawMin       *float64 `json:"raw_min"`
+	RawMax       *float64 `json:"raw_max"`
+	EUMin        *float64 `json:"eu_min"`
+	EUMax        *float64 `json:"eu_max"`
+	EUOffset     *float64 `json:"eu_offset"`
+	Clamp        *string  `json:"clamp"`
+	Deadband     *float64 `json:"deadband"`
+	DeadbandType *string  `json:"deadband_type"`
+	MinPublishMs *int     `json:"min_publish_ms"`
+	ScanClass    *string  `json:"scan_class"`
+	Expression   *string  `json:"expression"`
+	IsActive     *bool    `json:"is_active"`
+
+	History              *bool    `json:"history"`
+	HistoryDeadband      *float64 `json:"history_deadband"`
+	HistoryMaxIntervalS  *int     `json:"history_max_interval_s"`
+	HistoryRawDays       *int     `json:"history_raw_days"`
+	HistoryRetentionDays *int     `json:"history_retention_days"`
+
+	Alarms *[]services.AlarmRule `json:"alarms"`
+
+	Events *bool              `json:"events"`
+	States *map[string]string `json:"states"`
+}
+
+// apply copia os campos enviados para o registro
+func (r tagRequest) apply(tag *models.Tag) {
+	if r.PLCID != "" {
+		tag.PLCID = r.PLCID
+	}
+	if r.Name != "" {
+		tag.Name = r.Name
+	}
+	if r.Type != "" {
+		tag.Type = r.Type
+	}
+	if r.Offset != nil {
+		tag.Offset = *r.Offset
+	}
+	if r.Address != nil {
+		tag.Address = *r.Address
+	}
+	if r.Length != nil {
+		tag.Length = *r.Length
+	}
+	if r.Description != nil {
+		tag.Description = *r.Description
+	}
+	if r.Unit != nil {
+		tag.Unit = *r.Unit
+	}
+	if r.MinValue != nil {
+		tag.MinValue = r.MinValue
+	}
+	if r.MaxValue != nil {
+		tag.MaxValue = r.MaxValue
+	}
+	if r.Scale != nil {
+		tag.Scale = r.Scale
+	}
+	if r.WordOrder != nil {
+		tag.WordOrder = *r.WordOrder
+	}
+	if r.RawMin != nil {
+		tag.RawMin = r.RawMin
+	}
+	if r.RawMax != nil {
+		tag.RawMax = r.RawMax
+	}
+	if r.EUMin != nil {
+		tag.EUMin = r.EUMin
+	}
+	if r.EUMax != nil {
+		tag.EUMax = r.EUMax
+	}
+	if r.EUOffset != nil {
+		tag.EUOffset = *r.EUOffset
+	}
+	if r.Clamp != nil {
+		tag.Clamp = *r.Clamp
+	}
+	if r.Deadband != nil {
+		tag.Deadband = *r.Deadband
+	}
+	if r.DeadbandType != nil {
+		tag.DeadbandType = *r.DeadbandType
+	}
+	if r.MinPublishMs != nil {
+		tag.MinPublishMs = *r.MinPublishMs
+	}
+	if r.ScanClass != nil {
+		tag.ScanClass = *r.ScanClass
+	}
+	if r.Expression != nil {
+		tag.Expression = *r.Expression
+	}
+	if r.IsActive != nil {
+		tag.IsActive = *r.IsActive
+	}
+	if r.History != nil {
+		tag.History = *r.History
+	}
+	if r.HistoryDeadband != nil {
+		tag.HistoryDeadband = *r.HistoryDeadband
+	}
+	if r.HistoryMaxIntervalS != nil {
+		tag.HistoryMaxIntervalS = *r.HistoryMaxIntervalS
+	}
+	if r.HistoryRawDays != nil {
+		tag.HistoryRawDays = *r.HistoryRawDays
+	}
+	if r.HistoryRetentionDays != nil {
+		tag.HistoryRetentionDays = *r.HistoryRetentionDays
+	}
+	if r.Alarms != nil {
+		tag.Alarms = services.AlarmRulesJSON(*r.Alarms)
+	}
+	if r.Events != nil {
+		tag.Events = r.Events
+	}
+	if r.States != nil {
+		tag.States = services.StatesJSON(*r.States)
+	}
+}
+
+// validateTag confere eclusa, tipo e endereço antes de gravar e valida a eclusa com
+// a mudança aplicada. previous é o registro antes da alteração (nil na criação).
+func validateTag(c *gin.Context, previous *models.Tag, tag models.Tag) bool {
+	if tag.Name == "" || tag.PLCID == "" {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "plc_id e name são obrigatórios", nil)
+		return false
+	}
+
+	if _, ok := services.GetPLCManager().Connector(tag.PLCID); !ok {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "Eclusa não configurada no tags.json",
+			gin.H{"plc_id": tag.PLCID})
+		return false
+	}
+
+	if tag.MinValue != nil && tag.MaxValue != nil && *tag.MinValue > *tag.MaxValue {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "min_value maior que max_value", nil)
+		return false
+	}
+
+	// Tag trocado de eclusa: a eclusa antiga precisa continuar válida sem ele
+	var oldName string
+	if previous != nil && previous.PLCID != tag.PLCID {
+		if previous.IsActive && !validateTagRemoval(c, *previous) {
+			return false
+		}
+	} else if previous != nil {
+		oldName = previous.Name
+	}
+
+	err := services.ValidateTagChange(tag.PLCID, oldName, tag.Name, services.PLCTagFromModel(tag), tag.IsActive)
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "Tag inválido: "+err.Error(),
+			gin.H{"name": tag.Name})
+		return false
+	}
+
+	return true
+}
+
+// validateTagRemoval confere se a eclusa continua válida sem o tag
+// (tags calculados, alarmes e eclusagem que dependem dele)
+func validateTagRemoval(c *gin.Context, tag models.Tag) bool {
+	if err := services.ValidateTagRemoval(tag.PLCID, tag.Name); err != nil {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "Tag em uso na eclusa: "+err.Error(),
+			gin.H{"name": tag.Name, "plc_id": tag.PLCID})
+		return false
+	}
+	return true
+}
+
+// seedLock passa a eclusa do tag para os tags do banco, importando antes os do
+// tags.json quando ela ainda não tem registros
+func seedLock(c *gin.Context, tag models.Tag) bool {
+	if err := services.SeedLockTags(tag.PLCID); err != nil {
+		errorResponse(c, http.StatusInternalServerError, "InternalServerError", "Erro ao gravar tag: "+err.Error(), nil)
+		return false
+	}
+	return true
+}
+
+// validateScanClass confere se a classe de varredura existe em alguma eclusa
+func validateScanClass(c *gin.Context, class string) bool {
+	if class != "" && !services.GetPLCManager().HasScanClass(class) {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "Classe de varredura desconhecida",
+			gin.H{"scan_class": class})
+		return false
+	}
+	return true
+}
+
+// validateGroupScanClass confere a classe do grupo na eclusa de cada tag membro
+func validateGroupScanClass(c *gin.Context, group models.TagGroup, tagIDs []uint) bool {
+	if !group.IsActive || group.ScanClass == "" {
+		return true
+	}
+	if err := services.ValidateGroupScanClass(group.ScanClass, tagIDs); err != nil {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "Classe de varredura do grupo inválida: "+err.Error(),
+			gin.H{"scan_class": group.ScanClass})
+		return false
+	}
+	return true
+}
+
+// reloadTags aplica as mudanças do banco nos conectores em execução. Se a recarga
+// falhar, responde com o erro e retorna false.
+func reloadTags(c *gin.Context) bool {
+	if _, err := services.GetPLCManager().Reload(); err != nil {
+		log.Printf("⚠️ Tags gravados, mas recarga dos conectores falhou: %v", err)
+		errorResponse(c, http.StatusInternalServerError, "InternalServerError",
+			"Alteração gravada, mas a recarga dos conectores falhou: "+err.Error(), nil)
+		return false
+	}
+	return true
+}
+
+// parseID lê um parâmetro numérico da rota
+func parseID(c *gin.Context, param string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(param), 10, 32)
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "ID inválido", gin.H{param: c.Param(param)})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// ListTags handles GET /api/tags
+func (ctrl *TagController) ListTags(c *gin.Context) {
+	db := database.GetDB()
+	var tags []models.Tag
+
+	query := db.Model(&models.Tag{})
+
+	// Filtrar por eclusa
+	if plcID := c.Query("plc_id"); plcID != "" {
+		query = query.Where("plc_id = ?", plcID)
+	}
+
+	// Filtrar por grupo
+	if groupID := c.Query("group_id"); groupID != "" {
+		query = query.Where("id IN (?)", db.Model(&models.TagGroupMember{}).Select("tag_id").Where("group_id = ?", groupID))
+	}
+
+	// Filtrar ativos/inativos
+	if active := c.Query("is_active"); active != "" {
+		query = query.Where("is_active = ?", active == "true")
+	}
+
+	if err := query.Order("plc_id, \"offset\", name").Find(&tags).Error; err != nil {
+		errorResponse(c, http.StatusInternalServerError, "InternalServerError", "Erro ao buscar tags: "+err.Error(), nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tags":  tags,
+		"total": len(tags),
+	})
+}
+
+// GetTag handles GET /api/tags/:id
+func (ctrl *TagController) GetTag(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var tag models.Tag
+	if err := database.GetDB().First(&tag, id).Error; err != nil {
+		errorResponse(c, http.StatusNotFound, "NotFoundError", "Tag não encontrado", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tag": tag})
+}
+
+// CreateTag handles POST /api/tags
+func (ctrl *TagController) CreateTag(c *gin.Context) {
+	db := database.GetDB()
+
+	var request tagRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "Dados do tag inválidos",
+			gin.H{"errors": err.Error()})
+		return
+	}
+
+	tag := models.Tag{IsActive: true}
+	request.apply(&tag)
+	if !validateTag(c, nil, tag) {
+		return
+	}
+
+	var count int64
+	db.Model(&models.Tag{}).Where("plc_id = ? AND name = ?", tag.PLCID, tag.Name).Count(&count)
+	if count > 0 {
+		errorResponse(c, http.StatusBadRequest, "ConflictError", "Já existe um tag com este nome nesta eclusa", nil)
+		return
+	}
+
+	if !seedLock(c, tag) {
+		return
+	}
+	if err := db.Create(&tag).Error; err != nil {
+		errorResponse(c, http.StatusInternalServerError, "InternalServerError", "Erro ao criar tag: "+err.Error(), nil)
+		return
+	}
+
+	// default:true do GORM ignora o false na criação
+	if !tag.IsActive {
+		db.Model(&tag).Update("is_active", false)
+	}
+
+	if !reloadTags(c) {
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"tag":     tag,
+		"message": "Tag criado com sucesso!",
+	})
+}
+
+// UpdateTag handles PUT /api/tags/:id
+func (ctrl *TagController) UpdateTag(c *gin.Context) {
+	db := database.GetDB()
+
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var tag models.Tag
+	if err := db.First(&tag, id).Error; err != nil {
+		errorResponse(c, http.StatusNotFound, "NotFoundError", "Tag não encontrado", nil)
+		return
+	}
+
+	var request tagRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "Dados do tag inválidos",
+			gin.H{"errors": err.Error()})
+		return
+	}
+
+	previous := tag
+	request.apply(&tag)
+	if !validateTag(c, &previous, tag) {
+		return
+	}
+
+	var count int64
+	db.Model(&models.Tag{}).Where("plc_id = ? AND name = ? AND id <> ?", tag.PLCID, tag.Name, tag.ID).Count(&count)
+	if count > 0 {
+		errorResponse(c, http.StatusBadRequest, "ConflictError", "Já existe um tag com este nome nesta eclusa", nil)
+		return
+	}
+
+	if tag.PLCID != previous.PLCID && !seedLock(c, tag) {
+		return
+	}
+	if err := db.Save(&tag).Error; err != nil {
+		errorResponse(c, http.StatusInternalServerError, "InternalServerError", "Erro ao atualizar tag: "+err.Error(), nil)
+		return
+	}
+
+	if !reloadTags(c) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tag":     tag,
+		"message": "Tag atualizado com sucesso!",
+	})
+}
+
+// DeleteTag handles DELETE /api/tags/:id
+func (ctrl *TagController) DeleteTag(c *gin.Context) {
+	db := database.GetDB()
+
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var tag models.Tag
+	if err := db.First(&tag, id).Error; err != nil {
+		errorResponse(c, http.StatusNotFound, "NotFoundError", "Tag não encontrado", nil)
+		return
+	}
+
+	// Tags com histórico não são apagados para não perder dados; desative-os
+	var historyCount int64
+	db.Model(&models.TagHistory{}).Where("tag_id = ?", tag.ID).Count(&historyCount)
+	if historyCount > 0 {
+		errorResponse(c, http.StatusConflict, "ConflictError",
+			"Tag possui histórico gravado; desative-o com is_active=false", gin.H{"history_rows": historyCount})
+		return
+	}
+	if tag.IsActive && !validateTagRemoval(c, tag) {
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("tag_id = ?", tag.ID).Delete(&models.TagGroupMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&tag).Error
+	})
+	if err != nil {
+		errorResponse(c, http.StatusInternalServerError, "InternalServerError", "Erro ao deletar tag: "+err.Error(), nil)
+		return
+	}
+
+	if !reloadTags(c) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Tag deletado com sucesso!",
+	})
+}
+
+// ListGroups handles GET /api/tags/groups
+func (ctrl *TagController) ListGroups(c *gin.Context) {
+	db := database.GetDB()
+	var groups []models.TagGroup
+
+	if err := db.Order("name").Find(&groups).Error; err != nil {
+		errorResponse(c, http.StatusInternalServerError, "InternalServerError", "Erro ao buscar grupos: "+err.Error(), nil)
+		return
+	}
+
+	// Incluir os IDs dos tags de cada grupo
+	var members []models.TagGroupMember
+	db.Find(&members)
+	tagIDs := make(map[uint][]uint)
+	for _, member := range members {
+		tagIDs[member.GroupID] = append(tagIDs[member.GroupID], member.TagID)
+	}
+
+	response := make([]gin.H, 0, len(groups))
+	for _, group := range groups {
+		ids := tagIDs[group.ID]
+		if ids == nil {
+			ids = []uint{}
+		}
+		response = append(response, gin.H{
+			"group":   group,
+			"tag_ids": ids,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"groups": response,
+	})
+}
+
+// CreateGroup handles POST /api/tags/groups
+func (ctrl *TagController) CreateGroup(c *gin.Context) {
+	db := database.GetDB()
+
+	var request struct {
+		Name        string `json:"name" binding:"required"`
+		Description string `json:"description"`
+		ScanClass   string `json:"scan_class"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "Nome do grupo é obrigatório", nil)
+		return
+	}
+	if !validateScanClass(c, request.ScanClass) {
+		return
+	}
+
+	group := models.TagGroup{
+		Name:        request.Name,
+		Description: request.Description,
+		ScanClass:   request.ScanClass,
+		IsActive:    true,
+	}
+	if err := db.Create(&group).Error; err != nil {
+		errorResponse(c, http.StatusBadRequest, "ConflictError", "Erro ao criar grupo: "+err.Error(), nil)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"group":   group,
+		"message": "Grupo criado com sucesso!",
+	})
+}
+
+// UpdateGroup handles PUT /api/tags/groups/:groupId
+func (ctrl *TagController) UpdateGroup(c *gin.Context) {
+	db := database.GetDB()
+
+	id, ok := parseID(c, "groupId")
+	if !ok {
+		return
+	}
+
+	var group models.TagGroup
+	if err := db.First(&group, id).Error; err != nil {
+		errorResponse(c, http.StatusNotFound, "NotFoundError", "Grupo não encontrado", nil)
+		return
+	}
+
+	var request struct {
+		Name        string  `json:"name"`
+		Description *string `json:"description"`
+		ScanClass   *string `json:"scan_class"`
+		IsActive    *bool   `json:"is_active"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "Dados do grupo inválidos", nil)
+		return
+	}
+	if request.ScanClass != nil {
+		if !validateScanClass(c, *request.ScanClass) {
+			return
+		}
+		group.ScanClass = *request.ScanClass
+	}
+
+	if request.Name != "" {
+		group.Name = request.Name
+	}
+	if request.Description != nil {
+		group.Description = *request.Description
+	}
+	if request.IsActive != nil {
+		group.IsActive = *request.IsActive
+	}
+
+	var memberIDs []uint
+	db.Model(&models.TagGroupMember{}).Where("group_id = ?", group.ID).Pluck("tag_id", &memberIDs)
+	if !validateGroupScanClass(c, group, memberIDs) {
+		return
+	}
+
+	if err := db.Save(&group).Error; err != nil {
+		errorResponse(c, http.StatusBadRequest, "ConflictError", "Erro ao atualizar grupo: "+err.Error(), nil)
+		return
+	}
+	if !reloadTags(c) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"group":   group,
+		"message": "Grupo atualizado com sucesso!",
+	})
+}
+
+// DeleteGroup handles DELETE /api/tags/groups/:groupId
+func (ctrl *TagController) DeleteGroup(c *gin.Context) {
+	db := database.GetDB()
+
+	id, ok := parseID(c, "groupId")
+	if !ok {
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("group_id = ?", id).Delete(&models.TagGroupMember{}).Error; err != nil {
+			return err
+		}
+		result := tx.Delete(&models.TagGroup{}, id)
+		if result.Error == nil && result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return result.Error
+	})
+	if err == gorm.ErrRecordNotFound {
+		errorResponse(c, http.StatusNotFound, "NotFoundError", "Grupo não encontrado", nil)
+		return
+	}
+	if err != nil {
+		errorResponse(c, http.StatusInternalServerError, "InternalServerError", "Erro ao deletar grupo: "+err.Error(), nil)
+		return
+	}
+	if !reloadTags(c) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Grupo deletado com sucesso!",
+	})
+}
+
+// AddGroupMembers handles POST /api/tags/groups/:groupId/members
+func (ctrl *TagController) AddGroupMembers(c *gin.Context) {
+	db := database.GetDB()
+
+	id, ok := parseID(c, "groupId")
+	if !ok {
+		return
+	}
+
+	var group models.TagGroup
+	if err := db.First(&group, id).Error; err != nil {
+		errorResponse(c, http.StatusNotFound, "NotFoundError", "Grupo não encontrado", nil)
+		return
+	}
+
+	var request struct {
+		TagIDs []uint `json:"tag_ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "tag_ids é obrigatório", nil)
+		return
+	}
+
+	var count int64
+	db.Model(&models.Tag{}).Where("id IN ?", request.TagIDs).Count(&count)
+	if int(count) != len(request.TagIDs) {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "Um ou mais tags não existem", nil)
+		return
+	}
+	if !validateGroupScanClass(c, group, request.TagIDs) {
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, tagID := range request.TagIDs {
+			member := models.TagGroupMember{TagID: tagID, GroupID: group.ID}
+			if err := tx.Where(member).FirstOrCreate(&member).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		errorResponse(c, http.StatusInternalServerError, "InternalServerError", "Erro ao adicionar tags ao grupo: "+err.Error(), nil)
+		return
+	}
+	if !reloadTags(c) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"group_id": group.ID,
+		"tag_ids":  request.TagIDs,
+		"message":  "Tags adicionados ao grupo",
+	})
+}
+
+// RemoveGroupMember handles DELETE /api/tags/groups/:groupId/members/:tagId
+func (ctrl *TagController) RemoveGroupMember(c *gin.Context) {
+	groupID, ok := parseID(c, "groupId")
+	if !ok {
+		return
+	}
+	tagID, ok := parseID(c, "tagId")
+	if !ok {
+		return
+	}
+
+	result := database.GetDB().Where("group_id = ? AND tag_id = ?", groupID, tagID).Delete(&models.TagGroupMember{})
+	if result.Error != nil {
+		errorResponse(c, http.StatusInternalServerError, "InternalServerError", "Erro ao remover tag do grupo: "+result.Error.Error(), nil)
+		return
+	}
+	if result.RowsAffected == 0 {
+		errorResponse(c, http.StatusNotFound, "NotFoundError", "Tag não pertence a este grupo", nil)
+		return
+	}
+	if !reloadTags(c) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Tag removido do grupo",
+	})
+}
+
+// ExportTags handles GET /api/tags/export (formato tags.json)
+func (ctrl *TagController) ExportTags(c *gin.Context) {
+	config, err := services.ExportConfig()
+	if err != nil {
+		errorResponse(c, http.StatusInternalServerError, "InternalServerError", "Erro ao exportar tags: "+err.Error(), nil)
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=tags.json")
+	c.IndentedJSON(http.StatusOK, config)
+}
+
+// ImportTags handles POST /api/tags/import (formato tags.json)
+// Com ?replace=true os tags ausentes do arquivo são desativados.
+func (ctrl *TagController) ImportTags(c *gin.Context) {
+	var file services.PLCConfigFile
+	if err := c.ShouldBindJSON(&file); err != nil {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "Arquivo de tags inválido",
+			gin.H{"errors": err.Error()})
+		return
+	}
+
+	connections, err := file.Connections()
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", err.Error(), nil)
+		return
+	}
+
+	for _, conn := range connections {
+		if _, ok := services.GetPLCManager().Connector(conn.ID); !ok {
+			errorResponse(c, http.StatusBadRequest, "ValidationError", "Eclusa não configurada no tags.json",
+				gin.H{"plc_id": conn.ID})
+			return
+		}
+	}
+
+	created, updated, err := services.ImportTags(connections, c.Query("replace") == "true")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "ValidationError", "Erro ao importar tags: "+err.Error(), nil)
+		return
+	}
+
+	if !reloadTags(c) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"created": created,
+		"updated": updated,
+		"message": "Tags importados com sucesso!",
+	})
+}
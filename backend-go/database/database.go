@@ -55,11 +55,36 @@ func runMigrations() error {
 		return err
 	}
 
-	// Migrate Tags (opcional - usando cache em memória)
-	if err := DB.AutoMigrate(&models.Tag{}, &models.TagHistory{}, &models.TagGroup{}, &models.TagGroupMember{}); err != nil {
-		log.Printf("⚠️ Tag migration failed (using memory cache): %v", err)
+	// Migrate Tags (configuração dos tags de cada eclusa)
+	tag := &models.Tag{}
+	if err := tag.Migrate(DB); err != nil {
+		log.Printf("⚠️ Tag migration failed (using tags.json): %v", err)
 	} else {
-		log.Println("✅ Tag tables migrated (backup option)")
+		log.Println("✅ Tag tables migrated")
+	}
+
+	// Migrate Alarms (ocorrências de alarme)
+	alarm := &models.Alarm{}
+	if err := alarm.Migrate(DB); err != nil {
+		return err
+	}
+
+	// Migrate TagEvents (sequência de eventos dos tags digitais)
+	tagEvent := &models.TagEvent{}
+	if err := tagEvent.Migrate(DB); err != nil {
+		return err
+	}
+
+	// Migrate Lockages (ciclos de eclusagem e suas fases)
+	lockage := &models.LockageCycle{}
+	if err := lockage.Migrate(DB); err != nil {
+		return err
+	}
+
+	// Migrate Notifications (fila de envio das notificações)
+	notification := &models.NotificationOutbox{}
+	if err := notification.Migrate(DB); err != nil {
+		return err
 	}
 
 	log.Println("✅ Migrations completed")
@@ -23,9 +23,24 @@ func main() {
 	log.Printf("🚀 Inicializando sistema WebSocket...")
 	services.GetWebSocketHub() // Inicializar WebSocket hub
 	
-	// Initialize S7 PLC Connection
-	log.Printf("🔌 Inicializando conexão S7 PLC...")
-	services.GetS7PLCConnector() // Inicializar conexão S7 PLC
+	// Initialize S7 PLC Connections (uma por eclusa)
+	log.Printf("🔌 Inicializando conexões S7 PLC...")
+	services.GetPLCManager() // Inicializar conexões S7 PLC
+
+	// Initialize tag history recorder
+	services.GetHistoryRecorder()
+
+	// Initialize sequence-of-events recorder
+	services.GetEventRecorder()
+
+	// Initialize lockage cycle detection
+	services.GetLockageEngine()
+
+	// Initialize notifications (SMTP / webhooks dos alarmes)
+	services.GetNotifier()
+
+	// Initialize alarm engine
+	services.GetAlarmEngine()
 
 	// Setup routes
 	r := routes.SetupRoutes()
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
@@ -10,6 +11,43 @@ import (
 	"backend-go/models"
 )
 
+// Chave de assinatura dos tokens JWT
+var jwtSecret = []byte("lb8ETlEcHM49QQuDY2iKCQ==")
+
+// UserFromToken valida um token JWT e carrega o usuário com o seu role
+// (para conexões que não passam pelo AuthMiddleware, como o WebSocket)
+func UserFromToken(tokenString string) (models.User, error) {
+	var user models.User
+
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return jwtSecret, nil
+	})
+	if err != nil || !token.Valid {
+		return user, errors.New("token inválido ou expirado")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return user, errors.New("claims do token inválidos")
+	}
+	userID, ok := claims["id"].(float64)
+	if !ok {
+		return user, errors.New("claims do token inválidos")
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		return user, errors.New("banco de dados indisponível")
+	}
+	if err := db.Preload("Role").First(&user, uint(userID)).Error; err != nil {
+		return user, errors.New("usuário não encontrado")
+	}
+	if user.Blocked {
+		return user, errors.New("usuário bloqueado")
+	}
+	return user, nil
+}
+
 // AuthMiddleware verifica se o usuário está autenticado
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -42,7 +80,7 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return []byte("lb8ETlEcHM49QQuDY2iKCQ=="), nil
+			return jwtSecret, nil
 		})
 
 		if err != nil || !token.Valid {
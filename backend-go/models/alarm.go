@@ -0,0 +1,86 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Estados do ciclo de vida de um alarme
+const (
+	AlarmActiveUnacked  = "active-unacked"  // condição ativa, não reconhecido
+	AlarmActiveAcked    = "active-acked"    // condição ativa, reconhecido
+	AlarmClearedUnacked = "cleared-unacked" // condição normalizada, falta reconhecer
+	AlarmNormal         = "normal"          // encerrado (normalizado e reconhecido)
+)
+
+// Alarm é uma ocorrência de alarme. Key identifica a regra ("<eclusa>/<regra>") e é a mesma
+// em todas as ocorrências; só uma ocorrência por Key fica aberta (estado diferente de normal).
+type Alarm struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	Key         string     `json:"key" gorm:"index;not null"`
+	PLCID       string     `json:"plc_id" gorm:"index;not null"`
+	TagName     string     `json:"tag_name" gorm:"index"`
+	RuleID      string     `json:"rule_id"`
+	Type        string     `json:"type"`     // hi, hihi, lo, lolo, bit, roc, deviation
+	Priority    string     `json:"priority"` // low, medium, high, critical
+	Message     string     `json:"message"`
+	State       string     `json:"state" gorm:"index"`
+	Value       float64    `json:"value"` // valor que disparou o alarme
+	Limit       float64    `json:"limit"`
+	ActivatedAt time.Time  `json:"activated_at" gorm:"index"`
+	ClearedAt   *time.Time `json:"cleared_at"`
+	AckedAt     *time.Time `json:"acked_at"`
+	AckedBy     string     `json:"acked_by"`
+	Shelved     bool       `json:"shelved"`    // regra em manutenção: fora da lista principal
+	Suppressed  bool       `json:"suppressed"` // suprimido pela condição suppress_when da regra
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// Ações registradas na auditoria de alarmes
+const (
+	AlarmActionAck      = "ack"      // reconhecimento
+	AlarmActionComment  = "comment"  // comentário do operador
+	AlarmActionShelve   = "shelve"   // regra retirada da lista por um tempo
+	AlarmActionUnshelve = "unshelve" // regra devolvida à lista (manual ou por expiração)
+)
+
+// AlarmAudit registra quem fez o quê com uma ocorrência de alarme e quando
+type AlarmAudit struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	AlarmID   uint      `json:"alarm_id" gorm:"index"`
+	AlarmKey  string    `json:"alarm_key" gorm:"index;not null"`
+	PLCID     string    `json:"plc_id" gorm:"index;not null"`
+	Action    string    `json:"action"`
+	Username  string    `json:"username"`
+	Comment   string    `json:"comment" gorm:"type:text"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
+
+// AlarmShelve retira uma regra de alarme da lista principal até ExpiresAt.
+// Fica aberta enquanto UnshelvedAt é nulo.
+type AlarmShelve struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	Key         string     `json:"key" gorm:"index;not null"`
+	PLCID       string     `json:"plc_id" gorm:"index;not null"`
+	TagName     string     `json:"tag_name"`
+	RuleID      string     `json:"rule_id"`
+	GroupID     *uint      `json:"group_id"` // grupo de tags pelo qual a regra foi retirada
+	Reason      string     `json:"reason" gorm:"type:text"`
+	ShelvedBy   string     `json:"shelved_by"`
+	ShelvedAt   time.Time  `json:"shelved_at"`
+	ExpiresAt   time.Time  `json:"expires_at" gorm:"index"`
+	UnshelvedAt *time.Time `json:"unshelved_at" gorm:"index"`
+	UnshelvedBy string     `json:"unshelved_by"` // vazio quando expirou
+}
+
+// IsOpen indica se a ocorrência ainda aparece na lista de alarmes
+func (a *Alarm) IsOpen() bool {
+	return a.State != AlarmNormal
+}
+
+// Migrate cria as tabelas de alarmes, da auditoria e das retiradas de manutenção
+func (a *Alarm) Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Alarm{}, &AlarmAudit{}, &AlarmShelve{})
+}
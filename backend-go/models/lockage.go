@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Sentido da eclusagem: "up" entra pela porta de jusante e sai a montante
+const (
+	LockageUp   = "up"
+	LockageDown = "down"
+)
+
+// Situação de um ciclo de eclusagem
+const (
+	LockageInProgress = "in-progress"
+	LockageCompleted  = "completed"
+	LockageAborted    = "aborted" // passou de max_cycle_minutes sem sair pela outra porta
+)
+
+// LockageCycle é uma eclusagem completa (ou interrompida) de uma eclusa.
+// Os níveis são os da câmara, de montante e de jusante no início e no fim do ciclo.
+type LockageCycle struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	PLCID     string     `json:"plc_id" gorm:"index:idx_lockage_cycles_key,priority:1;not null"`
+	Direction string     `json:"direction" gorm:"index"` // up, down ou vazio (não chegou a entrar)
+	Status    string     `json:"status" gorm:"index"`
+	StartedAt time.Time  `json:"started_at" gorm:"index:idx_lockage_cycles_key,priority:2"`
+	EndedAt   *time.Time `json:"ended_at"`
+	DurationS float64    `json:"duration_s"`
+
+	StartChamberLevel    float64 `json:"start_chamber_level"`
+	StartUpstreamLevel   float64 `json:"start_upstream_level"`
+	StartDownstreamLevel float64 `json:"start_downstream_level"`
+	EndChamberLevel      float64 `json:"end_chamber_level"`
+	EndUpstreamLevel     float64 `json:"end_upstream_level"`
+	EndDownstreamLevel   float64 `json:"end_downstream_level"`
+
+	Phases    []LockagePhase `json:"phases,omitempty" gorm:"foreignKey:CycleID;constraint:OnDelete:CASCADE"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// LockagePhase é uma fase do ciclo, com os níveis na transição de entrada e de saída
+type LockagePhase struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	CycleID   uint       `json:"cycle_id" gorm:"index"`
+	Seq       int        `json:"seq"`
+	Name      string     `json:"name"` // preparation, entry_door_opening, entry, ..., filling/emptying, ..., exit_door_closing
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at"`
+	DurationS float64    `json:"duration_s"`
+
+	StartChamberLevel    float64 `json:"start_chamber_level"`
+	StartUpstreamLevel   float64 `json:"start_upstream_level"`
+	StartDownstreamLevel float64 `json:"start_downstream_level"`
+	EndChamberLevel      float64 `json:"end_chamber_level"`
+	EndUpstreamLevel     float64 `json:"end_upstream_level"`
+	EndDownstreamLevel   float64 `json:"end_downstream_level"`
+}
+
+// Migrate cria as tabelas dos ciclos de eclusagem
+func (l *LockageCycle) Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&LockageCycle{}, &LockagePhase{})
+}
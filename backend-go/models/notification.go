@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Estados de uma notificação na fila de envio
+const (
+	NotificationPending = "pending" // aguardando envio (ou nova tentativa)
+	NotificationSent    = "sent"
+	NotificationFailed  = "failed" // esgotou as tentativas
+)
+
+// NotificationOutbox é uma notificação a enviar por um canal. O conteúdo é montado ao
+// entrar na fila, então as novas tentativas enviam exatamente a mesma mensagem.
+type NotificationOutbox struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	Channel       string     `json:"channel" gorm:"index;not null"` // nome do canal em notifications.json
+	Route         string     `json:"route"`
+	Event         string     `json:"event"` // activated, cleared, acked
+	AlarmKey      string     `json:"alarm_key" gorm:"index"`
+	PLCID         string     `json:"plc_id" gorm:"index"`
+	Recipients    string     `json:"recipients" gorm:"type:text"` // e-mails separados por vírgula
+	Subject       string     `json:"subject"`
+	Body          string     `json:"body" gorm:"type:text"`
+	Status        string     `json:"status" gorm:"index;not null"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt time.Time  `json:"next_attempt_at" gorm:"index"`
+	LastError     string     `json:"last_error" gorm:"type:text"`
+	SentAt        *time.Time `json:"sent_at"`
+	CreatedAt     time.Time  `json:"created_at" gorm:"index"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// Migrate cria a tabela da fila de notificações
+func (n *NotificationOutbox) Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&NotificationOutbox{})
+}
@@ -2,54 +2,135 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // Tag representa um tag do sistema de automação
 type Tag struct {
-	ID          uint      `json:"id" gorm:"primaryKey"`
-	Name        string    `json:"name" gorm:"uniqueIndex;not null"`
-	Value       float64   `json:"value"`
-	Type        string    `json:"type"`        // "real", "bool", "int", "string"
-	Offset      float64   `json:"offset"`      // Offset no PLC
-	Description string    `json:"description"`
-	Unit        string    `json:"unit"`        // "°C", "%", "bar", etc.
-	MinValue    *float64  `json:"min_value"`   // Valor mínimo permitido
-	MaxValue    *float64  `json:"max_value"`   // Valor máximo permitido
-	IsActive    bool      `json:"is_active" gorm:"default:true"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	PLCID        string    `json:"plc_id" gorm:"uniqueIndex:idx_tag_plc_name;not null;default:''"` // ID da eclusa (tags.json "plcs[].id")
+	Name         string    `json:"name" gorm:"uniqueIndex:idx_tag_plc_name;not null"`
+	Value        float64   `json:"value"`
+	Type         string    `json:"type"`    // "real", "bool", "int", "dint", "lreal", "string[n]", "dtl"...
+	Offset       float64   `json:"offset"`  // Offset no PLC
+	Address      string    `json:"address"` // Endereço S7 (DB19.DBX52.3, I0.1, MW10...); vazio = offset no DB da eclusa
+	Length       int       `json:"length"`  // Comprimento de string/wstring
+	Description  string    `json:"description"`
+	Unit         string    `json:"unit"`           // "°C", "%", "bar", etc.
+	MinValue     *float64  `json:"min_value"`      // Valor mínimo permitido
+	MaxValue     *float64  `json:"max_value"`      // Valor máximo permitido
+	Scale        *float64  `json:"scale"`          // Multiplicador do valor bruto
+	WordOrder    string    `json:"word_order"`     // Modbus: "big" ou "little" (vazio = plc_config)
+	RawMin       *float64  `json:"raw_min"`        // Valor bruto (após scale) que corresponde a eu_min
+	RawMax       *float64  `json:"raw_max"`        // Valor bruto que corresponde a eu_max
+	EUMin        *float64  `json:"eu_min"`         // Valor de engenharia em raw_min
+	EUMax        *float64  `json:"eu_max"`         // Valor de engenharia em raw_max
+	EUOffset     float64   `json:"eu_offset"`      // Somado ao valor de engenharia (ex: cota de referência)
+	Clamp        string    `json:"clamp"`          // "none" ou "limit" (satura em eu_min..eu_max)
+	Deadband     float64   `json:"deadband"`       // Banda morta para publicação
+	DeadbandType string    `json:"deadband_type"`  // "absolute" ou "percent"
+	MinPublishMs int       `json:"min_publish_ms"` // Intervalo mínimo entre publicações
+	ScanClass    string    `json:"scan_class"`     // Classe de varredura; vazio = do grupo
+	Expression   string    `json:"expression"`     // Tag calculado: expressão sobre outros tags
+	IsActive     bool      `json:"is_active" gorm:"default:true"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+
+	History              bool    `json:"history"`                // Grava o tag em tag_histories
+	HistoryDeadband      float64 `json:"history_deadband"`       // Banda morta do histórico (mesmo deadband_type)
+	HistoryMaxIntervalS  int     `json:"history_max_interval_s"` // Grava mesmo sem mudança após este intervalo
+	HistoryRawDays       int     `json:"history_raw_days"`       // Dias de dados brutos antes de agregar por hora
+	HistoryRetentionDays int     `json:"history_retention_days"` // Dias até apagar o histórico do tag
+
+	Alarms string `json:"alarms" gorm:"type:text"` // Regras de alarme como string JSON
+
+	Events *bool  `json:"events"`                  // Sequência de eventos (nulo = padrão do tipo)
+	States string `json:"states" gorm:"type:text"` // Rótulos dos valores como string JSON
+}
+
+// ManagedLock marca uma eclusa cujos tags são mantidos na tabela tags. A eclusa
+// marcada usa só os tags ativos do banco, mesmo sem nenhum: o tags.json não volta.
+type ManagedLock struct {
+	PLCID     string    `json:"plc_id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Migrate cria as tabelas de tags. O índice único antigo só por nome é trocado
+// pelo índice (plc_id, name), pois cada eclusa tem o seu próprio espaço de nomes.
+func (t *Tag) Migrate(db *gorm.DB) error {
+	if err := db.AutoMigrate(&Tag{}, &TagHistory{}, &TagHistoryAggregate{}, &TagGroup{}, &TagGroupMember{}, &ManagedLock{}); err != nil {
+		return err
+	}
+
+	// Eclusas que já têm tags no banco passam a ser mantidas pelo banco
+	err := db.Exec("INSERT INTO managed_locks (plc_id, created_at) SELECT DISTINCT plc_id, NOW() FROM tags ON CONFLICT DO NOTHING").Error
+	if err != nil {
+		return err
+	}
+
+	if db.Migrator().HasIndex(&Tag{}, "idx_tags_name") {
+		return db.Migrator().DropIndex(&Tag{}, "idx_tags_name")
+	}
+	return nil
 }
 
-// TagHistory para histórico de valores (opcional)
+// TagHistory guarda as leituras brutas dos tags com histórico ativo.
+// Tags do tags.json não têm registro na tabela tags; por isso a chave é (plc_id, tag_name).
 type TagHistory struct {
 	ID        uint      `json:"id" gorm:"primaryKey"`
-	TagID     uint      `json:"tag_id" gorm:"index"`
-	Tag       Tag       `json:"tag" gorm:"foreignKey:TagID"`
-	Value     float64   `json:"value"`
-	Timestamp time.Time `json:"timestamp"`
+	TagID     *uint     `json:"tag_id" gorm:"index"` // Tag no banco, quando existe
+	Tag       *Tag      `json:"tag,omitempty" gorm:"foreignKey:TagID"`
+	PLCID     string    `json:"plc_id" gorm:"index:idx_tag_history_key,priority:1;not null;default:''"`
+	TagName   string    `json:"tag_name" gorm:"index:idx_tag_history_key,priority:2;not null;default:''"`
+	Value     float64   `json:"value"` // bool gravado como 0/1
+	Quality   string    `json:"quality"`
+	Timestamp time.Time `json:"timestamp" gorm:"index:idx_tag_history_key,priority:3"`
+}
+
+// Resoluções de TagHistoryAggregate
+const (
+	HistoryResolutionHour = "hour"
+	HistoryResolutionDay  = "day"
+)
+
+// TagHistoryAggregate resume os dados brutos antigos de um tag por hora ou por dia
+type TagHistoryAggregate struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	PLCID       string    `json:"plc_id" gorm:"uniqueIndex:idx_tag_history_aggregate,priority:1;not null"`
+	TagName     string    `json:"tag_name" gorm:"uniqueIndex:idx_tag_history_aggregate,priority:2;not null"`
+	Resolution  string    `json:"resolution" gorm:"uniqueIndex:idx_tag_history_aggregate,priority:3;not null"` // "hour" ou "day"
+	BucketStart time.Time `json:"bucket_start" gorm:"uniqueIndex:idx_tag_history_aggregate,priority:4"`
+	Min         float64   `json:"min"`
+	Max         float64   `json:"max"`
+	Avg         float64   `json:"avg"`
+	First       float64   `json:"first"`
+	Last        float64   `json:"last"`
+	Count       int64     `json:"count"`
 }
 
 // TagGroup para agrupar tags relacionados
 type TagGroup struct {
-	ID          uint   `json:"id" gorm:"primaryKey"`
-	Name        string `json:"name" gorm:"uniqueIndex;not null"`
-	Description string `json:"description"`
-	IsActive    bool   `json:"is_active" gorm:"default:true"`
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Name        string    `json:"name" gorm:"uniqueIndex;not null"`
+	Description string    `json:"description"`
+	ScanClass   string    `json:"scan_class"` // Classe de varredura herdada pelos tags sem classe própria
+	IsActive    bool      `json:"is_active" gorm:"default:true"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
 // TagGroupMember para relacionamento many-to-many
 type TagGroupMember struct {
-	TagID   uint `json:"tag_id" gorm:"primaryKey"`
-	GroupID uint `json:"group_id" gorm:"primaryKey"`
-	Tag     Tag  `json:"tag" gorm:"foreignKey:TagID"`
+	TagID   uint     `json:"tag_id" gorm:"primaryKey"`
+	GroupID uint     `json:"group_id" gorm:"primaryKey"`
+	Tag     Tag      `json:"tag" gorm:"foreignKey:TagID"`
 	Group   TagGroup `json:"group" gorm:"foreignKey:GroupID"`
 }
 
 // WebSocketMessage estrutura para mensagens WebSocket
 type WebSocketMessage struct {
 	Type      string                 `json:"type"`
-	Timestamp time.Time             `json:"timestamp"`
+	Timestamp time.Time              `json:"timestamp"`
 	Data      map[string]interface{} `json:"data"`
-}
\ No newline at end of file
+}
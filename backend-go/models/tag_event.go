@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TagEvent é uma transição de um tag digital ou de estado (sequência de eventos).
+// A ordem exata é (timestamp, id): eventos da mesma leitura têm o mesmo timestamp e são
+// gravados na ordem em que foram detectados.
+type TagEvent struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	PLCID     string    `json:"plc_id" gorm:"index:idx_tag_events_key,priority:1;not null"`
+	TagName   string    `json:"tag_name" gorm:"index:idx_tag_events_key,priority:2;not null"`
+	OldValue  float64   `json:"old_value"` // bool gravado como 0/1
+	NewValue  float64   `json:"new_value"`
+	OldText   string    `json:"old_text"` // rótulo de states, ou o próprio valor
+	NewText   string    `json:"new_text"`
+	Quality   string    `json:"quality"`
+	Timestamp time.Time `json:"timestamp" gorm:"index:idx_tag_events_key,priority:3;index"`
+}
+
+// Migrate cria a tabela de eventos
+func (e *TagEvent) Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&TagEvent{})
+}
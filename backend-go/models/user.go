@@ -91,19 +91,19 @@ func SeedRoles(db *gorm.DB) error {
 		},
 		{
 			Name: "gerente", DisplayName: "Gerente", Description: "Gerenciamento operacional e supervisão",
-			Type: "gerente", Level: 80, Permissions: []string{"users.view", "users.manage", "reports.view", "system.monitor", "eclusa.control"},
+			Type: "gerente", Level: 80, Permissions: []string{"users.view", "users.manage", "reports.view", "system.monitor", "eclusa.control", "tags.manage", "alarms.ack"},
 		},
 		{
 			Name: "supervisor", DisplayName: "Supervisor", Description: "Supervisão de operações e equipe",
-			Type: "supervisor", Level: 70, Permissions: []string{"users.view", "users.manage", "reports.view", "eclusa.control", "maintenance.schedule"},
+			Type: "supervisor", Level: 70, Permissions: []string{"users.view", "users.manage", "reports.view", "eclusa.control", "maintenance.schedule", "tags.manage", "alarms.ack"},
 		},
 		{
 			Name: "tecnico", DisplayName: "Técnico", Description: "Suporte técnico e manutenção especializada",
-			Type: "tecnico", Level: 60, Permissions: []string{"maintenance.all", "diagnostics.run", "system.debug", "eclusa.maintenance"},
+			Type: "tecnico", Level: 60, Permissions: []string{"maintenance.all", "diagnostics.run", "system.debug", "eclusa.maintenance", "tags.manage", "alarms.ack"},
 		},
 		{
 			Name: "operador", DisplayName: "Operador", Description: "Operação diária do sistema e eclusa",
-			Type: "operador", Level: 50, Permissions: []string{"eclusa.operate", "reports.basic", "system.monitor"},
+			Type: "operador", Level: 50, Permissions: []string{"eclusa.operate", "reports.basic", "system.monitor", "alarms.ack"},
 		},
 		{
 			Name: "visitante", DisplayName: "Visitante", Description: "Acesso limitado apenas para visualização",
@@ -7,6 +7,7 @@ import (
 	"backend-go/middleware"
 	"backend-go/services"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -100,15 +101,101 @@ func SetupRoutes() *gin.Engine {
 	// WebSocket route
 	hub := services.GetWebSocketHub()
 	r.GET("/ws", func(c *gin.Context) {
-		hub.HandleWebSocket(c.Writer, c.Request)
+		// Token opcional (?token= ou Authorization): sem ele a conexão é só de leitura
+		token := c.Query("token")
+		if token == "" {
+			token = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		}
+		hub.HandleWebSocket(c.Writer, c.Request, token, middleware.UserFromToken)
 	})
 
-	// S7 PLC Status route
-	r.GET("/api/plc/status", func(c *gin.Context) {
-		s7plc := services.GetS7PLCConnector()
-		status := s7plc.GetStatus()
-		c.JSON(200, status)
-	})
+	// S7 PLC routes (indexado pelo ID da eclusa)
+	plcController := &controllers.PLCController{}
+	plcAPI := api.Group("/plc")
+	{
+		plcAPI.GET("/status", plcController.GetStatus)
+		plcAPI.GET("/:lockId/status", plcController.GetLockStatus)
+		plcAPI.GET("/:lockId/tags", plcController.ReadTags)
+		plcAPI.GET("/:lockId/tags/:tag", plcController.ReadTag)
+
+		// Histórico dos tags - requer permissão de relatórios
+		historyController := &controllers.HistoryController{}
+		plcAPI.GET("/:lockId/history", middleware.AuthMiddleware(), middleware.RequirePermission("reports.view"), historyController.GetHistory)
+		plcAPI.GET("/:lockId/history/export", middleware.AuthMiddleware(), middleware.RequirePermission("reports.view"), historyController.ExportHistory)
+
+		// Sequência de eventos dos tags digitais - requer permissão de relatórios
+		eventController := &controllers.EventController{}
+		plcAPI.GET("/:lockId/events", middleware.AuthMiddleware(), middleware.RequirePermission("reports.view"), eventController.GetEvents)
+		plcAPI.GET("/:lockId/events/export", middleware.AuthMiddleware(), middleware.RequirePermission("reports.view"), eventController.ExportEvents)
+
+		// Ciclos de eclusagem - requer permissão de relatórios
+		lockageController := &controllers.LockageController{}
+		plcAPI.GET("/:lockId/lockages", middleware.AuthMiddleware(), middleware.RequirePermission("reports.view"), lockageController.GetLockages)
+		plcAPI.GET("/:lockId/lockages/:cycleId", middleware.AuthMiddleware(), middleware.RequirePermission("reports.view"), lockageController.GetLockage)
+
+		// Alarmes: os abertos são públicos como os valores; o histórico requer relatórios
+		alarmController := &controllers.AlarmController{}
+		plcAPI.GET("/:lockId/alarms", alarmController.GetActiveAlarms)
+		plcAPI.GET("/:lockId/alarms/history", middleware.AuthMiddleware(), middleware.RequirePermission("reports.view"), alarmController.GetAlarmHistory)
+		plcAPI.GET("/:lockId/alarms/history/export", middleware.AuthMiddleware(), middleware.RequirePermission("reports.view"), alarmController.ExportAlarmHistory)
+		plcAPI.GET("/:lockId/alarms/audit", middleware.AuthMiddleware(), middleware.RequirePermission("reports.view"), alarmController.GetAlarmAudit)
+		plcAPI.POST("/:lockId/alarms/ack", middleware.AuthMiddleware(), middleware.RequirePermission("alarms.ack"), alarmController.AckAllAlarms)
+		plcAPI.POST("/:lockId/alarms/:ruleId/ack", middleware.AuthMiddleware(), middleware.RequirePermission("alarms.ack"), alarmController.AckAlarm)
+		plcAPI.POST("/:lockId/alarms/:ruleId/comments", middleware.AuthMiddleware(), middleware.RequirePermission("alarms.ack"), alarmController.CommentAlarm)
+
+		// Retirada de alarmes para manutenção - requer permissão de manutenção
+		plcAPI.GET("/:lockId/alarms/shelved", alarmController.GetShelvedAlarms)
+		plcAPI.POST("/:lockId/alarms/shelve", middleware.AuthMiddleware(), middleware.RequirePermission("eclusa.maintenance"), alarmController.ShelveAlarms)
+		plcAPI.POST("/:lockId/alarms/unshelve", middleware.AuthMiddleware(), middleware.RequirePermission("eclusa.maintenance"), alarmController.UnshelveAlarms)
+
+		// Comandos para o PLC - requer permissão de operar a eclusa
+		plcAPI.POST("/:lockId/write", middleware.AuthMiddleware(), middleware.RequirePermission("eclusa.operate"), plcController.WriteTag)
+
+		// Recarregar tags.json sem reiniciar - apenas admin
+		plcAPI.POST("/config/reload", middleware.AuthMiddleware(), middleware.RequireLevel(100), plcController.ReloadConfig)
+
+		// Simulador (eclusas com driver "simulator") - apenas admin
+		simulatorController := &controllers.SimulatorController{}
+		simulator := plcAPI.Group("/:lockId/simulator", middleware.AuthMiddleware(), middleware.RequireLevel(100))
+		{
+			simulator.GET("/memory", simulatorController.GetMemory)
+			simulator.PUT("/memory", simulatorController.SetMemory)
+			simulator.POST("/tags", simulatorController.SetTag)
+			simulator.PUT("/online", simulatorController.SetOnline)
+			simulator.POST("/scenario", simulatorController.StartScenario)
+			simulator.DELETE("/scenario", simulatorController.StopScenario)
+		}
+	}
+
+	// Tag configuration routes (tabela tags) - requer permissão de técnico
+	tagController := &controllers.TagController{}
+	tagsAPI := api.Group("/tags", middleware.AuthMiddleware(), middleware.RequirePermission("tags.manage"))
+	{
+		tagsAPI.GET("", tagController.ListTags)
+		tagsAPI.POST("", tagController.CreateTag)
+		tagsAPI.GET("/export", tagController.ExportTags)
+		tagsAPI.POST("/import", tagController.ImportTags)
+		tagsAPI.GET("/:id", tagController.GetTag)
+		tagsAPI.PUT("/:id", tagController.UpdateTag)
+		tagsAPI.DELETE("/:id", tagController.DeleteTag)
+
+		// Grupos de tags
+		tagsAPI.GET("/groups", tagController.ListGroups)
+		tagsAPI.POST("/groups", tagController.CreateGroup)
+		tagsAPI.PUT("/groups/:groupId", tagController.UpdateGroup)
+		tagsAPI.DELETE("/groups/:groupId", tagController.DeleteGroup)
+		tagsAPI.POST("/groups/:groupId/members", tagController.AddGroupMembers)
+		tagsAPI.DELETE("/groups/:groupId/members/:tagId", tagController.RemoveGroupMember)
+	}
+
+	// Notificações de alarmes (SMTP / webhooks) - apenas admin
+	notificationController := &controllers.NotificationController{}
+	notificationsAPI := api.Group("/notifications", middleware.AuthMiddleware(), middleware.RequireLevel(100))
+	{
+		notificationsAPI.GET("/outbox", notificationController.GetOutbox)
+		notificationsAPI.POST("/test", notificationController.SendTest)
+		notificationsAPI.POST("/reload", notificationController.ReloadConfig)
+	}
 
 	// ✅ DATABASE MONITOR ROUTES - FOCO APENAS NO BANCO DE DADOS
 	databaseMonitorController := &controllers.DatabaseMonitorController{}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"backend-go/models"
+)
+
+// Reconhecimento e comentários dos alarmes. Cada ação fica registrada em alarm_audits
+// com o usuário e a hora e é enviada a todos os clientes do WebSocket.
+
+var (
+	ErrAlarmNotFound     = errors.New("alarme não encontrado entre os abertos")
+	ErrAlarmAlreadyAcked = errors.New("alarme já reconhecido")
+	ErrEmptyAlarmComment = errors.New("comentário vazio")
+)
+
+// Tamanho máximo de um comentário, em caracteres
+const alarmCommentMaxLength = 2000
+
+// cleanAlarmComment tira os espaços das pontas e corta o comentário no limite sem
+// partir caracteres acentuados (bytes UTF-8 inválidos são recusados pelo banco)
+func cleanAlarmComment(comment string) string {
+	comment = strings.TrimSpace(strings.ToValidUTF8(comment, ""))
+	if runes := []rune(comment); len(runes) > alarmCommentMaxLength {
+		comment = strings.TrimSpace(string(runes[:alarmCommentMaxLength]))
+	}
+	return comment
+}
+
+// audit cria o registro de auditoria e enfileira a gravação antes da do próprio alarme,
+// enquanto a ocorrência ainda está aberta. Chamado com e.mutex.
+func (e *AlarmEngine) audit(alarm *models.Alarm, action string, username string, comment string, now time.Time) models.AlarmAudit {
+	entry := &models.AlarmAudit{
+		AlarmID:   alarm.ID,
+		AlarmKey:  alarm.Key,
+		PLCID:     alarm.PLCID,
+		Action:    action,
+		Username:  username,
+		Comment:   comment,
+		CreatedAt: now,
+	}
+	e.queueAudit(alarm, entry)
+	return *entry
+}
+
+// queueAudit enfileira a gravação de um registro de auditoria; alarm é a ocorrência
+// aberta da regra, se houver. Chamado com e.mutex.
+func (e *AlarmEngine) queueAudit(alarm *models.Alarm, entry *models.AlarmAudit) {
+	if e.saves == nil {
+		return
+	}
+	select {
+	case e.saves <- alarmSave{alarm: alarm, audit: entry}:
+	default:
+		log.Printf("⚠️ Fila de gravação de alarmes cheia: auditoria de %s não gravada", entry.AlarmKey)
+	}
+}
+
+// acknowledge reconhece uma ocorrência aberta. Chamado com e.mutex.
+func (e *AlarmEngine) acknowledge(alarm *models.Alarm, username string, comment string, now time.Time) (models.AlarmAudit, error) {
+	switch alarm.State {
+	case models.AlarmActiveUnacked:
+		alarm.State = models.AlarmActiveAcked
+	case models.AlarmClearedUnacked:
+		alarm.State = models.AlarmNormal
+	default:
+		return models.AlarmAudit{}, ErrAlarmAlreadyAcked
+	}
+
+	ackedAt := now
+	alarm.AckedAt = &ackedAt
+	alarm.AckedBy = username
+	alarm.UpdatedAt = now
+
+	entry := e.audit(alarm, models.AlarmActionAck, username, comment, now)
+	e.persist(alarm)
+	GetNotifier().AlarmEvent(*alarm, NotifyAcked)
+	if alarm.State == models.AlarmNormal {
+		delete(e.open, alarm.Key)
+	}
+
+	log.Printf("👍 [%s] Alarme %s reconhecido por %s", alarm.PLCID, alarm.RuleID, username)
+	return entry, nil
+}
+
+// Acknowledge reconhece o alarme aberto de uma regra, com um comentário opcional
+func (e *AlarmEngine) Acknowledge(lockID string, ruleID string, username string, comment string) (models.Alarm, error) {
+	comment = cleanAlarmComment(comment)
+
+	e.mutex.Lock()
+	alarm := e.open[alarmKey(lockID, ruleID)]
+	if alarm == nil {
+		e.mutex.Unlock()
+		return models.Alarm{}, ErrAlarmNotFound
+	}
+	entry, err := e.acknowledge(alarm, username, comment, time.Now())
+	acked := *alarm
+	e.mutex.Unlock()
+
+	if err != nil {
+		return acked, err
+	}
+	broadcastAlarms([]models.Alarm{acked})
+	broadcastAlarmAudits([]models.AlarmAudit{entry})
+	return acked, nil
+}
+
+// AcknowledgeAll reconhece todos os alarmes não reconhecidos da lista principal da eclusa
+func (e *AlarmEngine) AcknowledgeAll(lockID string, username string, comment string) []models.Alarm {
+	comment = cleanAlarmComment(comment)
+	now := time.Now()
+
+	var acked []models.Alarm
+	var entries []models.AlarmAudit
+	e.mutex.Lock()
+	for _, alarm := range e.open {
+		if alarm.PLCID != lockID || alarm.Shelved || alarm.Suppressed {
+			continue
+		}
+		if entry, err := e.acknowledge(alarm, username, comment, now); err == nil {
+			acked = append(acked, *alarm)
+			entries = append(entries, entry)
+		}
+	}
+	e.mutex.Unlock()
+
+	broadcastAlarms(acked)
+	broadcastAlarmAudits(entries)
+	return acked
+}
+
+// Comment anexa um comentário do operador ao alarme aberto de uma regra
+func (e *AlarmEngine) Comment(lockID string, ruleID string, username string, comment string) (models.AlarmAudit, error) {
+	comment = cleanAlarmComment(comment)
+	if comment == "" {
+		return models.AlarmAudit{}, ErrEmptyAlarmComment
+	}
+
+	e.mutex.Lock()
+	alarm := e.open[alarmKey(lockID, ruleID)]
+	if alarm == nil {
+		e.mutex.Unlock()
+		return models.AlarmAudit{}, ErrAlarmNotFound
+	}
+	entry := e.audit(alarm, models.AlarmActionComment, username, comment, time.Now())
+	e.mutex.Unlock()
+
+	broadcastAlarmAudits([]models.AlarmAudit{entry})
+	return entry, nil
+}
+
+// broadcastAlarmAudits envia as ações dos operadores aos clientes do WebSocket
+func broadcastAlarmAudits(entries []models.AlarmAudit) {
+	hub := GetWebSocketHub()
+	for _, entry := range entries {
+		hub.BroadcastMessage(map[string]interface{}{
+			"type":    "alarm_audit",
+			"lock_id": entry.PLCID,
+			"audit":   entry,
+		})
+	}
+}
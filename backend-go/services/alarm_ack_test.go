@@ -0,0 +1,21 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestCleanAlarmComment(t *testing.T) {
+	long := strings.Repeat("ã", alarmCommentMaxLength+10)
+	got := cleanAlarmComment(long)
+	if !utf8.ValidString(got) {
+		t.Fatalf("comentário cortado no meio de um caractere")
+	}
+	if n := utf8.RuneCountInString(got); n != alarmCommentMaxLength {
+		t.Errorf("obtido %d caracteres, esperado %d", n, alarmCommentMaxLength)
+	}
+	if got := cleanAlarmComment("  válvula travada \n"); got != "válvula travada" {
+		t.Errorf("obtido %q", got)
+	}
+}
@@ -0,0 +1,482 @@
+package services
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"backend-go/models"
+)
+
+// Motor de alarmes: a cada varredura avalia as regras "alarms" dos tags da classe lida,
+// aplica atrasos e histerese e conduz cada alarme pelo ciclo de vida
+// active-unacked → active-acked / cleared-unacked → normal. Os alarmes abertos ficam na
+// memória, são gravados em segundo plano na tabela alarms e enviados pelo WebSocket.
+
+// Alterações de alarme aguardando gravação (banco lento ou fora do ar)
+const alarmSaveQueue = 1000
+
+// alarmKey identifica uma regra de alarme entre todas as eclusas
+func alarmKey(lockID string, ruleID string) string {
+	return lockID + "/" + ruleID
+}
+
+// alarmTag é um tag com regras de alarme, avaliado na varredura da sua classe.
+// Suppress traz o suppress_when já interpretado de cada regra (nil sem supressão).
+type alarmTag struct {
+	Name     string
+	Tag      PLCTag
+	Suppress []*expression
+}
+
+// buildAlarmPlans agrupa por classe de varredura os tags com regras de alarme
+func buildAlarmPlans(conn PLCConnection) map[string][]alarmTag {
+	plans := make(map[string][]alarmTag)
+	for name, tag := range conn.Tags {
+		if len(tag.Alarms) == 0 {
+			continue
+		}
+		at := alarmTag{Name: name, Tag: tag, Suppress: make([]*expression, len(tag.Alarms))}
+		for i, rule := range tag.Alarms {
+			if rule.SuppressWhen != "" {
+				// Já validado ao carregar a configuração
+				at.Suppress[i], _ = parseExpression(rule.SuppressWhen)
+			}
+		}
+		class := conn.tagScanClass(tag)
+		plans[class] = append(plans[class], at)
+	}
+	for class := range plans {
+		plan := plans[class]
+		sort.Slice(plan, func(i, j int) bool { return plan[i].Name < plan[j].Name })
+	}
+	return plans
+}
+
+// alarmRuleState guarda o que a avaliação de uma regra precisa entre varreduras
+type alarmRuleState struct {
+	pendingSince time.Time // condição presente, aguardando delay_on_ms
+	clearSince   time.Time // condição ausente, aguardando delay_off_ms
+	rate         rateTracker
+}
+
+// alarmSave é uma alteração de alarme (ou um registro de auditoria) a gravar;
+// alarm identifica a ocorrência
+type alarmSave struct {
+	alarm *models.Alarm
+	row   models.Alarm
+	audit *models.AlarmAudit
+}
+
+// AlarmEngine avalia as regras de alarme e mantém os alarmes abertos
+type AlarmEngine struct {
+	mutex   sync.Mutex
+	states  map[string]*alarmRuleState
+	open    map[string]*models.Alarm       // ocorrências abertas por chave
+	shelves map[string]*models.AlarmShelve // regras retiradas para manutenção, por chave
+
+	saves chan alarmSave
+}
+
+var (
+	globalAlarmEngine *AlarmEngine
+	alarmEngineOnce   sync.Once
+)
+
+// GetAlarmEngine retorna instância singleton do motor de alarmes
+func GetAlarmEngine() *AlarmEngine {
+	alarmEngineOnce.Do(func() {
+		globalAlarmEngine = &AlarmEngine{
+			states:  make(map[string]*alarmRuleState),
+			open:    make(map[string]*models.Alarm),
+			shelves: make(map[string]*models.AlarmShelve),
+		}
+		go globalAlarmEngine.shelveLoop()
+
+		db := tagDB()
+		if db == nil {
+			log.Printf("⚠️ Banco indisponível: alarmes ficam apenas na memória")
+			return
+		}
+		globalAlarmEngine.loadShelves(db)
+
+		// Retomar os alarmes que estavam abertos quando o serviço parou
+		var alarms []models.Alarm
+		if err := db.Where("state <> ?", models.AlarmNormal).Order("id").Find(&alarms).Error; err != nil {
+			log.Printf("⚠️ Erro ao carregar alarmes abertos: %v", err)
+		}
+		for i := range alarms {
+			globalAlarmEngine.open[alarms[i].Key] = &alarms[i]
+		}
+
+		globalAlarmEngine.saves = make(chan alarmSave, alarmSaveQueue)
+		go globalAlarmEngine.saveLoop()
+		log.Printf("🚨 Motor de alarmes iniciado (%d alarmes abertos)", len(alarms))
+	})
+	return globalAlarmEngine
+}
+
+// saveLoop grava as alterações na ordem em que aconteceram
+func (e *AlarmEngine) saveLoop() {
+	ids := make(map[*models.Alarm]uint)
+	for save := range e.saves {
+		db := tagDB()
+		if save.audit != nil {
+			// A ocorrência pode ter sido criada depois de o registro entrar na fila
+			if save.audit.AlarmID == 0 {
+				save.audit.AlarmID = ids[save.alarm]
+			}
+			if err := db.Create(save.audit).Error; err != nil {
+				log.Printf("❌ Erro ao gravar auditoria do alarme %s: %v", save.audit.AlarmKey, err)
+			}
+			continue
+		}
+
+		row := save.row
+		if row.ID == 0 {
+			row.ID = ids[save.alarm]
+		}
+
+		if row.ID == 0 {
+			if err := db.Create(&row).Error; err != nil {
+				log.Printf("❌ Erro ao gravar alarme %s: %v", row.Key, err)
+				continue
+			}
+			ids[save.alarm] = row.ID
+			e.mutex.Lock()
+			save.alarm.ID = row.ID
+			e.mutex.Unlock()
+		} else if err := db.Save(&row).Error; err != nil {
+			log.Printf("❌ Erro ao atualizar alarme %s: %v", row.Key, err)
+		}
+
+		if !row.IsOpen() {
+			delete(ids, save.alarm)
+		}
+	}
+}
+
+// persist enfileira a gravação do estado atual do alarme (chamado com e.mutex)
+func (e *AlarmEngine) persist(alarm *models.Alarm) {
+	if e.saves == nil {
+		return
+	}
+	select {
+	case e.saves <- alarmSave{alarm: alarm, row: *alarm}:
+	default:
+		log.Printf("⚠️ Fila de gravação de alarmes cheia: alteração de %s não gravada", alarm.Key)
+	}
+}
+
+// broadcastAlarms envia as alterações de alarme aos clientes do WebSocket
+func broadcastAlarms(changed []models.Alarm) {
+	hub := GetWebSocketHub()
+	for _, alarm := range changed {
+		hub.BroadcastMessage(map[string]interface{}{
+			"type":    "alarm",
+			"lock_id": alarm.PLCID,
+			"alarm":   alarm,
+		})
+	}
+}
+
+// evaluate avalia as regras dos tags com os valores atuais da eclusa.
+// Valores sem qualidade boa ou incerta não mudam o estado dos alarmes.
+func (e *AlarmEngine) evaluate(lockID string, plan []alarmTag, values map[string]TagValue, now time.Time) {
+	var changed []models.Alarm
+
+	e.mutex.Lock()
+	for _, at := range plan {
+		current, ok := values[at.Name]
+		if !ok || current.Value == nil || !usableQuality(current.effectiveQuality(now)) {
+			continue
+		}
+
+		for i, rule := range at.Tag.Alarms {
+			key := alarmKey(lockID, rule.ruleID(at.Name))
+			state := e.states[key]
+			if state == nil {
+				state = &alarmRuleState{}
+				e.states[key] = state
+			}
+
+			rate := 0.0
+			if rule.Type == alarmRate {
+				number, isNumber := toFloat64(current.Value)
+				if !isNumber {
+					continue
+				}
+				var rateOK bool
+				if rate, rateOK = state.rate.add(now, number, rule.rateWindow()); !rateOK {
+					continue
+				}
+			}
+
+			var reference interface{}
+			if rule.Reference != "" {
+				ref, ok := values[rule.Reference]
+				if !ok || ref.Value == nil || !usableQuality(ref.effectiveQuality(now)) {
+					continue
+				}
+				reference = ref.Value
+			}
+
+			// Retirada para manutenção e supressão por projeto só tiram o alarme da lista principal
+			shelved := e.shelves[key] != nil
+			suppressed := alarmSuppressed(at.Suppress[i], values, now)
+
+			alarm := e.open[key]
+			if alarm != nil && (alarm.Shelved != shelved || alarm.Suppressed != suppressed) {
+				alarm.Shelved, alarm.Suppressed = shelved, suppressed
+				alarm.UpdatedAt = now
+				e.persist(alarm)
+				changed = append(changed, *alarm)
+			}
+
+			active := alarm != nil && (alarm.State == models.AlarmActiveUnacked || alarm.State == models.AlarmActiveAcked)
+			condition, measured, ok := alarmCondition(rule, at.Tag, current.Value, reference, rate, active)
+			if !ok {
+				continue
+			}
+
+			if condition {
+				state.clearSince = time.Time{}
+				if active {
+					continue
+				}
+				if state.pendingSince.IsZero() {
+					state.pendingSince = now
+				}
+				if now.Sub(state.pendingSince) < time.Duration(rule.DelayOnMs)*time.Millisecond {
+					continue
+				}
+				state.pendingSince = time.Time{}
+				alarm = e.activate(key, lockID, at, rule, measured, shelved, suppressed, now)
+				changed = append(changed, *alarm)
+			} else {
+				state.pendingSince = time.Time{}
+				if !active {
+					continue
+				}
+				if state.clearSince.IsZero() {
+					state.clearSince = now
+				}
+				if now.Sub(state.clearSince) < time.Duration(rule.DelayOffMs)*time.Millisecond {
+					continue
+				}
+				state.clearSince = time.Time{}
+				changed = append(changed, *e.clear(key, now))
+			}
+		}
+	}
+	e.mutex.Unlock()
+
+	broadcastAlarms(changed)
+}
+
+// alarmSuppressed avalia o suppress_when da regra; sem valor para avaliar, o alarme não é suprimido
+func alarmSuppressed(expr *expression, values map[string]TagValue, now time.Time) bool {
+	if expr == nil {
+		return false
+	}
+	suppressed, err := expr.evalBool(func(name string) (interface{}, bool) {
+		value, ok := values[name]
+		return value.Value, ok && value.Value != nil && usableQuality(value.effectiveQuality(now))
+	}, now)
+	return err == nil && suppressed
+}
+
+// usableQuality indica se um valor com esta qualidade pode mudar o estado dos alarmes
+func usableQuality(quality string) bool {
+	return quality == QualityGood || quality == QualityUncertain
+}
+
+// activate ativa o alarme da regra. Uma ocorrência normalizada e ainda não reconhecida
+// volta a ativa em vez de abrir outra. Chamado com e.mutex.
+func (e *AlarmEngine) activate(key string, lockID string, at alarmTag, rule AlarmRule, measured float64, shelved bool, suppressed bool, now time.Time) *models.Alarm {
+	alarm := e.open[key]
+	if alarm == nil {
+		alarm = &models.Alarm{
+			Key:         key,
+			PLCID:       lockID,
+			TagName:     at.Name,
+			RuleID:      rule.ruleID(at.Name),
+			Type:        rule.Type,
+			ActivatedAt: now,
+			CreatedAt:   now,
+		}
+		e.open[key] = alarm
+	}
+
+	alarm.Priority = rule.priority()
+	alarm.Message = rule.message(at.Name, at.Tag)
+	alarm.State = models.AlarmActiveUnacked
+	alarm.Value = measured
+	alarm.ClearedAt = nil
+	alarm.Shelved = shelved
+	alarm.Suppressed = suppressed
+	if limit, ok := rule.limit(at.Tag); ok {
+		alarm.Limit = limit
+	} else if rule.Type == alarmBit {
+		alarm.Limit = 1
+		if rule.Value != nil && !*rule.Value {
+			alarm.Limit = 0
+		}
+	}
+	alarm.UpdatedAt = now
+
+	if shelved || suppressed {
+		log.Printf("🔕 [%s] Alarme %s ativo fora da lista (retirado/suprimido): %s", lockID, alarm.RuleID, alarm.Message)
+	} else {
+		log.Printf("🚨 [%s] Alarme %s ativo (%s): %s", lockID, alarm.RuleID, alarm.Priority, alarm.Message)
+	}
+	e.persist(alarm)
+	GetNotifier().AlarmEvent(*alarm, NotifyActivated)
+	return alarm
+}
+
+// clear normaliza o alarme: sem reconhecimento fica cleared-unacked, reconhecido é
+// encerrado. Chamado com e.mutex.
+func (e *AlarmEngine) clear(key string, now time.Time) *models.Alarm {
+	alarm := e.open[key]
+	clearedAt := now
+	alarm.ClearedAt = &clearedAt
+	alarm.UpdatedAt = now
+	if alarm.State == models.AlarmActiveAcked {
+		alarm.State = models.AlarmNormal
+		delete(e.open, key)
+	} else {
+		alarm.State = models.AlarmClearedUnacked
+	}
+
+	log.Printf("✅ [%s] Alarme %s normalizado: %s", alarm.PLCID, alarm.RuleID, alarm.Message)
+	e.persist(alarm)
+	GetNotifier().AlarmEvent(*alarm, NotifyCleared)
+	return alarm
+}
+
+// syncRules encerra os alarmes abertos da eclusa cujas regras deixaram de existir
+func (e *AlarmEngine) syncRules(conn PLCConnection) {
+	keys := make(map[string]bool)
+	for name, tag := range conn.Tags {
+		for _, rule := range tag.Alarms {
+			keys[alarmKey(conn.ID, rule.ruleID(name))] = true
+		}
+	}
+
+	var changed []models.Alarm
+	now := time.Now()
+	prefix := conn.ID + "/"
+
+	e.mutex.Lock()
+	for key := range e.states {
+		if len(key) > len(prefix) && key[:len(prefix)] == prefix && !keys[key] {
+			delete(e.states, key)
+		}
+	}
+	for key, alarm := range e.open {
+		if alarm.PLCID != conn.ID || keys[key] {
+			continue
+		}
+		if alarm.ClearedAt == nil {
+			clearedAt := now
+			alarm.ClearedAt = &clearedAt
+		}
+		alarm.State = models.AlarmNormal
+		alarm.UpdatedAt = now
+		delete(e.open, key)
+		log.Printf("🗑️ [%s] Alarme %s encerrado: regra removida", alarm.PLCID, alarm.RuleID)
+		e.persist(alarm)
+		changed = append(changed, *alarm)
+	}
+	e.mutex.Unlock()
+
+	broadcastAlarms(changed)
+}
+
+// ActiveAlarms retorna os alarmes abertos da lista principal da eclusa (todas com lockID
+// vazio), dos mais urgentes aos menos e, na mesma prioridade, dos mais recentes aos mais antigos
+func (e *AlarmEngine) ActiveAlarms(lockID string) []models.Alarm {
+	return e.openAlarms(lockID, false)
+}
+
+// ShelvedAlarms retorna os alarmes abertos retirados para manutenção ou suprimidos
+func (e *AlarmEngine) ShelvedAlarms(lockID string) []models.Alarm {
+	return e.openAlarms(lockID, true)
+}
+
+// openAlarms lista os alarmes abertos da eclusa, os da lista principal ou os fora dela
+func (e *AlarmEngine) openAlarms(lockID string, hidden bool) []models.Alarm {
+	e.mutex.Lock()
+	alarms := make([]models.Alarm, 0, len(e.open))
+	for _, alarm := range e.open {
+		if (lockID == "" || alarm.PLCID == lockID) && (alarm.Shelved || alarm.Suppressed) == hidden {
+			alarms = append(alarms, *alarm)
+		}
+	}
+	e.mutex.Unlock()
+
+	sort.Slice(alarms, func(i, j int) bool {
+		ri, rj := alarmPriorityRank[alarms[i].Priority], alarmPriorityRank[alarms[j].Priority]
+		if ri != rj {
+			return ri > rj
+		}
+		return alarms[i].ActivatedAt.After(alarms[j].ActivatedAt)
+	})
+	return alarms
+}
+
+// SendActiveAlarms envia os alarmes abertos de cada eclusa para um novo cliente
+func (e *AlarmEngine) SendActiveAlarms(clientSend chan []byte) {
+	for _, connector := range GetPLCManager().Connectors() {
+		data, err := json.Marshal(map[string]interface{}{
+			"type":    "alarms",
+			"lock_id": connector.ID(),
+			"alarms":  e.ActiveAlarms(connector.ID()),
+			"shelved": e.ShelvedAlarms(connector.ID()),
+		})
+		if err != nil {
+			continue
+		}
+		select {
+		case clientSend <- data:
+		default:
+			log.Printf("❌ [%s] Falha ao enviar alarmes iniciais", connector.ID())
+		}
+	}
+}
+
+// evaluateAlarms avalia as regras de alarme dos tags da classe com os valores atuais do cache
+func (s7 *S7PLCConnector) evaluateAlarms(class string, now time.Time) {
+	s7.mutex.Lock()
+	if s7.alarmPlans == nil {
+		s7.alarmPlans = buildAlarmPlans(s7.config)
+	}
+	plan := s7.alarmPlans[class]
+	s7.mutex.Unlock()
+
+	if len(plan) == 0 {
+		return
+	}
+
+	values := make(map[string]TagValue)
+	s7.currentMutex.RLock()
+	for _, at := range plan {
+		values[at.Name] = s7.currentValues[at.Name]
+		for i, rule := range at.Tag.Alarms {
+			if rule.Reference != "" {
+				values[rule.Reference] = s7.currentValues[rule.Reference]
+			}
+			if expr := at.Suppress[i]; expr != nil {
+				for _, ref := range expr.refs {
+					values[ref] = s7.currentValues[ref]
+				}
+			}
+		}
+	}
+	s7.currentMutex.RUnlock()
+
+	GetAlarmEngine().evaluate(s7.lockID, plan, values, now)
+}
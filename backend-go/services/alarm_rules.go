@@ -0,0 +1,275 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Tipos de regra em "alarms" de cada tag
+const (
+	alarmHi        = "hi"        // acima do limite (padrão max_value)
+	alarmHiHi      = "hihi"      // acima do limite (padrão max_value), prioridade alta
+	alarmLo        = "lo"        // abaixo do limite (padrão min_value)
+	alarmLoLo      = "lolo"      // abaixo do limite (padrão min_value), prioridade alta
+	alarmBit       = "bit"       // tag igual a "value" (padrão true)
+	alarmRate      = "roc"       // variação por segundo acima de "limit" (em módulo)
+	alarmDeviation = "deviation" // afastamento de "reference" ou "setpoint" acima de "limit"
+)
+
+// Prioridades de alarme, da menor para a maior
+const (
+	AlarmPriorityLow      = "low"
+	AlarmPriorityMedium   = "medium"
+	AlarmPriorityHigh     = "high"
+	AlarmPriorityCritical = "critical"
+)
+
+// alarmPriorityRank ordena as prioridades (maior = mais urgente)
+var alarmPriorityRank = map[string]int{
+	AlarmPriorityLow:      1,
+	AlarmPriorityMedium:   2,
+	AlarmPriorityHigh:     3,
+	AlarmPriorityCritical: 4,
+}
+
+// Janela padrão da taxa de variação
+const alarmDefaultRateWindow = 10 * time.Second
+
+// AlarmRule é uma regra de alarme de um tag.
+// Ex: {"type": "hi", "limit": 95, "deadband": 1, "delay_on_ms": 2000, "priority": "high"}
+type AlarmRule struct {
+	ID       string   `json:"id,omitempty"` // padrão "<tag>:<type>"
+	Type     string   `json:"type"`
+	Limit    *float64 `json:"limit,omitempty"`
+	Value    *bool    `json:"value,omitempty"` // bit: estado que dispara o alarme
+	Priority string   `json:"priority,omitempty"`
+	Message  string   `json:"message,omitempty"`
+
+	// Taxa de variação: janela em segundos (padrão 10)
+	WindowS int `json:"window_s,omitempty"`
+
+	// Desvio: comparado com outro tag ou com um valor fixo
+	Reference string   `json:"reference,omitempty"`
+	Setpoint  *float64 `json:"setpoint,omitempty"`
+
+	// A condição precisa durar delay_on_ms para ativar e sumir por delay_off_ms para normalizar;
+	// deadband é a histerese: o alarme só normaliza depois de voltar além do limite menos a banda
+	DelayOnMs  int     `json:"delay_on_ms,omitempty"`
+	DelayOffMs int     `json:"delay_off_ms,omitempty"`
+	Deadband   float64 `json:"deadband,omitempty"`
+
+	// Supressão por projeto: enquanto a expressão for verdadeira o alarme não vai para a
+	// lista principal (ex: "!{Eclusa_Operação}" para alarmes que só valem em operação)
+	SuppressWhen string `json:"suppress_when,omitempty"`
+}
+
+// ruleID retorna o identificador da regra dentro da eclusa
+func (r AlarmRule) ruleID(tagName string) string {
+	if r.ID != "" {
+		return r.ID
+	}
+	return tagName + ":" + r.Type
+}
+
+// limit retorna o limite da regra; hi/hihi usam max_value e lo/lolo min_value por padrão
+func (r AlarmRule) limit(tag PLCTag) (float64, bool) {
+	if r.Limit != nil {
+		return *r.Limit, true
+	}
+	switch r.Type {
+	case alarmHi, alarmHiHi:
+		if tag.MaxValue != nil {
+			return *tag.MaxValue, true
+		}
+	case alarmLo, alarmLoLo:
+		if tag.MinValue != nil {
+			return *tag.MinValue, true
+		}
+	}
+	return 0, false
+}
+
+// priority retorna a prioridade da regra (hihi/lolo são altas por padrão)
+func (r AlarmRule) priority() string {
+	if r.Priority != "" {
+		return r.Priority
+	}
+	if r.Type == alarmHiHi || r.Type == alarmLoLo {
+		return AlarmPriorityHigh
+	}
+	return AlarmPriorityMedium
+}
+
+// rateWindow retorna a janela da taxa de variação
+func (r AlarmRule) rateWindow() time.Duration {
+	if r.WindowS > 0 {
+		return time.Duration(r.WindowS) * time.Second
+	}
+	return alarmDefaultRateWindow
+}
+
+// message retorna o texto do alarme, com um padrão a partir da descrição do tag
+func (r AlarmRule) message(tagName string, tag PLCTag) string {
+	if r.Message != "" {
+		return r.Message
+	}
+	name := tag.Description
+	if name == "" {
+		name = tagName
+	}
+	switch r.Type {
+	case alarmHi:
+		return name + " alto"
+	case alarmHiHi:
+		return name + " muito alto"
+	case alarmLo:
+		return name + " baixo"
+	case alarmLoLo:
+		return name + " muito baixo"
+	case alarmRate:
+		return name + " variando rápido demais"
+	case alarmDeviation:
+		return name + " fora do valor de referência"
+	}
+	return name
+}
+
+// validateAlarmRules verifica as regras de alarme de um tag
+func validateAlarmRules(tag PLCTag, info tagTypeInfo, conn PLCConnection) error {
+	ids := make(map[string]bool)
+	for i, rule := range tag.Alarms {
+		id := rule.ruleID("")
+		if strings.Contains(rule.ID, "/") {
+			return fmt.Errorf("alarms[%d]: id não pode conter \"/\": %s", i, rule.ID)
+		}
+		if ids[id] {
+			return fmt.Errorf("alarms[%d]: id repetido: %s", i, id)
+		}
+		ids[id] = true
+
+		if rule.Priority != "" {
+			if _, ok := alarmPriorityRank[rule.Priority]; !ok {
+				return fmt.Errorf("alarms[%d]: priority inválida: %s (use low, medium, high ou critical)", i, rule.Priority)
+			}
+		}
+		if rule.DelayOnMs < 0 || rule.DelayOffMs < 0 || rule.Deadband < 0 || rule.WindowS < 0 {
+			return fmt.Errorf("alarms[%d]: atrasos, deadband e window_s não podem ser negativos", i)
+		}
+
+		if rule.SuppressWhen != "" {
+			expr, err := parseExpression(rule.SuppressWhen)
+			if err != nil {
+				return fmt.Errorf("alarms[%d]: suppress_when inválido: %v", i, err)
+			}
+			for _, ref := range expr.refs {
+				if _, ok := conn.Tags[ref]; !ok {
+					return fmt.Errorf("alarms[%d]: suppress_when usa tag desconhecido: %s", i, ref)
+				}
+			}
+		}
+
+		switch rule.Type {
+		case alarmBit:
+			if info.Base != "bool" && !info.isNumeric() {
+				return fmt.Errorf("alarms[%d]: alarme bit exige tag bool ou numérico", i)
+			}
+			continue
+		case alarmHi, alarmHiHi, alarmLo, alarmLoLo, alarmRate, alarmDeviation:
+		default:
+			return fmt.Errorf("alarms[%d]: type inválido: %s", i, rule.Type)
+		}
+
+		if !info.isNumeric() {
+			return fmt.Errorf("alarms[%d]: alarme %s exige tag numérico (tipo %s)", i, rule.Type, tag.Type)
+		}
+		if _, ok := rule.limit(tag); !ok {
+			return fmt.Errorf("alarms[%d]: alarme %s exige limit (ou min_value/max_value)", i, rule.Type)
+		}
+		if rule.Type == alarmDeviation {
+			if (rule.Reference == "") == (rule.Setpoint == nil) {
+				return fmt.Errorf("alarms[%d]: desvio exige reference ou setpoint", i)
+			}
+			if rule.Reference != "" {
+				if _, ok := conn.Tags[rule.Reference]; !ok {
+					return fmt.Errorf("alarms[%d]: reference desconhecida: %s", i, rule.Reference)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// validateAlarmIDs verifica se os ids das regras são únicos na eclusa
+func validateAlarmIDs(conn PLCConnection) error {
+	owners := make(map[string]string)
+	for name, tag := range conn.Tags {
+		for _, rule := range tag.Alarms {
+			id := rule.ruleID(name)
+			if owner, exists := owners[id]; exists && owner != name {
+				return fmt.Errorf("id de alarme repetido nos tags %s e %s: %s", owner, name, id)
+			}
+			owners[id] = name
+		}
+	}
+	return nil
+}
+
+// alarmCondition avalia a condição da regra com o valor atual.
+// active é o estado atual do alarme, para aplicar a histerese na normalização.
+// measured é o valor comparado com o limite (o próprio valor, a taxa ou o desvio).
+func alarmCondition(rule AlarmRule, tag PLCTag, value interface{}, reference interface{}, rate float64, active bool) (condition bool, measured float64, ok bool) {
+	if rule.Type == alarmBit {
+		want := true
+		if rule.Value != nil {
+			want = *rule.Value
+		}
+		state, isBool := value.(bool)
+		if !isBool {
+			number, isNumber := toFloat64(value)
+			if !isNumber {
+				return false, 0, false
+			}
+			state = number != 0
+		}
+		if state {
+			measured = 1
+		}
+		return state == want, measured, true
+	}
+
+	number, isNumber := toFloat64(value)
+	if !isNumber || math.IsNaN(number) || math.IsInf(number, 0) {
+		return false, 0, false
+	}
+	limit, _ := rule.limit(tag)
+
+	// Histerese: ativo, o alarme só normaliza além do limite menos a banda
+	band := 0.0
+	if active {
+		band = rule.Deadband
+	}
+
+	switch rule.Type {
+	case alarmHi, alarmHiHi:
+		return number > limit-band, number, true
+	case alarmLo, alarmLoLo:
+		return number < limit+band, number, true
+	case alarmRate:
+		return math.Abs(rate) > limit-band, rate, true
+	case alarmDeviation:
+		ref := 0.0
+		if rule.Setpoint != nil {
+			ref = *rule.Setpoint
+		} else {
+			var refOK bool
+			if ref, refOK = toFloat64(reference); !refOK {
+				return false, 0, false
+			}
+		}
+		deviation := number - ref
+		return math.Abs(deviation) > limit-band, deviation, true
+	}
+	return false, 0, false
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"backend-go/models"
+)
+
+func floatPtr(v float64) *float64 { return &v }
+func boolPtr(v bool) *bool        { return &v }
+
+func TestAlarmCondition(t *testing.T) {
+	tag := PLCTag{Type: "real", MinValue: floatPtr(10), MaxValue: floatPtr(90)}
+
+	tests := []struct {
+		name      string
+		rule      AlarmRule
+		value     interface{}
+		reference interface{}
+		rate      float64
+		active    bool
+		want      bool
+		measured  float64
+		ok        bool
+	}{
+		{"hi abaixo", AlarmRule{Type: alarmHi, Limit: floatPtr(95)}, 94.0, nil, 0, false, false, 94, true},
+		{"hi acima", AlarmRule{Type: alarmHi, Limit: floatPtr(95)}, 95.5, nil, 0, false, true, 95.5, true},
+		{"hi no limite não ativa", AlarmRule{Type: alarmHi, Limit: floatPtr(95)}, 95.0, nil, 0, false, false, 95, true},
+		{"hi usa max_value", AlarmRule{Type: alarmHi}, 91.0, nil, 0, false, true, 91, true},
+		{"hi ativo dentro da banda continua", AlarmRule{Type: alarmHi, Limit: floatPtr(95), Deadband: 2}, 94.0, nil, 0, true, true, 94, true},
+		{"hi ativo além da banda normaliza", AlarmRule{Type: alarmHi, Limit: floatPtr(95), Deadband: 2}, 92.9, nil, 0, true, false, 92.9, true},
+		{"hi inativo ignora a banda", AlarmRule{Type: alarmHi, Limit: floatPtr(95), Deadband: 2}, 94.0, nil, 0, false, false, 94, true},
+		{"lolo usa min_value", AlarmRule{Type: alarmLoLo}, 9.0, nil, 0, false, true, 9, true},
+		{"lo ativo dentro da banda", AlarmRule{Type: alarmLo, Limit: floatPtr(20), Deadband: 1}, 20.5, nil, 0, true, true, 20.5, true},
+		{"lo ativo além da banda", AlarmRule{Type: alarmLo, Limit: floatPtr(20), Deadband: 1}, 21.5, nil, 0, true, false, 21.5, true},
+		{"bit padrão true", AlarmRule{Type: alarmBit}, true, nil, 0, false, true, 1, true},
+		{"bit esperando false", AlarmRule{Type: alarmBit, Value: boolPtr(false)}, false, nil, 0, false, true, 0, true},
+		{"bit numérico", AlarmRule{Type: alarmBit}, int16(2), nil, 0, false, true, 1, true},
+		{"bit com texto", AlarmRule{Type: alarmBit}, "x", nil, 0, false, false, 0, false},
+		{"taxa em módulo", AlarmRule{Type: alarmRate, Limit: floatPtr(0.5)}, 50.0, nil, -0.6, false, true, -0.6, true},
+		{"taxa ativa com banda", AlarmRule{Type: alarmRate, Limit: floatPtr(0.5), Deadband: 0.2}, 50.0, nil, 0.4, true, true, 0.4, true},
+		{"desvio do setpoint", AlarmRule{Type: alarmDeviation, Limit: floatPtr(5), Setpoint: floatPtr(50)}, 44.0, nil, 0, false, true, -6, true},
+		{"desvio da referência", AlarmRule{Type: alarmDeviation, Limit: floatPtr(5), Reference: "Montante"}, 44.0, float32(47), 0, false, false, -3, true},
+		{"desvio sem referência numérica", AlarmRule{Type: alarmDeviation, Limit: floatPtr(5), Reference: "Montante"}, 44.0, "x", 0, false, false, 0, false},
+		{"NaN não avalia", AlarmRule{Type: alarmHi, Limit: floatPtr(95)}, math.NaN(), nil, 0, false, false, 0, false},
+	}
+
+	for _, tt := range tests {
+		condition, measured, ok := alarmCondition(tt.rule, tag, tt.value, tt.reference, tt.rate, tt.active)
+		if condition != tt.want || ok != tt.ok || (ok && measured != tt.measured) {
+			t.Errorf("%s: obtido (%v, %v, %v), esperado (%v, %v, %v)",
+				tt.name, condition, measured, ok, tt.want, tt.measured, tt.ok)
+		}
+	}
+}
+
+// Histerese e atrasos de ativação/normalização no motor de alarmes
+func TestAlarmEngineDelaysAndHysteresis(t *testing.T) {
+	conn := PLCConnection{
+		ID: "teste",
+		Tags: map[string]PLCTag{
+			"Nivel": {Type: "real", Offset: 0, Alarms: []AlarmRule{
+				{Type: alarmHi, Limit: floatPtr(95), Deadband: 2, DelayOnMs: 2000, DelayOffMs: 1000},
+			}},
+		},
+	}
+	plan := buildAlarmPlans(conn)[conn.defaultClass()]
+	engine := &AlarmEngine{
+		states:  make(map[string]*alarmRuleState),
+		open:    make(map[string]*models.Alarm),
+		shelves: make(map[string]*models.AlarmShelve),
+	}
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	state := func() string {
+		alarm := engine.open[alarmKey("teste", "Nivel:hi")]
+		if alarm == nil {
+			return models.AlarmNormal
+		}
+		return alarm.State
+	}
+
+	steps := []struct {
+		at      time.Duration
+		value   float64
+		quality string
+		want    string
+	}{
+		{0, 96, QualityGood, models.AlarmNormal},                       // condição começa, aguardando delay_on
+		{1500 * time.Millisecond, 97, QualityGood, models.AlarmNormal}, // ainda no atraso
+		{2 * time.Second, 97, QualityGood, models.AlarmActiveUnacked},  // 2 s: ativa
+		{3 * time.Second, 94, QualityGood, models.AlarmActiveUnacked},  // dentro da histerese (95 - 2)
+		{4 * time.Second, 92, QualityGood, models.AlarmActiveUnacked},  // além da banda, aguardando delay_off
+		{5 * time.Second, 92, QualityBadComm, models.AlarmActiveUnacked},
+		{5 * time.Second, 92, QualityGood, models.AlarmClearedUnacked}, // 1 s fora: normaliza sem reconhecimento
+		{6 * time.Second, 96, QualityGood, models.AlarmClearedUnacked}, // volta a aguardar delay_on
+		{8 * time.Second, 96, QualityGood, models.AlarmActiveUnacked},  // reativa a mesma ocorrência
+	}
+
+	first := (*models.Alarm)(nil)
+	for i, step := range steps {
+		now := start.Add(step.at)
+		values := map[string]TagValue{"Nivel": {Value: step.value, Quality: step.quality, ReadAt: now}}
+		engine.evaluate("teste", plan, values, now)
+		if got := state(); got != step.want {
+			t.Fatalf("passo %d (%v, %v): estado %s, esperado %s", i, step.at, step.value, got, step.want)
+		}
+		if alarm := engine.open[alarmKey("teste", "Nivel:hi")]; alarm != nil {
+			if first == nil {
+				first = alarm
+			} else if alarm != first {
+				t.Fatalf("passo %d: nova ocorrência em vez de reativar a anterior", i)
+			}
+		}
+	}
+}
@@ -0,0 +1,369 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"backend-go/models"
+	"gorm.io/gorm"
+)
+
+// Retirada de alarmes para manutenção: regras (ou todas as regras dos tags de um grupo)
+// saem da lista principal por um tempo limitado, com motivo, e voltam sozinhas quando o
+// prazo acaba. Os alarmes continuam sendo avaliados e gravados; ficam na lista de retirados.
+
+const (
+	AlarmShelveMaxDuration   = 24 * time.Hour
+	alarmShelveCheckInterval = 5 * time.Second
+)
+
+var (
+	ErrInvalidShelve    = errors.New("retirada de alarme inválida")
+	ErrAlarmNotShelved  = errors.New("nenhum alarme retirado entre os informados")
+	errShelveNeedsRules = errors.New("informe rule_ids ou group_id")
+)
+
+// shelveMutex serializa as retiradas, devoluções e expirações (com a gravação no banco)
+var shelveMutex sync.Mutex
+
+// AlarmShelveRequest descreve uma retirada ou devolução de alarmes
+type AlarmShelveRequest struct {
+	LockID   string
+	RuleIDs  []string
+	GroupID  *uint
+	Duration time.Duration // só na retirada
+	Reason   string        // só na retirada
+	Username string
+}
+
+// alarmRuleTags retorna o tag de cada regra de alarme da eclusa
+func (s7 *S7PLCConnector) alarmRuleTags() map[string]string {
+	s7.mutex.RLock()
+	defer s7.mutex.RUnlock()
+
+	rules := make(map[string]string)
+	for name, tag := range s7.config.Tags {
+		for _, rule := range tag.Alarms {
+			rules[rule.ruleID(name)] = name
+		}
+	}
+	return rules
+}
+
+// shelveTargets resolve as regras pedidas (por id ou pelos tags do grupo) em regra → tag
+func (r AlarmShelveRequest) shelveTargets() (map[string]string, error) {
+	if len(r.RuleIDs) == 0 && r.GroupID == nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidShelve, errShelveNeedsRules)
+	}
+	connector, ok := GetPLCManager().Connector(r.LockID)
+	if !ok {
+		return nil, fmt.Errorf("%w: eclusa não encontrada: %s", ErrInvalidShelve, r.LockID)
+	}
+	rules := connector.alarmRuleTags()
+
+	targets := make(map[string]string)
+	for _, ruleID := range r.RuleIDs {
+		tagName, ok := rules[ruleID]
+		if !ok {
+			return nil, fmt.Errorf("%w: regra de alarme desconhecida: %s", ErrInvalidShelve, ruleID)
+		}
+		targets[ruleID] = tagName
+	}
+
+	if r.GroupID != nil {
+		db := tagDB()
+		if db == nil {
+			return nil, fmt.Errorf("%w: grupos de tags exigem banco de dados", ErrInvalidShelve)
+		}
+		var names []string
+		err := db.Model(&models.Tag{}).
+			Where("plc_id = ? AND id IN (?)", r.LockID, db.Model(&models.TagGroupMember{}).Select("tag_id").Where("group_id = ?", *r.GroupID)).
+			Pluck("name", &names).Error
+		if err != nil {
+			return nil, err
+		}
+		members := make(map[string]bool, len(names))
+		for _, name := range names {
+			members[name] = true
+		}
+		for ruleID, tagName := range rules {
+			if members[tagName] {
+				targets[ruleID] = tagName
+			}
+		}
+	}
+
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("%w: o grupo não tem tags com alarmes nesta eclusa", ErrInvalidShelve)
+	}
+	return targets, nil
+}
+
+// loadShelves retoma as retiradas abertas; as vencidas são devolvidas na próxima verificação
+func (e *AlarmEngine) loadShelves(db *gorm.DB) {
+	var shelves []models.AlarmShelve
+	if err := db.Where("unshelved_at IS NULL").Find(&shelves).Error; err != nil {
+		log.Printf("⚠️ Erro ao carregar alarmes retirados: %v", err)
+		return
+	}
+	for i := range shelves {
+		e.shelves[shelves[i].Key] = &shelves[i]
+	}
+}
+
+// saveShelve grava a retirada fora de e.mutex. Só quem tem shelveMutex altera as
+// retiradas; o ID novo é devolvido com e.mutex, pois Shelves lê a retirada.
+func (e *AlarmEngine) saveShelve(shelve *models.AlarmShelve) {
+	db := tagDB()
+	if db == nil {
+		return
+	}
+	row := *shelve
+	if err := db.Save(&row).Error; err != nil {
+		log.Printf("❌ Erro ao gravar retirada do alarme %s: %v", shelve.Key, err)
+		return
+	}
+	e.mutex.Lock()
+	shelve.ID = row.ID
+	e.mutex.Unlock()
+}
+
+// setShelved atualiza o alarme aberto da regra após retirada ou devolução. Chamado com e.mutex.
+func (e *AlarmEngine) setShelved(key string, shelved bool, now time.Time) *models.Alarm {
+	alarm := e.open[key]
+	if alarm == nil || alarm.Shelved == shelved {
+		return nil
+	}
+	alarm.Shelved = shelved
+	alarm.UpdatedAt = now
+	e.persist(alarm)
+	return alarm
+}
+
+// Shelve retira as regras da lista principal por Duration. Regras já retiradas têm
+// o prazo e o motivo substituídos.
+func (e *AlarmEngine) Shelve(request AlarmShelveRequest) ([]models.AlarmShelve, error) {
+	if request.Duration <= 0 || request.Duration > AlarmShelveMaxDuration {
+		return nil, fmt.Errorf("%w: duração deve ser maior que zero e até %s", ErrInvalidShelve, AlarmShelveMaxDuration)
+	}
+	if request.Reason == "" {
+		return nil, fmt.Errorf("%w: motivo obrigatório", ErrInvalidShelve)
+	}
+	targets, err := request.shelveTargets()
+	if err != nil {
+		return nil, err
+	}
+
+	shelveMutex.Lock()
+	defer shelveMutex.Unlock()
+
+	now := time.Now()
+	var shelves []*models.AlarmShelve
+	var changed []models.Alarm
+	var entries []models.AlarmAudit
+
+	e.mutex.Lock()
+	for _, ruleID := range sortedKeys(targets) {
+		key := alarmKey(request.LockID, ruleID)
+		shelve := e.shelves[key]
+		if shelve == nil {
+			shelve = &models.AlarmShelve{
+				Key:     key,
+				PLCID:   request.LockID,
+				TagName: targets[ruleID],
+				RuleID:  ruleID,
+			}
+			e.shelves[key] = shelve
+		}
+		shelve.GroupID = request.GroupID
+		shelve.Reason = request.Reason
+		shelve.ShelvedBy = request.Username
+		shelve.ShelvedAt = now
+		shelve.ExpiresAt = now.Add(request.Duration)
+		shelves = append(shelves, shelve)
+
+		if alarm := e.setShelved(key, true, now); alarm != nil {
+			changed = append(changed, *alarm)
+		}
+		entry := &models.AlarmAudit{
+			AlarmKey:  key,
+			PLCID:     request.LockID,
+			Action:    models.AlarmActionShelve,
+			Username:  request.Username,
+			Comment:   fmt.Sprintf("%s (até %s)", request.Reason, shelve.ExpiresAt.Format("02/01/2006 15:04")),
+			CreatedAt: now,
+		}
+		if alarm := e.open[key]; alarm != nil {
+			entry.AlarmID = alarm.ID
+		}
+		e.queueAudit(e.open[key], entry)
+		entries = append(entries, *entry)
+	}
+	e.mutex.Unlock()
+
+	result := make([]models.AlarmShelve, 0, len(shelves))
+	for _, shelve := range shelves {
+		e.saveShelve(shelve)
+		result = append(result, *shelve)
+	}
+
+	log.Printf("🔧 [%s] %d alarme(s) retirado(s) por %s até %s: %s",
+		request.LockID, len(result), request.Username, now.Add(request.Duration).Format("15:04"), request.Reason)
+	broadcastAlarms(changed)
+	broadcastAlarmAudits(entries)
+	broadcastShelves(request.LockID, result)
+	return result, nil
+}
+
+// Unshelve devolve as regras à lista principal antes do prazo
+func (e *AlarmEngine) Unshelve(request AlarmShelveRequest) ([]models.AlarmShelve, error) {
+	targets, err := request.shelveTargets()
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(targets))
+	for _, ruleID := range sortedKeys(targets) {
+		keys = append(keys, alarmKey(request.LockID, ruleID))
+	}
+
+	result := e.unshelve(keys, request.Username, time.Now())
+	if len(result) == 0 {
+		return nil, ErrAlarmNotShelved
+	}
+	return result, nil
+}
+
+// unshelve encerra as retiradas das chaves; username vazio indica expiração do prazo
+func (e *AlarmEngine) unshelve(keys []string, username string, now time.Time) []models.AlarmShelve {
+	shelveMutex.Lock()
+	defer shelveMutex.Unlock()
+
+	var shelves []*models.AlarmShelve
+	var changed []models.Alarm
+	var entries []models.AlarmAudit
+
+	e.mutex.Lock()
+	for _, key := range keys {
+		shelve := e.shelves[key]
+		if shelve == nil {
+			continue
+		}
+		delete(e.shelves, key)
+		unshelvedAt := now
+		shelve.UnshelvedAt = &unshelvedAt
+		shelve.UnshelvedBy = username
+		shelves = append(shelves, shelve)
+
+		if alarm := e.setShelved(key, false, now); alarm != nil {
+			changed = append(changed, *alarm)
+		}
+		comment := "devolvido à lista"
+		if username == "" {
+			comment = "prazo da retirada expirou"
+		}
+		entry := &models.AlarmAudit{
+			AlarmKey:  key,
+			PLCID:     shelve.PLCID,
+			Action:    models.AlarmActionUnshelve,
+			Username:  username,
+			Comment:   comment,
+			CreatedAt: now,
+		}
+		if alarm := e.open[key]; alarm != nil {
+			entry.AlarmID = alarm.ID
+		}
+		e.queueAudit(e.open[key], entry)
+		entries = append(entries, *entry)
+	}
+	e.mutex.Unlock()
+
+	result := make([]models.AlarmShelve, 0, len(shelves))
+	for _, shelve := range shelves {
+		e.saveShelve(shelve)
+		result = append(result, *shelve)
+		if username == "" {
+			log.Printf("⏰ [%s] Retirada do alarme %s expirou", shelve.PLCID, shelve.RuleID)
+		} else {
+			log.Printf("🔧 [%s] Alarme %s devolvido à lista por %s", shelve.PLCID, shelve.RuleID, username)
+		}
+	}
+
+	broadcastAlarms(changed)
+	broadcastAlarmAudits(entries)
+	for lockID, lockShelves := range shelvesByLock(result) {
+		broadcastShelves(lockID, lockShelves)
+	}
+	return result
+}
+
+// shelveLoop devolve à lista as regras cujo prazo de retirada acabou
+func (e *AlarmEngine) shelveLoop() {
+	ticker := time.NewTicker(alarmShelveCheckInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		var expired []string
+		e.mutex.Lock()
+		for key, shelve := range e.shelves {
+			if !now.Before(shelve.ExpiresAt) {
+				expired = append(expired, key)
+			}
+		}
+		e.mutex.Unlock()
+
+		if len(expired) > 0 {
+			sort.Strings(expired)
+			e.unshelve(expired, "", now)
+		}
+	}
+}
+
+// Shelves retorna as retiradas em vigor da eclusa, das que vencem primeiro às últimas
+func (e *AlarmEngine) Shelves(lockID string) []models.AlarmShelve {
+	e.mutex.Lock()
+	shelves := make([]models.AlarmShelve, 0, len(e.shelves))
+	for _, shelve := range e.shelves {
+		if shelve.PLCID == lockID {
+			shelves = append(shelves, *shelve)
+		}
+	}
+	e.mutex.Unlock()
+
+	sort.Slice(shelves, func(i, j int) bool { return shelves[i].ExpiresAt.Before(shelves[j].ExpiresAt) })
+	return shelves
+}
+
+// broadcastShelves avisa os clientes das retiradas alteradas de uma eclusa
+func broadcastShelves(lockID string, shelves []models.AlarmShelve) {
+	if len(shelves) == 0 {
+		return
+	}
+	GetWebSocketHub().BroadcastMessage(map[string]interface{}{
+		"type":    "alarm_shelves",
+		"lock_id": lockID,
+		"shelves": shelves,
+	})
+}
+
+// shelvesByLock agrupa as retiradas por eclusa
+func shelvesByLock(shelves []models.AlarmShelve) map[string][]models.AlarmShelve {
+	grouped := make(map[string][]models.AlarmShelve)
+	for _, shelve := range shelves {
+		grouped[shelve.PLCID] = append(grouped[shelve.PLCID], shelve)
+	}
+	return grouped
+}
+
+// sortedKeys retorna as chaves do mapa em ordem
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,204 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"backend-go/models"
+	"gorm.io/gorm"
+)
+
+// Exportação em CSV ou XLSX das ocorrências de alarme e da sequência de eventos dos tags
+// digitais, com os mesmos formatos e idiomas da exportação do histórico.
+
+// Tipos de evento exportáveis
+const (
+	ExportAlarms    = "alarms"
+	ExportTagEvents = "events"
+)
+
+// EventExport descreve uma exportação de alarmes ou de eventos de uma eclusa
+type EventExport struct {
+	LockID   string
+	Kind     string // alarms ou events
+	From     time.Time
+	To       time.Time
+	Tags     []string // filtro opcional por tag
+	Priority string   // alarmes: filtro opcional por prioridade
+	State    string   // alarmes: filtro opcional por estado
+	Format   string   // csv ou xlsx
+	Locale   ExportLocale
+}
+
+// Validate verifica tipo, formato, período e, no XLSX, o número de linhas antes de
+// começar a resposta
+func (e EventExport) Validate() error {
+	if e.Kind != ExportAlarms && e.Kind != ExportTagEvents {
+		return fmt.Errorf("%w: tipo de evento inválido: %s", ErrInvalidHistoryQuery, e.Kind)
+	}
+	if e.Format != ExportCSV && e.Format != ExportXLSX {
+		return fmt.Errorf("%w: formato inválido: %s (use csv ou xlsx)", ErrInvalidHistoryQuery, e.Format)
+	}
+	if !e.From.Before(e.To) {
+		return fmt.Errorf("%w: from deve ser anterior a to", ErrInvalidHistoryQuery)
+	}
+	if tagDB() == nil {
+		return ErrHistoryUnavailable
+	}
+
+	if e.Format == ExportXLSX {
+		var rows int64
+		if err := e.query().Count(&rows).Error; err != nil {
+			return err
+		}
+		if err := checkXLSXRows(rows); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// query monta a consulta do período com os filtros
+func (e EventExport) query() *gorm.DB {
+	db := tagDB()
+	if e.Kind == ExportAlarms {
+		query := db.Model(&models.Alarm{}).
+			Where("plc_id = ? AND activated_at >= ? AND activated_at < ?", e.LockID, e.From, e.To)
+		if len(e.Tags) > 0 {
+			query = query.Where("tag_name IN ?", e.Tags)
+		}
+		if e.Priority != "" {
+			query = query.Where("priority = ?", e.Priority)
+		}
+		if e.State != "" {
+			query = query.Where("state = ?", e.State)
+		}
+		return query
+	}
+
+	query := db.Model(&models.TagEvent{}).
+		Where("plc_id = ? AND timestamp >= ? AND timestamp < ?", e.LockID, e.From, e.To)
+	if len(e.Tags) > 0 {
+		query = query.Where("tag_name IN ?", e.Tags)
+	}
+	return query
+}
+
+// ContentType retorna o tipo MIME do arquivo
+func (e EventExport) ContentType() string {
+	return exportContentType(e.Format)
+}
+
+// FileName sugere o nome do arquivo (tipo, eclusa e período)
+func (e EventExport) FileName() string {
+	kind := "eventos"
+	if e.Kind == ExportAlarms {
+		kind = "alarmes"
+	}
+	return fmt.Sprintf("%s_%s_%s_%s.%s", kind, e.LockID,
+		e.From.Format("20060102-1504"), e.To.Format("20060102-1504"), e.Format)
+}
+
+// WriteEventExport escreve a exportação em w, em ordem cronológica. Como no histórico,
+// Validate deve ser chamado antes.
+func WriteEventExport(w io.Writer, e EventExport) error {
+	sheet := "Eventos"
+	if e.Kind == ExportAlarms {
+		sheet = "Alarmes"
+	}
+	table, flush, err := newExportTable(w, e.Format, sheet, e.Locale)
+	if err != nil {
+		return err
+	}
+
+	connector, _ := GetPLCManager().Connector(e.LockID)
+	if e.Kind == ExportAlarms {
+		err = writeAlarmExport(table, e, connector, flush)
+	} else {
+		err = writeTagEventExport(table, e, connector, flush)
+	}
+	if err != nil {
+		return err
+	}
+	return table.Close()
+}
+
+// writeTagEventExport escreve uma linha por transição de tag
+func writeTagEventExport(table tableWriter, e EventExport, connector *S7PLCConnector, flush func() error) error {
+	labels := e.Locale.Columns
+	header := []interface{}{e.Locale.TimeHeader, labels["tag"], labels["description"], labels["old"], labels["new"], labels["quality"]}
+	if err := table.WriteRow(header, true); err != nil {
+		return err
+	}
+
+	rows, err := e.query().Order("timestamp, id").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	written := 0
+	for rows.Next() {
+		var event models.TagEvent
+		if err := tagDB().ScanRows(rows, &event); err != nil {
+			return err
+		}
+		row := []interface{}{event.Timestamp.Local(), event.TagName, tagHeader(connector, event.TagName),
+			event.OldText, event.NewText, event.Quality}
+		if err := table.WriteRow(row, false); err != nil {
+			return err
+		}
+		if written++; written%exportFlushRows == 0 {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return rows.Err()
+}
+
+// writeAlarmExport escreve uma linha por ocorrência de alarme
+func writeAlarmExport(table tableWriter, e EventExport, connector *S7PLCConnector, flush func() error) error {
+	labels := e.Locale.Columns
+	header := []interface{}{labels["activated"], labels["cleared"], labels["acked"], labels["acked_by"],
+		labels["tag"], labels["description"], labels["type"], labels["priority"], labels["message"],
+		labels["state"], labels["value"], labels["limit"]}
+	if err := table.WriteRow(header, true); err != nil {
+		return err
+	}
+
+	rows, err := e.query().Order("activated_at, id").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	written := 0
+	for rows.Next() {
+		var alarm models.Alarm
+		if err := tagDB().ScanRows(rows, &alarm); err != nil {
+			return err
+		}
+		row := []interface{}{alarm.ActivatedAt.Local(), exportTime(alarm.ClearedAt), exportTime(alarm.AckedAt), alarm.AckedBy,
+			alarm.TagName, tagHeader(connector, alarm.TagName), alarm.Type, alarm.Priority, alarm.Message,
+			alarm.State, alarm.Value, alarm.Limit}
+		if err := table.WriteRow(row, false); err != nil {
+			return err
+		}
+		if written++; written%exportFlushRows == 0 {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return rows.Err()
+}
+
+// exportTime converte um horário opcional numa célula (vazia quando nil)
+func exportTime(at *time.Time) interface{} {
+	if at == nil {
+		return nil
+	}
+	return at.Local()
+}
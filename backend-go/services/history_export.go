@@ -0,0 +1,425 @@
+package services
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Exportação do histórico em CSV ou XLSX. As linhas são escritas à medida que saem do
+// banco; os cabeçalhos trazem a descrição e a unidade de cada tag e os números seguem o
+// idioma pedido (em português: vírgula decimal e ";" como separador do CSV).
+
+// Formatos de exportação
+const (
+	ExportCSV  = "csv"
+	ExportXLSX = "xlsx"
+)
+
+// Linhas escritas entre cada envio parcial ao cliente
+const exportFlushRows = 1000
+
+// Estatísticas exportáveis do modo agregado
+var exportStats = []string{"min", "max", "avg", "first", "last", "count"}
+
+// ExportLocale define separadores, formatos de data e rótulos do arquivo
+type ExportLocale struct {
+	Comma      rune   // separador de campos do CSV
+	Decimal    string // separador decimal do CSV
+	TimeLayout string // data/hora no CSV
+	XLSXDate   string // formato das células de data/hora no XLSX
+	TimeHeader string
+	StatLabels map[string]string
+	Columns    map[string]string // cabeçalhos da exportação de alarmes e eventos
+}
+
+var (
+	exportLocalePT = ExportLocale{
+		Comma:      ';',
+		Decimal:    ",",
+		TimeLayout: "02/01/2006 15:04:05",
+		XLSXDate:   "dd/mm/yyyy hh:mm:ss",
+		TimeHeader: "Data/hora",
+		StatLabels: map[string]string{
+			"min": "mínimo", "max": "máximo", "avg": "média",
+			"first": "primeiro", "last": "último", "count": "leituras",
+		},
+		Columns: map[string]string{
+			"tag": "Tag", "description": "Descrição", "old": "Valor anterior", "new": "Valor novo",
+			"quality": "Qualidade", "activated": "Ativado em", "cleared": "Normalizado em",
+			"acked": "Reconhecido em", "acked_by": "Reconhecido por", "type": "Tipo",
+			"priority": "Prioridade", "message": "Mensagem", "state": "Estado",
+			"value": "Valor", "limit": "Limite",
+		},
+	}
+	exportLocaleEN = ExportLocale{
+		Comma:      ',',
+		Decimal:    ".",
+		TimeLayout: "2006-01-02 15:04:05",
+		XLSXDate:   "yyyy-mm-dd hh:mm:ss",
+		TimeHeader: "Time",
+		StatLabels: map[string]string{
+			"min": "min", "max": "max", "avg": "avg",
+			"first": "first", "last": "last", "count": "count",
+		},
+		Columns: map[string]string{
+			"tag": "Tag", "description": "Description", "old": "Old value", "new": "New value",
+			"quality": "Quality", "activated": "Activated", "cleared": "Cleared",
+			"acked": "Acknowledged", "acked_by": "Acknowledged by", "type": "Type",
+			"priority": "Priority", "message": "Message", "state": "State",
+			"value": "Value", "limit": "Limit",
+		},
+	}
+)
+
+// ExportLocaleFor escolhe o idioma pelo código ("pt", "pt-BR", "en"...); o padrão é português
+func ExportLocaleFor(language string) ExportLocale {
+	if strings.HasPrefix(strings.ToLower(strings.TrimSpace(language)), "en") {
+		return exportLocaleEN
+	}
+	return exportLocalePT
+}
+
+// tableWriter é o destino das linhas exportadas (CSV ou XLSX)
+type tableWriter interface {
+	WriteRow(cells []interface{}, header bool) error
+	Flush() error
+	Close() error
+}
+
+// csvTableWriter escreve as linhas em CSV com os separadores do idioma
+type csvTableWriter struct {
+	csv    *csv.Writer
+	locale ExportLocale
+}
+
+// newCSVTableWriter começa o CSV com BOM, para o Excel reconhecer UTF-8
+func newCSVTableWriter(w io.Writer, locale ExportLocale) (*csvTableWriter, error) {
+	if _, err := io.WriteString(w, "\ufeff"); err != nil {
+		return nil, err
+	}
+	writer := csv.NewWriter(w)
+	writer.Comma = locale.Comma
+	return &csvTableWriter{csv: writer, locale: locale}, nil
+}
+
+// formatCell converte uma célula em texto no formato do idioma
+func (c *csvTableWriter) formatCell(cell interface{}) string {
+	switch v := cell.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		return v.Format(c.locale.TimeLayout)
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return ""
+		}
+		return strings.Replace(strconv.FormatFloat(v, 'f', -1, 64), ".", c.locale.Decimal, 1)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	}
+	return fmt.Sprint(cell)
+}
+
+func (c *csvTableWriter) WriteRow(cells []interface{}, header bool) error {
+	record := make([]string, len(cells))
+	for i, cell := range cells {
+		record[i] = c.formatCell(cell)
+	}
+	return c.csv.Write(record)
+}
+
+func (c *csvTableWriter) Flush() error {
+	c.csv.Flush()
+	return c.csv.Error()
+}
+
+func (c *csvTableWriter) Close() error {
+	return c.Flush()
+}
+
+// HistoryExport descreve uma exportação do histórico
+type HistoryExport struct {
+	Query  HistoryQuery
+	Format string   // csv ou xlsx
+	Raw    bool     // pontos brutos; senão agregados por Query.Bucket
+	Stats  []string // estatísticas do modo agregado (padrão avg, min, max)
+	Locale ExportLocale
+}
+
+// Validate verifica formato, estatísticas e a consulta antes de começar a resposta
+func (e *HistoryExport) Validate() error {
+	if err := e.Query.validate(); err != nil {
+		return err
+	}
+	if !e.Raw {
+		if err := e.Query.validateBuckets(); err != nil {
+			return err
+		}
+	}
+	if e.Format != ExportCSV && e.Format != ExportXLSX {
+		return fmt.Errorf("%w: formato inválido: %s (use csv ou xlsx)", ErrInvalidHistoryQuery, e.Format)
+	}
+	if len(e.Stats) == 0 {
+		e.Stats = []string{"avg", "min", "max"}
+	}
+	for _, stat := range e.Stats {
+		if _, ok := exportLocalePT.StatLabels[stat]; !ok {
+			return fmt.Errorf("%w: estatística inválida: %s (use %s)", ErrInvalidHistoryQuery, stat, strings.Join(exportStats, ", "))
+		}
+	}
+	if tagDB() == nil {
+		return ErrHistoryUnavailable
+	}
+
+	// No modo agregado o número de intervalos já é limitado; no bruto, uma linha por instante
+	if e.Format == ExportXLSX && e.Raw {
+		var rows int64
+		err := tagDB().Raw(`SELECT COUNT(DISTINCT "timestamp") FROM tag_histories
+			WHERE plc_id = ? AND tag_name IN ? AND "timestamp" >= ? AND "timestamp" < ?`,
+			e.Query.LockID, e.Query.Tags, e.Query.From, e.Query.To).Scan(&rows).Error
+		if err != nil {
+			return err
+		}
+		if err := checkXLSXRows(rows); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkXLSXRows recusa exportações XLSX com mais linhas (além do cabeçalho) que a planilha aceita
+func checkXLSXRows(rows int64) error {
+	if rows+1 > xlsxMaxRows {
+		return fmt.Errorf("%w: período com %d linhas excede o limite do XLSX (%d): reduza o período ou use csv",
+			ErrInvalidHistoryQuery, rows, xlsxMaxRows-1)
+	}
+	return nil
+}
+
+// ContentType retorna o tipo MIME do arquivo
+func (e HistoryExport) ContentType() string {
+	return exportContentType(e.Format)
+}
+
+func exportContentType(format string) string {
+	if format == ExportXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// FileName sugere o nome do arquivo (eclusa, modo e período)
+func (e HistoryExport) FileName() string {
+	mode := "agregado"
+	if e.Raw {
+		mode = "bruto"
+	}
+	return fmt.Sprintf("historico_%s_%s_%s_%s.%s", e.Query.LockID, mode,
+		e.Query.From.Format("20060102-1504"), e.Query.To.Format("20060102-1504"), e.Format)
+}
+
+// tagHeader monta o cabeçalho de um tag: descrição (ou nome) e unidade
+func tagHeader(connector *S7PLCConnector, name string) string {
+	header := name
+	if connector != nil {
+		if tag, ok := connector.Tag(name); ok {
+			if tag.Description != "" {
+				header = tag.Description
+			}
+			if tag.Unit != "" {
+				header += " (" + tag.Unit + ")"
+			}
+		}
+	}
+	return header
+}
+
+// WriteHistoryExport escreve a exportação em w. Erros depois do início da escrita só podem
+// truncar o arquivo, por isso Validate deve ser chamado antes.
+func WriteHistoryExport(w io.Writer, e HistoryExport) error {
+	table, flush, err := newExportTable(w, e.Format, "Histórico", e.Locale)
+	if err != nil {
+		return err
+	}
+
+	connector, _ := GetPLCManager().Connector(e.Query.LockID)
+	if e.Raw {
+		err = writeRawExport(table, e, connector, flush)
+	} else {
+		err = writeBucketExport(table, e, connector, flush)
+	}
+	if err != nil {
+		return err
+	}
+	return table.Close()
+}
+
+// newExportTable abre o arquivo no formato pedido e devolve a função que envia ao
+// cliente o que já foi escrito
+func newExportTable(w io.Writer, format string, sheetName string, locale ExportLocale) (tableWriter, func() error, error) {
+	var table tableWriter
+	var err error
+	if format == ExportXLSX {
+		table, err = newXLSXWriter(w, sheetName, locale.XLSXDate)
+	} else {
+		table, err = newCSVTableWriter(w, locale)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	flusher, _ := w.(http.Flusher)
+	flush := func() error {
+		if err := table.Flush(); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	}
+	return table, flush, nil
+}
+
+// writeRawExport escreve uma coluna por tag e uma linha por instante de leitura.
+// Tags lidos na mesma varredura caem na mesma linha; os demais ficam vazios.
+func writeRawExport(table tableWriter, e HistoryExport, connector *S7PLCConnector, flush func() error) error {
+	header := []interface{}{e.Locale.TimeHeader}
+	column := make(map[string]int, len(e.Query.Tags))
+	for i, name := range e.Query.Tags {
+		header = append(header, tagHeader(connector, name))
+		column[name] = i + 1
+	}
+	if err := table.WriteRow(header, true); err != nil {
+		return err
+	}
+
+	rows, err := tagDB().Raw(`SELECT "timestamp", tag_name, value FROM tag_histories
+		WHERE plc_id = @lock AND tag_name IN @tags AND "timestamp" >= @from AND "timestamp" < @to
+		ORDER BY "timestamp"`,
+		map[string]interface{}{"lock": e.Query.LockID, "tags": e.Query.Tags, "from": e.Query.From, "to": e.Query.To},
+	).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var current []interface{}
+	written := 0
+	writeCurrent := func() error {
+		if current == nil {
+			return nil
+		}
+		if err := table.WriteRow(current, false); err != nil {
+			return err
+		}
+		written++
+		if written%exportFlushRows == 0 {
+			return flush()
+		}
+		return nil
+	}
+
+	for rows.Next() {
+		var at time.Time
+		var name string
+		var value float64
+		if err := rows.Scan(&at, &name, &value); err != nil {
+			return err
+		}
+		at = at.Local()
+
+		if current == nil || !current[0].(time.Time).Equal(at) {
+			if err := writeCurrent(); err != nil {
+				return err
+			}
+			current = make([]interface{}, len(header))
+			current[0] = at
+		}
+		current[column[name]] = value
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return writeCurrent()
+}
+
+// writeBucketExport escreve uma linha por intervalo e, para cada tag, uma coluna por estatística
+func writeBucketExport(table tableWriter, e HistoryExport, connector *S7PLCConnector, flush func() error) error {
+	data, err := QueryHistoryBuckets(e.Query)
+	if err != nil {
+		return err
+	}
+
+	header := []interface{}{e.Locale.TimeHeader}
+	for _, name := range e.Query.Tags {
+		base := tagHeader(connector, name)
+		for _, stat := range e.Stats {
+			header = append(header, base+" - "+e.Locale.StatLabels[stat])
+		}
+	}
+	if err := table.WriteRow(header, true); err != nil {
+		return err
+	}
+
+	// Juntar os intervalos de todos os tags pela hora de início
+	rows := make(map[int64][]interface{})
+	for i, name := range e.Query.Tags {
+		for _, bucket := range data[name] {
+			key := bucket.Time.UnixNano()
+			row, ok := rows[key]
+			if !ok {
+				row = make([]interface{}, len(header))
+				row[0] = bucket.Time.Local()
+				rows[key] = row
+			}
+			for j, stat := range e.Stats {
+				row[1+i*len(e.Stats)+j] = bucketStat(bucket, stat)
+			}
+		}
+	}
+
+	keys := make([]int64, 0, len(rows))
+	for key := range rows {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	for i, key := range keys {
+		if err := table.WriteRow(rows[key], false); err != nil {
+			return err
+		}
+		if (i+1)%exportFlushRows == 0 {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// bucketStat retorna uma estatística do intervalo
+func bucketStat(bucket HistoryBucket, stat string) interface{} {
+	switch stat {
+	case "min":
+		return bucket.Min
+	case "max":
+		return bucket.Max
+	case "first":
+		return bucket.First
+	case "last":
+		return bucket.Last
+	case "count":
+		return bucket.Count
+	}
+	return bucket.Avg
+}
@@ -0,0 +1,31 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestExportNonFiniteCells(t *testing.T) {
+	csvWriter := &csvTableWriter{locale: exportLocalePT}
+	for _, value := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		if got := csvWriter.formatCell(value); got != "" {
+			t.Errorf("CSV: %v virou %q, esperada célula vazia", value, got)
+		}
+	}
+	if got := csvWriter.formatCell(1.5); got != "1,5" {
+		t.Errorf("CSV: obtido %q, esperado 1,5", got)
+	}
+
+	var sheet bytes.Buffer
+	x := &xlsxWriter{sheet: bufio.NewWriter(&sheet)}
+	if err := x.WriteRow([]interface{}{math.NaN(), 2.5, math.Inf(-1)}, false); err != nil {
+		t.Fatal(err)
+	}
+	x.sheet.Flush()
+	want := `<row r="1"><c r="B1"><v>2.5</v></c></row>`
+	if got := sheet.String(); got != want {
+		t.Errorf("XLSX: obtido %s, esperado %s", got, want)
+	}
+}
@@ -0,0 +1,522 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"backend-go/models"
+)
+
+// Ciclos de eclusagem: uma máquina de estados por eclusa, alimentada pelos níveis da
+// câmara, de montante e de jusante, pela posição das portas e pelos semáforos verdes.
+// O lado com que a câmara estava nivelada ao iniciar define a porta de entrada e o
+// sentido, confirmado pelo lado com que ela nivela depois; o ciclo termina quando a
+// porta do outro lado fecha depois de ter aberto totalmente.
+
+const (
+	defaultLockageLevelTolerance = 1.0  // unidade dos níveis
+	defaultLockageDoorClosed     = 2.0  // posição da porta (%)
+	defaultLockageDoorOpen       = 95.0 // posição da porta (%)
+	defaultLockageMaxCycleMin    = 120
+
+	lockageSaveQueue     = 100
+	lockageMemoryCycles  = 200 // ciclos mantidos na memória quando não há banco
+	lockageEntry         = "entry"
+	lockageExit          = "exit"
+	lockagePhaseOpening  = "_door_opening"
+	lockagePhaseClosing  = "_door_closing"
+	lockagePhasePrepare  = "preparation"
+	lockagePhaseFilling  = "filling"
+	lockagePhaseEmptying = "emptying"
+)
+
+// Lados da eclusa
+const (
+	lockageUpstream   = "upstream"
+	lockageDownstream = "downstream"
+)
+
+// LockageConfig liga a máquina de estados da eclusagem aos tags da eclusa
+type LockageConfig struct {
+	ChamberLevel    string   `json:"chamber_level"`
+	UpstreamLevel   string   `json:"upstream_level"`
+	DownstreamLevel string   `json:"downstream_level"`
+	UpstreamDoor    string   `json:"upstream_door"` // posição 0 (fechada) a 100 (aberta)
+	DownstreamDoor  string   `json:"downstream_door"`
+	Signals         []string `json:"signals,omitempty"` // semáforos verdes: acender com a eclusa parada inicia o ciclo
+
+	LevelTolerance  float64 `json:"level_tolerance,omitempty"`   // câmara nivelada com um lado (padrão 1)
+	DoorClosedBelow float64 `json:"door_closed_below,omitempty"` // porta fechada até esta posição (padrão 2)
+	DoorOpenAbove   float64 `json:"door_open_above,omitempty"`   // porta aberta a partir desta posição (padrão 95)
+	MaxCycleMinutes int     `json:"max_cycle_minutes,omitempty"` // acima disso o ciclo é interrompido (padrão 120)
+}
+
+func (c LockageConfig) levelTolerance() float64 {
+	if c.LevelTolerance > 0 {
+		return c.LevelTolerance
+	}
+	return defaultLockageLevelTolerance
+}
+
+func (c LockageConfig) doorClosedBelow() float64 {
+	if c.DoorClosedBelow > 0 {
+		return c.DoorClosedBelow
+	}
+	return defaultLockageDoorClosed
+}
+
+func (c LockageConfig) doorOpenAbove() float64 {
+	if c.DoorOpenAbove > 0 {
+		return c.DoorOpenAbove
+	}
+	return defaultLockageDoorOpen
+}
+
+func (c LockageConfig) maxCycle() time.Duration {
+	if c.MaxCycleMinutes > 0 {
+		return time.Duration(c.MaxCycleMinutes) * time.Minute
+	}
+	return defaultLockageMaxCycleMin * time.Minute
+}
+
+// numericTags são os tags de nível e de porta
+func (c LockageConfig) numericTags() []string {
+	return []string{c.ChamberLevel, c.UpstreamLevel, c.DownstreamLevel, c.UpstreamDoor, c.DownstreamDoor}
+}
+
+// validateLockage verifica os tags e limites do bloco lockage da eclusa
+func validateLockage(conn PLCConnection) error {
+	cfg := conn.Lockage
+	if cfg == nil {
+		return nil
+	}
+
+	for _, name := range cfg.numericTags() {
+		if name == "" {
+			return fmt.Errorf("lockage: chamber_level, upstream_level, downstream_level, upstream_door e downstream_door são obrigatórios")
+		}
+		tag, ok := conn.Tags[name]
+		if !ok {
+			return fmt.Errorf("lockage: tag %s não existe", name)
+		}
+		if strings.EqualFold(tag.Type, "bool") {
+			return fmt.Errorf("lockage: tag %s deve ser numérico", name)
+		}
+	}
+	for _, name := range cfg.Signals {
+		tag, ok := conn.Tags[name]
+		if !ok {
+			return fmt.Errorf("lockage: semáforo %s não existe", name)
+		}
+		if !strings.EqualFold(tag.Type, "bool") {
+			return fmt.Errorf("lockage: semáforo %s deve ser bool", name)
+		}
+	}
+
+	if cfg.LevelTolerance < 0 || cfg.DoorClosedBelow < 0 || cfg.DoorOpenAbove < 0 || cfg.MaxCycleMinutes < 0 {
+		return fmt.Errorf("lockage: level_tolerance, door_closed_below, door_open_above e max_cycle_minutes não podem ser negativos")
+	}
+	if cfg.doorClosedBelow() >= cfg.doorOpenAbove() {
+		return fmt.Errorf("lockage: door_closed_below (%v) deve ser menor que door_open_above (%v)", cfg.doorClosedBelow(), cfg.doorOpenAbove())
+	}
+	return nil
+}
+
+// lockageReading são os valores usados numa avaliação
+type lockageReading struct {
+	Chamber, Upstream, Downstream float64
+	UpstreamDoor, DownstreamDoor  float64
+	Signal                        bool // algum semáforo verde aceso
+}
+
+// side retorna o lado com que a câmara está nivelada (vazio durante o enchimento/esvaziamento)
+func (r lockageReading) side(tolerance float64) string {
+	atUpstream := math.Abs(r.Chamber-r.Upstream) <= tolerance
+	atDownstream := math.Abs(r.Chamber-r.Downstream) <= tolerance
+	switch {
+	case atUpstream && atDownstream:
+		// Montante e jusante no mesmo nível: vale o lado mais próximo
+		if math.Abs(r.Chamber-r.Upstream) <= math.Abs(r.Chamber-r.Downstream) {
+			return lockageUpstream
+		}
+		return lockageDownstream
+	case atUpstream:
+		return lockageUpstream
+	case atDownstream:
+		return lockageDownstream
+	}
+	return ""
+}
+
+// Estado de uma porta
+const (
+	doorClosed = iota
+	doorMoving
+	doorOpen
+)
+
+func (c LockageConfig) doorState(position float64) int {
+	switch {
+	case position <= c.doorClosedBelow():
+		return doorClosed
+	case position >= c.doorOpenAbove():
+		return doorOpen
+	}
+	return doorMoving
+}
+
+// lockageTracker é o estado da máquina de uma eclusa entre avaliações
+type lockageTracker struct {
+	ready      bool   // já viu as duas portas fechadas com a câmara nivelada
+	lastSide   string // último lado com que a câmara esteve nivelada
+	signal     bool
+	cycle      *models.LockageCycle
+	startSide  string // lado da câmara ao iniciar o ciclo
+	signaled   bool   // o ciclo começou (ou passou) por um semáforo
+	entry      string // porta de entrada: a do lado de partida ou a que já estava aberta
+	doorMoved  bool   // alguma porta saiu de fechada neste ciclo
+	doorOpened bool   // a porta em movimento já abriu totalmente nesta passagem
+	exitOpened bool   // a porta de saída abriu totalmente
+}
+
+// LockageEngine detecta e grava os ciclos de eclusagem de todas as eclusas
+type LockageEngine struct {
+	mutex    sync.Mutex
+	trackers map[string]*lockageTracker
+	saves    chan models.LockageCycle
+
+	// Ciclos encerrados quando o banco está indisponível
+	memory   []models.LockageCycle
+	memoryID uint
+}
+
+var (
+	globalLockageEngine *LockageEngine
+	lockageEngineOnce   sync.Once
+)
+
+// GetLockageEngine retorna instância singleton do detector de eclusagens
+func GetLockageEngine() *LockageEngine {
+	lockageEngineOnce.Do(func() {
+		globalLockageEngine = &LockageEngine{
+			trackers: make(map[string]*lockageTracker),
+		}
+		if tagDB() == nil {
+			log.Printf("⚠️ Banco indisponível: ciclos de eclusagem ficam apenas na memória")
+			return
+		}
+		globalLockageEngine.saves = make(chan models.LockageCycle, lockageSaveQueue)
+		go globalLockageEngine.saveLoop()
+		log.Printf("⛴️ Detecção de ciclos de eclusagem iniciada")
+	})
+	return globalLockageEngine
+}
+
+// saveLoop grava os ciclos encerrados com as suas fases
+func (e *LockageEngine) saveLoop() {
+	for cycle := range e.saves {
+		if err := tagDB().Create(&cycle).Error; err != nil {
+			log.Printf("❌ [%s] Erro ao gravar ciclo de eclusagem iniciado em %s: %v", cycle.PLCID, cycle.StartedAt.Format(time.RFC3339), err)
+		}
+	}
+}
+
+// store grava um ciclo encerrado (banco) ou o guarda na memória. Chamado com e.mutex.
+func (e *LockageEngine) store(cycle models.LockageCycle) {
+	// A gravação preenche os IDs das fases: usa uma cópia própria
+	cycle.Phases = append([]models.LockagePhase(nil), cycle.Phases...)
+	if e.saves != nil {
+		select {
+		case e.saves <- cycle:
+		default:
+			log.Printf("⚠️ [%s] Fila de gravação de eclusagens cheia: ciclo não gravado", cycle.PLCID)
+		}
+		return
+	}
+
+	e.memoryID++
+	cycle.ID = e.memoryID
+	for i := range cycle.Phases {
+		cycle.Phases[i].CycleID = cycle.ID
+	}
+	e.memory = append(e.memory, cycle)
+	if len(e.memory) > lockageMemoryCycles {
+		e.memory = e.memory[len(e.memory)-lockageMemoryCycles:]
+	}
+}
+
+// evaluate avança a máquina de estados da eclusa com uma leitura. Retorna true quando o
+// ciclo em andamento mudou de fase, começou, terminou ou foi descartado, junto com o
+// ciclo a enviar aos clientes (nil quando descartado). Um ciclo interrompido exige ver
+// a eclusa parada de novo antes do próximo.
+func (e *LockageEngine) evaluate(lockID string, cfg LockageConfig, r lockageReading, now time.Time) (*models.LockageCycle, bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	t := e.trackers[lockID]
+	if t == nil {
+		t = &lockageTracker{}
+		e.trackers[lockID] = t
+	}
+
+	side := r.side(cfg.levelTolerance())
+	signalRise := r.Signal && !t.signal
+	t.signal = r.Signal
+	upDoor, downDoor := cfg.doorState(r.UpstreamDoor), cfg.doorState(r.DownstreamDoor)
+
+	// Só começa a contar depois de ver a eclusa parada, para não gravar meio ciclo ao iniciar
+	if !t.ready {
+		if upDoor != doorClosed || downDoor != doorClosed || side == "" {
+			return nil, false
+		}
+		t.ready = true
+		t.lastSide = side
+	}
+
+	if t.cycle != nil && now.Sub(t.cycle.StartedAt) > cfg.maxCycle() {
+		aborted := e.finish(lockID, t, models.LockageAborted, r, now)
+		t.ready = false
+		return &aborted, true
+	}
+
+	var door string
+	var state int
+	switch {
+	case upDoor != doorClosed && downDoor != doorClosed:
+		// As duas portas abertas não é uma situação de eclusagem: mantém a fase atual
+		return nil, false
+	case upDoor != doorClosed:
+		door, state = lockageUpstream, upDoor
+	case downDoor != doorClosed:
+		door, state = lockageDownstream, downDoor
+	}
+
+	var phase string
+	if door != "" {
+		if t.cycle == nil {
+			e.start(lockID, t, r, now)
+			// A porta já aberta ao iniciar é a de entrada
+			t.setEntry(door)
+		}
+		t.doorMoved = true
+
+		role := lockageEntry
+		if door != t.entry {
+			role = lockageExit
+		}
+		switch {
+		case state == doorOpen:
+			t.doorOpened = true
+			if role == lockageExit {
+				t.exitOpened = true
+			}
+			phase = role
+		case t.doorOpened:
+			phase = role + lockagePhaseClosing
+		default:
+			phase = role + lockagePhaseOpening
+		}
+	} else {
+		t.doorOpened = false
+		if side != "" {
+			t.lastSide = side
+		}
+		if t.cycle != nil && side != "" && side != t.startSide {
+			// Câmara nivelada com o outro lado: a entrada foi pelo lado de partida
+			t.setEntry(t.startSide)
+		}
+
+		switch {
+		case t.cycle == nil:
+			// Eclusa parada: a câmara saindo do nível ou um semáforo acendendo começa o ciclo
+			if side != "" && !signalRise {
+				return nil, false
+			}
+			e.start(lockID, t, r, now)
+			t.signaled = signalRise
+			phase = lockagePhasePrepare
+		case t.exitOpened:
+			// A porta de saída fechou: eclusagem concluída
+			completed := e.finish(lockID, t, models.LockageCompleted, r, now)
+			return &completed, true
+		case !t.doorMoved && (side == "" || side == t.startSide):
+			t.signaled = t.signaled || signalRise
+			if side == t.startSide && !t.signaled {
+				// A câmara voltou ao lado de partida sem semáforo nem porta: não era um ciclo
+				log.Printf("⛴️ [%s] Início de eclusagem descartado (câmara voltou ao nível de %s)", lockID, side)
+				t.cycle = nil
+				return nil, true
+			}
+			phase = lockagePhasePrepare
+		case t.cycle.Direction == models.LockageUp:
+			phase = lockagePhaseFilling
+		default:
+			phase = lockagePhaseEmptying
+		}
+	}
+
+	if n := len(t.cycle.Phases); n > 0 && t.cycle.Phases[n-1].Name == phase {
+		return nil, false
+	}
+	nextLockagePhase(t.cycle, phase, r, now)
+	return e.currentLocked(lockID), true
+}
+
+// start abre um ciclo. Chamado com e.mutex.
+func (e *LockageEngine) start(lockID string, t *lockageTracker, r lockageReading, now time.Time) {
+	t.cycle = &models.LockageCycle{
+		PLCID:                lockID,
+		Status:               models.LockageInProgress,
+		StartedAt:            now,
+		StartChamberLevel:    r.Chamber,
+		StartUpstreamLevel:   r.Upstream,
+		StartDownstreamLevel: r.Downstream,
+		CreatedAt:            now,
+	}
+	t.startSide = t.lastSide
+	t.signaled = false
+	t.doorMoved = false
+	t.doorOpened = false
+	t.exitOpened = false
+	// Numa inversão a embarcação entra pela porta de saída do ciclo anterior, do lado de partida
+	t.setEntry(t.startSide)
+	log.Printf("⛴️ [%s] Eclusagem iniciada (câmara em %.2f)", lockID, r.Chamber)
+}
+
+// setEntry define a porta de entrada e o sentido do ciclo: entrar por montante é descer
+func (t *lockageTracker) setEntry(side string) {
+	t.entry = side
+	t.cycle.Direction = models.LockageDown
+	if side == lockageDownstream {
+		t.cycle.Direction = models.LockageUp
+	}
+}
+
+// finish encerra o ciclo com a situação informada e o grava. Chamado com e.mutex.
+func (e *LockageEngine) finish(lockID string, t *lockageTracker, status string, r lockageReading, now time.Time) models.LockageCycle {
+	cycle := t.cycle
+	if n := len(cycle.Phases); n > 0 {
+		endLockagePhase(&cycle.Phases[n-1], r, now)
+	}
+	endedAt := now
+	cycle.EndedAt = &endedAt
+	cycle.DurationS = now.Sub(cycle.StartedAt).Seconds()
+	cycle.Status = status
+	cycle.EndChamberLevel = r.Chamber
+	cycle.EndUpstreamLevel = r.Upstream
+	cycle.EndDownstreamLevel = r.Downstream
+
+	if status == models.LockageCompleted {
+		log.Printf("⛴️ [%s] Eclusagem concluída (%s) em %.0fs, %d fases", lockID, cycle.Direction, cycle.DurationS, len(cycle.Phases))
+	} else {
+		log.Printf("⚠️ [%s] Eclusagem interrompida após %.0fs na fase %s", lockID, cycle.DurationS, cycle.Phases[len(cycle.Phases)-1].Name)
+	}
+	e.store(*cycle)
+	t.cycle = nil
+	return *cycle
+}
+
+// nextLockagePhase encerra a fase atual e abre a próxima com os níveis da transição
+func nextLockagePhase(cycle *models.LockageCycle, name string, r lockageReading, now time.Time) {
+	n := len(cycle.Phases)
+	if n > 0 {
+		endLockagePhase(&cycle.Phases[n-1], r, now)
+	}
+	cycle.Phases = append(cycle.Phases, models.LockagePhase{
+		Seq:                  n + 1,
+		Name:                 name,
+		StartedAt:            now,
+		StartChamberLevel:    r.Chamber,
+		StartUpstreamLevel:   r.Upstream,
+		StartDownstreamLevel: r.Downstream,
+	})
+}
+
+func endLockagePhase(phase *models.LockagePhase, r lockageReading, now time.Time) {
+	endedAt := now
+	phase.EndedAt = &endedAt
+	phase.DurationS = now.Sub(phase.StartedAt).Seconds()
+	phase.EndChamberLevel = r.Chamber
+	phase.EndUpstreamLevel = r.Upstream
+	phase.EndDownstreamLevel = r.Downstream
+}
+
+// Current retorna uma cópia do ciclo em andamento da eclusa (nil se parada)
+func (e *LockageEngine) Current(lockID string) *models.LockageCycle {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.currentLocked(lockID)
+}
+
+func (e *LockageEngine) currentLocked(lockID string) *models.LockageCycle {
+	t := e.trackers[lockID]
+	if t == nil || t.cycle == nil {
+		return nil
+	}
+	cycle := *t.cycle
+	cycle.Phases = append([]models.LockagePhase(nil), t.cycle.Phases...)
+	return &cycle
+}
+
+// broadcastLockage envia o ciclo em andamento ou recém-encerrado (null quando descartado)
+// aos clientes do WebSocket
+func broadcastLockage(lockID string, cycle *models.LockageCycle) {
+	GetWebSocketHub().BroadcastMessage(map[string]interface{}{
+		"type":    "lockage",
+		"lock_id": lockID,
+		"cycle":   cycle,
+	})
+}
+
+// evaluateLockage alimenta a máquina de estados da eclusagem na classe do nível da câmara
+func (s7 *S7PLCConnector) evaluateLockage(class string, now time.Time) {
+	s7.mutex.RLock()
+	lockage := s7.config.Lockage
+	var tagClass string
+	if lockage != nil {
+		tagClass = s7.config.tagScanClass(s7.config.Tags[lockage.ChamberLevel])
+	}
+	s7.mutex.RUnlock()
+
+	if lockage == nil || tagClass != class {
+		return
+	}
+	cfg := *lockage
+
+	numbers := make([]float64, 0, 5)
+	var reading lockageReading
+	s7.currentMutex.RLock()
+	for _, name := range cfg.numericTags() {
+		current, ok := s7.currentValues[name]
+		if !ok || !usableQuality(current.effectiveQuality(now)) {
+			break
+		}
+		number, ok := toFloat64(current.Value)
+		if !ok {
+			break
+		}
+		numbers = append(numbers, number)
+	}
+	for _, name := range cfg.Signals {
+		current := s7.currentValues[name]
+		if on, ok := current.Value.(bool); ok && on && usableQuality(current.effectiveQuality(now)) {
+			reading.Signal = true
+		}
+	}
+	s7.currentMutex.RUnlock()
+
+	// Sem todos os níveis e portas a máquina fica na fase atual
+	if len(numbers) < 5 {
+		return
+	}
+	reading.Chamber, reading.Upstream, reading.Downstream = numbers[0], numbers[1], numbers[2]
+	reading.UpstreamDoor, reading.DownstreamDoor = numbers[3], numbers[4]
+
+	if cycle, changed := GetLockageEngine().evaluate(s7.lockID, cfg, reading, now); changed {
+		broadcastLockage(s7.lockID, cycle)
+	}
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"backend-go/models"
+
+	"gorm.io/gorm"
+)
+
+// Consulta dos ciclos de eclusagem: no banco quando disponível, senão os ciclos
+// encerrados guardados na memória pelo LockageEngine.
+
+// ErrLockageNotFound indica um ciclo que não existe na eclusa
+var ErrLockageNotFound = errors.New("ciclo de eclusagem não encontrado")
+
+// LockageQuery filtra os ciclos de uma eclusa pelo início do ciclo
+type LockageQuery struct {
+	LockID    string
+	From      time.Time // inclusivo
+	To        time.Time // exclusivo
+	Direction string    // up, down ou vazio (todos)
+	Status    string    // completed, aborted ou vazio (todos)
+	Limit     int
+}
+
+// LockageSummary são os indicadores dos ciclos concluídos do período
+type LockageSummary struct {
+	Completed       int64              `json:"completed"`
+	Aborted         int64              `json:"aborted"`
+	Up              int64              `json:"up"`
+	Down            int64              `json:"down"`
+	AvgDurationS    float64            `json:"avg_duration_s"`
+	AvgPhaseS       map[string]float64 `json:"avg_phase_s"`
+	AvgUpDuration   float64            `json:"avg_up_duration_s"`
+	AvgDownDuration float64            `json:"avg_down_duration_s"`
+}
+
+// lockageSummaryRow é a contagem e a duração média de um grupo (situação, sentido)
+type lockageSummaryRow struct {
+	Status       string
+	Direction    string
+	Count        int64
+	AvgDurationS float64
+}
+
+// Cycles lista os ciclos encerrados da consulta, dos mais recentes aos mais antigos, sem as fases
+func (e *LockageEngine) Cycles(q LockageQuery) ([]models.LockageCycle, error) {
+	if db := tagDB(); db != nil {
+		query := db.Where("plc_id = ? AND started_at >= ? AND started_at < ?", q.LockID, q.From, q.To)
+		if q.Direction != "" {
+			query = query.Where("direction = ?", q.Direction)
+		}
+		if q.Status != "" {
+			query = query.Where("status = ?", q.Status)
+		}
+		var cycles []models.LockageCycle
+		err := query.Order("started_at DESC").Limit(q.Limit).Find(&cycles).Error
+		return cycles, err
+	}
+
+	var cycles []models.LockageCycle
+	for _, cycle := range e.memoryCycles(q) {
+		cycle.Phases = nil
+		cycles = append(cycles, cycle)
+		if len(cycles) == q.Limit {
+			break
+		}
+	}
+	return cycles, nil
+}
+
+// memoryCycles filtra os ciclos da memória, dos mais recentes aos mais antigos
+func (e *LockageEngine) memoryCycles(q LockageQuery) []models.LockageCycle {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	var cycles []models.LockageCycle
+	for i := len(e.memory) - 1; i >= 0; i-- {
+		cycle := e.memory[i]
+		if cycle.PLCID != q.LockID || cycle.StartedAt.Before(q.From) || !cycle.StartedAt.Before(q.To) {
+			continue
+		}
+		if (q.Direction != "" && cycle.Direction != q.Direction) || (q.Status != "" && cycle.Status != q.Status) {
+			continue
+		}
+		cycles = append(cycles, cycle)
+	}
+	return cycles
+}
+
+// Cycle retorna um ciclo encerrado com as suas fases
+func (e *LockageEngine) Cycle(lockID string, id uint) (models.LockageCycle, error) {
+	var cycle models.LockageCycle
+	if db := tagDB(); db != nil {
+		result := db.Preload("Phases", func(tx *gorm.DB) *gorm.DB { return tx.Order("seq") }).
+			Where("plc_id = ? AND id = ?", lockID, id).Limit(1).Find(&cycle)
+		if result.Error != nil {
+			return cycle, result.Error
+		}
+		if result.RowsAffected == 0 {
+			return cycle, ErrLockageNotFound
+		}
+		return cycle, nil
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	for _, stored := range e.memory {
+		if stored.PLCID == lockID && stored.ID == id {
+			return stored, nil
+		}
+	}
+	return cycle, ErrLockageNotFound
+}
+
+// Summary calcula os indicadores do período (direction e status da consulta são ignorados)
+func (e *LockageEngine) Summary(q LockageQuery) (LockageSummary, error) {
+	summary := LockageSummary{AvgPhaseS: make(map[string]float64)}
+
+	var rows []lockageSummaryRow
+	phases := make(map[string][]float64) // duração de cada fase nos ciclos concluídos
+	if db := tagDB(); db != nil {
+		err := db.Model(&models.LockageCycle{}).
+			Select("status, direction, COUNT(*) AS count, AVG(duration_s) AS avg_duration_s").
+			Where("plc_id = ? AND started_at >= ? AND started_at < ?", q.LockID, q.From, q.To).
+			Group("status, direction").Scan(&rows).Error
+		if err != nil {
+			return summary, err
+		}
+
+		var phaseRows []struct {
+			Name         string
+			AvgDurationS float64
+		}
+		err = db.Table("lockage_phases").
+			Select("lockage_phases.name, AVG(lockage_phases.duration_s) AS avg_duration_s").
+			Joins("JOIN lockage_cycles ON lockage_cycles.id = lockage_phases.cycle_id").
+			Where("lockage_cycles.plc_id = ? AND lockage_cycles.started_at >= ? AND lockage_cycles.started_at < ? AND lockage_cycles.status = ?",
+				q.LockID, q.From, q.To, models.LockageCompleted).
+			Group("lockage_phases.name").Scan(&phaseRows).Error
+		if err != nil {
+			return summary, err
+		}
+		for _, row := range phaseRows {
+			summary.AvgPhaseS[row.Name] = row.AvgDurationS
+		}
+	} else {
+		q.Direction, q.Status = "", ""
+		groups := make(map[[2]string]*lockageSummaryRow)
+		for _, cycle := range e.memoryCycles(q) {
+			key := [2]string{cycle.Status, cycle.Direction}
+			row := groups[key]
+			if row == nil {
+				row = &lockageSummaryRow{Status: cycle.Status, Direction: cycle.Direction}
+				groups[key] = row
+			}
+			row.AvgDurationS = (row.AvgDurationS*float64(row.Count) + cycle.DurationS) / float64(row.Count+1)
+			row.Count++
+			if cycle.Status == models.LockageCompleted {
+				for _, phase := range cycle.Phases {
+					phases[phase.Name] = append(phases[phase.Name], phase.DurationS)
+				}
+			}
+		}
+		for _, row := range groups {
+			rows = append(rows, *row)
+		}
+		for name, durations := range phases {
+			total := 0.0
+			for _, d := range durations {
+				total += d
+			}
+			summary.AvgPhaseS[name] = total / float64(len(durations))
+		}
+	}
+
+	// Médias ponderadas dos grupos concluídos
+	var total, up, down float64
+	for _, row := range rows {
+		if row.Status != models.LockageCompleted {
+			if row.Status == models.LockageAborted {
+				summary.Aborted += row.Count
+			}
+			continue
+		}
+		summary.Completed += row.Count
+		total += row.AvgDurationS * float64(row.Count)
+		switch row.Direction {
+		case models.LockageUp:
+			summary.Up += row.Count
+			up += row.AvgDurationS * float64(row.Count)
+		case models.LockageDown:
+			summary.Down += row.Count
+			down += row.AvgDurationS * float64(row.Count)
+		}
+	}
+	if summary.Completed > 0 {
+		summary.AvgDurationS = total / float64(summary.Completed)
+	}
+	if summary.Up > 0 {
+		summary.AvgUpDuration = up / float64(summary.Up)
+	}
+	if summary.Down > 0 {
+		summary.AvgDownDuration = down / float64(summary.Down)
+	}
+	return summary, nil
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"backend-go/models"
+)
+
+// Níveis de referência: montante 72, jusante 48
+func lockageAt(chamber, upDoor, downDoor float64, signal bool) lockageReading {
+	return lockageReading{Chamber: chamber, Upstream: 72, Downstream: 48, UpstreamDoor: upDoor, DownstreamDoor: downDoor, Signal: signal}
+}
+
+func newTestLockageEngine() *LockageEngine {
+	return &LockageEngine{trackers: make(map[string]*lockageTracker)}
+}
+
+func TestLockageCycles(t *testing.T) {
+	tests := []struct {
+		name      string
+		readings  []lockageReading
+		status    string
+		direction string
+		phases    []string
+		stored    int // ciclos gravados ao final (padrão 1 quando concluído)
+	}{
+		{
+			name: "descida entrando por montante",
+			readings: []lockageReading{
+				lockageAt(72, 0, 0, false),
+				lockageAt(72, 50, 0, false),
+				lockageAt(72, 100, 0, false),
+				lockageAt(72, 50, 0, false),
+				lockageAt(72, 0, 0, false),
+				lockageAt(60, 0, 0, false),
+				lockageAt(48, 0, 0, false),
+				lockageAt(48, 0, 50, false),
+				lockageAt(48, 0, 100, false),
+				lockageAt(48, 0, 50, false),
+				lockageAt(48, 0, 0, false),
+			},
+			status:    models.LockageCompleted,
+			direction: models.LockageDown,
+			phases: []string{"entry_door_opening", "entry", "entry_door_closing", "emptying",
+				"exit_door_opening", "exit", "exit_door_closing"},
+		},
+		{
+			name: "subida iniciada pelo semáforo",
+			readings: []lockageReading{
+				lockageAt(48, 0, 0, false),
+				lockageAt(48, 0, 0, true),
+				lockageAt(48, 0, 100, false),
+				lockageAt(48, 0, 0, false),
+				lockageAt(60, 0, 0, false),
+				lockageAt(72, 0, 0, false),
+				lockageAt(72, 100, 0, false),
+				lockageAt(72, 0, 0, false),
+			},
+			status:    models.LockageCompleted,
+			direction: models.LockageUp,
+			phases:    []string{"preparation", "entry", "filling", "exit"}, // portas sem posição intermediária
+		},
+		{
+			name: "porta de entrada reabre sem sair: ciclo continua",
+			readings: []lockageReading{
+				lockageAt(72, 0, 0, false),
+				lockageAt(72, 100, 0, false),
+				lockageAt(72, 0, 0, false),
+				lockageAt(72, 100, 0, false),
+				lockageAt(72, 0, 0, false),
+			},
+			direction: models.LockageDown,
+			phases:    []string{"entry", "emptying", "entry", "emptying"},
+		},
+		{
+			name: "inversão: entra pela porta de saída do ciclo anterior",
+			readings: []lockageReading{
+				lockageAt(72, 0, 0, false),
+				lockageAt(72, 100, 0, false),
+				lockageAt(72, 0, 0, false),
+				lockageAt(48, 0, 0, false),
+				lockageAt(48, 0, 100, false),
+				lockageAt(48, 0, 100, false), // embarcação sai e outra entra com a porta aberta
+				lockageAt(48, 0, 0, false),
+				lockageAt(60, 0, 0, false),
+				lockageAt(72, 0, 0, false),
+				lockageAt(72, 100, 0, false),
+				lockageAt(72, 0, 0, false),
+			},
+			status:    models.LockageCompleted,
+			direction: models.LockageUp,
+			phases:    []string{"preparation", "filling", "exit"},
+			stored:    2,
+		},
+	}
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := newTestLockageEngine()
+			var last *models.LockageCycle
+			for i, r := range tt.readings {
+				if cycle, changed := engine.evaluate("eclusa", LockageConfig{}, r, start.Add(time.Duration(i)*time.Minute)); changed {
+					last = cycle
+				}
+			}
+			if last == nil {
+				t.Fatal("nenhum ciclo")
+			}
+			if tt.status == "" {
+				tt.status = models.LockageInProgress
+			}
+			if last.Status != tt.status || last.Direction != tt.direction {
+				t.Errorf("situação %s/%s, esperado %s/%s", last.Status, last.Direction, tt.status, tt.direction)
+			}
+			var phases []string
+			for _, phase := range last.Phases {
+				phases = append(phases, phase.Name)
+			}
+			if !reflect.DeepEqual(phases, tt.phases) {
+				t.Errorf("fases:\nobtido   %v\nesperado %v", phases, tt.phases)
+			}
+			if tt.status == models.LockageCompleted {
+				if tt.stored == 0 {
+					tt.stored = 1
+				}
+				if len(engine.memory) != tt.stored || engine.Current("eclusa") != nil {
+					t.Errorf("ciclo concluído deveria estar gravado e a eclusa parada")
+				}
+				if last.EndedAt == nil || last.DurationS <= 0 {
+					t.Errorf("ciclo concluído sem fim ou duração: %+v", last)
+				}
+			}
+		})
+	}
+}
+
+func TestLockageDiscardAndAbort(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Só começa depois de ver a eclusa parada
+	engine := newTestLockageEngine()
+	if _, changed := engine.evaluate("eclusa", LockageConfig{}, lockageAt(72, 100, 0, false), start); changed {
+		t.Error("porta aberta antes de ver a eclusa parada não deveria iniciar ciclo")
+	}
+
+	// Câmara oscila e volta ao nível de partida sem porta nem semáforo: descartado
+	engine = newTestLockageEngine()
+	engine.evaluate("eclusa", LockageConfig{}, lockageAt(72, 0, 0, false), start)
+	if cycle, changed := engine.evaluate("eclusa", LockageConfig{}, lockageAt(68, 0, 0, false), start.Add(time.Minute)); !changed || cycle == nil {
+		t.Fatal("câmara saindo do nível deveria iniciar a preparação")
+	}
+	cycle, changed := engine.evaluate("eclusa", LockageConfig{}, lockageAt(72, 0, 0, false), start.Add(2*time.Minute))
+	if !changed || cycle != nil || len(engine.memory) != 0 {
+		t.Errorf("ciclo deveria ser descartado sem gravação: %v %v", cycle, changed)
+	}
+
+	// Passou do tempo máximo: interrompido, e o próximo exige a eclusa parada de novo
+	cfg := LockageConfig{MaxCycleMinutes: 30}
+	engine = newTestLockageEngine()
+	engine.evaluate("eclusa", cfg, lockageAt(72, 0, 0, false), start)
+	engine.evaluate("eclusa", cfg, lockageAt(72, 100, 0, false), start.Add(time.Minute))
+	cycle, changed = engine.evaluate("eclusa", cfg, lockageAt(72, 100, 0, false), start.Add(32*time.Minute))
+	if !changed || cycle == nil || cycle.Status != models.LockageAborted || cycle.EndedAt == nil {
+		t.Fatalf("esperado ciclo interrompido, obtido %+v", cycle)
+	}
+	if _, changed := engine.evaluate("eclusa", cfg, lockageAt(72, 50, 0, false), start.Add(33*time.Minute)); changed {
+		t.Error("porta em movimento após interrupção não deveria abrir outro ciclo")
+	}
+	if _, changed := engine.evaluate("eclusa", cfg, lockageAt(60, 0, 0, false), start.Add(34*time.Minute)); changed {
+		t.Error("câmara fora de nível não deveria rearmar a detecção")
+	}
+	engine.evaluate("eclusa", cfg, lockageAt(72, 0, 0, false), start.Add(35*time.Minute))
+	if cycle, _ := engine.evaluate("eclusa", cfg, lockageAt(72, 100, 0, false), start.Add(36*time.Minute)); cycle == nil {
+		t.Error("após ver a eclusa parada um novo ciclo deveria começar")
+	}
+	if len(engine.memory) != 1 {
+		t.Errorf("%d ciclos gravados, esperado 1", len(engine.memory))
+	}
+}
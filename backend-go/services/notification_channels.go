@@ -0,0 +1,258 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Canais de notificação: e-mail por SMTP e webhook HTTP com corpo JSON montado por template.
+
+// Tipos de canal em notifications.json
+const (
+	channelSMTP    = "smtp"
+	channelWebhook = "webhook"
+)
+
+const (
+	defaultChannelTimeout    = 10 * time.Second
+	defaultChannelRatePerMin = 30
+)
+
+// Templates padrão (text/template; "json" serializa um valor para dentro do JSON)
+const (
+	defaultEmailSubject = `[{{.PriorityLabel}}] {{.LockName}}: {{.Alarm.Message}} ({{.EventLabel}})`
+	defaultEmailBody    = `Eclusa: {{.LockName}} ({{.LockID}})
+Alarme: {{.Alarm.Message}}
+Evento: {{.EventLabel}}
+Prioridade: {{.PriorityLabel}}
+Tag: {{.Alarm.TagName}} (regra {{.Alarm.RuleID}})
+Valor: {{.Alarm.Value}} (limite {{.Alarm.Limit}})
+Estado: {{.Alarm.State}}
+Hora: {{.TimeText}}{{if .Alarm.AckedBy}}
+Reconhecido por: {{.Alarm.AckedBy}}{{end}}
+`
+	defaultWebhookBody = `{"event": {{json .Event}}, "lock_id": {{json .LockID}}, "lock_name": {{json .LockName}}, "time": {{json .Time}}, "alarm": {{json .Alarm}}}`
+)
+
+// NotificationChannel é um canal de envio configurado em notifications.json
+type NotificationChannel struct {
+	Type string `json:"type"` // smtp ou webhook
+
+	// SMTP: senha direto em "password" ou, de preferência, no ambiente em "password_env"
+	Host        string   `json:"host,omitempty"`
+	Port        int      `json:"port,omitempty"` // padrão 25
+	Username    string   `json:"username,omitempty"`
+	Password    string   `json:"password,omitempty"`
+	PasswordEnv string   `json:"password_env,omitempty"`
+	From        string   `json:"from,omitempty"`
+	To          []string `json:"to,omitempty"` // destinatários fixos, além dos roles das rotas
+	Subject     string   `json:"subject,omitempty"`
+	Body        string   `json:"body,omitempty"`
+
+	// Webhook: corpo JSON montado com o template "body"
+	URL     string            `json:"url,omitempty"`
+	Method  string            `json:"method,omitempty"` // padrão POST
+	Headers map[string]string `json:"headers,omitempty"`
+
+	TimeoutS      int `json:"timeout_s,omitempty"`       // padrão 10
+	RatePerMinute int `json:"rate_per_minute,omitempty"` // envios por minuto (padrão 30)
+
+	subject *template.Template
+	body    *template.Template
+}
+
+// notificationFuncs são as funções disponíveis nos templates
+var notificationFuncs = template.FuncMap{
+	"json": func(value interface{}) (string, error) {
+		data, err := json.Marshal(value)
+		return string(data), err
+	},
+}
+
+// prepare valida o canal e interpreta os templates
+func (ch *NotificationChannel) prepare(name string) error {
+	subject, body := ch.Subject, ch.Body
+	switch ch.Type {
+	case channelSMTP:
+		if ch.Host == "" || ch.From == "" {
+			return fmt.Errorf("canal %s: smtp exige host e from", name)
+		}
+		if subject == "" {
+			subject = defaultEmailSubject
+		}
+		if body == "" {
+			body = defaultEmailBody
+		}
+	case channelWebhook:
+		if !strings.HasPrefix(ch.URL, "http://") && !strings.HasPrefix(ch.URL, "https://") {
+			return fmt.Errorf("canal %s: webhook exige url http(s)", name)
+		}
+		if body == "" {
+			body = defaultWebhookBody
+		}
+	default:
+		return fmt.Errorf("canal %s: type inválido: %s (use smtp ou webhook)", name, ch.Type)
+	}
+	if ch.TimeoutS < 0 || ch.RatePerMinute < 0 {
+		return fmt.Errorf("canal %s: timeout_s e rate_per_minute não podem ser negativos", name)
+	}
+
+	var err error
+	if ch.subject, err = template.New(name + ".subject").Funcs(notificationFuncs).Parse(subject); err != nil {
+		return fmt.Errorf("canal %s: subject inválido: %v", name, err)
+	}
+	if ch.body, err = template.New(name + ".body").Funcs(notificationFuncs).Parse(body); err != nil {
+		return fmt.Errorf("canal %s: body inválido: %v", name, err)
+	}
+	return nil
+}
+
+// render monta assunto e corpo da notificação
+func (ch *NotificationChannel) render(data notificationData) (string, string, error) {
+	var subject, body bytes.Buffer
+	if err := ch.subject.Execute(&subject, data); err != nil {
+		return "", "", err
+	}
+	if err := ch.body.Execute(&body, data); err != nil {
+		return "", "", err
+	}
+	if ch.Type == channelWebhook && !json.Valid(body.Bytes()) {
+		return "", "", fmt.Errorf("template do webhook não gerou JSON válido")
+	}
+	return strings.TrimSpace(subject.String()), body.String(), nil
+}
+
+func (ch *NotificationChannel) timeout() time.Duration {
+	if ch.TimeoutS > 0 {
+		return time.Duration(ch.TimeoutS) * time.Second
+	}
+	return defaultChannelTimeout
+}
+
+func (ch *NotificationChannel) ratePerMinute() int {
+	if ch.RatePerMinute > 0 {
+		return ch.RatePerMinute
+	}
+	return defaultChannelRatePerMin
+}
+
+// send envia uma notificação já montada
+func (ch *NotificationChannel) send(recipients []string, subject string, body string) error {
+	if ch.Type == channelSMTP {
+		return ch.sendMail(recipients, subject, body)
+	}
+	return ch.postWebhook(body)
+}
+
+// sendMail envia o e-mail pelo servidor SMTP, com STARTTLS quando o servidor oferece
+func (ch *NotificationChannel) sendMail(recipients []string, subject string, body string) error {
+	if len(recipients) == 0 {
+		return fmt.Errorf("sem destinatários")
+	}
+	port := ch.Port
+	if port == 0 {
+		port = 25
+	}
+	addr := net.JoinHostPort(ch.Host, strconv.Itoa(port))
+
+	conn, err := net.DialTimeout("tcp", addr, ch.timeout())
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(ch.timeout()))
+	client, err := smtp.NewClient(conn, ch.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: ch.Host}); err != nil {
+			return err
+		}
+	}
+	if ch.Username != "" {
+		password := ch.Password
+		if ch.PasswordEnv != "" {
+			password = os.Getenv(ch.PasswordEnv)
+		}
+		if err := client.Auth(smtp.PlainAuth("", ch.Username, password, ch.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(ch.From); err != nil {
+		return err
+	}
+	for _, recipient := range recipients {
+		if err := client.Rcpt(recipient); err != nil {
+			return err
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", ch.From)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(recipients, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	message.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	message.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+
+	if _, err := writer.Write(message.Bytes()); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// postWebhook envia o corpo JSON; respostas fora de 2xx contam como falha
+func (ch *NotificationChannel) postWebhook(body string) error {
+	method := ch.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ch.timeout())
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, method, ch.URL, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for key, value := range ch.Headers {
+		request.Header.Set(key, os.ExpandEnv(value))
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("webhook respondeu %d: %s", response.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
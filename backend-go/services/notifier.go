@@ -0,0 +1,691 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"backend-go/models"
+)
+
+// Eventos de alarme que podem ser notificados
+const (
+	NotifyActivated = "activated"
+	NotifyCleared   = "cleared"
+	NotifyAcked     = "acked"
+	NotifyTest      = "test"
+)
+
+const (
+	notificationEventQueue   = 256
+	notificationSendInterval = time.Second
+	notificationBatch        = 50
+	notificationMemoryItems  = 500 // itens mantidos na fila em memória (sem banco)
+
+	defaultNotificationAttempts = 5
+	defaultNotificationBackoff  = 30 * time.Second
+	maxNotificationBackoff      = time.Hour
+)
+
+// ErrUnknownChannel indica um canal que não está em notifications.json
+var ErrUnknownChannel = errors.New("canal de notificação não encontrado")
+
+// NotificationRoute decide quais alarmes vão para quais canais. Filtros vazios aceitam tudo.
+type NotificationRoute struct {
+	Name        string   `json:"name"`
+	Events      []string `json:"events,omitempty"`       // padrão: activated
+	MinPriority string   `json:"min_priority,omitempty"` // low, medium, high ou critical
+	Priorities  []string `json:"priorities,omitempty"`   // alternativa a min_priority
+	Locks       []string `json:"locks,omitempty"`        // IDs das eclusas
+	Roles       []string `json:"roles,omitempty"`        // e-mail aos usuários destes roles (type)
+	Channels    []string `json:"channels"`
+}
+
+// NotificationConfig é o conteúdo de notifications.json
+type NotificationConfig struct {
+	Channels      map[string]*NotificationChannel `json:"channels"`
+	Routes        []NotificationRoute             `json:"routes"`
+	MaxAttempts   int                             `json:"max_attempts,omitempty"`    // padrão 5
+	RetryBackoffS int                             `json:"retry_backoff_s,omitempty"` // padrão 30, dobra a cada tentativa
+}
+
+// notificationData são os campos disponíveis nos templates
+type notificationData struct {
+	Event         string
+	EventLabel    string
+	LockID        string
+	LockName      string
+	Alarm         models.Alarm
+	PriorityLabel string
+	Time          string // RFC3339
+	TimeText      string // horário local legível
+}
+
+var notificationEventLabels = map[string]string{
+	NotifyActivated: "ativado",
+	NotifyCleared:   "normalizado",
+	NotifyAcked:     "reconhecido",
+	NotifyTest:      "teste",
+}
+
+var notificationPriorityLabels = map[string]string{
+	AlarmPriorityLow:      "BAIXA",
+	AlarmPriorityMedium:   "MÉDIA",
+	AlarmPriorityHigh:     "ALTA",
+	AlarmPriorityCritical: "CRÍTICA",
+}
+
+type notificationEvent struct {
+	event string
+	alarm models.Alarm
+	at    time.Time
+}
+
+// Notifier encaminha os eventos de alarme para os canais configurados, através de
+// uma fila persistida com novas tentativas e limite de envios por canal
+type Notifier struct {
+	mutex      sync.RWMutex
+	config     *NotificationConfig
+	configFile string
+	limiters   map[string]*rateLimiter
+
+	events chan notificationEvent
+	wake   chan struct{}
+
+	// Fila em memória quando o banco está indisponível
+	memoryMutex sync.Mutex
+	memory      []*models.NotificationOutbox
+	memoryID    uint
+}
+
+var (
+	globalNotifier *Notifier
+	notifierOnce   sync.Once
+)
+
+// GetNotifier retorna instância singleton do serviço de notificações
+func GetNotifier() *Notifier {
+	notifierOnce.Do(func() {
+		configFile := os.Getenv("NOTIFICATIONS_CONFIG")
+		if configFile == "" {
+			configFile = "notifications.json"
+		}
+		globalNotifier = &Notifier{
+			configFile: configFile,
+			limiters:   make(map[string]*rateLimiter),
+			events:     make(chan notificationEvent, notificationEventQueue),
+			wake:       make(chan struct{}, 1),
+		}
+
+		if err := globalNotifier.Reload(); err != nil {
+			log.Printf("❌ Erro ao carregar %s: %v (notificações desativadas)", configFile, err)
+		}
+		if tagDB() == nil {
+			log.Printf("⚠️ Banco indisponível: fila de notificações fica apenas na memória")
+		}
+
+		go globalNotifier.eventLoop()
+		go globalNotifier.sendLoop()
+	})
+	return globalNotifier
+}
+
+// loadNotificationConfig lê e valida o arquivo de notificações
+func loadNotificationConfig(filename string) (*NotificationConfig, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var config NotificationConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("JSON inválido: %v", err)
+	}
+	if config.MaxAttempts < 0 || config.RetryBackoffS < 0 {
+		return nil, fmt.Errorf("max_attempts e retry_backoff_s não podem ser negativos")
+	}
+
+	for name, channel := range config.Channels {
+		if channel == nil {
+			return nil, fmt.Errorf("canal %s vazio", name)
+		}
+		if err := channel.prepare(name); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range config.Routes {
+		route := &config.Routes[i]
+		if route.Name == "" {
+			route.Name = fmt.Sprintf("rota %d", i+1)
+		}
+		if len(route.Events) == 0 {
+			route.Events = []string{NotifyActivated}
+		}
+		for _, event := range route.Events {
+			if event != NotifyActivated && event != NotifyCleared && event != NotifyAcked {
+				return nil, fmt.Errorf("rota %s: evento inválido: %s (use activated, cleared ou acked)", route.Name, event)
+			}
+		}
+		if route.MinPriority != "" {
+			if _, ok := alarmPriorityRank[route.MinPriority]; !ok {
+				return nil, fmt.Errorf("rota %s: min_priority inválida: %s", route.Name, route.MinPriority)
+			}
+		}
+		for _, priority := range route.Priorities {
+			if _, ok := alarmPriorityRank[priority]; !ok {
+				return nil, fmt.Errorf("rota %s: priority inválida: %s", route.Name, priority)
+			}
+		}
+		if len(route.Channels) == 0 {
+			return nil, fmt.Errorf("rota %s: nenhum canal", route.Name)
+		}
+		for _, name := range route.Channels {
+			if config.Channels[name] == nil {
+				return nil, fmt.Errorf("rota %s: canal %s não existe", route.Name, name)
+			}
+		}
+	}
+	return &config, nil
+}
+
+// Reload relê notifications.json; com erro a configuração atual é mantida
+func (n *Notifier) Reload() error {
+	config, err := loadNotificationConfig(n.configFile)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Printf("ℹ️ %s não encontrado: notificações desativadas", n.configFile)
+		config, err = &NotificationConfig{}, nil
+	}
+	if err != nil {
+		return err
+	}
+
+	n.mutex.Lock()
+	n.config = config
+	n.limiters = make(map[string]*rateLimiter)
+	n.mutex.Unlock()
+
+	log.Printf("📣 Notificações: %d canais, %d rotas", len(config.Channels), len(config.Routes))
+	return nil
+}
+
+// currentConfig retorna a configuração em uso (nunca nil)
+func (n *Notifier) currentConfig() *NotificationConfig {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+	if n.config == nil {
+		return &NotificationConfig{}
+	}
+	return n.config
+}
+
+// AlarmEvent enfileira um evento de alarme sem bloquear (pode ser chamado com e.mutex).
+// Alarmes retirados para manutenção ou suprimidos não são notificados.
+func (n *Notifier) AlarmEvent(alarm models.Alarm, event string) {
+	if alarm.Shelved || alarm.Suppressed {
+		return
+	}
+	select {
+	case n.events <- notificationEvent{event: event, alarm: alarm, at: time.Now()}:
+	default:
+		log.Printf("⚠️ Fila de eventos de notificação cheia: %s de %s descartado", event, alarm.Key)
+	}
+}
+
+// matches indica se a rota aceita o evento do alarme
+func (r NotificationRoute) matches(event string, alarm models.Alarm) bool {
+	if !containsString(r.Events, event) {
+		return false
+	}
+	if len(r.Locks) > 0 && !containsString(r.Locks, alarm.PLCID) {
+		return false
+	}
+	if r.MinPriority != "" && alarmPriorityRank[alarm.Priority] < alarmPriorityRank[r.MinPriority] {
+		return false
+	}
+	if len(r.Priorities) > 0 && !containsString(r.Priorities, alarm.Priority) {
+		return false
+	}
+	return true
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// eventLoop monta as notificações de cada evento e as coloca na fila de envio
+func (n *Notifier) eventLoop() {
+	for ev := range n.events {
+		config := n.currentConfig()
+		data := newNotificationData(ev.event, ev.alarm, ev.at)
+
+		// Um canal recebe o evento uma vez, somando os destinatários das rotas que o aceitam
+		type delivery struct {
+			routes     []string
+			recipients []string
+		}
+		deliveries := make(map[string]*delivery)
+		var order []string
+		for _, route := range config.Routes {
+			if !route.matches(ev.event, ev.alarm) {
+				continue
+			}
+			for _, name := range route.Channels {
+				d := deliveries[name]
+				if d == nil {
+					d = &delivery{recipients: config.Channels[name].To}
+					deliveries[name] = d
+					order = append(order, name)
+				}
+				d.routes = append(d.routes, route.Name)
+				if config.Channels[name].Type == channelSMTP {
+					d.recipients = append(d.recipients, roleRecipients(route.Roles)...)
+				}
+			}
+		}
+
+		queued := 0
+		for _, name := range order {
+			channel, d := config.Channels[name], deliveries[name]
+			item, err := buildNotification(name, channel, data, d.recipients)
+			if err != nil {
+				log.Printf("❌ Notificação %s de %s pelo canal %s: %v", ev.event, ev.alarm.Key, name, err)
+				continue
+			}
+			item.Route = strings.Join(d.routes, ", ")
+			n.enqueue(item)
+			queued++
+		}
+		if queued > 0 {
+			n.signal()
+		}
+	}
+}
+
+// newNotificationData prepara os campos dos templates
+func newNotificationData(event string, alarm models.Alarm, at time.Time) notificationData {
+	lockName := alarm.PLCID
+	if connector, ok := GetPLCManager().Connector(alarm.PLCID); ok {
+		lockName = connector.Name()
+	}
+	priority := notificationPriorityLabels[alarm.Priority]
+	if priority == "" {
+		priority = strings.ToUpper(alarm.Priority)
+	}
+	return notificationData{
+		Event:         event,
+		EventLabel:    notificationEventLabels[event],
+		LockID:        alarm.PLCID,
+		LockName:      lockName,
+		Alarm:         alarm,
+		PriorityLabel: priority,
+		Time:          at.Format(time.RFC3339),
+		TimeText:      at.Format("02/01/2006 15:04:05"),
+	}
+}
+
+// buildNotification monta o item da fila com o conteúdo final da mensagem
+func buildNotification(name string, channel *NotificationChannel, data notificationData, recipients []string) (*models.NotificationOutbox, error) {
+	subject, body, err := channel.render(data)
+	if err != nil {
+		return nil, err
+	}
+
+	recipients = uniqueStrings(recipients)
+	if channel.Type == channelSMTP && len(recipients) == 0 {
+		return nil, fmt.Errorf("nenhum destinatário (to vazio e nenhum usuário nos roles da rota)")
+	}
+
+	now := time.Now()
+	return &models.NotificationOutbox{
+		Channel:       name,
+		Event:         data.Event,
+		AlarmKey:      data.Alarm.Key,
+		PLCID:         data.LockID,
+		Recipients:    strings.Join(recipients, ","),
+		Subject:       subject,
+		Body:          body,
+		Status:        models.NotificationPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}, nil
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool)
+	unique := make([]string, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value != "" && !seen[strings.ToLower(value)] {
+			seen[strings.ToLower(value)] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
+
+// roleRecipients retorna os e-mails dos usuários ativos dos roles informados
+func roleRecipients(roles []string) []string {
+	db := tagDB()
+	if len(roles) == 0 || db == nil {
+		return nil
+	}
+
+	var emails []string
+	err := db.Model(&models.User{}).
+		Joins("JOIN roles ON roles.id = users.role_id").
+		Where("roles.type IN ? AND roles.active = ? AND users.blocked = ?", roles, true, false).
+		Pluck("users.email", &emails).Error
+	if err != nil {
+		log.Printf("⚠️ Erro ao buscar destinatários dos roles %v: %v", roles, err)
+	}
+	return emails
+}
+
+// signal acorda o envio sem esperar o próximo ciclo
+func (n *Notifier) signal() {
+	select {
+	case n.wake <- struct{}{}:
+	default:
+	}
+}
+
+// enqueue grava o item na fila (banco ou memória)
+func (n *Notifier) enqueue(item *models.NotificationOutbox) {
+	if db := tagDB(); db != nil {
+		if err := db.Create(item).Error; err != nil {
+			log.Printf("❌ Erro ao gravar notificação na fila: %v", err)
+		}
+		return
+	}
+
+	n.memoryMutex.Lock()
+	defer n.memoryMutex.Unlock()
+	n.memoryID++
+	item.ID = n.memoryID
+	n.memory = append(n.memory, item)
+
+	// Descartar os itens encerrados mais antigos
+	for len(n.memory) > notificationMemoryItems {
+		dropped := false
+		for i, old := range n.memory {
+			if old.Status != models.NotificationPending {
+				n.memory = append(n.memory[:i], n.memory[i+1:]...)
+				dropped = true
+				break
+			}
+		}
+		if !dropped {
+			break
+		}
+	}
+}
+
+// dueNotifications retorna os itens pendentes cuja próxima tentativa já venceu,
+// exceto os dos canais em skip (limite de envios esgotado)
+func (n *Notifier) dueNotifications(now time.Time, skip map[string]bool) []models.NotificationOutbox {
+	var items []models.NotificationOutbox
+	if db := tagDB(); db != nil {
+		query := db.Where("status = ? AND next_attempt_at <= ?", models.NotificationPending, now)
+		if len(skip) > 0 {
+			channels := make([]string, 0, len(skip))
+			for name := range skip {
+				channels = append(channels, name)
+			}
+			query = query.Where("channel NOT IN ?", channels)
+		}
+		err := query.Order("id").Limit(notificationBatch).Find(&items).Error
+		if err != nil {
+			log.Printf("⚠️ Erro ao ler fila de notificações: %v", err)
+		}
+		return items
+	}
+
+	n.memoryMutex.Lock()
+	defer n.memoryMutex.Unlock()
+	for _, item := range n.memory {
+		if item.Status == models.NotificationPending && !item.NextAttemptAt.After(now) && !skip[item.Channel] {
+			items = append(items, *item)
+			if len(items) == notificationBatch {
+				break
+			}
+		}
+	}
+	return items
+}
+
+// saveNotification grava o resultado de uma tentativa
+func (n *Notifier) saveNotification(item models.NotificationOutbox) {
+	if db := tagDB(); db != nil {
+		if err := db.Save(&item).Error; err != nil {
+			log.Printf("❌ Erro ao atualizar notificação %d: %v", item.ID, err)
+		}
+		return
+	}
+
+	n.memoryMutex.Lock()
+	defer n.memoryMutex.Unlock()
+	for _, stored := range n.memory {
+		if stored.ID == item.ID {
+			*stored = item
+			return
+		}
+	}
+}
+
+// Outbox lista os itens da fila, dos mais recentes aos mais antigos
+func (n *Notifier) Outbox(status string, limit int) ([]models.NotificationOutbox, error) {
+	var items []models.NotificationOutbox
+	if db := tagDB(); db != nil {
+		query := db.Order("id DESC").Limit(limit)
+		if status != "" {
+			query = query.Where("status = ?", status)
+		}
+		err := query.Find(&items).Error
+		return items, err
+	}
+
+	n.memoryMutex.Lock()
+	defer n.memoryMutex.Unlock()
+	for i := len(n.memory) - 1; i >= 0 && len(items) < limit; i-- {
+		if status == "" || n.memory[i].Status == status {
+			items = append(items, *n.memory[i])
+		}
+	}
+	return items, nil
+}
+
+// sendLoop envia os itens vencidos a cada ciclo ou quando novos itens entram na fila
+func (n *Notifier) sendLoop() {
+	ticker := time.NewTicker(notificationSendInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-n.wake:
+		}
+		n.sendDue(time.Now())
+	}
+}
+
+// sendDue faz uma tentativa de envio de cada item vencido; itens acima do limite do
+// canal ficam na fila para o próximo ciclo. Canais com o limite esgotado ficam fora
+// da busca, para que uma rajada num canal não atrase os demais.
+func (n *Notifier) sendDue(now time.Time) {
+	config := n.currentConfig()
+	limited := make(map[string]bool)
+	for name, channel := range config.Channels {
+		if !n.limiter(name, channel).available(now) {
+			limited[name] = true
+		}
+	}
+
+	for _, item := range n.dueNotifications(now, limited) {
+		channel := config.Channels[item.Channel]
+		if channel == nil {
+			n.finish(item, config, fmt.Errorf("canal %s removido da configuração", item.Channel), true)
+			continue
+		}
+		if limited[item.Channel] || !n.limiter(item.Channel, channel).allow(time.Now()) {
+			limited[item.Channel] = true
+			continue
+		}
+
+		var recipients []string
+		if item.Recipients != "" {
+			recipients = strings.Split(item.Recipients, ",")
+		}
+		err := channel.send(recipients, item.Subject, item.Body)
+		n.finish(item, config, err, false)
+	}
+}
+
+// finish registra o resultado da tentativa e agenda a próxima com espera exponencial
+func (n *Notifier) finish(item models.NotificationOutbox, config *NotificationConfig, err error, final bool) {
+	now := time.Now()
+	item.Attempts++
+	item.UpdatedAt = now
+
+	if err == nil {
+		item.Status = models.NotificationSent
+		item.SentAt = &now
+		item.LastError = ""
+		log.Printf("📨 Notificação %d (%s de %s) enviada pelo canal %s", item.ID, item.Event, item.AlarmKey, item.Channel)
+		n.saveNotification(item)
+		return
+	}
+
+	item.LastError = err.Error()
+	maxAttempts := config.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = defaultNotificationAttempts
+	}
+	if final || item.Attempts >= maxAttempts {
+		item.Status = models.NotificationFailed
+		log.Printf("❌ Notificação %d pelo canal %s falhou após %d tentativas: %v", item.ID, item.Channel, item.Attempts, err)
+	} else {
+		backoff := defaultNotificationBackoff
+		if config.RetryBackoffS > 0 {
+			backoff = time.Duration(config.RetryBackoffS) * time.Second
+		}
+		backoff <<= uint(item.Attempts - 1)
+		if backoff > maxNotificationBackoff || backoff <= 0 {
+			backoff = maxNotificationBackoff
+		}
+		item.NextAttemptAt = now.Add(backoff)
+		log.Printf("⚠️ Notificação %d pelo canal %s falhou (tentativa %d, nova em %v): %v", item.ID, item.Channel, item.Attempts, backoff, err)
+	}
+	n.saveNotification(item)
+}
+
+// limiter retorna o limitador de envios do canal
+func (n *Notifier) limiter(name string, channel *NotificationChannel) *rateLimiter {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	limiter := n.limiters[name]
+	if limiter == nil {
+		limiter = newRateLimiter(channel.ratePerMinute())
+		n.limiters[name] = limiter
+	}
+	return limiter
+}
+
+// SendTest envia uma notificação de teste diretamente pelo canal, sem passar pela fila
+func (n *Notifier) SendTest(name string, to []string) error {
+	channel := n.currentConfig().Channels[name]
+	if channel == nil {
+		return ErrUnknownChannel
+	}
+
+	now := time.Now()
+	alarm := models.Alarm{
+		Key:         "teste/notificacao",
+		RuleID:      "notificacao",
+		TagName:     "teste",
+		Type:        alarmBit,
+		Priority:    AlarmPriorityLow,
+		Message:     "Teste de notificação",
+		State:       models.AlarmActiveUnacked,
+		ActivatedAt: now,
+	}
+	if to == nil {
+		to = channel.To
+	}
+	item, err := buildNotification(name, channel, newNotificationData(NotifyTest, alarm, now), to)
+	if err != nil {
+		return err
+	}
+	var recipients []string
+	if item.Recipients != "" {
+		recipients = strings.Split(item.Recipients, ",")
+	}
+	return channel.send(recipients, item.Subject, item.Body)
+}
+
+// Channels lista os nomes dos canais configurados
+func (n *Notifier) Channels() []string {
+	config := n.currentConfig()
+	names := make([]string, 0, len(config.Channels))
+	for name := range config.Channels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// rateLimiter é um balde de fichas: até perMinute envios por minuto, com rajada do mesmo tamanho
+type rateLimiter struct {
+	mutex  sync.Mutex
+	tokens float64
+	max    float64
+	last   time.Time
+}
+
+func newRateLimiter(perMinute int) *rateLimiter {
+	return &rateLimiter{tokens: float64(perMinute), max: float64(perMinute)}
+}
+
+// refill repõe as fichas do tempo decorrido; chamado com l.mutex
+func (l *rateLimiter) refill(now time.Time) {
+	if !l.last.IsZero() && now.After(l.last) {
+		l.tokens += now.Sub(l.last).Minutes() * l.max
+		if l.tokens > l.max {
+			l.tokens = l.max
+		}
+	}
+	if now.After(l.last) {
+		l.last = now
+	}
+}
+
+// available indica se há ficha para um envio, sem consumi-la
+func (l *rateLimiter) available(now time.Time) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.refill(now)
+	return l.tokens >= 1
+}
+
+func (l *rateLimiter) allow(now time.Time) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.refill(now)
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
@@ -0,0 +1,451 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"backend-go/models"
+)
+
+// testNotificationData monta os campos dos templates sem consultar o gerenciador de eclusas
+func testNotificationData() notificationData {
+	return notificationData{
+		Event:         NotifyActivated,
+		EventLabel:    notificationEventLabels[NotifyActivated],
+		LockID:        "regua",
+		LockName:      "Eclusa Régua",
+		Alarm:         models.Alarm{Key: "regua/nivel_hh", RuleID: "nivel_hh", TagName: "nivel", Message: "Nível \"muito\" alto", Priority: AlarmPriorityHigh, Value: 12.5, Limit: 12},
+		PriorityLabel: notificationPriorityLabels[AlarmPriorityHigh],
+		Time:          "2026-10-17T08:00:00-03:00",
+		TimeText:      "17/10/2026 08:00:00",
+	}
+}
+
+// webhookServer responde com o status atual e guarda os corpos recebidos
+type webhookServer struct {
+	*httptest.Server
+	mutex  sync.Mutex
+	status int
+	bodies []string
+	header http.Header
+}
+
+func newWebhookServer(t *testing.T) *webhookServer {
+	server := &webhookServer{status: http.StatusOK}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		server.mutex.Lock()
+		defer server.mutex.Unlock()
+		server.bodies = append(server.bodies, string(body))
+		server.header = r.Header.Clone()
+		w.WriteHeader(server.status)
+		if server.status >= 300 {
+			io.WriteString(w, "indisponível")
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (s *webhookServer) setStatus(status int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.status = status
+}
+
+func (s *webhookServer) received() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string(nil), s.bodies...)
+}
+
+func webhookChannel(t *testing.T, url string) *NotificationChannel {
+	channel := &NotificationChannel{Type: channelWebhook, URL: url, Headers: map[string]string{"X-Token": "${NOTIFIER_TEST_TOKEN}"}}
+	if err := channel.prepare("webhook"); err != nil {
+		t.Fatal(err)
+	}
+	return channel
+}
+
+func TestNotificationWebhook(t *testing.T) {
+	t.Setenv("NOTIFIER_TEST_TOKEN", "segredo")
+	server := newWebhookServer(t)
+	channel := webhookChannel(t, server.URL)
+
+	item, err := buildNotification("webhook", channel, testNotificationData(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := channel.send(nil, item.Subject, item.Body); err != nil {
+		t.Fatal(err)
+	}
+
+	var payload struct {
+		Event  string       `json:"event"`
+		LockID string       `json:"lock_id"`
+		Alarm  models.Alarm `json:"alarm"`
+	}
+	if err := json.Unmarshal([]byte(server.received()[0]), &payload); err != nil {
+		t.Fatalf("corpo não é JSON: %v", err)
+	}
+	if payload.Event != NotifyActivated || payload.LockID != "regua" || payload.Alarm.Message != "Nível \"muito\" alto" {
+		t.Errorf("corpo inesperado: %+v", payload)
+	}
+	server.mutex.Lock()
+	header := server.header
+	server.mutex.Unlock()
+	if got := header.Get("X-Token"); got != "segredo" {
+		t.Errorf("cabeçalho X-Token = %q", got)
+	}
+	if got := header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q", got)
+	}
+
+	server.setStatus(http.StatusServiceUnavailable)
+	err = channel.send(nil, item.Subject, item.Body)
+	if err == nil || !strings.Contains(err.Error(), "webhook respondeu 503: indisponível") {
+		t.Errorf("erro %v, esperado resposta 503", err)
+	}
+}
+
+// fakeSMTPServer aceita uma conexão, conversa o mínimo do SMTP e guarda o envelope e o DATA
+type fakeSMTPServer struct {
+	listener   net.Listener
+	done       chan struct{}
+	from       string
+	recipients []string
+	data       string
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &fakeSMTPServer{listener: listener, done: make(chan struct{})}
+	t.Cleanup(func() { listener.Close() })
+	go server.serve()
+	return server
+}
+
+func (s *fakeSMTPServer) serve() {
+	defer close(s.done)
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 teste ESMTP")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch command {
+		case "EHLO", "HELO":
+			text.PrintfLine("250 teste")
+		case "MAIL":
+			s.from = strings.TrimPrefix(line, "MAIL FROM:")
+			text.PrintfLine("250 ok")
+		case "RCPT":
+			s.recipients = append(s.recipients, strings.TrimPrefix(line, "RCPT TO:"))
+			text.PrintfLine("250 ok")
+		case "DATA":
+			text.PrintfLine("354 fim com <CRLF>.<CRLF>")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.data = string(data)
+			text.PrintfLine("250 aceito")
+		case "QUIT":
+			text.PrintfLine("221 tchau")
+			return
+		default:
+			text.PrintfLine("502 comando não implementado")
+		}
+	}
+}
+
+func TestNotificationSMTP(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	host, port, _ := net.SplitHostPort(server.listener.Addr().String())
+	number, _ := strconv.Atoi(port)
+
+	channel := &NotificationChannel{Type: channelSMTP, Host: host, Port: number, From: "scada@eclusa.gov.br",
+		To: []string{"operacao@eclusa.gov.br"}, Body: "Alarme: {{.Alarm.Message}}\n.linha com ponto\nfim"}
+	if err := channel.prepare("email"); err != nil {
+		t.Fatal(err)
+	}
+	item, err := buildNotification("email", channel, testNotificationData(), append(channel.To, "chefia@eclusa.gov.br"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := channel.send(strings.Split(item.Recipients, ","), item.Subject, item.Body); err != nil {
+		t.Fatal(err)
+	}
+	<-server.done
+
+	if server.from != "<scada@eclusa.gov.br>" {
+		t.Errorf("MAIL FROM %q", server.from)
+	}
+	if strings.Join(server.recipients, " ") != "<operacao@eclusa.gov.br> <chefia@eclusa.gov.br>" {
+		t.Errorf("RCPT TO %v", server.recipients)
+	}
+
+	// ReadDotBytes desfaz o dot-stuffing e troca CRLF por LF
+	header, body, ok := strings.Cut(server.data, "\n\n")
+	if !ok {
+		t.Fatalf("mensagem sem separação de cabeçalho: %q", server.data)
+	}
+	message, err := textproto.NewReader(bufio.NewReader(strings.NewReader(header + "\n\n"))).ReadMIMEHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(message.Get("Subject"))
+	if want := "[ALTA] Eclusa Régua: Nível \"muito\" alto (ativado)"; err != nil || subject != want {
+		t.Errorf("Subject %q (%v), esperado %q", subject, err, want)
+	}
+	if got := message.Get("To"); got != "operacao@eclusa.gov.br, chefia@eclusa.gov.br" {
+		t.Errorf("To %q", got)
+	}
+	if got := message.Get("Content-Type"); got != "text/plain; charset=UTF-8" {
+		t.Errorf("Content-Type %q", got)
+	}
+	if want := "Alarme: Nível \"muito\" alto\n.linha com ponto\nfim\n"; body != want {
+		t.Errorf("corpo %q, esperado %q", body, want)
+	}
+}
+
+func TestNotificationTemplates(t *testing.T) {
+	tests := []struct {
+		name       string
+		channel    NotificationChannel
+		prepareErr bool
+		renderErr  bool
+	}{
+		{"webhook padrão", NotificationChannel{Type: channelWebhook, URL: "http://exemplo"}, false, false},
+		{"webhook com json", NotificationChannel{Type: channelWebhook, URL: "http://exemplo", Body: `{"texto": {{json .Alarm.Message}}}`}, false, false},
+		{"webhook sem aspas", NotificationChannel{Type: channelWebhook, URL: "http://exemplo", Body: `{"texto": {{.Alarm.Message}}}`}, false, true},
+		{"webhook com aspas internas", NotificationChannel{Type: channelWebhook, URL: "http://exemplo", Body: `{"texto": "{{.Alarm.Message}}"}`}, false, true},
+		{"template inválido", NotificationChannel{Type: channelWebhook, URL: "http://exemplo", Body: `{{.Alarm`}, true, false},
+		{"campo inexistente", NotificationChannel{Type: channelWebhook, URL: "http://exemplo", Body: `{{.Nada}}`}, false, true},
+		{"webhook sem url", NotificationChannel{Type: channelWebhook, URL: "ftp://exemplo"}, true, false},
+		{"smtp sem host", NotificationChannel{Type: channelSMTP, From: "a@b"}, true, false},
+		{"smtp texto livre", NotificationChannel{Type: channelSMTP, Host: "smtp", From: "a@b", Body: "{{.Alarm.Message}}"}, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			channel := tt.channel
+			err := channel.prepare("canal")
+			if (err != nil) != tt.prepareErr {
+				t.Fatalf("prepare: erro %v, esperado erro = %v", err, tt.prepareErr)
+			}
+			if err != nil {
+				return
+			}
+			if _, _, err := channel.render(testNotificationData()); (err != nil) != tt.renderErr {
+				t.Errorf("render: erro %v, esperado erro = %v", err, tt.renderErr)
+			}
+		})
+	}
+}
+
+// newTestNotifier cria um notificador com a fila em memória (sem banco)
+func newTestNotifier(config *NotificationConfig) *Notifier {
+	return &Notifier{config: config, limiters: make(map[string]*rateLimiter), wake: make(chan struct{}, 1)}
+}
+
+// queueTest enfileira uma notificação do canal e devolve o ID
+func queueTest(t *testing.T, n *Notifier, name string) uint {
+	item, err := buildNotification(name, n.currentConfig().Channels[name], testNotificationData(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.enqueue(item)
+	return item.ID
+}
+
+// stored devolve o item da fila em memória
+func stored(n *Notifier, id uint) models.NotificationOutbox {
+	n.memoryMutex.Lock()
+	defer n.memoryMutex.Unlock()
+	for _, item := range n.memory {
+		if item.ID == id {
+			return *item
+		}
+	}
+	return models.NotificationOutbox{}
+}
+
+func TestNotifierSendDue(t *testing.T) {
+	server := newWebhookServer(t)
+	config := &NotificationConfig{
+		Channels:      map[string]*NotificationChannel{"webhook": webhookChannel(t, server.URL)},
+		MaxAttempts:   3,
+		RetryBackoffS: 10,
+	}
+	n := newTestNotifier(config)
+
+	// Sucesso na primeira tentativa
+	sent := queueTest(t, n, "webhook")
+	n.sendDue(time.Now())
+	if item := stored(n, sent); item.Status != models.NotificationSent || item.Attempts != 1 || item.SentAt == nil {
+		t.Errorf("envio: status %s, tentativas %d", item.Status, item.Attempts)
+	}
+
+	// Falha: nova tentativa com espera de 10 s, dobrando; desiste na terceira
+	server.setStatus(http.StatusInternalServerError)
+	retried := queueTest(t, n, "webhook")
+	for attempt := 1; attempt <= 3; attempt++ {
+		before := time.Now()
+		n.sendDue(before.Add(time.Duration(attempt) * time.Hour))
+		item := stored(n, retried)
+		if item.Attempts != attempt || !strings.Contains(item.LastError, "500") {
+			t.Fatalf("tentativa %d: tentativas %d, erro %q", attempt, item.Attempts, item.LastError)
+		}
+		if attempt < 3 {
+			wait := item.NextAttemptAt.Sub(before)
+			backoff := time.Duration(10<<uint(attempt-1)) * time.Second
+			if item.Status != models.NotificationPending || wait < backoff || wait > backoff+time.Second {
+				t.Errorf("tentativa %d: status %s, nova tentativa em %v, esperado %v", attempt, item.Status, wait, backoff)
+			}
+		} else if item.Status != models.NotificationFailed {
+			t.Errorf("após %d tentativas: status %s, esperado failed", attempt, item.Status)
+		}
+	}
+
+	// Item ainda não vencido não é enviado
+	server.setStatus(http.StatusOK)
+	pending := queueTest(t, n, "webhook")
+	n.memory[len(n.memory)-1].NextAttemptAt = time.Now().Add(time.Minute)
+	n.sendDue(time.Now())
+	if item := stored(n, pending); item.Attempts != 0 {
+		t.Errorf("item não vencido enviado: %d tentativas", item.Attempts)
+	}
+
+	// Canal removido da configuração: desiste sem tentar de novo
+	orphan := queueTest(t, n, "webhook")
+	n.mutex.Lock()
+	n.config = &NotificationConfig{Channels: map[string]*NotificationChannel{}}
+	n.mutex.Unlock()
+	n.sendDue(time.Now())
+	if item := stored(n, orphan); item.Status != models.NotificationFailed || item.Attempts != 1 {
+		t.Errorf("canal removido: status %s, tentativas %d", item.Status, item.Attempts)
+	}
+}
+
+func TestNotifierFinishBackoff(t *testing.T) {
+	n := newTestNotifier(&NotificationConfig{})
+	tests := []struct {
+		attempts int
+		want     time.Duration
+		status   string
+	}{
+		{0, defaultNotificationBackoff, models.NotificationPending},
+		{1, 2 * defaultNotificationBackoff, models.NotificationPending},
+		{3, 8 * defaultNotificationBackoff, models.NotificationPending},
+		{defaultNotificationAttempts - 1, 0, models.NotificationFailed},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d tentativas", tt.attempts), func(t *testing.T) {
+			id := n.memoryID + 1
+			n.enqueue(&models.NotificationOutbox{Channel: "c", Status: models.NotificationPending, Attempts: tt.attempts})
+			before := time.Now()
+			n.finish(stored(n, id), n.currentConfig(), fmt.Errorf("falha"), false)
+
+			item := stored(n, id)
+			if item.Status != tt.status {
+				t.Fatalf("status %s, esperado %s", item.Status, tt.status)
+			}
+			if tt.status == models.NotificationPending {
+				if wait := item.NextAttemptAt.Sub(before); wait < tt.want || wait > tt.want+time.Second {
+					t.Errorf("espera %v, esperado %v", wait, tt.want)
+				}
+			}
+		})
+	}
+
+	// Espera limitada a uma hora
+	id := n.memoryID + 1
+	n.enqueue(&models.NotificationOutbox{Channel: "c", Status: models.NotificationPending, Attempts: 1})
+	n.finish(stored(n, id), &NotificationConfig{MaxAttempts: 20, RetryBackoffS: 3600}, fmt.Errorf("falha"), false)
+	if wait := time.Until(stored(n, id).NextAttemptAt); wait > maxNotificationBackoff {
+		t.Errorf("espera %v acima do máximo", wait)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	start := time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC)
+	limiter := newRateLimiter(2)
+
+	if !limiter.allow(start) || !limiter.allow(start) {
+		t.Fatal("rajada inicial recusada")
+	}
+	if limiter.available(start) || limiter.allow(start.Add(10*time.Second)) {
+		t.Error("terceiro envio no mesmo minuto aceito")
+	}
+	if !limiter.allow(start.Add(40 * time.Second)) {
+		t.Error("ficha não reposta após 30 s")
+	}
+	// Relógio voltando não repõe fichas
+	if limiter.allow(start) {
+		t.Error("ficha reposta com horário anterior")
+	}
+	if !limiter.available(start.Add(10 * time.Minute)) {
+		t.Error("fichas não repostas após 10 min")
+	}
+}
+
+func TestNotifierRateLimitPerChannel(t *testing.T) {
+	server := newWebhookServer(t)
+	busy := webhookChannel(t, server.URL)
+	busy.RatePerMinute = 1
+	n := newTestNotifier(&NotificationConfig{Channels: map[string]*NotificationChannel{
+		"rajada": busy,
+		"outro":  webhookChannel(t, server.URL),
+	}})
+
+	first := queueTest(t, n, "rajada")
+	second := queueTest(t, n, "rajada")
+	other := queueTest(t, n, "outro")
+	n.sendDue(time.Now())
+
+	if stored(n, first).Status != models.NotificationSent {
+		t.Error("primeiro item do canal não enviado")
+	}
+	if item := stored(n, second); item.Status != models.NotificationPending || item.Attempts != 0 {
+		t.Errorf("item acima do limite: status %s, tentativas %d", item.Status, item.Attempts)
+	}
+	if stored(n, other).Status != models.NotificationSent {
+		t.Error("canal sem limite atrasado pela rajada de outro canal")
+	}
+
+	// Com o limite esgotado, o canal fica fora da busca até repor a ficha
+	n.sendDue(time.Now())
+	if stored(n, second).Attempts != 0 {
+		t.Error("item enviado antes de repor a ficha")
+	}
+	n.sendDue(time.Now().Add(time.Minute))
+	if stored(n, second).Status != models.NotificationSent {
+		t.Error("item não enviado após repor a ficha")
+	}
+	if got := len(server.received()); got != 3 {
+		t.Errorf("%d envios, esperado 3", got)
+	}
+}
@@ -0,0 +1,268 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Áreas de memória S7 suportadas nos endereços de tag
+const (
+	areaDB      = "DB"
+	areaInputs  = "I" // Entradas (E em mnemônico alemão)
+	areaOutputs = "Q" // Saídas (A em mnemônico alemão)
+	areaMerkers = "M"
+	areaTimers  = "T"
+	areaCounter = "C" // Contadores (Z em mnemônico alemão)
+
+	// Áreas Modbus (endereços base zero do protocolo)
+	areaHoldingRegisters = "HR"
+	areaInputRegisters   = "IR"
+	areaCoils            = "CO"
+	areaDiscreteInputs   = "DI"
+)
+
+var (
+	dbAddressPattern     = regexp.MustCompile(`^DB(\d+)\.DB([XBWD])(\d+)(?:\.(\d+))?$`)
+	memoryAddressPattern = regexp.MustCompile(`^([IEQAM])([XBWD]?)(\d+)(?:\.(\d+))?$`)
+	timerAddressPattern  = regexp.MustCompile(`^([TCZ])(\d+)$`)
+	modbusAddressPattern = regexp.MustCompile(`^(HR|IR|CO|DI)(\d+)(?:\.(\d+))?$`)
+)
+
+// tagAddress é a posição de um tag na memória do PLC
+type tagAddress struct {
+	Area     string
+	DBNumber int // apenas para área DB
+	Byte     int // byte inicial (número do timer/contador para T/C)
+	Bit      int
+	Width    string // mnemônico X/B/W/D do endereço ("" para T/C e offset legado)
+	WordSwap bool   // Modbus: valores de 32/64 bits com a palavra menos significativa primeiro
+}
+
+// isModbusArea indica se a área pertence a um dispositivo Modbus
+func isModbusArea(area string) bool {
+	switch area {
+	case areaHoldingRegisters, areaInputRegisters, areaCoils, areaDiscreteInputs:
+		return true
+	}
+	return false
+}
+
+// isBitArea indica se a área é de bits Modbus (coils e entradas discretas)
+func isBitArea(area string) bool {
+	return area == areaCoils || area == areaDiscreteInputs
+}
+
+// unitSize é o tamanho em bytes de cada unidade de endereço da área
+// (coils e entradas discretas são agrupados em bytes de 8 bits)
+func (a tagAddress) unitSize() int {
+	switch a.Area {
+	case areaTimers, areaCounter, areaHoldingRegisters, areaInputRegisters:
+		return 2
+	}
+	return 1
+}
+
+// bitLocation retorna o byte (relativo ao início da unidade) e o bit de um endereço de bit.
+// Em registradores Modbus o bit 0 é o menos significativo, que fica no segundo byte.
+func (a tagAddress) bitLocation() (int, int) {
+	if a.unitSize() == 2 {
+		return 1 - a.Bit/8, a.Bit % 8
+	}
+	return 0, a.Bit
+}
+
+// String formata o endereço na sintaxe S7
+func (a tagAddress) String() string {
+	switch a.Area {
+	case areaDB:
+		if a.Width == "X" || a.Width == "" {
+			return fmt.Sprintf("DB%d.DBX%d.%d", a.DBNumber, a.Byte, a.Bit)
+		}
+		return fmt.Sprintf("DB%d.DB%s%d", a.DBNumber, a.Width, a.Byte)
+	case areaTimers, areaCounter:
+		return fmt.Sprintf("%s%d", a.Area, a.Byte)
+	case areaCoils, areaDiscreteInputs:
+		return fmt.Sprintf("%s%d", a.Area, a.Byte*8+a.Bit)
+	case areaHoldingRegisters, areaInputRegisters:
+		if a.Width == "X" {
+			return fmt.Sprintf("%s%d.%d", a.Area, a.Byte, a.Bit)
+		}
+		return fmt.Sprintf("%s%d", a.Area, a.Byte)
+	default:
+		if a.Width == "X" || a.Width == "" {
+			return fmt.Sprintf("%s%d.%d", a.Area, a.Byte, a.Bit)
+		}
+		return fmt.Sprintf("%s%s%d", a.Area, a.Width, a.Byte)
+	}
+}
+
+// parseTagAddress resolve o endereço do tag. Sem "address", usa o formato antigo:
+// "offset" (byte.bit) no DB padrão da eclusa.
+//
+// Exemplos aceitos: DB19.DBX52.3, DB19.DBW66, DB19.DBD0, I0.1, IW64, Q4.0,
+// M10.2, MB5, MW100, MD20, T5, C3 (com ou sem "%" e mnemônicos alemães E/A/Z).
+// Em dispositivos Modbus: HR100, HR100.3 (bit), IR5, CO17, DI3.
+func parseTagAddress(tag PLCTag, info tagTypeInfo, config PLCConfig) (tagAddress, error) {
+	driver := config.driverName()
+
+	if strings.TrimSpace(tag.Address) == "" {
+		if driver == driverModbus {
+			return tagAddress{}, fmt.Errorf("tags Modbus exigem address (ex: HR100, CO5)")
+		}
+		if tag.Offset < 0 {
+			return tagAddress{}, fmt.Errorf("offset negativo")
+		}
+		byteOffset, bitOffset, err := tagBitOffset(tag.Offset)
+		if err != nil {
+			return tagAddress{}, err
+		}
+		return tagAddress{Area: areaDB, DBNumber: config.DBNumber, Byte: byteOffset, Bit: bitOffset}, nil
+	}
+
+	text := strings.ToUpper(strings.TrimPrefix(strings.TrimSpace(tag.Address), "%"))
+	text = strings.ReplaceAll(text, " ", "")
+
+	if m := modbusAddressPattern.FindStringSubmatch(text); m != nil {
+		if driver == driverS7 {
+			return tagAddress{}, fmt.Errorf("endereço Modbus %s numa conexão S7", tag.Address)
+		}
+		return parseModbusAddress(tag, info, config, m)
+	}
+	if driver == driverModbus {
+		return tagAddress{}, fmt.Errorf("endereço %s não é Modbus (ex: HR100, IR5, CO17, DI3)", tag.Address)
+	}
+
+	var addr tagAddress
+	var bitText string
+
+	if m := dbAddressPattern.FindStringSubmatch(text); m != nil {
+		addr.Area = areaDB
+		addr.DBNumber, _ = strconv.Atoi(m[1])
+		addr.Width = m[2]
+		addr.Byte, _ = strconv.Atoi(m[3])
+		bitText = m[4]
+		if addr.DBNumber <= 0 {
+			return tagAddress{}, fmt.Errorf("número de DB inválido em %s", tag.Address)
+		}
+	} else if m := memoryAddressPattern.FindStringSubmatch(text); m != nil {
+		switch m[1] {
+		case "I", "E":
+			addr.Area = areaInputs
+		case "Q", "A":
+			addr.Area = areaOutputs
+		default:
+			addr.Area = areaMerkers
+		}
+		addr.Width = m[2]
+		addr.Byte, _ = strconv.Atoi(m[3])
+		bitText = m[4]
+		if addr.Width == "" {
+			if bitText == "" {
+				return tagAddress{}, fmt.Errorf("endereço %s sem bit nem largura (ex: %s0.0, %sW0)", tag.Address, m[1], m[1])
+			}
+			addr.Width = "X"
+		}
+	} else if m := timerAddressPattern.FindStringSubmatch(text); m != nil {
+		addr.Area = areaTimers
+		if m[1] != "T" {
+			addr.Area = areaCounter
+		}
+		addr.Byte, _ = strconv.Atoi(m[2])
+		if info.byteSize() != 2 {
+			return tagAddress{}, fmt.Errorf("timers/contadores são de 16 bits; use tipo word ou int")
+		}
+		return addr, nil
+	} else {
+		return tagAddress{}, fmt.Errorf("endereço S7 inválido: %s", tag.Address)
+	}
+
+	// Bit só existe em endereços X e deve estar entre 0 e 7
+	if addr.Width == "X" {
+		if bitText == "" {
+			return tagAddress{}, fmt.Errorf("endereço de bit sem número do bit: %s", tag.Address)
+		}
+		addr.Bit, _ = strconv.Atoi(bitText)
+		if addr.Bit > 7 {
+			return tagAddress{}, fmt.Errorf("bit offset inválido: %d (deve ser 0-7)", addr.Bit)
+		}
+		if info.Base != "bool" {
+			return tagAddress{}, fmt.Errorf("endereço de bit %s exige tipo bool", tag.Address)
+		}
+		return addr, nil
+	}
+
+	if bitText != "" {
+		return tagAddress{}, fmt.Errorf("endereço %s não aceita número de bit", tag.Address)
+	}
+	if info.Base == "bool" {
+		return tagAddress{}, fmt.Errorf("tipo bool exige endereço de bit (X)")
+	}
+
+	// W e D fixam a largura; B marca só o byte inicial (tipos maiores como string/lreal)
+	width := map[string]int{"W": 2, "D": 4}[addr.Width]
+	if width > 0 && width != info.byteSize() {
+		return tagAddress{}, fmt.Errorf("endereço %s tem %d bytes, tipo %s tem %d", tag.Address, width, info.Base, info.byteSize())
+	}
+	return addr, nil
+}
+
+// parseModbusAddress completa o endereço Modbus já reconhecido pela expressão regular
+func parseModbusAddress(tag PLCTag, info tagTypeInfo, config PLCConfig, m []string) (tagAddress, error) {
+	number, _ := strconv.Atoi(m[2])
+	if number > 65535 {
+		return tagAddress{}, fmt.Errorf("endereço Modbus fora da faixa 0-65535: %s", tag.Address)
+	}
+
+	addr := tagAddress{Area: m[1]}
+
+	// Coils e entradas discretas: só bool, empacotados em bytes
+	if addr.Area == areaCoils || addr.Area == areaDiscreteInputs {
+		if m[3] != "" {
+			return tagAddress{}, fmt.Errorf("endereço %s não aceita número de bit", tag.Address)
+		}
+		if info.Base != "bool" {
+			return tagAddress{}, fmt.Errorf("coils e entradas discretas exigem tipo bool")
+		}
+		addr.Byte, addr.Bit = number/8, number%8
+		addr.Width = "X"
+		return addr, nil
+	}
+
+	addr.Byte = number
+
+	// Bit dentro de um registrador (0-15)
+	if m[3] != "" {
+		addr.Bit, _ = strconv.Atoi(m[3])
+		if addr.Bit > 15 {
+			return tagAddress{}, fmt.Errorf("bit de registrador inválido: %d (deve ser 0-15)", addr.Bit)
+		}
+		if info.Base != "bool" {
+			return tagAddress{}, fmt.Errorf("endereço de bit %s exige tipo bool", tag.Address)
+		}
+		addr.Width = "X"
+		return addr, nil
+	}
+	if info.Base == "bool" {
+		return tagAddress{}, fmt.Errorf("tipo bool em registrador exige o bit (ex: %s.0)", tag.Address)
+	}
+	// Registradores são lidos e escritos inteiros: byte, char e strings ímpares ficariam pela metade
+	if info.byteSize()%2 != 0 {
+		return tagAddress{}, fmt.Errorf("tipo %s ocupa %d bytes; registradores Modbus exigem tamanho par (use word/int ou string de comprimento par)", info.Base, info.byteSize())
+	}
+
+	wordOrder := tag.WordOrder
+	if wordOrder == "" {
+		wordOrder = config.WordOrder
+	}
+	switch strings.ToLower(wordOrder) {
+	case "", "big":
+	case "little":
+		addr.WordSwap = true
+	default:
+		return tagAddress{}, fmt.Errorf("word_order inválido: %s (use big ou little)", wordOrder)
+	}
+
+	return addr, nil
+}
@@ -0,0 +1,99 @@
+package services
+
+import "testing"
+
+func TestParseTagAddress(t *testing.T) {
+	s7 := PLCConfig{IP: "10.0.0.1", DBNumber: 19}
+	modbus := PLCConfig{IP: "10.0.0.2", Driver: driverModbus}
+
+	tests := []struct {
+		name    string
+		tag     PLCTag
+		config  PLCConfig
+		want    tagAddress
+		wantErr bool
+	}{
+		{"offset legado real", PLCTag{Type: "real", Offset: 4}, s7, tagAddress{Area: areaDB, DBNumber: 19, Byte: 4}, false},
+		{"offset legado bool", PLCTag{Type: "bool", Offset: 52.3}, s7, tagAddress{Area: areaDB, DBNumber: 19, Byte: 52, Bit: 3}, false},
+		{"offset com bit 8", PLCTag{Type: "bool", Offset: 52.8}, s7, tagAddress{}, true},
+		{"offset negativo", PLCTag{Type: "int", Offset: -2}, s7, tagAddress{}, true},
+		{"DB bit", PLCTag{Type: "bool", Address: "DB19.DBX52.3"}, s7, tagAddress{Area: areaDB, DBNumber: 19, Byte: 52, Bit: 3, Width: "X"}, false},
+		{"DB word com %", PLCTag{Type: "int", Address: "%db5.dbw66"}, s7, tagAddress{Area: areaDB, DBNumber: 5, Byte: 66, Width: "W"}, false},
+		{"DB dword", PLCTag{Type: "real", Address: "DB19.DBD0"}, s7, tagAddress{Area: areaDB, DBNumber: 19, Byte: 0, Width: "D"}, false},
+		{"DB byte para string", PLCTag{Type: "string[20]", Address: "DB19.DBB100"}, s7, tagAddress{Area: areaDB, DBNumber: 19, Byte: 100, Width: "B"}, false},
+		{"DB zero", PLCTag{Type: "int", Address: "DB0.DBW0"}, s7, tagAddress{}, true},
+		{"largura incompatível", PLCTag{Type: "real", Address: "DB19.DBW0"}, s7, tagAddress{}, true},
+		{"bit em tipo não bool", PLCTag{Type: "int", Address: "DB19.DBX0.1"}, s7, tagAddress{}, true},
+		{"bool sem bit", PLCTag{Type: "bool", Address: "DB19.DBB0"}, s7, tagAddress{}, true},
+		{"bit 8", PLCTag{Type: "bool", Address: "DB19.DBX0.8"}, s7, tagAddress{}, true},
+		{"entrada", PLCTag{Type: "bool", Address: "I0.1"}, s7, tagAddress{Area: areaInputs, Byte: 0, Bit: 1, Width: "X"}, false},
+		{"entrada alemã", PLCTag{Type: "int", Address: "EW64"}, s7, tagAddress{Area: areaInputs, Byte: 64, Width: "W"}, false},
+		{"saída alemã", PLCTag{Type: "bool", Address: "A4.0"}, s7, tagAddress{Area: areaOutputs, Byte: 4, Width: "X"}, false},
+		{"merker dword", PLCTag{Type: "dint", Address: "MD20"}, s7, tagAddress{Area: areaMerkers, Byte: 20, Width: "D"}, false},
+		{"merker sem bit nem largura", PLCTag{Type: "byte", Address: "M5"}, s7, tagAddress{}, true},
+		{"timer", PLCTag{Type: "word", Address: "T5"}, s7, tagAddress{Area: areaTimers, Byte: 5}, false},
+		{"contador alemão", PLCTag{Type: "int", Address: "Z3"}, s7, tagAddress{Area: areaCounter, Byte: 3}, false},
+		{"timer de 32 bits", PLCTag{Type: "dint", Address: "T5"}, s7, tagAddress{}, true},
+		{"endereço inválido", PLCTag{Type: "int", Address: "XYZ"}, s7, tagAddress{}, true},
+		{"Modbus em S7", PLCTag{Type: "int", Address: "HR100"}, s7, tagAddress{}, true},
+
+		{"holding register", PLCTag{Type: "int", Address: "HR100"}, modbus, tagAddress{Area: areaHoldingRegisters, Byte: 100}, false},
+		{"bit de registrador", PLCTag{Type: "bool", Address: "HR100.12"}, modbus, tagAddress{Area: areaHoldingRegisters, Byte: 100, Bit: 12, Width: "X"}, false},
+		{"bit de registrador 16", PLCTag{Type: "bool", Address: "HR100.16"}, modbus, tagAddress{}, true},
+		{"bool em registrador sem bit", PLCTag{Type: "bool", Address: "IR5"}, modbus, tagAddress{}, true},
+		{"coil", PLCTag{Type: "bool", Address: "CO17"}, modbus, tagAddress{Area: areaCoils, Byte: 2, Bit: 1, Width: "X"}, false},
+		{"entrada discreta", PLCTag{Type: "bool", Address: "DI3"}, modbus, tagAddress{Area: areaDiscreteInputs, Byte: 0, Bit: 3, Width: "X"}, false},
+		{"coil não bool", PLCTag{Type: "int", Address: "CO1"}, modbus, tagAddress{}, true},
+		{"byte em registrador", PLCTag{Type: "byte", Address: "HR5"}, modbus, tagAddress{}, true},
+		{"string ímpar em registrador", PLCTag{Type: "string", Length: 3, Address: "HR5"}, modbus, tagAddress{}, true},
+		{"string par em registrador", PLCTag{Type: "string", Length: 4, Address: "HR5"}, modbus, tagAddress{Area: areaHoldingRegisters, Byte: 5}, false},
+		{"word order da tag", PLCTag{Type: "real", Address: "HR10", WordOrder: "little"}, modbus, tagAddress{Area: areaHoldingRegisters, Byte: 10, WordSwap: true}, false},
+		{"word order inválido", PLCTag{Type: "real", Address: "HR10", WordOrder: "middle"}, modbus, tagAddress{}, true},
+		{"fora da faixa", PLCTag{Type: "int", Address: "HR70000"}, modbus, tagAddress{}, true},
+		{"S7 em Modbus", PLCTag{Type: "int", Address: "MW10"}, modbus, tagAddress{}, true},
+		{"Modbus sem address", PLCTag{Type: "int", Offset: 2}, modbus, tagAddress{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := parseTagType(tt.tag)
+			if err != nil {
+				t.Fatalf("parseTagType: %v", err)
+			}
+			got, err := parseTagAddress(tt.tag, info, tt.config)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("esperado erro, obtido %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("erro inesperado: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("obtido %+v, esperado %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTagAddressString(t *testing.T) {
+	tests := []struct {
+		addr tagAddress
+		want string
+	}{
+		{tagAddress{Area: areaDB, DBNumber: 19, Byte: 52, Bit: 3, Width: "X"}, "DB19.DBX52.3"},
+		{tagAddress{Area: areaDB, DBNumber: 19, Byte: 66, Width: "W"}, "DB19.DBW66"},
+		{tagAddress{Area: areaMerkers, Byte: 10, Width: "W"}, "MW10"},
+		{tagAddress{Area: areaInputs, Byte: 0, Bit: 1, Width: "X"}, "I0.1"},
+		{tagAddress{Area: areaTimers, Byte: 5}, "T5"},
+		{tagAddress{Area: areaCoils, Byte: 2, Bit: 1, Width: "X"}, "CO17"},
+		{tagAddress{Area: areaHoldingRegisters, Byte: 100, Bit: 12, Width: "X"}, "HR100.12"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.addr.String(); got != tt.want {
+			t.Errorf("%+v: obtido %s, esperado %s", tt.addr, got, tt.want)
+		}
+	}
+}
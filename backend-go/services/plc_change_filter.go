@@ -0,0 +1,95 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Tipos de banda morta aceitos em "deadband_type"
+const (
+	deadbandAbsolute = "absolute"
+	deadbandPercent  = "percent"
+)
+
+// publishedValue é o último valor de um tag enviado aos clientes
+type publishedValue struct {
+	Value interface{}
+	At    time.Time
+}
+
+// validateChangePolicy verifica banda morta e intervalo mínimo de publicação do tag
+func validateChangePolicy(tag PLCTag) error {
+	if tag.Deadband < 0 {
+		return fmt.Errorf("deadband negativo")
+	}
+	switch tag.DeadbandType {
+	case "", deadbandAbsolute, deadbandPercent:
+	default:
+		return fmt.Errorf("deadband_type inválido: %s (use absolute ou percent)", tag.DeadbandType)
+	}
+	if tag.MinPublishMs < 0 {
+		return fmt.Errorf("min_publish_ms negativo")
+	}
+	return nil
+}
+
+// sameValue compara duas leituras; NaN repetido conta como igual, para que um tag
+// inválido não seja republicado a cada varredura
+func sameValue(a interface{}, b interface{}) bool {
+	if a == b {
+		return true
+	}
+	switch v := a.(type) {
+	case float32:
+		w, ok := b.(float32)
+		return ok && math.IsNaN(float64(v)) && math.IsNaN(float64(w))
+	case float64:
+		w, ok := b.(float64)
+		return ok && math.IsNaN(v) && math.IsNaN(w)
+	}
+	return false
+}
+
+// exceedsDeadband indica se o valor novo se afastou o suficiente do último publicado.
+// Só vale para valores numéricos; os demais são comparados por igualdade.
+// A banda percentual é relativa à faixa min_value..max_value quando definida,
+// senão ao próprio último valor publicado.
+func exceedsDeadband(tag PLCTag, last interface{}, value interface{}) bool {
+	if tag.Deadband <= 0 {
+		return !sameValue(last, value)
+	}
+
+	lastNumber, ok1 := toFloat64(last)
+	number, ok2 := toFloat64(value)
+	if !ok1 || !ok2 || math.IsNaN(lastNumber) || math.IsNaN(number) {
+		return !sameValue(last, value)
+	}
+
+	band := tag.Deadband
+	if tag.DeadbandType == deadbandPercent {
+		if tag.MinValue != nil && tag.MaxValue != nil && *tag.MaxValue > *tag.MinValue {
+			band = tag.Deadband / 100 * (*tag.MaxValue - *tag.MinValue)
+		} else {
+			band = tag.Deadband / 100 * math.Abs(lastNumber)
+		}
+	}
+
+	return math.Abs(number-lastNumber) > band
+}
+
+// shouldPublish decide se a leitura de um tag deve ser enviada aos clientes,
+// aplicando a banda morta e o intervalo mínimo entre publicações.
+// Uma mudança segurada pelo intervalo é publicada no primeiro ciclo após ele expirar.
+func shouldPublish(tag PLCTag, last publishedValue, exists bool, value interface{}, now time.Time) bool {
+	if !exists {
+		return true
+	}
+	if !exceedsDeadband(tag, last.Value, value) {
+		return false
+	}
+	if tag.MinPublishMs > 0 && now.Sub(last.At) < time.Duration(tag.MinPublishMs)*time.Millisecond {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"math"
+	"testing"
+)
+
+func TestExceedsDeadband(t *testing.T) {
+	nan32 := float32(math.NaN())
+	low, high := 0.0, 200.0
+	absolute := PLCTag{Deadband: 0.5}
+	percent := PLCTag{Deadband: 1, DeadbandType: deadbandPercent, MinValue: &low, MaxValue: &high}
+
+	tests := []struct {
+		name  string
+		tag   PLCTag
+		last  interface{}
+		value interface{}
+		want  bool
+	}{
+		{"sem banda igual", PLCTag{}, int16(3), int16(3), false},
+		{"sem banda diferente", PLCTag{}, int16(3), int16(4), true},
+		{"NaN repetido", PLCTag{}, nan32, nan32, false},
+		{"NaN repetido com banda", absolute, math.NaN(), math.NaN(), false},
+		{"NaN para número", absolute, nan32, float32(1), true},
+		{"dentro da banda absoluta", absolute, 10.0, 10.4, false},
+		{"fora da banda absoluta", absolute, 10.0, 10.6, true},
+		{"banda percentual da faixa", percent, 10.0, 11.9, false},
+		{"fora da banda percentual", percent, 10.0, 12.1, true},
+		{"texto", absolute, "aberta", "fechada", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exceedsDeadband(tt.tag, tt.last, tt.value); got != tt.want {
+				t.Errorf("obtido %v, esperado %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+)
+
+// Tags calculados: um tag com "expression" não é lido do PLC; o valor vem da expressão
+// sobre outros tags, avaliada a cada varredura da sua classe. O resultado passa pela
+// mesma escala, banda morta, qualidade e publicação dos tags lidos.
+
+// computedTag é um tag calculado pronto para avaliação
+type computedTag struct {
+	Name string
+	Tag  PLCTag
+	Info tagTypeInfo
+	Expr *expression
+}
+
+// isComputed indica se o tag é calculado (sem endereço no PLC)
+func (t PLCTag) isComputed() bool {
+	return t.Expression != ""
+}
+
+// computedType resolve o tipo do resultado: bool ou numérico (padrão real)
+func computedType(tag PLCTag) (tagTypeInfo, error) {
+	if tag.Type == "" {
+		return tagTypeInfo{Base: "real"}, nil
+	}
+	info, err := parseTagType(tag)
+	if err != nil {
+		return info, err
+	}
+	if info.Base != "bool" && !info.isNumeric() {
+		return info, fmt.Errorf("tag calculado deve ser bool ou numérico (tipo %s)", tag.Type)
+	}
+	return info, nil
+}
+
+// validateComputedTag verifica a expressão e os tags que ela usa
+func validateComputedTag(tag PLCTag, conn PLCConnection) (tagTypeInfo, error) {
+	info, err := computedType(tag)
+	if err != nil {
+		return info, err
+	}
+	if tag.Address != "" {
+		return info, fmt.Errorf("tag calculado não tem address")
+	}
+
+	expr, err := parseExpression(tag.Expression)
+	if err != nil {
+		return info, err
+	}
+	for _, ref := range expr.refs {
+		if _, ok := conn.Tags[ref]; !ok {
+			return info, fmt.Errorf("expressão usa tag desconhecido: %s", ref)
+		}
+	}
+	return info, nil
+}
+
+// computedOrder ordena os tags calculados para que cada um seja avaliado depois
+// dos calculados de que depende; dependência circular é erro
+func computedOrder(conn PLCConnection) ([]string, error) {
+	names := make([]string, 0)
+	for name, tag := range conn.Tags {
+		if tag.isComputed() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int)
+	order := make([]string, 0, len(names))
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case done:
+			return nil
+		case visiting:
+			return fmt.Errorf("dependência circular entre tags calculados: %v", append(path, name))
+		}
+		state[name] = visiting
+
+		expr, err := parseExpression(conn.Tags[name].Expression)
+		if err != nil {
+			return fmt.Errorf("tag %s: %v", name, err)
+		}
+		for _, ref := range expr.refs {
+			if tag, ok := conn.Tags[ref]; ok && tag.isComputed() {
+				if err := visit(ref, append(path, name)); err != nil {
+					return err
+				}
+			}
+		}
+
+		state[name] = done
+		order = append(order, name)
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// buildComputedPlans prepara os tags calculados de cada classe de varredura, em ordem de dependência
+func buildComputedPlans(conn PLCConnection) map[string][]computedTag {
+	plans := make(map[string][]computedTag)
+
+	order, err := computedOrder(conn)
+	if err != nil {
+		log.Printf("⚠️ [%s] Tags calculados desativados: %v", conn.ID, err)
+		return plans
+	}
+
+	for _, name := range order {
+		tag := conn.Tags[name]
+		info, err := validateComputedTag(tag, conn)
+		if err != nil {
+			log.Printf("⚠️ [%s] Tag calculado %s ignorado: %v", conn.ID, name, err)
+			continue
+		}
+		expr, _ := parseExpression(tag.Expression)
+
+		class := conn.tagScanClass(tag)
+		plans[class] = append(plans[class], computedTag{Name: name, Tag: tag, Info: info, Expr: expr})
+	}
+	return plans
+}
+
+// qualityRank ordena as qualidades da melhor para a pior
+var qualityRank = map[string]int{
+	QualityGood:      0,
+	QualityUncertain: 1,
+	QualityStale:     2,
+	QualityBadComm:   3,
+	QualityBadConfig: 4,
+}
+
+// worseQuality retorna a pior das duas qualidades
+func worseQuality(a, b string) string {
+	if qualityRank[b] > qualityRank[a] {
+		return b
+	}
+	return a
+}
+
+// computedResult converte o resultado da expressão no tipo do tag
+func computedResult(info tagTypeInfo, value interface{}) (interface{}, error) {
+	if info.Base == "bool" {
+		return exprBool(value)
+	}
+	number, err := exprNumber(value)
+	if err != nil {
+		return nil, err
+	}
+	if info.Base != "real" && info.Base != "lreal" && !math.IsNaN(number) && !math.IsInf(number, 0) {
+		number = math.Round(number)
+	}
+	return number, nil
+}
+
+// evaluateComputed avalia os tags calculados da classe com os valores atuais do cache.
+// A qualidade do resultado é a pior entre a do cálculo e a dos tags usados.
+// Retorna true quando algum valor foi publicado ou mudou de qualidade.
+func (s7 *S7PLCConnector) evaluateComputed(class string, now time.Time, staleAfter time.Duration) bool {
+	s7.mutex.Lock()
+	if s7.computedPlans == nil {
+		s7.computedPlans = buildComputedPlans(s7.config)
+	}
+	plan := s7.computedPlans[class]
+	s7.mutex.Unlock()
+
+	hasChanges := false
+	for _, ct := range plan {
+		values := make(map[string]TagValue, len(ct.Expr.refs))
+		s7.currentMutex.RLock()
+		for _, ref := range ct.Expr.refs {
+			values[ref] = s7.currentValues[ref]
+		}
+		s7.currentMutex.RUnlock()
+
+		inputQuality := QualityGood
+		for _, value := range values {
+			inputQuality = worseQuality(inputQuality, value.effectiveQuality(now))
+		}
+
+		result, err := ct.Expr.eval(func(name string) (interface{}, bool) {
+			value, ok := values[name]
+			return value.Value, ok && value.Quality != QualityBadConfig
+		}, now)
+		if err == nil {
+			result, err = computedResult(ct.Info, result)
+		}
+		if err != nil {
+			quality := QualityBadComm
+			if !errors.Is(err, errExprNoValue) {
+				quality = QualityBadConfig
+				if time.Now().Unix()%60 == 0 {
+					log.Printf("⚠️ [%s] Erro ao calcular tag %s: %v", s7.lockID, ct.Name, err)
+				}
+			}
+			if s7.setQuality(ct.Name, worseQuality(quality, inputQuality)) {
+				hasChanges = true
+			}
+			continue
+		}
+
+		if s7.storeTagValue(ct.Name, ct.Tag, scaleTagValue(ct.Tag, result), now, staleAfter, inputQuality) {
+			hasChanges = true
+		}
+	}
+	return hasChanges
+}
@@ -0,0 +1,419 @@
+package services
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// Comprimento padrão de STRING/WSTRING no S7 quando não é informado
+const s7DefaultStringLength = 254
+
+// tagTypeInfo é o tipo S7 normalizado de um tag
+type tagTypeInfo struct {
+	Base   string // nome canônico do tipo (ex: "dint", "string")
+	Length int    // comprimento máximo para string/wstring
+}
+
+// parseTagType normaliza o tipo do tag. Aceita a sintaxe S7 "string[20]"
+// ou o campo "length" do tag, e os aliases usuais do TIA Portal.
+func parseTagType(tag PLCTag) (tagTypeInfo, error) {
+	typeName := strings.ToLower(strings.TrimSpace(tag.Type))
+	length := tag.Length
+
+	if open := strings.Index(typeName, "["); open >= 0 && strings.HasSuffix(typeName, "]") {
+		n, err := strconv.Atoi(typeName[open+1 : len(typeName)-1])
+		if err != nil || n <= 0 {
+			return tagTypeInfo{}, fmt.Errorf("comprimento inválido no tipo %s", tag.Type)
+		}
+		length = n
+		typeName = typeName[:open]
+	}
+
+	switch typeName {
+	case "date_and_time":
+		typeName = "dt"
+	case "float":
+		typeName = "real"
+	case "double":
+		typeName = "lreal"
+	}
+
+	switch typeName {
+	case "bool", "byte", "usint", "sint", "char",
+		"word", "uint", "int",
+		"dword", "udint", "dint", "real", "time",
+		"lreal", "dt", "dtl":
+		return tagTypeInfo{Base: typeName}, nil
+	case "string", "wstring":
+		if length <= 0 {
+			length = s7DefaultStringLength
+		}
+		if typeName == "string" && length > 254 {
+			return tagTypeInfo{}, fmt.Errorf("STRING suporta no máximo 254 caracteres")
+		}
+		return tagTypeInfo{Base: typeName, Length: length}, nil
+	default:
+		return tagTypeInfo{}, fmt.Errorf("tipo de tag não suportado: %s", tag.Type)
+	}
+}
+
+// byteSize retorna quantos bytes o tipo ocupa na memória do PLC
+func (t tagTypeInfo) byteSize() int {
+	switch t.Base {
+	case "bool", "byte", "usint", "sint", "char":
+		return 1
+	case "word", "uint", "int":
+		return 2
+	case "dword", "udint", "dint", "real", "time":
+		return 4
+	case "lreal", "dt":
+		return 8
+	case "dtl":
+		return 12
+	case "string":
+		return t.Length + 2 // max + atual + caracteres
+	case "wstring":
+		return 2*t.Length + 4 // max + atual (WORD) + caracteres UTF-16
+	}
+	return 0
+}
+
+// isNumeric indica se o tipo é lido como número (aceita escala de engenharia)
+func (t tagTypeInfo) isNumeric() bool {
+	switch t.Base {
+	case "byte", "usint", "sint", "word", "uint", "int",
+		"dword", "udint", "dint", "real", "time", "lreal":
+		return true
+	}
+	return false
+}
+
+// jsonSafeValue troca NaN/Inf (que o JSON não representa) por nil
+func jsonSafeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case float32:
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return nil
+		}
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil
+		}
+	}
+	return value
+}
+
+// decodeS7Value interpreta os bytes (big-endian) de um tag no tipo Go correspondente:
+// byte/usint→uint8, sint→int8, word/uint→uint16, int→int16, dword/udint→uint32,
+// dint→int32, real→float32, lreal→float64, time→int32 (ms), char/string/wstring→string,
+// dt/dtl→time.Time (hora local do PLC)
+func decodeS7Value(info tagTypeInfo, bit int, data []byte) (interface{}, error) {
+	if len(data) < info.byteSize() {
+		return nil, fmt.Errorf("buffer curto para %s: %d bytes", info.Base, len(data))
+	}
+
+	switch info.Base {
+	case "bool":
+		return (data[0] & (1 << bit)) != 0, nil
+	case "byte", "usint":
+		return data[0], nil
+	case "sint":
+		return int8(data[0]), nil
+	case "char":
+		return string(rune(data[0])), nil
+	case "word", "uint":
+		return binary.BigEndian.Uint16(data), nil
+	case "int":
+		return int16(binary.BigEndian.Uint16(data)), nil
+	case "dword", "udint":
+		return binary.BigEndian.Uint32(data), nil
+	case "dint", "time":
+		return int32(binary.BigEndian.Uint32(data)), nil
+	case "real":
+		return math.Float32frombits(binary.BigEndian.Uint32(data)), nil
+	case "lreal":
+		return math.Float64frombits(binary.BigEndian.Uint64(data)), nil
+	case "string":
+		return decodeS7String(data, info.Length), nil
+	case "wstring":
+		return decodeS7WString(data, info.Length), nil
+	case "dt":
+		return decodeS7DateAndTime(data)
+	case "dtl":
+		return decodeS7DTL(data)
+	}
+	return nil, fmt.Errorf("tipo de tag não suportado: %s", info.Base)
+}
+
+// decodeS7String lê um STRING S7: [tamanho máximo][tamanho atual][caracteres Latin-1]
+func decodeS7String(data []byte, maxLength int) string {
+	length := int(data[1])
+	if length > maxLength {
+		length = maxLength
+	}
+	if length > len(data)-2 {
+		length = len(data) - 2
+	}
+
+	runes := make([]rune, length)
+	for i := 0; i < length; i++ {
+		runes[i] = rune(data[2+i])
+	}
+	return string(runes)
+}
+
+// decodeS7WString lê um WSTRING S7: [máximo WORD][atual WORD][caracteres UTF-16BE]
+func decodeS7WString(data []byte, maxLength int) string {
+	length := int(binary.BigEndian.Uint16(data[2:]))
+	if length > maxLength {
+		length = maxLength
+	}
+	if 4+2*length > len(data) {
+		length = (len(data) - 4) / 2
+	}
+
+	units := make([]uint16, length)
+	for i := 0; i < length; i++ {
+		units[i] = binary.BigEndian.Uint16(data[4+2*i:])
+	}
+	return string(utf16.Decode(units))
+}
+
+func bcdToInt(b byte) int {
+	return int(b>>4)*10 + int(b&0x0F)
+}
+
+func intToBCD(n int) byte {
+	return byte((n/10)%10<<4 | n%10)
+}
+
+// decodeS7DateAndTime lê um DATE_AND_TIME (8 bytes BCD)
+func decodeS7DateAndTime(data []byte) (time.Time, error) {
+	year := bcdToInt(data[0])
+	if year >= 90 {
+		year += 1900
+	} else {
+		year += 2000
+	}
+	ms := bcdToInt(data[6])*10 + int(data[7]>>4)
+
+	month := bcdToInt(data[1])
+	if month < 1 || month > 12 {
+		return time.Time{}, fmt.Errorf("DATE_AND_TIME inválido: mês %d", month)
+	}
+
+	return time.Date(year, time.Month(month), bcdToInt(data[2]),
+		bcdToInt(data[3]), bcdToInt(data[4]), bcdToInt(data[5]),
+		ms*int(time.Millisecond), time.Local), nil
+}
+
+// decodeS7DTL lê um DTL (12 bytes: ano WORD, mês, dia, dia da semana, hora,
+// minuto, segundo e nanossegundos DWORD)
+func decodeS7DTL(data []byte) (time.Time, error) {
+	month := int(data[2])
+	if month < 1 || month > 12 {
+		return time.Time{}, fmt.Errorf("DTL inválido: mês %d", month)
+	}
+
+	return time.Date(int(binary.BigEndian.Uint16(data[0:])), time.Month(month), int(data[3]),
+		int(data[5]), int(data[6]), int(data[7]),
+		int(binary.BigEndian.Uint32(data[8:])), time.Local), nil
+}
+
+// encodeS7Value converte um valor recebido via JSON nos bytes do tipo S7.
+// Bool gera um byte 0/1 que o chamador aplica no bit com leitura-modificação-escrita.
+func encodeS7Value(info tagTypeInfo, value interface{}, limits TagLimits) ([]byte, error) {
+	buffer := make([]byte, info.byteSize())
+
+	switch info.Base {
+	case "bool":
+		switch v := value.(type) {
+		case bool:
+			if v {
+				buffer[0] = 1
+			}
+			return buffer, nil
+		case float64:
+			if v == 0 || v == 1 {
+				buffer[0] = byte(v)
+				return buffer, nil
+			}
+		}
+		return nil, fmt.Errorf("%w: esperado booleano", ErrInvalidTagValue)
+
+	case "char", "string", "wstring":
+		text, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: esperado texto", ErrInvalidTagValue)
+		}
+		return encodeS7Text(info, text, buffer)
+
+	case "dt", "dtl":
+		text, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: esperado data/hora RFC3339", ErrInvalidTagValue)
+		}
+		t, err := time.Parse(time.RFC3339Nano, text)
+		if err != nil {
+			return nil, fmt.Errorf("%w: esperado data/hora RFC3339", ErrInvalidTagValue)
+		}
+		return encodeS7Time(info, t.In(time.Local), buffer)
+	}
+
+	number, ok := value.(float64)
+	if !ok || math.IsNaN(number) || math.IsInf(number, 0) {
+		return nil, fmt.Errorf("%w: esperado número", ErrInvalidTagValue)
+	}
+
+	if limits.MinValue != nil && number < *limits.MinValue {
+		return nil, fmt.Errorf("%w: %v abaixo do mínimo %v", ErrInvalidTagValue, number, *limits.MinValue)
+	}
+	if limits.MaxValue != nil && number > *limits.MaxValue {
+		return nil, fmt.Errorf("%w: %v acima do máximo %v", ErrInvalidTagValue, number, *limits.MaxValue)
+	}
+
+	switch info.Base {
+	case "real":
+		if math.Abs(number) > math.MaxFloat32 {
+			return nil, fmt.Errorf("%w: fora da faixa REAL", ErrInvalidTagValue)
+		}
+		binary.BigEndian.PutUint32(buffer, math.Float32bits(float32(number)))
+		return buffer, nil
+	case "lreal":
+		binary.BigEndian.PutUint64(buffer, math.Float64bits(number))
+		return buffer, nil
+	}
+
+	// Tipos inteiros
+	var min, max float64
+	switch info.Base {
+	case "byte", "usint":
+		min, max = 0, math.MaxUint8
+	case "sint":
+		min, max = math.MinInt8, math.MaxInt8
+	case "word", "uint":
+		min, max = 0, math.MaxUint16
+	case "int":
+		min, max = math.MinInt16, math.MaxInt16
+	case "dword", "udint":
+		min, max = 0, math.MaxUint32
+	case "dint", "time":
+		min, max = math.MinInt32, math.MaxInt32
+	default:
+		return nil, fmt.Errorf("%w: tipo %s não suporta escrita", ErrInvalidTagValue, info.Base)
+	}
+
+	if number != math.Trunc(number) || number < min || number > max {
+		return nil, fmt.Errorf("%w: esperado %s inteiro entre %.0f e %.0f",
+			ErrInvalidTagValue, strings.ToUpper(info.Base), min, max)
+	}
+
+	integer := int64(number)
+	switch len(buffer) {
+	case 1:
+		buffer[0] = byte(integer)
+	case 2:
+		binary.BigEndian.PutUint16(buffer, uint16(integer))
+	case 4:
+		binary.BigEndian.PutUint32(buffer, uint32(integer))
+	}
+	return buffer, nil
+}
+
+// encodeS7Text monta CHAR, STRING (Latin-1) ou WSTRING (UTF-16BE) com cabeçalho S7
+func encodeS7Text(info tagTypeInfo, text string, buffer []byte) ([]byte, error) {
+	runes := []rune(text)
+
+	switch info.Base {
+	case "char":
+		if len(runes) != 1 || runes[0] > 0xFF {
+			return nil, fmt.Errorf("%w: CHAR aceita um caractere Latin-1", ErrInvalidTagValue)
+		}
+		buffer[0] = byte(runes[0])
+
+	case "string":
+		if len(runes) > info.Length {
+			return nil, fmt.Errorf("%w: texto maior que STRING[%d]", ErrInvalidTagValue, info.Length)
+		}
+		buffer[0] = byte(info.Length)
+		buffer[1] = byte(len(runes))
+		for i, r := range runes {
+			if r > 0xFF {
+				return nil, fmt.Errorf("%w: caractere %q fora do Latin-1", ErrInvalidTagValue, r)
+			}
+			buffer[2+i] = byte(r)
+		}
+
+	case "wstring":
+		units := utf16.Encode(runes)
+		if len(units) > info.Length {
+			return nil, fmt.Errorf("%w: texto maior que WSTRING[%d]", ErrInvalidTagValue, info.Length)
+		}
+		binary.BigEndian.PutUint16(buffer[0:], uint16(info.Length))
+		binary.BigEndian.PutUint16(buffer[2:], uint16(len(units)))
+		for i, u := range units {
+			binary.BigEndian.PutUint16(buffer[4+2*i:], u)
+		}
+	}
+
+	return buffer, nil
+}
+
+// encodeS7Time monta DATE_AND_TIME (BCD) ou DTL
+func encodeS7Time(info tagTypeInfo, t time.Time, buffer []byte) ([]byte, error) {
+	if info.Base == "dtl" {
+		binary.BigEndian.PutUint16(buffer[0:], uint16(t.Year()))
+		buffer[2] = byte(t.Month())
+		buffer[3] = byte(t.Day())
+		buffer[4] = byte(t.Weekday()) + 1 // S7: 1 = domingo
+		buffer[5] = byte(t.Hour())
+		buffer[6] = byte(t.Minute())
+		buffer[7] = byte(t.Second())
+		binary.BigEndian.PutUint32(buffer[8:], uint32(t.Nanosecond()))
+		return buffer, nil
+	}
+
+	if t.Year() < 1990 || t.Year() > 2089 {
+		return nil, fmt.Errorf("%w: DATE_AND_TIME suporta anos de 1990 a 2089", ErrInvalidTagValue)
+	}
+	ms := t.Nanosecond() / int(time.Millisecond)
+	buffer[0] = intToBCD(t.Year() % 100)
+	buffer[1] = intToBCD(int(t.Month()))
+	buffer[2] = intToBCD(t.Day())
+	buffer[3] = intToBCD(t.Hour())
+	buffer[4] = intToBCD(t.Minute())
+	buffer[5] = intToBCD(t.Second())
+	buffer[6] = intToBCD(ms / 10)
+	buffer[7] = byte(ms%10)<<4 | byte(t.Weekday()+1)
+	return buffer, nil
+}
+
+// toFloat64 converte os valores numéricos decodificados para float64
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case uint8:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	}
+	return 0, false
+}
@@ -0,0 +1,229 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestParseTagType(t *testing.T) {
+	tests := []struct {
+		typ     string
+		length  int
+		want    tagTypeInfo
+		size    int
+		wantErr bool
+	}{
+		{"bool", 0, tagTypeInfo{Base: "bool"}, 1, false},
+		{"INT", 0, tagTypeInfo{Base: "int"}, 2, false},
+		{"float", 0, tagTypeInfo{Base: "real"}, 4, false},
+		{"double", 0, tagTypeInfo{Base: "lreal"}, 8, false},
+		{"Date_And_Time", 0, tagTypeInfo{Base: "dt"}, 8, false},
+		{"dtl", 0, tagTypeInfo{Base: "dtl"}, 12, false},
+		{"string[20]", 0, tagTypeInfo{Base: "string", Length: 20}, 22, false},
+		{"string", 10, tagTypeInfo{Base: "string", Length: 10}, 12, false},
+		{"string", 0, tagTypeInfo{Base: "string", Length: 254}, 256, false},
+		{"wstring[8]", 0, tagTypeInfo{Base: "wstring", Length: 8}, 20, false},
+		{"string[300]", 0, tagTypeInfo{}, 0, true},
+		{"string[x]", 0, tagTypeInfo{}, 0, true},
+		{"udt_motor", 0, tagTypeInfo{}, 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseTagType(PLCTag{Type: tt.typ, Length: tt.length})
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: esperado erro", tt.typ)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: erro inesperado: %v", tt.typ, err)
+			continue
+		}
+		if got != tt.want || got.byteSize() != tt.size {
+			t.Errorf("%s: obtido %+v (%d bytes), esperado %+v (%d bytes)", tt.typ, got, got.byteSize(), tt.want, tt.size)
+		}
+	}
+}
+
+func TestDecodeS7Value(t *testing.T) {
+	tests := []struct {
+		typ  string
+		bit  int
+		data []byte
+		want interface{}
+	}{
+		{"bool", 3, []byte{0x08}, true},
+		{"bool", 2, []byte{0x08}, false},
+		{"byte", 0, []byte{0xFE}, uint8(254)},
+		{"sint", 0, []byte{0xFE}, int8(-2)},
+		{"char", 0, []byte{'A'}, "A"},
+		{"word", 0, []byte{0x12, 0x34}, uint16(0x1234)},
+		{"int", 0, []byte{0xFF, 0xFE}, int16(-2)},
+		{"dword", 0, []byte{0x00, 0x01, 0x00, 0x00}, uint32(65536)},
+		{"dint", 0, []byte{0xFF, 0xFF, 0xFF, 0xFF}, int32(-1)},
+		{"time", 0, []byte{0x00, 0x00, 0x03, 0xE8}, int32(1000)},
+		{"real", 0, []byte{0x42, 0x90, 0xCC, 0xCD}, float32(72.4)},
+		{"lreal", 0, []byte{0x40, 0x09, 0x21, 0xFB, 0x54, 0x44, 0x2D, 0x18}, math.Pi},
+		{"string[10]", 0, []byte{10, 3, 'a', 'b', 'c', 0, 0, 0, 0, 0, 0, 0}, "abc"},
+		{"string[2]", 0, []byte{2, 5, 'a', 'b'}, "ab"}, // tamanho atual maior que o máximo
+		{"string[4]", 0, []byte{4, 2, 0xE7, 0xE3, 0, 0}, "çã"},
+		{"wstring[4]", 0, []byte{0, 4, 0, 2, 0x00, 0xE7, 0x20, 0xAC, 0, 0, 0, 0}, "ç€"},
+	}
+
+	for _, tt := range tests {
+		info, err := parseTagType(PLCTag{Type: tt.typ})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.typ, err)
+		}
+		got, err := decodeS7Value(info, tt.bit, tt.data)
+		if err != nil {
+			t.Errorf("%s: erro inesperado: %v", tt.typ, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: obtido %#v, esperado %#v", tt.typ, got, tt.want)
+		}
+	}
+
+	if _, err := decodeS7Value(tagTypeInfo{Base: "dint"}, 0, []byte{1, 2}); err == nil {
+		t.Error("buffer curto: esperado erro")
+	}
+}
+
+func TestDecodeS7Time(t *testing.T) {
+	dt, err := decodeS7DateAndTime([]byte{0x24, 0x03, 0x15, 0x13, 0x45, 0x30, 0x12, 0x34})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2024, 3, 15, 13, 45, 30, 123*int(time.Millisecond), time.Local)
+	if !dt.Equal(want) {
+		t.Errorf("DATE_AND_TIME: obtido %v, esperado %v", dt, want)
+	}
+
+	dtl, err := decodeS7DTL([]byte{0x07, 0xE8, 3, 15, 6, 13, 45, 30, 0x07, 0x54, 0xD4, 0xC0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = time.Date(2024, 3, 15, 13, 45, 30, 123000000, time.Local)
+	if !dtl.Equal(want) {
+		t.Errorf("DTL: obtido %v, esperado %v", dtl, want)
+	}
+
+	if _, err := decodeS7DTL([]byte{0x07, 0xE8, 13, 1, 0, 0, 0, 0, 0, 0, 0, 0}); err == nil {
+		t.Error("DTL com mês 13: esperado erro")
+	}
+}
+
+func TestEncodeS7Value(t *testing.T) {
+	min, max := -10.0, 100.0
+	limits := TagLimits{MinValue: &min, MaxValue: &max}
+
+	tests := []struct {
+		typ     string
+		value   interface{}
+		limits  TagLimits
+		want    []byte
+		wantErr bool
+	}{
+		{"bool", true, TagLimits{}, []byte{1}, false},
+		{"bool", 0.0, TagLimits{}, []byte{0}, false},
+		{"bool", 2.0, TagLimits{}, nil, true},
+		{"byte", 255.0, TagLimits{}, []byte{0xFF}, false},
+		{"byte", 256.0, TagLimits{}, nil, true},
+		{"sint", -128.0, TagLimits{}, []byte{0x80}, false},
+		{"int", -2.0, TagLimits{}, []byte{0xFF, 0xFE}, false},
+		{"int", 1.5, TagLimits{}, nil, true},
+		{"word", -1.0, TagLimits{}, nil, true},
+		{"dint", -1.0, TagLimits{}, []byte{0xFF, 0xFF, 0xFF, 0xFF}, false},
+		{"udint", 65536.0, TagLimits{}, []byte{0x00, 0x01, 0x00, 0x00}, false},
+		{"real", 72.4, TagLimits{}, []byte{0x42, 0x90, 0xCC, 0xCD}, false},
+		{"real", 1e39, TagLimits{}, nil, true},
+		{"real", math.NaN(), TagLimits{}, nil, true},
+		{"lreal", math.Pi, TagLimits{}, []byte{0x40, 0x09, 0x21, 0xFB, 0x54, 0x44, 0x2D, 0x18}, false},
+		{"int", 50.0, limits, []byte{0x00, 0x32}, false},
+		{"int", 101.0, limits, nil, true},
+		{"int", -11.0, limits, nil, true},
+		{"int", "12", TagLimits{}, nil, true},
+		{"string[5]", "abc", TagLimits{}, []byte{5, 3, 'a', 'b', 'c', 0, 0}, false},
+		{"string[2]", "abc", TagLimits{}, nil, true},
+		{"string[5]", 12.0, TagLimits{}, nil, true},
+	}
+
+	for _, tt := range tests {
+		info, err := parseTagType(PLCTag{Type: tt.typ})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.typ, err)
+		}
+		got, err := encodeS7Value(info, tt.value, tt.limits)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s %v: esperado erro, obtido % x", tt.typ, tt.value, got)
+			} else if !errors.Is(err, ErrInvalidTagValue) {
+				t.Errorf("%s %v: erro deveria ser ErrInvalidTagValue: %v", tt.typ, tt.value, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s %v: erro inesperado: %v", tt.typ, tt.value, err)
+			continue
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("%s %v: obtido % x, esperado % x", tt.typ, tt.value, got, tt.want)
+		}
+	}
+}
+
+// Escrever e ler de volta deve devolver o mesmo valor
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	tests := []struct {
+		typ   string
+		value interface{}
+	}{
+		{"wstring[10]", "Eclusa ç€"},
+		{"string[20]", "Régua"},
+		{"char", "Z"},
+		{"dtl", "2024-03-15T13:45:30.5Z"},
+		{"dt", "2024-03-15T13:45:30.12Z"},
+	}
+
+	for _, tt := range tests {
+		info, err := parseTagType(PLCTag{Type: tt.typ})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.typ, err)
+		}
+		data, err := encodeS7Value(info, tt.value, TagLimits{})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.typ, err)
+		}
+		got, err := decodeS7Value(info, 0, data)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.typ, err)
+		}
+
+		if when, ok := got.(time.Time); ok {
+			want, _ := time.Parse(time.RFC3339Nano, tt.value.(string))
+			if !when.Equal(want) {
+				t.Errorf("%s: obtido %v, esperado %v", tt.typ, when, want)
+			}
+			continue
+		}
+		if got != tt.value {
+			t.Errorf("%s: obtido %#v, esperado %#v", tt.typ, got, tt.value)
+		}
+	}
+}
+
+func TestJSONSafeValue(t *testing.T) {
+	for _, value := range []interface{}{math.NaN(), math.Inf(1), float32(math.Inf(-1))} {
+		if got := jsonSafeValue(value); got != nil {
+			t.Errorf("%v: obtido %v, esperado nil", value, got)
+		}
+	}
+	if got := jsonSafeValue(1.5); got != 1.5 {
+		t.Errorf("1.5: obtido %v", got)
+	}
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/robinson/gos7"
+)
+
+// Drivers de comunicação disponíveis em plc_config.driver (driverModbus em plc_modbus.go)
+const (
+	driverS7        = "s7"
+	driverSimulator = "simulator"
+)
+
+// PLCDriver é o acesso de baixo nível à memória de um PLC.
+// start e size são em unidades da área (bytes; timers/contadores para T/C;
+// registradores para HR/IR; grupos de 8 bits para coils/entradas discretas).
+type PLCDriver interface {
+	Name() string
+	Connect() error
+	Close() error
+	PDULength() int // 0 quando desconhecido (usa o padrão S7)
+	ReadArea(area string, dbNumber int, start int, size int, buffer []byte) error
+	WriteArea(area string, dbNumber int, start int, size int, buffer []byte) error
+	Status() map[string]interface{}
+}
+
+// driverName retorna o driver efetivo da eclusa.
+// PLC_DRIVER=simulator força o simulador em todas as eclusas (desenvolvimento offline).
+func (c PLCConfig) driverName() string {
+	if env := strings.ToLower(strings.TrimSpace(os.Getenv("PLC_DRIVER"))); env != "" {
+		return env
+	}
+	if c.Driver == "" {
+		return driverS7
+	}
+	return strings.ToLower(c.Driver)
+}
+
+// validateDriver verifica se o driver existe e tem os parâmetros necessários
+func (c PLCConfig) validateDriver() error {
+	switch c.driverName() {
+	case driverS7:
+		if c.IP == "" {
+			return fmt.Errorf("plc_config.ip é obrigatório")
+		}
+	case driverModbus:
+		if c.IP == "" {
+			return fmt.Errorf("plc_config.ip é obrigatório")
+		}
+		if c.Port < 0 || c.Port > 65535 {
+			return fmt.Errorf("plc_config.port inválida: %d", c.Port)
+		}
+		if c.UnitID < 0 || c.UnitID > 255 {
+			return fmt.Errorf("plc_config.unit_id inválido: %d", c.UnitID)
+		}
+	case driverSimulator:
+	default:
+		return fmt.Errorf("driver desconhecido: %s", c.driverName())
+	}
+	return nil
+}
+
+// newPLCDriver cria o driver configurado para a eclusa (a configuração já foi validada)
+func newPLCDriver(conn PLCConnection) PLCDriver {
+	switch conn.PLCConfig.driverName() {
+	case driverSimulator:
+		return newSimulatorDriver(conn.ID)
+	case driverModbus:
+		return newModbusDriver(conn.PLCConfig)
+	}
+	return &s7Driver{config: conn.PLCConfig}
+}
+
+// s7Driver fala com o PLC Siemens via gos7 (ISO-on-TCP)
+type s7Driver struct {
+	config  PLCConfig
+	handler *gos7.TCPClientHandler
+	client  gos7.Client
+}
+
+func (d *s7Driver) Name() string {
+	return driverS7
+}
+
+func (d *s7Driver) Connect() error {
+	// Configurar handler S7
+	handler := gos7.NewTCPClientHandler(d.config.IP, d.config.Rack, d.config.Slot)
+	handler.Timeout = 5 * time.Second
+	handler.IdleTimeout = 5 * time.Second
+
+	if err := handler.Connect(); err != nil {
+		return err
+	}
+
+	d.handler = handler
+	d.client = gos7.NewClient(handler)
+	return nil
+}
+
+func (d *s7Driver) Close() error {
+	if d.handler == nil {
+		return nil
+	}
+	err := d.handler.Close()
+	d.handler = nil
+	d.client = nil
+	return err
+}
+
+func (d *s7Driver) PDULength() int {
+	if d.handler == nil {
+		return 0
+	}
+	return d.handler.PDULength
+}
+
+func (d *s7Driver) ReadArea(area string, dbNumber int, start int, size int, buffer []byte) error {
+	if d.client == nil {
+		return ErrPLCNotConnected
+	}
+
+	switch area {
+	case areaDB:
+		return d.client.AGReadDB(dbNumber, start, size, buffer)
+	case areaInputs:
+		return d.client.AGReadEB(start, size, buffer)
+	case areaOutputs:
+		return d.client.AGReadAB(start, size, buffer)
+	case areaMerkers:
+		return d.client.AGReadMB(start, size, buffer)
+	case areaTimers:
+		return d.client.AGReadTM(start, size, buffer)
+	case areaCounter:
+		return d.client.AGReadCT(start, size, buffer)
+	}
+	return fmt.Errorf("área de memória desconhecida: %s", area)
+}
+
+func (d *s7Driver) WriteArea(area string, dbNumber int, start int, size int, buffer []byte) error {
+	if d.client == nil {
+		return ErrPLCNotConnected
+	}
+
+	// Entradas (I) não são graváveis
+	switch area {
+	case areaDB:
+		return d.client.AGWriteDB(dbNumber, start, size, buffer)
+	case areaOutputs:
+		return d.client.AGWriteAB(start, size, buffer)
+	case areaMerkers:
+		return d.client.AGWriteMB(start, size, buffer)
+	case areaTimers:
+		return d.client.AGWriteTM(start, size, buffer)
+	case areaCounter:
+		return d.client.AGWriteCT(start, size, buffer)
+	}
+	return fmt.Errorf("área de memória %s não aceita escrita", area)
+}
+
+func (d *s7Driver) Status() map[string]interface{} {
+	return map[string]interface{}{
+		"ip":   d.config.IP,
+		"rack": d.config.Rack,
+		"slot": d.config.Slot,
+		"pdu":  d.PDULength(),
+	}
+}
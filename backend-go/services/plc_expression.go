@@ -0,0 +1,635 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Expressões de tags calculados (e de outras regras sobre tags).
+//
+//	{Porta Jusante} < 1 && {Porta Montante} < 1
+//	Eclusa_Nivel_Montante - Eclusa_Nivel_Caldeira
+//	rate(Eclusa_Nivel_Caldeira, 10) * 60
+//
+// Tags são referenciados pelo nome (letras, dígitos e _) ou entre chaves, para nomes com
+// espaços ou colchetes. Operadores: + - * / %, < <= > >= == !=, && || ! (ou and/or/not)
+// e cond ? a : b. Funções: min, max, abs, round(x[, casas]) e rate(x[, janela em s]),
+// a variação por segundo. Valores bool valem 1/0 em contas; números valem true quando != 0.
+
+// errExprNoValue indica que um tag da expressão (ou o rate) ainda não tem valor
+var errExprNoValue = errors.New("valor indisponível")
+
+// exprLookup busca o valor atual de um tag
+type exprLookup func(name string) (interface{}, bool)
+
+type exprNode interface {
+	eval(lookup exprLookup, now time.Time) (interface{}, error)
+}
+
+// expression é uma expressão já analisada
+type expression struct {
+	source string
+	root   exprNode
+	refs   []string // tags referenciados, sem repetição
+}
+
+// parseExpression analisa uma expressão e lista os tags que ela usa
+func parseExpression(source string) (*expression, error) {
+	tokens, err := lexExpression(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &exprParser{tokens: tokens, seen: make(map[string]bool)}
+	root, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokenEOF {
+		return nil, fmt.Errorf("expressão: trecho inesperado %q", p.peek().text)
+	}
+
+	return &expression{source: source, root: root, refs: p.refs}, nil
+}
+
+// eval avalia a expressão; o resultado é float64 ou bool
+func (e *expression) eval(lookup exprLookup, now time.Time) (interface{}, error) {
+	return e.root.eval(lookup, now)
+}
+
+// evalBool avalia a expressão como condição
+func (e *expression) evalBool(lookup exprLookup, now time.Time) (bool, error) {
+	value, err := e.eval(lookup, now)
+	if err != nil {
+		return false, err
+	}
+	return exprBool(value)
+}
+
+// --- Léxico ---
+
+const (
+	tokenEOF = iota
+	tokenNumber
+	tokenIdent
+	tokenTag // {nome do tag}
+	tokenOp
+)
+
+type exprToken struct {
+	kind int
+	text string
+	pos  int
+}
+
+var exprOperators = []string{"&&", "||", "<=", ">=", "==", "!=", "+", "-", "*", "/", "%", "<", ">", "!", "(", ")", ",", "?", ":"}
+
+func lexExpression(source string) ([]exprToken, error) {
+	var tokens []exprToken
+	runes := []rune(source)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			if i < len(runes) && (runes[i] == 'e' || runes[i] == 'E') {
+				i++
+				if i < len(runes) && (runes[i] == '+' || runes[i] == '-') {
+					i++
+				}
+				for i < len(runes) && unicode.IsDigit(runes[i]) {
+					i++
+				}
+			}
+			tokens = append(tokens, exprToken{kind: tokenNumber, text: string(runes[start:i]), pos: start})
+
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, exprToken{kind: tokenIdent, text: string(runes[start:i]), pos: start})
+
+		case r == '{':
+			end := i + 1
+			for end < len(runes) && runes[end] != '}' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("expressão: '{' sem '}' na posição %d", i)
+			}
+			name := strings.TrimSpace(string(runes[i+1 : end]))
+			if name == "" {
+				return nil, fmt.Errorf("expressão: nome de tag vazio na posição %d", i)
+			}
+			tokens = append(tokens, exprToken{kind: tokenTag, text: name, pos: i})
+			i = end + 1
+
+		default:
+			matched := false
+			for _, op := range exprOperators {
+				if strings.HasPrefix(string(runes[i:]), op) {
+					tokens = append(tokens, exprToken{kind: tokenOp, text: op, pos: i})
+					i += len([]rune(op))
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("expressão: caractere inesperado %q na posição %d", r, i)
+			}
+		}
+	}
+
+	return append(tokens, exprToken{kind: tokenEOF, pos: len(runes)}), nil
+}
+
+// --- Sintaxe ---
+
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+	refs   []string
+	seen   map[string]bool
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() exprToken {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consome o operador (ou palavra-chave) se ele for o próximo token
+func (p *exprParser) accept(texts ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokenOp && t.kind != tokenIdent {
+		return "", false
+	}
+	for _, text := range texts {
+		if t.text == text {
+			p.pos++
+			return text, true
+		}
+	}
+	return "", false
+}
+
+func (p *exprParser) expect(text string) error {
+	if _, ok := p.accept(text); !ok {
+		return fmt.Errorf("expressão: esperado %q na posição %d", text, p.peek().pos)
+	}
+	return nil
+}
+
+func (p *exprParser) parseTernary() (exprNode, error) {
+	cond, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.accept("?"); !ok {
+		return cond, nil
+	}
+
+	then, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	otherwise, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	return &exprTernary{cond: cond, then: then, otherwise: otherwise}, nil
+}
+
+// Operadores binários por precedência (do menor para o maior)
+var exprPrecedence = [][]string{
+	{"||", "or"},
+	{"&&", "and"},
+	{"==", "!="},
+	{"<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *exprParser) parseBinary(level int) (exprNode, error) {
+	if level == len(exprPrecedence) {
+		return p.parseUnary()
+	}
+
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept(exprPrecedence[level]...)
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &exprBinary{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if op, ok := p.accept("-", "!", "not"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &exprUnary{op: op, operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenNumber:
+		number, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expressão: número inválido %q", t.text)
+		}
+		return exprConst{value: number}, nil
+
+	case tokenTag:
+		return p.tagRef(t.text), nil
+
+	case tokenIdent:
+		switch t.text {
+		case "true":
+			return exprConst{value: true}, nil
+		case "false":
+			return exprConst{value: false}, nil
+		}
+		if _, ok := p.accept("("); ok {
+			return p.parseCall(t)
+		}
+		return p.tagRef(t.text), nil
+
+	case tokenOp:
+		if t.text == "(" {
+			inner, err := p.parseTernary()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return inner, nil
+		}
+	}
+
+	if t.kind == tokenEOF {
+		return nil, fmt.Errorf("expressão incompleta")
+	}
+	return nil, fmt.Errorf("expressão: %q inesperado na posição %d", t.text, t.pos)
+}
+
+func (p *exprParser) tagRef(name string) exprNode {
+	if !p.seen[name] {
+		p.seen[name] = true
+		p.refs = append(p.refs, name)
+	}
+	return exprTag{name: name}
+}
+
+func (p *exprParser) parseCall(name exprToken) (exprNode, error) {
+	var args []exprNode
+	if _, ok := p.accept(")"); !ok {
+		for {
+			arg, err := p.parseTernary()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if _, ok := p.accept(","); ok {
+				continue
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	switch name.text {
+	case "min", "max":
+		if len(args) == 0 {
+			return nil, fmt.Errorf("expressão: %s() precisa de argumentos", name.text)
+		}
+	case "abs":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("expressão: abs() recebe 1 argumento")
+		}
+	case "round":
+		if len(args) < 1 || len(args) > 2 {
+			return nil, fmt.Errorf("expressão: round() recebe 1 ou 2 argumentos")
+		}
+	case "rate":
+		if len(args) < 1 || len(args) > 2 {
+			return nil, fmt.Errorf("expressão: rate() recebe 1 ou 2 argumentos")
+		}
+		window := 0.0
+		if len(args) == 2 {
+			constant, ok := args[1].(exprConst)
+			number, isNumber := constant.value.(float64)
+			if !ok || !isNumber || number < 0 {
+				return nil, fmt.Errorf("expressão: janela de rate() deve ser um número de segundos")
+			}
+			window = number
+		}
+		return &exprRate{operand: args[0], window: time.Duration(window * float64(time.Second))}, nil
+	default:
+		return nil, fmt.Errorf("expressão: função desconhecida %s()", name.text)
+	}
+
+	return &exprCall{name: name.text, args: args}, nil
+}
+
+// --- Avaliação ---
+
+func exprNumber(value interface{}) (float64, error) {
+	if b, ok := value.(bool); ok {
+		if b {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	if number, ok := toFloat64(value); ok {
+		return number, nil
+	}
+	return 0, fmt.Errorf("valor não numérico: %v", value)
+}
+
+func exprBool(value interface{}) (bool, error) {
+	if b, ok := value.(bool); ok {
+		return b, nil
+	}
+	number, err := exprNumber(value)
+	return number != 0, err
+}
+
+type exprConst struct {
+	value interface{}
+}
+
+func (n exprConst) eval(exprLookup, time.Time) (interface{}, error) {
+	return n.value, nil
+}
+
+type exprTag struct {
+	name string
+}
+
+func (n exprTag) eval(lookup exprLookup, _ time.Time) (interface{}, error) {
+	value, ok := lookup(n.name)
+	if !ok || value == nil {
+		return nil, fmt.Errorf("%w: %s", errExprNoValue, n.name)
+	}
+	if _, isBool := value.(bool); isBool {
+		return value, nil
+	}
+	return exprNumber(value)
+}
+
+type exprUnary struct {
+	op      string
+	operand exprNode
+}
+
+func (n *exprUnary) eval(lookup exprLookup, now time.Time) (interface{}, error) {
+	value, err := n.operand.eval(lookup, now)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "-" {
+		number, err := exprNumber(value)
+		return -number, err
+	}
+	b, err := exprBool(value)
+	return !b, err
+}
+
+type exprBinary struct {
+	op          string
+	left, right exprNode
+}
+
+func (n *exprBinary) eval(lookup exprLookup, now time.Time) (interface{}, error) {
+	left, err := n.left.eval(lookup, now)
+	if err != nil {
+		return nil, err
+	}
+
+	// && e || não avaliam o lado direito quando o esquerdo já decide
+	switch n.op {
+	case "&&", "and", "||", "or":
+		l, err := exprBool(left)
+		if err != nil {
+			return nil, err
+		}
+		if (n.op == "&&" || n.op == "and") != l {
+			return l, nil
+		}
+		right, err := n.right.eval(lookup, now)
+		if err != nil {
+			return nil, err
+		}
+		return exprBool(right)
+	}
+
+	right, err := n.right.eval(lookup, now)
+	if err != nil {
+		return nil, err
+	}
+
+	// bool == bool compara direto; os demais comparam como número
+	lb, lIsBool := left.(bool)
+	rb, rIsBool := right.(bool)
+	if lIsBool && rIsBool {
+		switch n.op {
+		case "==":
+			return lb == rb, nil
+		case "!=":
+			return lb != rb, nil
+		}
+	}
+
+	l, err := exprNumber(left)
+	if err != nil {
+		return nil, err
+	}
+	r, err := exprNumber(right)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/":
+		return l / r, nil
+	case "%":
+		return math.Mod(l, r), nil
+	case "<":
+		return l < r, nil
+	case "<=":
+		return l <= r, nil
+	case ">":
+		return l > r, nil
+	case ">=":
+		return l >= r, nil
+	case "==":
+		return l == r, nil
+	case "!=":
+		return l != r, nil
+	}
+	return nil, fmt.Errorf("operador desconhecido: %s", n.op)
+}
+
+type exprTernary struct {
+	cond, then, otherwise exprNode
+}
+
+func (n *exprTernary) eval(lookup exprLookup, now time.Time) (interface{}, error) {
+	value, err := n.cond.eval(lookup, now)
+	if err != nil {
+		return nil, err
+	}
+	cond, err := exprBool(value)
+	if err != nil {
+		return nil, err
+	}
+	if cond {
+		return n.then.eval(lookup, now)
+	}
+	return n.otherwise.eval(lookup, now)
+}
+
+type exprCall struct {
+	name string
+	args []exprNode
+}
+
+func (n *exprCall) eval(lookup exprLookup, now time.Time) (interface{}, error) {
+	numbers := make([]float64, len(n.args))
+	for i, arg := range n.args {
+		value, err := arg.eval(lookup, now)
+		if err != nil {
+			return nil, err
+		}
+		if numbers[i], err = exprNumber(value); err != nil {
+			return nil, err
+		}
+	}
+
+	switch n.name {
+	case "min":
+		result := numbers[0]
+		for _, number := range numbers[1:] {
+			result = math.Min(result, number)
+		}
+		return result, nil
+	case "max":
+		result := numbers[0]
+		for _, number := range numbers[1:] {
+			result = math.Max(result, number)
+		}
+		return result, nil
+	case "abs":
+		return math.Abs(numbers[0]), nil
+	case "round":
+		factor := 1.0
+		if len(numbers) == 2 {
+			factor = math.Pow(10, math.Round(numbers[1]))
+		}
+		return math.Round(numbers[0]*factor) / factor, nil
+	}
+	return nil, fmt.Errorf("função desconhecida: %s", n.name)
+}
+
+// exprRate calcula a variação por segundo do operando entre avaliações
+type exprRate struct {
+	operand exprNode
+	window  time.Duration
+
+	mutex sync.Mutex
+	rate  rateTracker
+}
+
+type rateSample struct {
+	at    time.Time
+	value float64
+}
+
+// rateTracker calcula a variação por segundo de uma série de amostras.
+// Com janela, compara com a amostra mais antiga dentro dela (suaviza o ruído).
+type rateTracker struct {
+	samples []rateSample
+}
+
+// add registra uma amostra e retorna a variação por segundo; ok é false até haver duas amostras
+func (r *rateTracker) add(now time.Time, number float64, window time.Duration) (float64, bool) {
+	// Guardar a amostra anterior à janela, para sempre haver uma referência
+	keep := 0
+	for keep < len(r.samples)-1 && now.Sub(r.samples[keep+1].at) >= window {
+		keep++
+	}
+	r.samples = append(r.samples[keep:], rateSample{at: now, value: number})
+
+	oldest := r.samples[0]
+	elapsed := now.Sub(oldest.at).Seconds()
+	if len(r.samples) < 2 || elapsed <= 0 {
+		return 0, false
+	}
+	return (number - oldest.value) / elapsed, true
+}
+
+func (n *exprRate) eval(lookup exprLookup, now time.Time) (interface{}, error) {
+	value, err := n.operand.eval(lookup, now)
+	if err != nil {
+		return nil, err
+	}
+	number, err := exprNumber(value)
+	if err != nil {
+		return nil, err
+	}
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	rate, ok := n.rate.add(now, number, n.window)
+	if !ok {
+		return nil, fmt.Errorf("%w: rate() aguardando a segunda amostra", errExprNoValue)
+	}
+	return rate, nil
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExpressionEval(t *testing.T) {
+	values := map[string]interface{}{
+		"Montante":       72.5,
+		"Caldeira":       48.0,
+		"Porta Jusante":  float32(0.5),
+		"Porta Montante": int16(0),
+		"Operacao":       true,
+		"Falha":          false,
+		"Contador":       uint16(7),
+	}
+	lookup := func(name string) (interface{}, bool) {
+		value, ok := values[name]
+		return value, ok
+	}
+
+	tests := []struct {
+		source string
+		want   interface{}
+	}{
+		{"Montante - Caldeira", 24.5},
+		{"1 + 2 * 3", 7.0},
+		{"(1 + 2) * 3", 9.0},
+		{"-Caldeira + 50", 2.0},
+		{"10 - 4 - 3", 3.0},
+		{"Contador % 4", 3.0},
+		{"2.5e1 / 5", 5.0},
+		{"{Porta Jusante} < 1 && {Porta Montante} < 1", true},
+		{"Operacao and not Falha", true},
+		{"Falha or Contador > 10", false},
+		{"!Operacao || Montante >= 72.5", true},
+		{"Caldeira == 48 ? 1 : 2", 1.0},
+		{"Caldeira != 48 ? 1 : Falha ? 2 : 3", 3.0},
+		{"Operacao + Operacao", 2.0},
+		{"min(Montante, Caldeira, 50)", 48.0},
+		{"max(1, Contador)", 7.0},
+		{"abs(Caldeira - Montante)", 24.5},
+		{"round(Montante / 3)", 24.0},
+		{"round(Montante / 3, 2)", 24.17},
+		{"Caldeira / 0", math.Inf(1)},
+	}
+
+	for _, tt := range tests {
+		expr, err := parseExpression(tt.source)
+		if err != nil {
+			t.Errorf("%q: erro de análise: %v", tt.source, err)
+			continue
+		}
+		got, err := expr.eval(lookup, time.Now())
+		if err != nil {
+			t.Errorf("%q: erro de avaliação: %v", tt.source, err)
+			continue
+		}
+		if number, ok := got.(float64); ok {
+			if want, ok := tt.want.(float64); ok && (number == want || math.Abs(number-want) < 1e-9) {
+				continue
+			}
+		}
+		if got != tt.want {
+			t.Errorf("%q: obtido %#v, esperado %#v", tt.source, got, tt.want)
+		}
+	}
+
+	// 0/0 continua NaN: quem publica decide como representar
+	expr, _ := parseExpression("(Caldeira - 48) / (Caldeira - 48)")
+	if got, err := expr.eval(lookup, time.Now()); err != nil || !math.IsNaN(got.(float64)) {
+		t.Errorf("0/0: obtido %v (%v), esperado NaN", got, err)
+	}
+}
+
+func TestExpressionRefs(t *testing.T) {
+	expr, err := parseExpression("{Porta Jusante} < 1 && rate(Caldeira, 5) > 0 && Caldeira > Montante")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"Porta Jusante", "Caldeira", "Montante"}
+	if !reflect.DeepEqual(expr.refs, want) {
+		t.Errorf("refs: obtido %v, esperado %v", expr.refs, want)
+	}
+}
+
+func TestExpressionErrors(t *testing.T) {
+	for _, source := range []string{
+		"",
+		"1 +",
+		"(1 + 2",
+		"1 2",
+		"{sem fim",
+		"Caldeira ? 1",
+		"foo(1)",
+		"min()",
+		"abs(1, 2)",
+		"round(1, 2, 3)",
+		"rate()",
+		"1 # 2",
+	} {
+		if _, err := parseExpression(source); err == nil {
+			t.Errorf("%q: esperado erro de análise", source)
+		}
+	}
+
+	lookup := func(name string) (interface{}, bool) {
+		if name == "Texto" {
+			return "abc", true
+		}
+		return nil, false
+	}
+	expr, _ := parseExpression("Desconhecido + 1")
+	if _, err := expr.eval(lookup, time.Now()); !errors.Is(err, errExprNoValue) {
+		t.Errorf("tag sem valor: esperado errExprNoValue, obtido %v", err)
+	}
+	expr, _ = parseExpression("Texto + 1")
+	if _, err := expr.eval(lookup, time.Now()); err == nil {
+		t.Error("texto em conta: esperado erro")
+	}
+}
+
+func TestExpressionRate(t *testing.T) {
+	level := 10.0
+	lookup := func(string) (interface{}, bool) { return level, true }
+
+	expr, err := parseExpression("rate(Caldeira, 10) * 60")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := expr.eval(lookup, start); !errors.Is(err, errExprNoValue) {
+		t.Fatalf("primeira amostra: esperado errExprNoValue, obtido %v", err)
+	}
+
+	// +0,5 por segundo durante 20 s: a janela de 10 s compara com a amostra de 10 s atrás
+	for i := 1; i <= 20; i++ {
+		level += 0.5
+		got, err := expr.eval(lookup, start.Add(time.Duration(i)*time.Second))
+		if err != nil {
+			t.Fatalf("amostra %d: %v", i, err)
+		}
+		if math.Abs(got.(float64)-30) > 1e-9 {
+			t.Fatalf("amostra %d: obtido %v por minuto, esperado 30", i, got)
+		}
+	}
+}
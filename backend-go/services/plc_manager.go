@@ -0,0 +1,405 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// ID usado quando o tags.json ainda está no formato antigo de PLC único
+const defaultLockID = "regua"
+
+// Intervalo de verificação de alterações no arquivo de configuração
+const configWatchInterval = 2 * time.Second
+
+// PLCManager mantém um conector S7 por eclusa configurada
+type PLCManager struct {
+	connectors map[string]*S7PLCConnector
+	order      []string
+	mutex      sync.RWMutex
+
+	// Arquivo de configuração monitorado para recarga a quente
+	configFile    string
+	configModTime time.Time
+	reloadMutex   sync.Mutex
+}
+
+// ReloadResult resume o que mudou numa recarga da configuração
+type ReloadResult struct {
+	Added       []string `json:"added"`
+	Removed     []string `json:"removed"`
+	Updated     []string `json:"updated"`
+	Reconnected []string `json:"reconnected"`
+}
+
+var (
+	globalPLCManager *PLCManager
+	plcManagerOnce   sync.Once
+)
+
+// GetPLCManager retorna instância singleton do gerenciador de PLCs
+func GetPLCManager() *PLCManager {
+	plcManagerOnce.Do(func() {
+		globalPLCManager = &PLCManager{
+			connectors: make(map[string]*S7PLCConnector),
+		}
+
+		// Carregar configuração
+		connections, err := loadPLCConnections("tags.json")
+		if err == nil {
+			globalPLCManager.configFile = "tags.json"
+		} else {
+			log.Printf("❌ Erro ao carregar tags.json: %v", err)
+			// Tentar carregar do websocket antigo
+			connections, err = loadPLCConnections("../websocket/tags.json")
+			if err == nil {
+				globalPLCManager.configFile = "../websocket/tags.json"
+			} else {
+				log.Printf("❌ Erro ao carregar ../websocket/tags.json: %v", err)
+				// Configuração padrão
+				connections = defaultPLCConnections()
+			}
+		}
+
+		// Tags vêm do banco; o tags.json serve de carga inicial das eclusas sem tags no banco
+		seedTagsFromFile(connections)
+		if err := applyDBTags(connections); err != nil {
+			log.Printf("⚠️ Erro ao carregar tags do banco, usando tags.json: %v", err)
+		}
+
+		hub := GetWebSocketHub()
+		for _, conn := range connections {
+			connector := newS7PLCConnector(conn, hub)
+			globalPLCManager.connectors[conn.ID] = connector
+			globalPLCManager.order = append(globalPLCManager.order, conn.ID)
+			connector.Start()
+		}
+
+		log.Printf("✅ PLC Manager inicializado com %d eclusa(s)", len(connections))
+
+		// Recarregar automaticamente quando o arquivo mudar
+		if globalPLCManager.configFile != "" {
+			if info, err := os.Stat(globalPLCManager.configFile); err == nil {
+				globalPLCManager.configModTime = info.ModTime()
+			}
+			go globalPLCManager.watchConfigFile()
+		}
+	})
+
+	return globalPLCManager
+}
+
+// loadPLCConnections lê o arquivo de configuração e normaliza para a lista de conexões
+func loadPLCConnections(filename string) ([]PLCConnection, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var file PLCConfigFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	connections, err := file.Connections()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, conn := range connections {
+		if err := validatePLCConnection(conn); err != nil {
+			return nil, fmt.Errorf("eclusa %s: %v", conn.ID, err)
+		}
+	}
+
+	return connections, nil
+}
+
+// validatePLCConnection verifica endereço do PLC e tipo/endereço de cada tag
+func validatePLCConnection(conn PLCConnection) error {
+	if err := conn.PLCConfig.validateDriver(); err != nil {
+		return err
+	}
+	if err := conn.validateScanClasses(); err != nil {
+		return err
+	}
+	if err := validateWebSocketFields(conn.WebSocketFields); err != nil {
+		return err
+	}
+
+	for name, tag := range conn.Tags {
+		if err := ValidatePLCTag(tag, conn); err != nil {
+			return fmt.Errorf("tag %s: %v", name, err)
+		}
+	}
+	if _, err := computedOrder(conn); err != nil {
+		return err
+	}
+	if err := validateAlarmIDs(conn); err != nil {
+		return err
+	}
+	if err := validateLockage(conn); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Connections retorna as conexões do arquivo, convertendo o formato antigo
+// de PLC único numa lista com uma eclusa
+func (f PLCConfigFile) Connections() ([]PLCConnection, error) {
+	connections := f.PLCs
+	if len(connections) == 0 && f.PLCConfig != nil {
+		connections = []PLCConnection{{
+			ID:              defaultLockID,
+			Name:            "Régua",
+			PLCConfig:       *f.PLCConfig,
+			Tags:            f.Tags,
+			WebSocketFields: f.WSFields,
+		}}
+	}
+
+	if len(connections) == 0 {
+		return nil, fmt.Errorf("nenhum PLC configurado")
+	}
+
+	seen := make(map[string]bool)
+	for i := range connections {
+		if connections[i].ID == "" {
+			return nil, fmt.Errorf("PLC na posição %d sem id", i)
+		}
+		if seen[connections[i].ID] {
+			return nil, fmt.Errorf("id de PLC duplicado: %s", connections[i].ID)
+		}
+		seen[connections[i].ID] = true
+
+		if connections[i].Name == "" {
+			connections[i].Name = connections[i].ID
+		}
+	}
+
+	return connections, nil
+}
+
+func defaultPLCConnections() []PLCConnection {
+	return []PLCConnection{{
+		ID:   defaultLockID,
+		Name: "Régua",
+		PLCConfig: PLCConfig{
+			IP:       "192.168.1.33",
+			Rack:     0,
+			Slot:     1,
+			DBNumber: 19,
+		},
+		Tags: map[string]PLCTag{
+			"Eclusa_Nivel_Caldeira": {
+				Type:        "real",
+				Offset:      0.0,
+				Description: "Eclusa Nível Caldeira (Real)",
+			},
+			"Eclusa_Nivel_Montante": {
+				Type:        "real",
+				Offset:      4.0,
+				Description: "Eclusa Nível Montante (Real)",
+			},
+			"Eclusa_Nivel_Jusante": {
+				Type:        "real",
+				Offset:      8.0,
+				Description: "Eclusa Nível Jusante (Real)",
+			},
+		},
+		WebSocketFields: []WebSocketField{
+			{Key: "nivelCaldeiraValue", Tag: "Eclusa_Nivel_Caldeira", Transform: transformFloat32, Default: 0},
+			{Key: "nivelMontanteValue", Tag: "Eclusa_Nivel_Montante", Transform: transformFloat32, Default: 0},
+			{Key: "nivelJusanteValue", Tag: "Eclusa_Nivel_Jusante", Transform: transformFloat32, Default: 0},
+		},
+	}}
+}
+
+// Connector retorna o conector de uma eclusa pelo ID
+func (m *PLCManager) Connector(lockID string) (*S7PLCConnector, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	connector, ok := m.connectors[lockID]
+	return connector, ok
+}
+
+// Connectors retorna todos os conectores na ordem do arquivo de configuração
+func (m *PLCManager) Connectors() []*S7PLCConnector {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	connectors := make([]*S7PLCConnector, 0, len(m.order))
+	for _, id := range m.order {
+		connectors = append(connectors, m.connectors[id])
+	}
+	return connectors
+}
+
+// currentConnections copia a configuração em uso de cada eclusa
+func (m *PLCManager) currentConnections() []PLCConnection {
+	connectors := m.Connectors()
+	connections := make([]PLCConnection, 0, len(connectors))
+
+	for _, connector := range connectors {
+		connector.mutex.RLock()
+		connections = append(connections, connector.config)
+		connector.mutex.RUnlock()
+	}
+	return connections
+}
+
+// HasScanClass indica se alguma eclusa define a classe de varredura
+func (m *PLCManager) HasScanClass(name string) bool {
+	for _, conn := range m.currentConnections() {
+		if _, ok := conn.scanClassIntervals()[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// GetStatus retorna o status de cada eclusa, indexado pelo ID
+func (m *PLCManager) GetStatus() map[string]interface{} {
+	status := make(map[string]interface{})
+	for _, connector := range m.Connectors() {
+		status[connector.ID()] = connector.GetStatus()
+	}
+	return status
+}
+
+// SendCurrentValues envia os valores atuais de todas as eclusas para um novo cliente
+func (m *PLCManager) SendCurrentValues(clientSend chan []byte) {
+	for _, connector := range m.Connectors() {
+		connector.SendCurrentValues(clientSend)
+	}
+}
+
+// watchConfigFile verifica periodicamente a data de modificação do arquivo
+func (m *PLCManager) watchConfigFile() {
+	ticker := time.NewTicker(configWatchInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		info, err := os.Stat(m.configFile)
+		if err != nil {
+			continue
+		}
+
+		m.reloadMutex.Lock()
+		changed := !info.ModTime().Equal(m.configModTime)
+		m.reloadMutex.Unlock()
+
+		if changed {
+			log.Printf("📝 %s alterado, recarregando configuração...", m.configFile)
+			if _, err := m.Reload(); err != nil {
+				log.Printf("❌ Configuração nova rejeitada, mantendo a atual: %v", err)
+			}
+		}
+	}
+}
+
+// Reload valida o arquivo de configuração e aplica as mudanças sem reiniciar o servidor.
+// Só reconecta as eclusas cujo plc_config mudou; as demais apenas trocam os tags.
+func (m *PLCManager) Reload() (ReloadResult, error) {
+	m.reloadMutex.Lock()
+	defer m.reloadMutex.Unlock()
+
+	result := ReloadResult{
+		Added:       []string{},
+		Removed:     []string{},
+		Updated:     []string{},
+		Reconnected: []string{},
+	}
+
+	var connections []PLCConnection
+	if m.configFile != "" {
+		// Marcar a versão como vista mesmo se inválida, para não repetir o erro a cada ciclo
+		if info, err := os.Stat(m.configFile); err == nil {
+			m.configModTime = info.ModTime()
+		}
+
+		var err error
+		connections, err = loadPLCConnections(m.configFile)
+		if err != nil {
+			return result, err
+		}
+	} else {
+		connections = m.currentConnections()
+	}
+
+	// Eclusas novas no arquivo entram no banco com os tags do tags.json
+	seedTagsFromFile(connections)
+	if err := applyDBTags(connections); err != nil {
+		return result, fmt.Errorf("erro ao carregar tags do banco: %v", err)
+	}
+
+	hub := GetWebSocketHub()
+	newConnectors := make(map[string]*S7PLCConnector)
+	newOrder := make([]string, 0, len(connections))
+	var started []*S7PLCConnector
+
+	m.mutex.RLock()
+	for _, conn := range connections {
+		newOrder = append(newOrder, conn.ID)
+
+		if connector, exists := m.connectors[conn.ID]; exists {
+			if connector.applyConfig(conn) {
+				result.Reconnected = append(result.Reconnected, conn.ID)
+			}
+			result.Updated = append(result.Updated, conn.ID)
+			newConnectors[conn.ID] = connector
+			continue
+		}
+
+		connector := newS7PLCConnector(conn, hub)
+		newConnectors[conn.ID] = connector
+		started = append(started, connector)
+		result.Added = append(result.Added, conn.ID)
+	}
+
+	var stopped []*S7PLCConnector
+	for id, connector := range m.connectors {
+		if _, keep := newConnectors[id]; !keep {
+			stopped = append(stopped, connector)
+			result.Removed = append(result.Removed, id)
+		}
+	}
+	m.mutex.RUnlock()
+
+	// Troca atômica do conjunto de conectores
+	m.mutex.Lock()
+	m.connectors = newConnectors
+	m.order = newOrder
+	m.mutex.Unlock()
+
+	for _, connector := range stopped {
+		connector.Stop()
+	}
+	for _, connector := range started {
+		connector.Start()
+	}
+
+	log.Printf("🔄 Configuração recarregada: %d nova(s), %d removida(s), %d atualizada(s), %d reconectada(s)",
+		len(result.Added), len(result.Removed), len(result.Updated), len(result.Reconnected))
+
+	hub.BroadcastMessage(map[string]interface{}{
+		"type":      "config_changed",
+		"timestamp": time.Now().Unix(),
+		"locks":     newOrder,
+		"changes":   result,
+	})
+
+	return result, nil
+}
+
+// Stop para todos os conectores
+func (m *PLCManager) Stop() {
+	for _, connector := range m.Connectors() {
+		connector.Stop()
+	}
+}
@@ -0,0 +1,296 @@
+package services
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	driverModbus = "modbus"
+
+	modbusDefaultPort   = 502
+	modbusDefaultUnitID = 1
+	modbusTimeout       = 5 * time.Second
+
+	// Maior leitura por requisição: 125 registradores ou 2000 bits (250 bytes)
+	modbusMaxReadBytes = 250
+	// Maior escrita de registradores por requisição (FC16)
+	modbusMaxWriteRegisters = 123
+)
+
+// Códigos de função Modbus
+const (
+	modbusReadCoils              = 0x01
+	modbusReadDiscreteInputs     = 0x02
+	modbusReadHoldingRegisters   = 0x03
+	modbusReadInputRegisters     = 0x04
+	modbusWriteSingleCoil        = 0x05
+	modbusWriteSingleRegister    = 0x06
+	modbusWriteMultipleCoils     = 0x0F
+	modbusWriteMultipleRegisters = 0x10
+)
+
+// Mensagens das exceções Modbus mais comuns
+var modbusExceptions = map[byte]string{
+	0x01: "função ilegal",
+	0x02: "endereço ilegal",
+	0x03: "valor ilegal",
+	0x04: "falha no dispositivo",
+	0x06: "dispositivo ocupado",
+	0x0A: "gateway sem caminho",
+	0x0B: "dispositivo do gateway não respondeu",
+}
+
+// modbusDriver é um cliente Modbus TCP. Registradores são vistos como palavras
+// big-endian (2 bytes por endereço) e coils/entradas discretas como bytes de 8 bits,
+// para reaproveitar o plano de leitura e os decodificadores S7.
+type modbusDriver struct {
+	config        PLCConfig
+	conn          net.Conn
+	transactionID uint16
+	mutex         sync.Mutex
+}
+
+func newModbusDriver(config PLCConfig) *modbusDriver {
+	return &modbusDriver{config: config}
+}
+
+func (d *modbusDriver) address() string {
+	port := d.config.Port
+	if port == 0 {
+		port = modbusDefaultPort
+	}
+	return net.JoinHostPort(d.config.IP, strconv.Itoa(port))
+}
+
+func (d *modbusDriver) unitID() byte {
+	if d.config.UnitID == 0 {
+		return modbusDefaultUnitID
+	}
+	return byte(d.config.UnitID)
+}
+
+func (d *modbusDriver) Name() string {
+	return driverModbus
+}
+
+func (d *modbusDriver) Connect() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	conn, err := net.DialTimeout("tcp", d.address(), modbusTimeout)
+	if err != nil {
+		return err
+	}
+	d.conn = conn
+	return nil
+}
+
+func (d *modbusDriver) Close() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.conn == nil {
+		return nil
+	}
+	err := d.conn.Close()
+	d.conn = nil
+	return err
+}
+
+// PDULength devolve o tamanho equivalente ao PDU S7 usado pelo plano de leitura
+func (d *modbusDriver) PDULength() int {
+	return modbusMaxReadBytes + s7ReadReplyHeader
+}
+
+func (d *modbusDriver) ReadArea(area string, dbNumber int, start int, size int, buffer []byte) error {
+	switch area {
+	case areaHoldingRegisters, areaInputRegisters:
+		function := byte(modbusReadHoldingRegisters)
+		if area == areaInputRegisters {
+			function = modbusReadInputRegisters
+		}
+		data, err := d.request(function, modbusUint16s(start, size), size*2)
+		if err != nil {
+			return err
+		}
+		copy(buffer, data)
+		return nil
+
+	case areaCoils, areaDiscreteInputs:
+		return d.ReadBits(area, start*8, size*8, buffer)
+	}
+	return fmt.Errorf("área %s não existe em dispositivos Modbus", area)
+}
+
+// ReadBits lê count coils ou entradas discretas a partir do bit first
+func (d *modbusDriver) ReadBits(area string, first int, count int, buffer []byte) error {
+	function := byte(modbusReadCoils)
+	switch area {
+	case areaCoils:
+	case areaDiscreteInputs:
+		function = modbusReadDiscreteInputs
+	default:
+		return fmt.Errorf("área %s não é de bits", area)
+	}
+
+	// Os bits chegam empacotados LSB primeiro, igual ao layout byte/bit usado aqui
+	data, err := d.request(function, modbusUint16s(first, count), (count+7)/8)
+	if err != nil {
+		return err
+	}
+	copy(buffer, data)
+	return nil
+}
+
+func (d *modbusDriver) WriteArea(area string, dbNumber int, start int, size int, buffer []byte) error {
+	switch area {
+	case areaHoldingRegisters:
+		if size == 1 {
+			_, err := d.request(modbusWriteSingleRegister, append(modbusUint16s(start), buffer[0:2]...), -1)
+			return err
+		}
+		if size > modbusMaxWriteRegisters {
+			return fmt.Errorf("escrita de %d registradores excede o máximo de %d", size, modbusMaxWriteRegisters)
+		}
+		payload := append(modbusUint16s(start, size), byte(size*2))
+		_, err := d.request(modbusWriteMultipleRegisters, append(payload, buffer[:size*2]...), -1)
+		return err
+
+	case areaCoils:
+		payload := append(modbusUint16s(start*8, size*8), byte(size))
+		_, err := d.request(modbusWriteMultipleCoils, append(payload, buffer[:size]...), -1)
+		return err
+	}
+	return fmt.Errorf("área de memória %s não aceita escrita", area)
+}
+
+// WriteBit escreve um coil com FC5; bits de registrador usam leitura-modificação-escrita
+func (d *modbusDriver) WriteBit(area string, dbNumber int, index int, bit int, value bool) error {
+	switch area {
+	case areaCoils:
+		state := uint16(0x0000)
+		if value {
+			state = 0xFF00
+		}
+		_, err := d.request(modbusWriteSingleCoil, modbusUint16s(index*8+bit, int(state)), -1)
+		return err
+
+	case areaHoldingRegisters:
+		current := make([]byte, 2)
+		if err := d.ReadArea(area, dbNumber, index, 1, current); err != nil {
+			return err
+		}
+		word := binary.BigEndian.Uint16(current)
+		if value {
+			word |= 1 << bit
+		} else {
+			word &^= 1 << bit
+		}
+		binary.BigEndian.PutUint16(current, word)
+		return d.WriteArea(area, dbNumber, index, 1, current)
+	}
+	return fmt.Errorf("área de memória %s não aceita escrita", area)
+}
+
+func (d *modbusDriver) Status() map[string]interface{} {
+	return map[string]interface{}{
+		"address":    d.address(),
+		"unit_id":    d.unitID(),
+		"word_order": d.config.WordOrder,
+	}
+}
+
+// request envia uma requisição MBAP e devolve os dados da resposta.
+// Para leituras, expected é o número de bytes de dados; -1 para escritas (resposta de eco).
+func (d *modbusDriver) request(function byte, payload []byte, expected int) ([]byte, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.conn == nil {
+		return nil, ErrPLCNotConnected
+	}
+
+	d.transactionID++
+	frame := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint16(frame[0:], d.transactionID)
+	binary.BigEndian.PutUint16(frame[2:], 0) // protocolo Modbus
+	binary.BigEndian.PutUint16(frame[4:], uint16(len(payload)+2))
+	frame[6] = d.unitID()
+	frame[7] = function
+	frame = append(frame, payload...)
+
+	d.conn.SetDeadline(time.Now().Add(modbusTimeout))
+	if _, err := d.conn.Write(frame); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 7)
+	if _, err := io.ReadFull(d.conn, header); err != nil {
+		return nil, err
+	}
+	if binary.BigEndian.Uint16(header[0:]) != d.transactionID {
+		return nil, fmt.Errorf("resposta Modbus fora de ordem (transação %d)", binary.BigEndian.Uint16(header[0:]))
+	}
+	length := int(binary.BigEndian.Uint16(header[4:]))
+	if length < 2 || length > 260 {
+		return nil, fmt.Errorf("resposta Modbus com tamanho inválido: %d", length)
+	}
+
+	pdu := make([]byte, length-1)
+	if _, err := io.ReadFull(d.conn, pdu); err != nil {
+		return nil, err
+	}
+
+	if pdu[0] == function|0x80 {
+		code := byte(0)
+		if len(pdu) > 1 {
+			code = pdu[1]
+		}
+		message, ok := modbusExceptions[code]
+		if !ok {
+			message = "exceção desconhecida"
+		}
+		return nil, fmt.Errorf("exceção Modbus %d na função %d: %s", code, function, message)
+	}
+	if pdu[0] != function {
+		return nil, fmt.Errorf("resposta Modbus com função %d, esperado %d", pdu[0], function)
+	}
+
+	if expected < 0 {
+		return nil, nil
+	}
+	if len(pdu) < 2 || int(pdu[1]) != expected || len(pdu) < 2+expected {
+		return nil, fmt.Errorf("resposta Modbus com %d bytes, esperado %d", len(pdu)-2, expected)
+	}
+	return pdu[2 : 2+expected], nil
+}
+
+// modbusUint16s monta campos de 16 bits big-endian (endereço, quantidade...)
+func modbusUint16s(values ...int) []byte {
+	data := make([]byte, 0, len(values)*2)
+	for _, v := range values {
+		data = append(data, byte(v>>8), byte(v))
+	}
+	return data
+}
+
+// swapWords inverte a ordem das palavras de 16 bits (ABCD -> CDAB) de valores
+// de 32/64 bits gravados com a palavra menos significativa primeiro
+func swapWords(data []byte) []byte {
+	if len(data) < 4 || len(data)%2 != 0 {
+		return data
+	}
+
+	swapped := make([]byte, len(data))
+	words := len(data) / 2
+	for i := 0; i < words; i++ {
+		copy(swapped[i*2:i*2+2], data[(words-1-i)*2:(words-i)*2])
+	}
+	return swapped
+}
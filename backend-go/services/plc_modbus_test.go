@@ -0,0 +1,282 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeModbusServer é um escravo Modbus TCP mínimo (FC1, FC3, FC5 e FC16) que guarda
+// o PDU de cada requisição para conferir o enquadramento
+type fakeModbusServer struct {
+	listener  net.Listener
+	mutex     sync.Mutex
+	registers [64]uint16
+	coils     [64]bool
+	unitIDs   []byte
+	requests  [][]byte
+}
+
+func newFakeModbusServer(t *testing.T) *fakeModbusServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &fakeModbusServer{listener: listener}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+// config aponta um driver Modbus para o servidor
+func (s *fakeModbusServer) config(unitID int) PLCConfig {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	number, _ := strconv.Atoi(port)
+	return PLCConfig{IP: host, Port: number, UnitID: unitID, Driver: driverModbus}
+}
+
+func (s *fakeModbusServer) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		header := make([]byte, 7)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		pdu := make([]byte, binary.BigEndian.Uint16(header[4:])-1)
+		if _, err := io.ReadFull(conn, pdu); err != nil {
+			return
+		}
+
+		s.mutex.Lock()
+		s.unitIDs = append(s.unitIDs, header[6])
+		s.requests = append(s.requests, pdu)
+		reply := s.handle(pdu)
+		s.mutex.Unlock()
+
+		frame := make([]byte, 7, 7+len(reply))
+		copy(frame, header[:4])
+		binary.BigEndian.PutUint16(frame[4:], uint16(len(reply)+1))
+		frame[6] = header[6]
+		if _, err := conn.Write(append(frame, reply...)); err != nil {
+			return
+		}
+	}
+}
+
+// handle monta a resposta de um PDU; endereços fora da memória geram exceção 2
+func (s *fakeModbusServer) handle(pdu []byte) []byte {
+	function := pdu[0]
+	exception := func(code byte) []byte { return []byte{function | 0x80, code} }
+	start := int(binary.BigEndian.Uint16(pdu[1:]))
+	count := int(binary.BigEndian.Uint16(pdu[3:]))
+
+	switch function {
+	case modbusReadCoils:
+		if start+count > len(s.coils) {
+			return exception(0x02)
+		}
+		data := make([]byte, (count+7)/8)
+		for i := 0; i < count; i++ {
+			if s.coils[start+i] {
+				data[i/8] |= 1 << (i % 8)
+			}
+		}
+		return append([]byte{function, byte(len(data))}, data...)
+
+	case modbusReadHoldingRegisters:
+		if start+count > len(s.registers) {
+			return exception(0x02)
+		}
+		reply := []byte{function, byte(count * 2)}
+		for _, word := range s.registers[start : start+count] {
+			reply = append(reply, byte(word>>8), byte(word))
+		}
+		return reply
+
+	case modbusWriteSingleCoil:
+		if start >= len(s.coils) {
+			return exception(0x02)
+		}
+		s.coils[start] = count == 0xFF00
+		return pdu
+
+	case modbusWriteMultipleRegisters:
+		if start+count > len(s.registers) || int(pdu[5]) != count*2 {
+			return exception(0x03)
+		}
+		for i := 0; i < count; i++ {
+			s.registers[start+i] = binary.BigEndian.Uint16(pdu[6+i*2:])
+		}
+		return pdu[:5]
+	}
+	return exception(0x01)
+}
+
+// lastRequest devolve o PDU da última requisição recebida
+func (s *fakeModbusServer) lastRequest() []byte {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.requests[len(s.requests)-1]
+}
+
+func connectFakeModbus(t *testing.T, server *fakeModbusServer) *modbusDriver {
+	driver := newModbusDriver(server.config(7))
+	if err := driver.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { driver.Close() })
+	return driver
+}
+
+func TestModbusDriverReadPlan(t *testing.T) {
+	server := newFakeModbusServer(t)
+	driver := connectFakeModbus(t, server)
+
+	// 12.5 com a palavra baixa primeiro (CDAB), 0.25 em big-endian e -2 num DINT
+	level := math.Float32bits(12.5)
+	flow := math.Float32bits(0.25)
+	counter := uint32(0xFFFFFFFE)
+	server.mutex.Lock()
+	copy(server.registers[10:], []uint16{uint16(level), uint16(level >> 16), uint16(flow >> 16), uint16(flow), uint16(counter >> 16), uint16(counter)})
+	server.coils[17] = true
+	server.mutex.Unlock()
+
+	tags := map[string]PLCTag{
+		"nivel":    {Type: "real", Address: "HR10", WordOrder: "little"},
+		"vazao":    {Type: "real", Address: "HR12"},
+		"contador": {Type: "dint", Address: "HR14"},
+		"porta":    {Type: "bool", Address: "CO17"},
+		"bomba":    {Type: "bool", Address: "CO19"},
+	}
+	blocks, invalid := buildReadPlan(tags, driver.config, driver.PDULength())
+	if len(invalid) > 0 {
+		t.Fatalf("tags inválidos: %v", invalid)
+	}
+
+	values := make(map[string]interface{})
+	for _, block := range blocks {
+		buffer := make([]byte, block.byteLength())
+		var err error
+		if isBitArea(block.Area) {
+			err = readBlockBits(driver, block, buffer)
+		} else {
+			err = driver.ReadArea(block.Area, block.DBNumber, block.Start, block.Size, buffer)
+		}
+		if err != nil {
+			t.Fatalf("leitura do bloco %s: %v", block.Area, err)
+		}
+		for _, pt := range block.Tags {
+			if values[pt.Name], err = decodeTag(block, pt, buffer); err != nil {
+				t.Fatalf("%s: %v", pt.Name, err)
+			}
+		}
+	}
+
+	want := map[string]interface{}{
+		"nivel": float32(12.5), "vazao": float32(0.25), "contador": int32(-2), "porta": true, "bomba": false,
+	}
+	for name, value := range want {
+		if values[name] != value {
+			t.Errorf("%s: obtido %#v, esperado %#v", name, values[name], value)
+		}
+	}
+
+	// Uma requisição por bloco: FC1 dos bits 17..19 e FC3 dos registradores 10..15
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	requests := map[byte][]byte{}
+	for _, pdu := range server.requests {
+		requests[pdu[0]] = pdu
+	}
+	if got := requests[modbusReadCoils]; !bytes.Equal(got, []byte{0x01, 0, 17, 0, 3}) {
+		t.Errorf("FC1: % x", got)
+	}
+	if got := requests[modbusReadHoldingRegisters]; !bytes.Equal(got, []byte{0x03, 0, 10, 0, 6}) {
+		t.Errorf("FC3: % x", got)
+	}
+	for _, unit := range server.unitIDs {
+		if unit != 7 {
+			t.Errorf("unit id %d, esperado 7", unit)
+		}
+	}
+}
+
+func TestModbusDriverWrites(t *testing.T) {
+	server := newFakeModbusServer(t)
+	driver := connectFakeModbus(t, server)
+
+	// FC5: coil 19 (byte 2, bit 3) ligado
+	if err := driver.WriteBit(areaCoils, 0, 2, 3, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := server.lastRequest(); !bytes.Equal(got, []byte{0x05, 0, 19, 0xFF, 0x00}) {
+		t.Errorf("FC5: % x", got)
+	}
+	server.mutex.Lock()
+	if !server.coils[19] {
+		t.Error("coil 19 não ligado")
+	}
+	server.mutex.Unlock()
+
+	// FC16: dois registradores a partir do 20
+	if err := driver.WriteArea(areaHoldingRegisters, 0, 20, 2, []byte{0x12, 0x34, 0xAB, 0xCD}); err != nil {
+		t.Fatal(err)
+	}
+	if got := server.lastRequest(); !bytes.Equal(got, []byte{0x10, 0, 20, 0, 2, 4, 0x12, 0x34, 0xAB, 0xCD}) {
+		t.Errorf("FC16: % x", got)
+	}
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if server.registers[20] != 0x1234 || server.registers[21] != 0xABCD {
+		t.Errorf("registradores: %04x %04x", server.registers[20], server.registers[21])
+	}
+}
+
+func TestModbusDriverExceptions(t *testing.T) {
+	server := newFakeModbusServer(t)
+	driver := connectFakeModbus(t, server)
+
+	tests := []struct {
+		name string
+		call func() error
+		want string
+	}{
+		{"endereço fora da memória", func() error {
+			return driver.ReadArea(areaHoldingRegisters, 0, 60, 10, make([]byte, 20))
+		}, "exceção Modbus 2 na função 3: endereço ilegal"},
+		{"função não suportada", func() error {
+			return driver.ReadArea(areaInputRegisters, 0, 0, 1, make([]byte, 2))
+		}, "exceção Modbus 1 na função 4: função ilegal"},
+		{"coil fora da memória", func() error {
+			return driver.WriteBit(areaCoils, 0, 10, 0, true)
+		}, "exceção Modbus 2 na função 5: endereço ilegal"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("erro %v, esperado %q", err, tt.want)
+			}
+		})
+	}
+
+	// A conexão continua utilizável depois de uma exceção
+	if err := driver.ReadArea(areaHoldingRegisters, 0, 0, 1, make([]byte, 2)); err != nil {
+		t.Errorf("leitura após exceção: %v", err)
+	}
+}
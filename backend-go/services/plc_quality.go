@@ -0,0 +1,230 @@
+package services
+
+import (
+	"log"
+	"math"
+	"time"
+)
+
+// Qualidade do valor de um tag
+const (
+	QualityGood      = "good"       // lido agora do PLC
+	QualityBadComm   = "bad-comm"   // sem comunicação: o valor é o último lido
+	QualityBadConfig = "bad-config" // tipo/endereço inválido ou bytes que não decodificam
+	QualityStale     = "stale"      // sem leitura nova há mais tempo que o esperado
+	QualityUncertain = "uncertain"  // lido, mas NaN/Inf ou fora de min_value..max_value
+)
+
+// Um valor fica "stale" quando passa staleFactor intervalos da classe sem leitura
+// (nunca antes de staleMinimum)
+const (
+	staleFactor      = 3
+	staleMinimum     = 2 * time.Second
+	qualityCheckTick = time.Second
+)
+
+// TagValue é o valor em cache de um tag com a sua qualidade e carimbos de tempo
+type TagValue struct {
+	Value     interface{} `json:"value"`
+	Quality   string      `json:"quality"`
+	ReadAt    time.Time   `json:"read_at"`    // última leitura do PLC
+	ChangedAt time.Time   `json:"changed_at"` // última mudança de valor
+	Unit      string      `json:"unit,omitempty"`
+
+	staleAfter time.Duration
+}
+
+// staleAfterInterval calcula a idade máxima de um valor lido no intervalo dado
+func staleAfterInterval(interval time.Duration) time.Duration {
+	if limit := staleFactor * interval; limit > staleMinimum {
+		return limit
+	}
+	return staleMinimum
+}
+
+// readQuality classifica um valor lido com sucesso
+func readQuality(tag PLCTag, value interface{}) string {
+	number, ok := toFloat64(value)
+	if !ok {
+		return QualityGood
+	}
+	if math.IsNaN(number) || math.IsInf(number, 0) {
+		return QualityUncertain
+	}
+	if (tag.MinValue != nil && number < *tag.MinValue) || (tag.MaxValue != nil && number > *tag.MaxValue) {
+		return QualityUncertain
+	}
+	return QualityGood
+}
+
+// effectiveQuality aplica o envelhecimento a um valor bom
+func (v TagValue) effectiveQuality(now time.Time) string {
+	if (v.Quality == QualityGood || v.Quality == QualityUncertain) && v.staleAfter > 0 && now.Sub(v.ReadAt) > v.staleAfter {
+		return QualityStale
+	}
+	return v.Quality
+}
+
+// storeValue grava uma leitura no cache, atualizando qualidade e carimbos.
+// quality é a melhor qualidade possível para o valor (a dos tags de origem, nos calculados).
+// Retorna true quando a qualidade efetiva mudou (ex: tag voltando a good com o mesmo valor).
+func (s7 *S7PLCConnector) storeValue(name string, tag PLCTag, value interface{}, readAt time.Time, staleAfter time.Duration, quality string) bool {
+	s7.currentMutex.Lock()
+	defer s7.currentMutex.Unlock()
+
+	entry, exists := s7.currentValues[name]
+	if !exists || !sameValue(entry.Value, value) {
+		entry.ChangedAt = readAt
+	}
+	previous := entry.effectiveQuality(readAt)
+	entry.Value = value
+	entry.Quality = worseQuality(readQuality(tag, value), quality)
+	entry.ReadAt = readAt
+	entry.staleAfter = staleAfter
+	s7.currentValues[name] = entry
+
+	if !s7.plcReadAtLeastOnce {
+		s7.plcReadAtLeastOnce = true
+		log.Printf("✅ [%s] Primeira leitura do S7 PLC concluída", s7.lockID)
+	}
+	return exists && previous != entry.Quality
+}
+
+// setQuality marca um tag com qualidade ruim, mantendo o último valor lido.
+// Retorna true quando a qualidade mudou.
+func (s7 *S7PLCConnector) setQuality(name string, quality string) bool {
+	s7.currentMutex.Lock()
+	defer s7.currentMutex.Unlock()
+
+	entry, exists := s7.currentValues[name]
+	if exists && entry.Quality == quality {
+		return false
+	}
+	if quality == QualityBadConfig {
+		entry.Value = nil
+	}
+	entry.Quality = quality
+	s7.currentValues[name] = entry
+	return true
+}
+
+// markBadComm marca todos os tags da eclusa como sem comunicação.
+// Retorna true quando algum tag mudou de qualidade.
+func (s7 *S7PLCConnector) markBadComm() bool {
+	s7.mutex.RLock()
+	names := make([]string, 0, len(s7.config.Tags))
+	for name := range s7.config.Tags {
+		names = append(names, name)
+	}
+	s7.mutex.RUnlock()
+
+	changed := false
+	for _, name := range names {
+		s7.currentMutex.RLock()
+		entry := s7.currentValues[name]
+		s7.currentMutex.RUnlock()
+
+		// Erros de configuração continuam sendo erros de configuração
+		if entry.Quality == QualityBadConfig {
+			continue
+		}
+		if s7.setQuality(name, QualityBadComm) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// TagValues retorna o valor, a qualidade e os carimbos de tempo de todos os tags da eclusa.
+// Tags ainda não lidos aparecem sem valor, como bad-comm (desconectado) ou uncertain.
+func (s7 *S7PLCConnector) TagValues() map[string]TagValue {
+	s7.mutex.RLock()
+	connected := s7.isConnected
+	units := make(map[string]string, len(s7.config.Tags))
+	for name, tag := range s7.config.Tags {
+		units[name] = tag.Unit
+	}
+	s7.mutex.RUnlock()
+
+	now := time.Now()
+
+	s7.currentMutex.RLock()
+	defer s7.currentMutex.RUnlock()
+
+	values := make(map[string]TagValue, len(units))
+	for name, unit := range units {
+		entry, exists := s7.currentValues[name]
+		entry.Unit = unit
+		if !exists {
+			entry.Quality = QualityUncertain
+			if !connected {
+				entry.Quality = QualityBadComm
+			}
+		}
+		entry.Quality = entry.effectiveQuality(now)
+		entry.Value = jsonSafeValue(entry.Value)
+		values[name] = entry
+	}
+	return values
+}
+
+// TagValue retorna o valor com qualidade de um tag
+func (s7 *S7PLCConnector) TagValue(name string) (TagValue, bool) {
+	if _, ok := s7.Tag(name); !ok {
+		return TagValue{}, false
+	}
+	value, ok := s7.TagValues()[name]
+	return value, ok
+}
+
+// qualityPayload resume qualidade e carimbos (Unix ms) de cada tag para o WebSocket
+func (s7 *S7PLCConnector) qualityPayload() map[string]interface{} {
+	payload := make(map[string]interface{})
+	for name, value := range s7.TagValues() {
+		entry := map[string]interface{}{
+			"quality": value.Quality,
+		}
+		if !value.ReadAt.IsZero() {
+			entry["read_at"] = value.ReadAt.UnixMilli()
+		}
+		if !value.ChangedAt.IsZero() {
+			entry["changed_at"] = value.ChangedAt.UnixMilli()
+		}
+		payload[name] = entry
+	}
+	return payload
+}
+
+// qualityLoop avisa os clientes quando tags ficam stale (ou deixam de ficar)
+// sem que nenhuma leitura tenha gerado broadcast
+func (s7 *S7PLCConnector) qualityLoop() {
+	ticker := time.NewTicker(qualityCheckTick)
+	defer ticker.Stop()
+
+	stale := make(map[string]bool)
+	for {
+		select {
+		case <-s7.stopChan:
+			return
+		case <-ticker.C:
+			current := make(map[string]bool)
+			for name, value := range s7.TagValues() {
+				if value.Quality == QualityStale {
+					current[name] = true
+				}
+			}
+
+			changed := len(current) != len(stale)
+			for name := range current {
+				if !stale[name] {
+					changed = true
+				}
+			}
+			stale = current
+
+			if changed {
+				s7.broadcastValues(s7.publishedValues())
+			}
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStoreTagValueQualityChange(t *testing.T) {
+	s7 := newS7PLCConnector(PLCConnection{ID: "teste"}, nil)
+	tag := PLCTag{Type: "int"}
+	now := time.Now()
+
+	if !s7.storeTagValue("nivel", tag, int16(10), now, time.Second, QualityGood) {
+		t.Fatal("primeira leitura não publicada")
+	}
+	if s7.storeTagValue("nivel", tag, int16(10), now.Add(100*time.Millisecond), time.Second, QualityGood) {
+		t.Error("mesmo valor e mesma qualidade publicados")
+	}
+
+	s7.setQuality("nivel", QualityBadComm)
+	if !s7.storeTagValue("nivel", tag, int16(10), now.Add(200*time.Millisecond), time.Second, QualityGood) {
+		t.Error("volta a good com o mesmo valor não publicada")
+	}
+
+	// Envelhecido: a leitura seguinte, mesmo com o valor igual, volta a good
+	if !s7.storeTagValue("nivel", tag, int16(10), now.Add(5*time.Second), time.Second, QualityGood) {
+		t.Error("volta de stale com o mesmo valor não publicada")
+	}
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+const (
+	// Cabeçalho da resposta S7 que ocupa espaço dentro do PDU negociado
+	s7ReadReplyHeader = 18
+	// PDU mínimo garantido por qualquer CPU S7 (usado se a negociação falhar)
+	s7DefaultPDULength = 240
+	// Buracos até este tamanho são lidos junto para economizar round-trips
+	readPlanMaxGap = 32
+)
+
+// readBlock representa uma faixa contígua de uma área de memória lida numa só requisição
+type readBlock struct {
+	Area     string
+	DBNumber int
+	Start    int // em unidades da área (bytes; timers/contadores para T/C)
+	Size     int // em unidades da área
+	Tags     []plannedTag
+}
+
+// byteLength é o tamanho do buffer necessário para o bloco
+func (b readBlock) byteLength() int {
+	return b.Size * tagAddress{Area: b.Area}.unitSize()
+}
+
+// bitRange retorna o primeiro bit e a quantidade de bits usados pelos tags de um
+// bloco de coils/entradas discretas, para ler só os bits configurados
+func (b readBlock) bitRange() (first int, count int) {
+	first, last := -1, -1
+	for _, pt := range b.Tags {
+		bit := pt.Addr.Byte*8 + pt.Addr.Bit
+		if first < 0 || bit < first {
+			first = bit
+		}
+		if bit > last {
+			last = bit
+		}
+	}
+	return first, last - first + 1
+}
+
+// tagNames lista os tags lidos no bloco
+func (b readBlock) tagNames() []string {
+	names := make([]string, 0, len(b.Tags))
+	for _, pt := range b.Tags {
+		names = append(names, pt.Name)
+	}
+	return names
+}
+
+// String formata o início do bloco na sintaxe S7 (para logs)
+func (b readBlock) String() string {
+	return tagAddress{Area: b.Area, DBNumber: b.DBNumber, Byte: b.Start, Width: "B"}.String()
+}
+
+// plannedTag localiza um tag dentro do buffer do bloco
+type plannedTag struct {
+	Name  string
+	Tag   PLCTag
+	Info  tagTypeInfo
+	Addr  tagAddress
+	Units int // quantas unidades da área o tag ocupa
+}
+
+// tagBitOffset extrai o bit do offset no formato byte.bit (ex: 52.3)
+func tagBitOffset(offset float64) (int, int, error) {
+	byteOffset := int(offset)
+	bitOffset := int(math.Round((offset - float64(byteOffset)) * 10))
+
+	if bitOffset < 0 || bitOffset > 7 {
+		return 0, 0, fmt.Errorf("bit offset inválido: %d (deve ser 0-7)", bitOffset)
+	}
+	return byteOffset, bitOffset, nil
+}
+
+// resolveTag valida tipo e endereço de um tag
+func resolveTag(tag PLCTag, config PLCConfig) (tagTypeInfo, tagAddress, error) {
+	info, err := parseTagType(tag)
+	if err != nil {
+		return tagTypeInfo{}, tagAddress{}, err
+	}
+
+	addr, err := parseTagAddress(tag, info, config)
+	if err != nil {
+		return tagTypeInfo{}, tagAddress{}, err
+	}
+	return info, addr, nil
+}
+
+// buildReadPlan agrupa os tags, por área de memória, em blocos contíguos que cabem
+// num único PDU. Tags inválidos são devolvidos em invalid para que o chamador possa reportá-los.
+func buildReadPlan(tags map[string]PLCTag, config PLCConfig, pduLength int) (blocks []readBlock, invalid map[string]error) {
+	invalid = make(map[string]error)
+
+	if pduLength <= s7ReadReplyHeader {
+		pduLength = s7DefaultPDULength
+	}
+	maxBytes := pduLength - s7ReadReplyHeader
+
+	// Dispositivos Modbus costumam recusar leituras que cruzam endereços não mapeados
+	maxGap := readPlanMaxGap
+	if config.driverName() == driverModbus {
+		maxGap = 0
+	}
+
+	planned := make([]plannedTag, 0, len(tags))
+	for name, tag := range tags {
+		info, addr, err := resolveTag(tag, config)
+		if err != nil {
+			invalid[name] = err
+			continue
+		}
+
+		unit := addr.unitSize()
+		planned = append(planned, plannedTag{
+			Name:  name,
+			Tag:   tag,
+			Info:  info,
+			Addr:  addr,
+			Units: (info.byteSize() + unit - 1) / unit,
+		})
+	}
+
+	// Ordenar por área, DB e offset (e nome para um plano estável)
+	sort.Slice(planned, func(i, j int) bool {
+		a, b := planned[i].Addr, planned[j].Addr
+		if a.Area != b.Area {
+			return a.Area < b.Area
+		}
+		if a.DBNumber != b.DBNumber {
+			return a.DBNumber < b.DBNumber
+		}
+		if a.Byte != b.Byte {
+			return a.Byte < b.Byte
+		}
+		return planned[i].Name < planned[j].Name
+	})
+
+	for _, pt := range planned {
+		end := pt.Addr.Byte + pt.Units
+		maxUnits := maxBytes / pt.Addr.unitSize()
+
+		if n := len(blocks); n > 0 {
+			last := &blocks[n-1]
+			lastEnd := last.Start + last.Size
+			newEnd := end
+			if lastEnd > newEnd {
+				newEnd = lastEnd
+			}
+
+			sameArea := last.Area == pt.Addr.Area && last.DBNumber == pt.Addr.DBNumber
+			if sameArea && pt.Addr.Byte-lastEnd <= maxGap && newEnd-last.Start <= maxUnits {
+				last.Size = newEnd - last.Start
+				last.Tags = append(last.Tags, pt)
+				continue
+			}
+		}
+
+		blocks = append(blocks, readBlock{
+			Area:     pt.Addr.Area,
+			DBNumber: pt.Addr.DBNumber,
+			Start:    pt.Addr.Byte,
+			Size:     pt.Units,
+			Tags:     []plannedTag{pt},
+		})
+	}
+
+	return blocks, invalid
+}
+
+// decodeTag interpreta o valor de um tag a partir do buffer do bloco, já com o "scale" aplicado
+func decodeTag(block readBlock, pt plannedTag, buffer []byte) (interface{}, error) {
+	pos := (pt.Addr.Byte - block.Start) * pt.Addr.unitSize()
+	size := pt.Info.byteSize()
+	bit := pt.Addr.Bit
+	if pt.Info.Base == "bool" {
+		var byteOffset int
+		byteOffset, bit = pt.Addr.bitLocation()
+		pos += byteOffset
+	}
+	if pos < 0 || pos+size > len(buffer) {
+		return nil, fmt.Errorf("tag fora do bloco lido (%s)", pt.Addr)
+	}
+
+	data := buffer[pos : pos+size]
+	if pt.Addr.WordSwap {
+		data = swapWords(data)
+	}
+
+	value, err := decodeS7Value(pt.Info, bit, data)
+	if err != nil {
+		return nil, err
+	}
+	return scaleTagValue(pt.Tag, value), nil
+}
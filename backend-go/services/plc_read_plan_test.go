@@ -0,0 +1,216 @@
+package services
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestBuildReadPlan(t *testing.T) {
+	s7 := PLCConfig{IP: "10.0.0.1", DBNumber: 19}
+	modbus := PLCConfig{IP: "10.0.0.2", Driver: driverModbus}
+
+	type block struct {
+		Area  string
+		DB    int
+		Start int
+		Size  int
+		Tags  []string
+	}
+
+	tests := []struct {
+		name    string
+		tags    map[string]PLCTag
+		config  PLCConfig
+		pdu     int
+		want    []block
+		invalid []string
+	}{
+		{
+			name: "tags contíguos num bloco",
+			tags: map[string]PLCTag{
+				"a": {Type: "real", Offset: 0},
+				"b": {Type: "real", Offset: 4},
+				"c": {Type: "bool", Offset: 8.1},
+			},
+			config: s7, pdu: 240,
+			want: []block{{areaDB, 19, 0, 9, []string{"a", "b", "c"}}},
+		},
+		{
+			name: "buraco pequeno lido junto, grande separa",
+			tags: map[string]PLCTag{
+				"a": {Type: "int", Offset: 0},
+				"b": {Type: "int", Offset: 20},  // buraco de 18 bytes
+				"c": {Type: "int", Offset: 100}, // buraco de 78 bytes
+			},
+			config: s7, pdu: 240,
+			want: []block{
+				{areaDB, 19, 0, 22, []string{"a", "b"}},
+				{areaDB, 19, 100, 2, []string{"c"}},
+			},
+		},
+		{
+			name: "bloco limitado pelo PDU",
+			tags: map[string]PLCTag{
+				"a": {Type: "string[40]", Address: "DB19.DBB0"},  // 42 bytes
+				"b": {Type: "string[40]", Address: "DB19.DBB42"}, // termina em 84
+			},
+			config: s7, pdu: 80, // 62 bytes úteis
+			want: []block{
+				{areaDB, 19, 0, 42, []string{"a"}},
+				{areaDB, 19, 42, 42, []string{"b"}},
+			},
+		},
+		{
+			name: "PDU inválido usa o padrão",
+			tags: map[string]PLCTag{
+				"a": {Type: "int", Offset: 0},
+				"b": {Type: "int", Offset: 200},
+			},
+			config: s7, pdu: 0,
+			want: []block{
+				{areaDB, 19, 0, 2, []string{"a"}},
+				{areaDB, 19, 200, 2, []string{"b"}},
+			},
+		},
+		{
+			name: "áreas e DBs separados",
+			tags: map[string]PLCTag{
+				"db19": {Type: "int", Address: "DB19.DBW0"},
+				"db20": {Type: "int", Address: "DB20.DBW0"},
+				"m":    {Type: "bool", Address: "M0.0"},
+				"t":    {Type: "word", Address: "T3"},
+				"t2":   {Type: "word", Address: "T4"},
+			},
+			config: s7, pdu: 240,
+			want: []block{
+				{areaDB, 19, 0, 2, []string{"db19"}},
+				{areaDB, 20, 0, 2, []string{"db20"}},
+				{areaMerkers, 0, 0, 1, []string{"m"}},
+				{areaTimers, 0, 3, 2, []string{"t", "t2"}},
+			},
+		},
+		{
+			name: "tag inválido fora do plano",
+			tags: map[string]PLCTag{
+				"ok":  {Type: "int", Offset: 0},
+				"bad": {Type: "udt", Offset: 2},
+			},
+			config: s7, pdu: 240,
+			want:    []block{{areaDB, 19, 0, 2, []string{"ok"}}},
+			invalid: []string{"bad"},
+		},
+		{
+			name: "Modbus não lê buracos",
+			tags: map[string]PLCTag{
+				"a": {Type: "int", Address: "HR0"},
+				"b": {Type: "real", Address: "HR1"},
+				"c": {Type: "int", Address: "HR4"},
+			},
+			config: modbus, pdu: 240,
+			want: []block{
+				{areaHoldingRegisters, 0, 0, 3, []string{"a", "b"}},
+				{areaHoldingRegisters, 0, 4, 1, []string{"c"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blocks, invalid := buildReadPlan(tt.tags, tt.config, tt.pdu)
+
+			got := make([]block, 0, len(blocks))
+			for _, b := range blocks {
+				got = append(got, block{b.Area, b.DBNumber, b.Start, b.Size, b.tagNames()})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("blocos:\nobtido   %+v\nesperado %+v", got, tt.want)
+			}
+
+			var names []string
+			for name := range invalid {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			if !reflect.DeepEqual(names, tt.invalid) {
+				t.Errorf("inválidos: obtido %v, esperado %v", names, tt.invalid)
+			}
+		})
+	}
+}
+
+func TestDecodeTag(t *testing.T) {
+	scale := 0.1
+	tags := map[string]PLCTag{
+		"nivel":  {Type: "real", Offset: 0},
+		"porta":  {Type: "int", Offset: 4, Scale: &scale},
+		"bit":    {Type: "bool", Offset: 6.2},
+		"regBit": {Type: "bool", Address: "HR1.9"},
+	}
+
+	blocks, invalid := buildReadPlan(map[string]PLCTag{"nivel": tags["nivel"], "porta": tags["porta"], "bit": tags["bit"]},
+		PLCConfig{IP: "x", DBNumber: 1}, 240)
+	if len(invalid) > 0 || len(blocks) != 1 {
+		t.Fatalf("plano inesperado: %v %v", blocks, invalid)
+	}
+	buffer := []byte{0x42, 0x90, 0xCC, 0xCD, 0x03, 0xE8, 0x04}
+	want := map[string]interface{}{"nivel": float32(72.4), "porta": 100.0, "bit": true}
+	for _, pt := range blocks[0].Tags {
+		got, err := decodeTag(blocks[0], pt, buffer)
+		if err != nil {
+			t.Fatalf("%s: %v", pt.Name, err)
+		}
+		if got != want[pt.Name] {
+			t.Errorf("%s: obtido %#v, esperado %#v", pt.Name, got, want[pt.Name])
+		}
+	}
+
+	// Bit 9 de um registrador fica no byte mais significativo (o primeiro)
+	blocks, _ = buildReadPlan(map[string]PLCTag{"regBit": tags["regBit"]}, PLCConfig{IP: "x", Driver: driverModbus}, 240)
+	got, err := decodeTag(blocks[0], blocks[0].Tags[0], []byte{0x02, 0x00})
+	if err != nil || got != true {
+		t.Errorf("HR1.9: obtido %v (%v), esperado true", got, err)
+	}
+
+	// Buffer menor que o bloco: erro em vez de pânico
+	blocks, _ = buildReadPlan(map[string]PLCTag{"nivel": tags["nivel"]}, PLCConfig{IP: "x", DBNumber: 1}, 240)
+	if _, err := decodeTag(blocks[0], blocks[0].Tags[0], []byte{0x42}); err == nil {
+		t.Error("buffer curto: esperado erro")
+	}
+}
+
+// bitsStub devolve bits fixos e guarda a faixa pedida
+type bitsStub struct {
+	first, count int
+	bits         []byte
+}
+
+func (b *bitsStub) ReadBits(area string, first int, count int, buffer []byte) error {
+	b.first, b.count = first, count
+	copy(buffer, b.bits)
+	return nil
+}
+
+func TestReadBlockBits(t *testing.T) {
+	tags := map[string]PLCTag{
+		"a": {Type: "bool", Address: "CO17"},
+		"b": {Type: "bool", Address: "CO19"},
+	}
+	blocks, invalid := buildReadPlan(tags, PLCConfig{IP: "x", Driver: driverModbus}, 240)
+	if len(invalid) > 0 || len(blocks) != 1 {
+		t.Fatalf("plano inesperado: %v %v", blocks, invalid)
+	}
+
+	// Só os coils 17 a 19, não o byte inteiro 16-23
+	stub := &bitsStub{bits: []byte{0x05}}
+	buffer := make([]byte, blocks[0].byteLength())
+	if err := readBlockBits(stub, blocks[0], buffer); err != nil {
+		t.Fatal(err)
+	}
+	if stub.first != 17 || stub.count != 3 {
+		t.Errorf("bits pedidos %d+%d, esperado 17+3", stub.first, stub.count)
+	}
+	if buffer[0] != 0x0A {
+		t.Errorf("buffer %#x, esperado 0x0a", buffer[0])
+	}
+}
@@ -1,16 +1,11 @@
 package services
 
 import (
-	"encoding/binary"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"log"
-	"math"
 	"sync"
 	"time"
-
-	"github.com/robinson/gos7"
 )
 
 type PLCConfig struct {
@@ -18,644 +13,586 @@ type PLCConfig struct {
 	Rack     int    `json:"rack"`
 	Slot     int    `json:"slot"`
 	DBNumber int    `json:"db_number"`
+	Driver   string `json:"driver,omitempty"`   // "s7" (padrão), "modbus" ou "simulator"
+	Scenario string `json:"scenario,omitempty"` // Arquivo de cenário do simulador
+
+	// Modbus TCP
+	Port      int    `json:"port,omitempty"`       // Padrão 502
+	UnitID    int    `json:"unit_id,omitempty"`    // Padrão 1
+	WordOrder string `json:"word_order,omitempty"` // Ordem das palavras em valores de 32/64 bits: "big" (padrão) ou "little"
 }
 
 type PLCTag struct {
-	Type        string  `json:"type"`
-	Offset      float64 `json:"offset"`
-	Description string  `json:"description"`
+	Type        string   `json:"type"`              // bool, byte, word, int, dint, real, lreal, string[n], dtl...
+	Offset      float64  `json:"offset"`            // byte.bit no DB padrão (formato antigo)
+	Address     string   `json:"address,omitempty"` // Endereço S7 (DB19.DBX52.3, I0.1, MW10, T5...) ou Modbus (HR100, CO5...)
+	Length      int      `json:"length,omitempty"`  // Comprimento de string/wstring (padrão 254)
+	Description string   `json:"description"`
+	MinValue    *float64 `json:"min_value,omitempty"`  // Limite para escrita
+	MaxValue    *float64 `json:"max_value,omitempty"`  // Limite para escrita
+	Scale       *float64 `json:"scale,omitempty"`      // Multiplicador do valor bruto (ex: 0.1 para registradores em décimos)
+	WordOrder   string   `json:"word_order,omitempty"` // Modbus: "big" ou "little" (sobrepõe o plc_config)
+
+	// Unidades de engenharia: bruto (após scale) raw_min..raw_max → eu_min..eu_max, + eu_offset
+	// (ex. cota do zero hidrográfico), e clamp "limit" para saturar em eu_min..eu_max
+	RawMin   *float64 `json:"raw_min,omitempty"`
+	RawMax   *float64 `json:"raw_max,omitempty"`
+	EUMin    *float64 `json:"eu_min,omitempty"`
+	EUMax    *float64 `json:"eu_max,omitempty"`
+	EUOffset float64  `json:"eu_offset,omitempty"`
+	Unit     string   `json:"unit,omitempty"`
+	Clamp    string   `json:"clamp,omitempty"` // "none" (padrão) ou "limit"
+
+	// Política de publicação: variações menores que a banda morta não geram broadcast
+	Deadband     float64 `json:"deadband,omitempty"`
+	DeadbandType string  `json:"deadband_type,omitempty"`  // "absolute" (padrão) ou "percent"
+	MinPublishMs int     `json:"min_publish_ms,omitempty"` // Intervalo mínimo entre publicações do tag
+
+	ScanClass string `json:"scan_class,omitempty"` // Classe de varredura (vazio = do grupo ou default_scan_class)
+
+	// Tag calculado: expressão sobre outros tags (ex: "{Porta Jusante} < 1 && {Porta Montante} < 1"),
+	// avaliada a cada varredura da classe; não tem endereço no PLC
+	Expression string `json:"expression,omitempty"`
+
+	// Histórico em tag_histories: grava quando o valor sai de history_deadband (mesmo deadband_type)
+	// ou após history_max_interval_s sem gravar; dados brutos viram agregados horários após
+	// history_raw_days e tudo é apagado após history_retention_days
+	History              bool    `json:"history,omitempty"`
+	HistoryDeadband      float64 `json:"history_deadband,omitempty"`
+	HistoryMaxIntervalS  int     `json:"history_max_interval_s,omitempty"`
+	HistoryRawDays       int     `json:"history_raw_days,omitempty"`
+	HistoryRetentionDays int     `json:"history_retention_days,omitempty"`
+
+	// Regras de alarme do tag (limites, bit, taxa de variação, desvio)
+	Alarms []AlarmRule `json:"alarms,omitempty"`
+
+	// Sequência de eventos em tag_events: cada mudança de valor é gravada com o valor anterior.
+	// Padrão ligado nos bool; tags inteiros de estado (motores, válvulas) ativam com true.
+	// states dá nome aos valores nos eventos (ex: {"0": "parado", "1": "abrindo"})
+	Events *bool             `json:"events,omitempty"`
+	States map[string]string `json:"states,omitempty"`
+}
+
+// PLCConnection descreve uma conexão PLC nomeada (uma por eclusa)
+type PLCConnection struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name"`
+	PLCConfig PLCConfig         `json:"plc_config"`
+	Tags      map[string]PLCTag `json:"tags"`
+
+	// Classes de varredura: intervalo em ms por nome (somadas às padrão fast/normal/slow)
+	ScanClasses      map[string]int `json:"scan_classes,omitempty"`
+	DefaultScanClass string         `json:"default_scan_class,omitempty"`
+
+	// Campos da mensagem do WebSocket mapeados a partir dos tags
+	WebSocketFields []WebSocketField `json:"ws_fields,omitempty"`
+
+	// Detecção dos ciclos de eclusagem (níveis, portas e semáforos da eclusa)
+	Lockage *LockageConfig `json:"lockage,omitempty"`
 }
 
+// PLCConfigFile aceita a lista "plcs" ou o formato antigo de PLC único
+// ("plc_config" + "tags" na raiz do arquivo)
 type PLCConfigFile struct {
-	PLCConfig PLCConfig           `json:"plc_config"`
-	Tags      map[string]PLCTag   `json:"tags"`
+	PLCs      []PLCConnection   `json:"plcs,omitempty"`
+	PLCConfig *PLCConfig        `json:"plc_config,omitempty"`
+	Tags      map[string]PLCTag `json:"tags,omitempty"`
+	WSFields  []WebSocketField  `json:"ws_fields,omitempty"`
 }
 
 type S7PLCConnector struct {
-	config       PLCConfigFile
-	client       gos7.Client
-	handler      *gos7.TCPClientHandler
-	isConnected  bool
-	mutex        sync.RWMutex
-	hub          *WebSocketHub
-	stopChan     chan bool
-	
-	// Cache para valores atuais
-	currentValues     map[string]interface{}
-	currentMutex      sync.RWMutex
+	lockID      string // ID da eclusa; fixo, ao contrário de config, trocada pela recarga
+	config      PLCConnection
+	driver      PLCDriver
+	isConnected bool
+	mutex       sync.RWMutex
+	ioMutex     sync.Mutex // serializa leituras e escritas no driver
+	hub         *WebSocketHub
+	stopChan    chan bool
+
+	// Cache para valores atuais (com qualidade e carimbos de tempo)
+	currentValues      map[string]TagValue
+	currentMutex       sync.RWMutex
 	plcReadAtLeastOnce bool
-	lastValues        map[string]interface{}
+	lastValues         map[string]publishedValue // último valor publicado de cada tag
+	publishMutex       sync.Mutex                // protege lastValues (uma rotina por classe)
+
+	// Plano de leitura em blocos por classe de varredura (recalculado a cada conexão)
+	readPlans map[string][]readBlock
+
+	// Tags calculados por classe de varredura, em ordem de avaliação
+	computedPlans map[string][]computedTag
+
+	// Tags com regras de alarme por classe de varredura
+	alarmPlans map[string][]alarmTag
+
+	// Encerra as rotinas de varredura da configuração atual
+	scanStop chan struct{}
+
+	// Saúde da comunicação e espera entre tentativas de conexão
+	stats        *connectionStats
+	backoff      reconnectBackoff
+	statsChanged chan struct{}
+	reconnectNow chan struct{} // acorda o connectLoop após troca de driver/endereço
 }
 
-var (
-	globalS7Connector *S7PLCConnector
-	s7Once            sync.Once
-)
+// newS7PLCConnector cria o conector de uma eclusa (sem iniciar as rotinas)
+func newS7PLCConnector(conn PLCConnection, hub *WebSocketHub) *S7PLCConnector {
+	if conn.Tags == nil {
+		conn.Tags = make(map[string]PLCTag)
+	}
+
+	return &S7PLCConnector{
+		lockID:        conn.ID,
+		config:        conn,
+		driver:        newPLCDriver(conn),
+		hub:           hub,
+		stopChan:      make(chan bool, 1),
+		currentValues: make(map[string]TagValue),
+		lastValues:    make(map[string]publishedValue),
+		stats:         newConnectionStats(),
+		backoff:       reconnectBackoff{min: reconnectMinDelay, max: reconnectMaxDelay},
+		statsChanged:  make(chan struct{}, 1),
+		reconnectNow:  make(chan struct{}, 1),
+	}
+}
 
-// GetS7PLCConnector retorna instância singleton do conector S7
-func GetS7PLCConnector() *S7PLCConnector {
-	s7Once.Do(func() {
-		globalS7Connector = &S7PLCConnector{
-			hub:           GetWebSocketHub(),
-			stopChan:      make(chan bool, 1),
-			currentValues: make(map[string]interface{}),
-			lastValues:    make(map[string]interface{}),
+// Start inicia as rotinas de conexão e leitura do conector
+func (s7 *S7PLCConnector) Start() {
+	s7.mutex.RLock()
+	config := s7.config
+	driverName := s7.driver.Name()
+	s7.mutex.RUnlock()
+
+	log.Printf("✅ S7 PLC Connector [%s] inicializado: %s %s DB%d, %d tags",
+		s7.lockID,
+		driverName,
+		config.PLCConfig.IP,
+		config.PLCConfig.DBNumber,
+		len(config.Tags))
+
+	s7.startScenario()
+	GetAlarmEngine().syncRules(config)
+
+	go s7.connectLoop()
+	go s7.qualityLoop()
+	go s7.statsLoop()
+	s7.startScanners()
+}
+
+// applyConfig troca a configuração da eclusa de forma atômica.
+// Retorna true quando o plc_config mudou e a conexão foi refeita.
+func (s7 *S7PLCConnector) applyConfig(conn PLCConnection) bool {
+	if conn.Tags == nil {
+		conn.Tags = make(map[string]PLCTag)
+	}
+
+	// ioMutex garante que nenhuma leitura/escrita use o driver durante a troca
+	s7.ioMutex.Lock()
+	s7.mutex.Lock()
+	reconnect := s7.config.PLCConfig != conn.PLCConfig
+	s7.config = conn
+	s7.readPlans = nil
+	s7.computedPlans = nil
+	s7.alarmPlans = nil
+
+	var oldDriver PLCDriver
+	if reconnect {
+		oldDriver = s7.driver
+		s7.driver = newPLCDriver(conn)
+		s7.isConnected = false
+	}
+	s7.mutex.Unlock()
+	s7.ioMutex.Unlock()
+
+	// Remover do cache os tags que deixaram de existir
+	s7.currentMutex.Lock()
+	for name := range s7.currentValues {
+		if _, ok := conn.Tags[name]; !ok {
+			delete(s7.currentValues, name)
 		}
+	}
+	s7.currentMutex.Unlock()
 
-		// Carregar configuração
-		if err := globalS7Connector.loadConfig("tags.json"); err != nil {
-			log.Printf("❌ Erro ao carregar tags.json: %v", err)
-			// Tentar carregar do websocket antigo
-			if err2 := globalS7Connector.loadConfig("../websocket/tags.json"); err2 != nil {
-				log.Printf("❌ Erro ao carregar ../websocket/tags.json: %v", err2)
-				// Configuração padrão
-				globalS7Connector.setupDefaultConfig()
-			}
+	s7.publishMutex.Lock()
+	for name := range s7.lastValues {
+		if _, ok := conn.Tags[name]; !ok {
+			delete(s7.lastValues, name)
 		}
+	}
+	s7.publishMutex.Unlock()
 
-		log.Printf("✅ S7 PLC Connector inicializado: %s DB%d, %d tags", 
-			globalS7Connector.config.PLCConfig.IP, 
-			globalS7Connector.config.PLCConfig.DBNumber, 
-			len(globalS7Connector.config.Tags))
+	s7.stats.pruneTags(conn.Tags)
+	GetAlarmEngine().syncRules(conn)
 
-		// Iniciar conexão e leitura
-		go globalS7Connector.connectLoop()
-		go globalS7Connector.readLoop()
-	})
+	// Tags podem ter mudado de classe de varredura
+	s7.startScanners()
 
-	return globalS7Connector
+	if reconnect {
+		// connectLoop reconecta com o novo endereço/driver
+		if sim, ok := oldDriver.(*SimulatorDriver); ok {
+			sim.StopScenario()
+		}
+		oldDriver.Close()
+		s7.stats.recordDisconnect(nil, time.Now())
+		s7.markBadComm()
+		select {
+		case s7.reconnectNow <- struct{}{}:
+		default:
+		}
+		log.Printf("🔌 [%s] Desconectado do S7 PLC (configuração alterada)", s7.lockID)
+		s7.startScenario()
+	}
+
+	return reconnect
 }
 
-func (s7 *S7PLCConnector) loadConfig(filename string) error {
-	data, err := ioutil.ReadFile(filename)
-	if err != nil {
-		return err
-	}
+// ID retorna o identificador da eclusa deste conector
+func (s7 *S7PLCConnector) ID() string {
+	return s7.lockID
+}
 
-	return json.Unmarshal(data, &s7.config)
-}
-
-func (s7 *S7PLCConnector) setupDefaultConfig() {
-	s7.config = PLCConfigFile{
-		PLCConfig: PLCConfig{
-			IP:       "192.168.1.33",
-			Rack:     0,
-			Slot:     1,
-			DBNumber: 19,
-		},
-		Tags: map[string]PLCTag{
-			"Eclusa_Nivel_Caldeira": {
-				Type:        "real",
-				Offset:      0.0,
-				Description: "Eclusa Nível Caldeira (Real)",
-			},
-			"Eclusa_Nivel_Montante": {
-				Type:        "real", 
-				Offset:      4.0,
-				Description: "Eclusa Nível Montante (Real)",
-			},
-			"Eclusa_Nivel_Jusante": {
-				Type:        "real",
-				Offset:      8.0,
-				Description: "Eclusa Nível Jusante (Real)",
-			},
-		},
-	}
+// Name retorna o nome de exibição da eclusa deste conector
+func (s7 *S7PLCConnector) Name() string {
+	s7.mutex.RLock()
+	defer s7.mutex.RUnlock()
+	return s7.config.Name
 }
 
+// connectLoop mantém a conexão: verifica a cada segundo e, desconectado, tenta de novo
+// com espera exponencial (1 s a 60 s, com jitter)
 func (s7 *S7PLCConnector) connectLoop() {
 	for {
+		wait := connectionCheckInterval
+		if !s7.isConnected {
+			if err := s7.connect(); err != nil {
+				wait = s7.backoff.next()
+				s7.stats.recordRetry(time.Now().Add(wait))
+				s7.mutex.RLock()
+				ip := s7.config.PLCConfig.IP
+				s7.mutex.RUnlock()
+				log.Printf("⚠️ [%s] Erro ao conectar S7 PLC %s: %v (nova tentativa em %s)",
+					s7.lockID, ip, err, wait.Round(100*time.Millisecond))
+			} else {
+				s7.backoff.reset()
+			}
+		}
+
 		select {
 		case <-s7.stopChan:
 			return
-		default:
-			if !s7.isConnected {
-				s7.connect()
-			}
-			time.Sleep(5 * time.Second)
+		case <-time.After(wait):
+		case <-s7.reconnectNow:
+			s7.backoff.reset()
 		}
 	}
 }
 
-func (s7 *S7PLCConnector) connect() {
+func (s7 *S7PLCConnector) connect() error {
 	s7.mutex.Lock()
 	defer s7.mutex.Unlock()
 
 	if s7.isConnected {
-		return
+		return nil
 	}
 
-	// Configurar handler S7
-	s7.handler = gos7.NewTCPClientHandler(
-		s7.config.PLCConfig.IP,
-		s7.config.PLCConfig.Rack,
-		s7.config.PLCConfig.Slot,
-	)
-	s7.handler.Timeout = 5 * time.Second
-	s7.handler.IdleTimeout = 5 * time.Second
-
-	s7.client = gos7.NewClient(s7.handler)
-
 	// Tentar conectar
-	err := s7.handler.Connect()
+	err := s7.driver.Connect()
+	s7.stats.recordConnect(err, time.Now())
+	s7.notifyStats()
 	if err != nil {
-		log.Printf("⚠️ Erro ao conectar S7 PLC %s: %v", s7.config.PLCConfig.IP, err)
-		return
+		return err
 	}
 
 	s7.isConnected = true
-	log.Printf("✅ Conectado ao S7 PLC %s DB%d", s7.config.PLCConfig.IP, s7.config.PLCConfig.DBNumber)
+	s7.readPlans = nil // PDU pode ter mudado
+	log.Printf("✅ [%s] Conectado ao S7 PLC %s DB%d (%s)", s7.lockID, s7.config.PLCConfig.IP, s7.config.PLCConfig.DBNumber, s7.driver.Name())
+	return nil
 }
 
-func (s7 *S7PLCConnector) disconnect() {
+// disconnect fecha a conexão; err é o motivo (nil quando pedida)
+func (s7 *S7PLCConnector) disconnect(err error) {
 	s7.mutex.Lock()
 	defer s7.mutex.Unlock()
 
-	if s7.handler != nil {
-		s7.handler.Close()
-		s7.handler = nil
-	}
+	s7.driver.Close()
 
 	s7.isConnected = false
-	log.Printf("🔌 Desconectado do S7 PLC")
+	s7.stats.recordDisconnect(err, time.Now())
+	s7.notifyStats()
+	log.Printf("🔌 [%s] Desconectado do S7 PLC", s7.lockID)
 }
 
-func (s7 *S7PLCConnector) readLoop() {
-	ticker := time.NewTicker(25 * time.Millisecond) // 40 Hz como no código antigo
+// startScanners inicia uma rotina de leitura por classe de varredura com tags
+func (s7 *S7PLCConnector) startScanners() {
+	s7.mutex.Lock()
+	defer s7.mutex.Unlock()
+
+	if s7.scanStop != nil {
+		close(s7.scanStop)
+	}
+	stop := make(chan struct{})
+	s7.scanStop = stop
+
+	intervals := s7.config.scanClassIntervals()
+	for class := range s7.config.tagsByScanClass() {
+		go s7.scanLoop(class, intervals[class], stop)
+	}
+}
+
+// scanLoop lê os tags de uma classe no intervalo dela
+func (s7 *S7PLCConnector) scanLoop(class string, interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-s7.stopChan:
 			return
+		case <-stop:
+			return
 		case <-ticker.C:
 			if s7.isConnected {
-				s7.readAllTags()
+				s7.readScanClass(class)
 			}
 		}
 	}
 }
 
-func (s7 *S7PLCConnector) readAllTags() {
-	values := make(map[string]interface{})
+// readScanClass lê os tags de uma classe e, havendo mudança publicável,
+// envia aos clientes o conjunto completo de valores publicados (de todas as classes)
+func (s7 *S7PLCConnector) readScanClass(class string) {
 	hasChanges := false
+	now := time.Now()
+	failed := false
+
+	s7.mutex.RLock()
+	staleAfter := staleAfterInterval(s7.config.scanClassIntervals()[class])
+	s7.mutex.RUnlock()
 
-	// Ler cada tag configurado
-	for tagName, tag := range s7.config.Tags {
-		value, err := s7.readTag(tag)
+	// Ler a memória em blocos contíguos: uma requisição por bloco em vez de uma por tag
+	for _, block := range s7.getReadPlan(class) {
+		buffer, err := s7.readBlock(block)
 		if err != nil {
 			// Log erro apenas a cada 60 segundos
 			if time.Now().Unix()%60 == 0 {
-				log.Printf("⚠️ Erro ao ler tag %s: %v", tagName, err)
+				log.Printf("⚠️ [%s] Erro ao ler bloco %s (%d bytes, %d tags): %v",
+					s7.lockID, block, block.byteLength(), len(block.Tags), err)
+			}
+			s7.stats.recordReadError(err, block.tagNames(), time.Now())
+			failed = true
+
+			// Conexão perdida: os clientes passam a ver os últimos valores como bad-comm
+			// e não adianta tentar os blocos seguintes
+			if s7.markBadComm() {
+				hasChanges = true
+			}
+			break
+		}
+
+		for _, pt := range block.Tags {
+			value, err := decodeTag(block, pt, buffer)
+			if err != nil {
+				if time.Now().Unix()%60 == 0 {
+					log.Printf("⚠️ Erro ao decodificar tag %s: %v", pt.Name, err)
+				}
+				s7.stats.recordReadError(err, []string{pt.Name}, time.Now())
+				if s7.setQuality(pt.Name, QualityBadConfig) {
+					hasChanges = true
+				}
+				continue
 			}
-			continue
-		}
 
-		// Atualizar cache de valores atuais
-		s7.currentMutex.Lock()
-		s7.currentValues[tagName] = value
-		if !s7.plcReadAtLeastOnce {
-			s7.plcReadAtLeastOnce = true
-			log.Printf("✅ Primeira leitura do S7 PLC concluída")
+			if s7.storeTagValue(pt.Name, pt.Tag, value, now, staleAfter, QualityGood) {
+				hasChanges = true
+			}
 		}
-		s7.currentMutex.Unlock()
+	}
 
-		// Verificar mudanças
-		if lastVal, exists := s7.lastValues[tagName]; !exists || lastVal != value {
-			hasChanges = true
-			s7.lastValues[tagName] = value
+	if !failed {
+		s7.stats.recordScan(class, time.Since(now), time.Now())
 
-			// Log para semáforos
-			if tag.Type == "bool" {
-				log.Printf("🚦 S7 Tag %s mudou: %v -> %v", tagName, lastVal, value)
-			}
+		// Tags calculados da classe, com os valores recém-lidos
+		if s7.evaluateComputed(class, now, staleAfter) {
+			hasChanges = true
 		}
-		values[tagName] = value
 
+		// Alarmes dos tags da classe (com os calculados já atualizados)
+		s7.evaluateAlarms(class, now)
+
+		// Máquina de estados da eclusagem
+		s7.evaluateLockage(class, now)
 	}
 
 	// Broadcast mudanças via WebSocket
 	if hasChanges {
-		s7.broadcastValues(values)
+		s7.broadcastValues(s7.publishedValues())
 	}
 }
 
-func (s7 *S7PLCConnector) readTag(tag PLCTag) (interface{}, error) {
-	switch tag.Type {
-	case "real":
-		return s7.readReal(int(tag.Offset))
-	case "int":
-		return s7.readInt(int(tag.Offset))
-	case "bool":
-		return s7.readBool(tag.Offset)
-	default:
-		return nil, fmt.Errorf("tipo de tag não suportado: %s", tag.Type)
-	}
-}
+// storeTagValue atualiza o cache com o valor de um tag e decide se ele deve ser
+// publicado (banda morta e intervalo mínimo de publicação do tag, ou mudança de qualidade)
+func (s7 *S7PLCConnector) storeTagValue(name string, tag PLCTag, value interface{}, now time.Time, staleAfter time.Duration, quality string) bool {
+	// Atualizar cache de valores atuais
+	qualityChanged := s7.storeValue(name, tag, value, now, staleAfter, quality)
 
-func (s7 *S7PLCConnector) readReal(offset int) (float32, error) {
-	if !s7.isConnected {
-		return 0, fmt.Errorf("S7 PLC não conectado")
-	}
+	// Histórico tem banda morta e intervalo próprios, independentes da publicação
+	GetHistoryRecorder().Record(s7.lockID, name, tag, value, worseQuality(readQuality(tag, value), quality), now, staleAfter)
 
-	buffer := make([]byte, 4)
-	err := s7.client.AGReadDB(s7.config.PLCConfig.DBNumber, offset, 4, buffer)
-	if err != nil {
-		s7.disconnect() // Desconectar em caso de erro
-		return 0, err
+	// Verificar mudanças
+	s7.publishMutex.Lock()
+	last, exists := s7.lastValues[name]
+	publish := shouldPublish(tag, last, exists, value, now)
+	if publish {
+		s7.lastValues[name] = publishedValue{Value: value, At: now}
 	}
+	s7.publishMutex.Unlock()
 
-	bits := binary.BigEndian.Uint32(buffer)
-	return math.Float32frombits(bits), nil
+	// Sequência de eventos: toda transição dos tags digitais e de estado, mesmo as retidas
+	// pelo intervalo de publicação
+	GetEventRecorder().Record(s7.lockID, name, tag, value, worseQuality(readQuality(tag, value), quality), now)
+
+	return publish || qualityChanged
 }
 
-func (s7 *S7PLCConnector) readInt(offset int) (int16, error) {
-	if !s7.isConnected {
-		return 0, fmt.Errorf("S7 PLC não conectado")
+// publishedValues copia o último valor publicado de cada tag. Os clientes recebem
+// esses valores; variações dentro da banda morta ficam de fora.
+func (s7 *S7PLCConnector) publishedValues() map[string]interface{} {
+	s7.publishMutex.Lock()
+	defer s7.publishMutex.Unlock()
+
+	values := make(map[string]interface{}, len(s7.lastValues))
+	for name, published := range s7.lastValues {
+		values[name] = published.Value
 	}
+	return values
+}
 
-	buffer := make([]byte, 2)
-	err := s7.client.AGReadDB(s7.config.PLCConfig.DBNumber, offset, 2, buffer)
-	if err != nil {
-		s7.disconnect() // Desconectar em caso de erro
-		return 0, err
+// getReadPlan retorna o plano de leitura da classe, recalculando os planos quando necessário
+func (s7 *S7PLCConnector) getReadPlan(class string) []readBlock {
+	s7.mutex.Lock()
+	defer s7.mutex.Unlock()
+
+	if s7.readPlans == nil {
+		pduLength := s7.driver.PDULength()
+		s7.readPlans = make(map[string][]readBlock)
+
+		for name, classTags := range s7.config.tagsByScanClass() {
+			// Tags calculados não são lidos do PLC
+			tags := make(map[string]PLCTag, len(classTags))
+			for tagName, tag := range classTags {
+				if !tag.isComputed() {
+					tags[tagName] = tag
+				}
+			}
+
+			blocks, invalid := buildReadPlan(tags, s7.config.PLCConfig, pduLength)
+			for tagName, err := range invalid {
+				log.Printf("⚠️ Tag %s ignorado no plano de leitura: %v", tagName, err)
+				s7.setQuality(tagName, QualityBadConfig)
+			}
+
+			s7.readPlans[name] = blocks
+			log.Printf("📋 [%s] Plano de leitura S7, classe %s: %d tags em %d blocos (PDU %d bytes)",
+				s7.lockID, name, len(tags)-len(invalid), len(blocks), pduLength)
+		}
 	}
 
-	return int16(binary.BigEndian.Uint16(buffer)), nil
+	return s7.readPlans[class]
 }
 
-func (s7 *S7PLCConnector) readBool(offset float64) (bool, error) {
+// readBlock lê um bloco contíguo de uma área de memória
+func (s7 *S7PLCConnector) readBlock(block readBlock) ([]byte, error) {
+	s7.ioMutex.Lock()
+	defer s7.ioMutex.Unlock()
+
 	if !s7.isConnected {
-		return false, fmt.Errorf("S7 PLC não conectado")
+		return nil, ErrPLCNotConnected
 	}
 
-	byteOffset := int(offset)
-	bitOffset := int(math.Round((offset - float64(byteOffset)) * 10))
-
-	if bitOffset < 0 || bitOffset > 7 {
-		return false, fmt.Errorf("bit offset inválido: %d (deve ser 0-7)", bitOffset)
+	buffer := make([]byte, block.byteLength())
+	var err error
+	if reader, ok := s7.driver.(bitReader); ok && isBitArea(block.Area) {
+		err = readBlockBits(reader, block, buffer)
+	} else {
+		err = s7.driver.ReadArea(block.Area, block.DBNumber, block.Start, block.Size, buffer)
 	}
-
-	buffer := make([]byte, 1)
-	err := s7.client.AGReadDB(s7.config.PLCConfig.DBNumber, byteOffset, 1, buffer)
 	if err != nil {
-		s7.disconnect() // Desconectar em caso de erro
-		return false, err
+		s7.disconnect(err) // Desconectar em caso de erro
+		return nil, err
 	}
 
-	result := (buffer[0] & (1 << bitOffset)) != 0
-	return result, nil
+	return buffer, nil
 }
 
+// readBlockBits lê só os bits usados pelo bloco e os posiciona no buffer em bytes do bloco
+func readBlockBits(reader bitReader, block readBlock, buffer []byte) error {
+	first, count := block.bitRange()
+	bits := make([]byte, (count+7)/8)
+	if err := reader.ReadBits(block.Area, first, count, bits); err != nil {
+		return err
+	}
+
+	offset := first - block.Start*8
+	for i := 0; i < count; i++ {
+		if bits[i/8]&(1<<(i%8)) != 0 {
+			pos := offset + i
+			buffer[pos/8] |= 1 << (pos % 8)
+		}
+	}
+	return nil
+}
 
 func (s7 *S7PLCConnector) broadcastValues(values map[string]interface{}) {
 	// Usar a função buildMessage do websocket antigo
 	message := s7.buildWebSocketMessage(values)
 	
-	log.Printf("🚀 BROADCAST: Enviando mensagem WebSocket com %d campos", len(message))
-	log.Printf("🚀 BROADCAST: Dados: %+v", message)
-	
 	if data, err := json.Marshal(message); err == nil {
-		log.Printf("🚀 BROADCAST: JSON serializado com %d bytes", len(data))
 		// Usar o hub para broadcast
 		select {
 		case s7.hub.broadcast <- data:
-			log.Printf("✅ BROADCAST: Mensagem enviada para canal")
 		default:
 			// Se canal estiver cheio, enviar em goroutine
-			log.Printf("⚠️ BROADCAST: Canal cheio, enviando em goroutine")
+			log.Printf("⚠️ [%s] Canal de broadcast cheio, enviando em goroutine", s7.lockID)
 			go func() {
 				s7.hub.broadcast <- data
 			}()
 		}
 	} else {
-		log.Printf("❌ BROADCAST: Erro ao serializar JSON: %v", err)
+		log.Printf("❌ [%s] Erro ao serializar mensagem WebSocket: %v", s7.lockID, err)
 	}
 }
 
-// Copiar a função buildMessage do código antigo
+// buildWebSocketMessage monta a mensagem de valores da eclusa. Os campos legados
+// (nivelCaldeiraValue, semaforos, pipe_system_N...) vêm do ws_fields da eclusa no tags.json.
 func (s7 *S7PLCConnector) buildWebSocketMessage(values map[string]interface{}) map[string]interface{} {
 	data := make(map[string]interface{})
 
-	// NOVOS NÍVEIS DA ECLUSA
-	if nivelCaldeira, ok := values["Eclusa_Nivel_Caldeira"]; ok {
-		if nivelFloat, isFloat := nivelCaldeira.(float32); isFloat {
-			limitedNivel := math.Max(0, math.Min(100, float64(nivelFloat)))
-			data["nivelCaldeiraValue"] = float32(limitedNivel)
-		} else {
-			data["nivelCaldeiraValue"] = float32(0)
-		}
-	}
-
-	if nivelMontante, ok := values["Eclusa_Nivel_Montante"]; ok {
-		if nivelFloat, isFloat := nivelMontante.(float32); isFloat {
-			limitedNivel := math.Max(0, math.Min(100, float64(nivelFloat)))
-			data["nivelMontanteValue"] = float32(limitedNivel)
-		} else {
-			data["nivelMontanteValue"] = float32(0)
-		}
-	}
-
-	if nivelJusante, ok := values["Eclusa_Nivel_Jusante"]; ok {
-		if nivelFloat, isFloat := nivelJusante.(float32); isFloat {
-			limitedNivel := math.Max(0, math.Min(100, float64(nivelFloat)))
-			data["nivelJusanteValue"] = float32(limitedNivel)
-		} else {
-			data["nivelJusanteValue"] = float32(0)
-		}
-	}
-
-	// RADARES DA ECLUSA
-	if radarCaldeiraDistancia, ok := values["Eclusa_Radar_Caldeira_Distancia"]; ok {
-		if radarFloat, isFloat := radarCaldeiraDistancia.(float32); isFloat {
-			data["radarCaldeiraDistanciaValue"] = radarFloat
-		} else {
-			data["radarCaldeiraDistanciaValue"] = float32(0)
-		}
-	}
-
-	if radarCaldeiraVelocidade, ok := values["Eclusa_Radar_Caldeira_Velocidade"]; ok {
-		if radarFloat, isFloat := radarCaldeiraVelocidade.(float32); isFloat {
-			data["radarCaldeiraVelocidadeValue"] = radarFloat
-		} else {
-			data["radarCaldeiraVelocidadeValue"] = float32(0)
-		}
-	}
-
-	// PORTAS DA ECLUSA
-	if eclusaPortaJusante, ok := values["Eclusa_Porta_Jusante"]; ok {
-		if portaFloat, isFloat := eclusaPortaJusante.(float32); isFloat {
-			limitedPorta := math.Max(0, math.Min(100, float64(portaFloat)))
-			data["eclusaPortaJusanteValue"] = float32(limitedPorta)
-		} else {
-			data["eclusaPortaJusanteValue"] = float32(0)
-		}
-	}
-
-	if eclusaPortaMontante, ok := values["Eclusa_Porta_Montante"]; ok {
-		if portaFloat, isFloat := eclusaPortaMontante.(float32); isFloat {
-			limitedPorta := math.Max(0, math.Min(100, float64(portaFloat)))
-			data["eclusaPortaMontanteValue"] = float32(limitedPorta)
-		} else {
-			data["eclusaPortaMontanteValue"] = float32(0)
-		}
-	}
-
-	// STATUS DA ECLUSA
-	if comunicacaoPLC, ok := values["Eclusa_Comunicação_PLC"]; ok {
-		if boolVal, isBool := comunicacaoPLC.(bool); isBool {
-			data["comunicacaoPLCValue"] = boolVal
-		} else {
-			data["comunicacaoPLCValue"] = false
-		}
-	}
-
-	if operacao, ok := values["Eclusa_Operação"]; ok {
-		if boolVal, isBool := operacao.(bool); isBool {
-			data["operacaoValue"] = boolVal
-		} else {
-			data["operacaoValue"] = false
-		}
-	}
-
-	// ✅ RADARES MONTANTE E JUSANTE (FALTAVAM)
-	if radarMontanteDistancia, ok := values["Eclusa_Radar_Montante_Distancia"]; ok {
-		if radarFloat, isFloat := radarMontanteDistancia.(float32); isFloat {
-			data["radarMontanteDistanciaValue"] = radarFloat
-		} else {
-			data["radarMontanteDistanciaValue"] = float32(0)
-		}
-	}
-
-	if radarMontanteVelocidade, ok := values["Eclusa_Radar_Montante_Velocidade"]; ok {
-		if radarFloat, isFloat := radarMontanteVelocidade.(float32); isFloat {
-			data["radarMontanteVelocidadeValue"] = radarFloat
-		} else {
-			data["radarMontanteVelocidadeValue"] = float32(0)
-		}
-	}
-
-	if radarJusanteDistancia, ok := values["Eclusa_Radar_Jusante_Distancia"]; ok {
-		if radarFloat, isFloat := radarJusanteDistancia.(float32); isFloat {
-			data["radarJusanteDistanciaValue"] = radarFloat
-		} else {
-			data["radarJusanteDistanciaValue"] = float32(0)
-		}
-	}
-
-	if radarJusanteVelocidade, ok := values["Eclusa_Radar_Jusante_Velocidade"]; ok {
-		if radarFloat, isFloat := radarJusanteVelocidade.(float32); isFloat {
-			data["radarJusanteVelocidadeValue"] = radarFloat
-		} else {
-			data["radarJusanteVelocidadeValue"] = float32(0)
-		}
-	}
-
-	// ✅ LASERS DA ECLUSA (FALTAVAM)
-	if laserMontante, ok := values["Eclusa_Laser_Montante"]; ok {
-		if laserFloat, isFloat := laserMontante.(float32); isFloat {
-			data["laserMontanteValue"] = laserFloat
-		} else {
-			data["laserMontanteValue"] = float32(0)
-		}
-	}
-
-	if laserJusante, ok := values["Eclusa_Laser_Jusante"]; ok {
-		if laserFloat, isFloat := laserJusante.(float32); isFloat {
-			data["laserJusanteValue"] = laserFloat
-		} else {
-			data["laserJusanteValue"] = float32(0)
-		}
-	}
-
-	// ✅ STATUS DA ECLUSA EXTRAS (FALTAVAM)
-	if alarmesAtivo, ok := values["Eclusa_Alarmes_Ativo"]; ok {
-		if boolVal, isBool := alarmesAtivo.(bool); isBool {
-			data["alarmesAtivoValue"] = boolVal
-		} else {
-			data["alarmesAtivoValue"] = false
-		}
-	}
-
-	if emergenciaAtiva, ok := values["Eclusa_Emergencia_Ativa"]; ok {
-		if boolVal, isBool := emergenciaAtiva.(bool); isBool {
-			data["emergenciaAtivaValue"] = boolVal
-		} else {
-			data["emergenciaAtivaValue"] = false
-		}
-	}
-
-	if inundacao, ok := values["Eclusa_Inundacao"]; ok {
-		if boolVal, isBool := inundacao.(bool); isBool {
-			data["inundacaoValue"] = boolVal
-		} else {
-			data["inundacaoValue"] = false
-		}
-	}
-
-	// ✅ PORTAS LEGADAS (PARA COMPATIBILIDADE COM COMPONENTES ANTIGOS)
-	if portaJusante, ok := values["Porta Jusante"]; ok {
-		if portaFloat, isFloat := portaJusante.(float32); isFloat {
-			limitedPorta := math.Max(0, math.Min(100, float64(portaFloat)))
-			data["nivelValue"] = float32(limitedPorta) // compat. antiga
-			data["motorValue"] = float32(limitedPorta) // compat. antiga
-		}
-	}
-
-	if portaMontante, ok := values["Porta Montante"]; ok {
-		if portaFloat, isFloat := portaMontante.(float32); isFloat {
-			limitedPorta := math.Max(0, math.Min(100, float64(portaFloat)))
-			data["portaMontanteValue"] = float32(limitedPorta)
-		}
-	}
-
-	// ✅ CONTRAPES OS PORTAS (FALTAVAM)
-	if contrapesoJusanteDireito, ok := values["PortaJusante_ContraPeso Direito"]; ok {
-		if contrapesoFloat, isFloat := contrapesoJusanteDireito.(float32); isFloat {
-			limitedContrapeso := math.Max(0, math.Min(100, float64(contrapesoFloat)))
-			data["contrapesoDirectoValue"] = float32(limitedContrapeso)
-		}
-	}
-
-	if contrapesoJusanteEsquerdo, ok := values["PortaJusante_ContraPeso Esquerdo"]; ok {
-		if contrapesoFloat, isFloat := contrapesoJusanteEsquerdo.(float32); isFloat {
-			limitedContrapeso := math.Max(0, math.Min(100, float64(contrapesoFloat)))
-			data["contrapesoEsquerdoValue"] = float32(limitedContrapeso)
-		}
-	}
-
-	if contrapesoMontanteDireito, ok := values["PortaMontante_ContraPesoDireito"]; ok {
-		if contrapesoFloat, isFloat := contrapesoMontanteDireito.(float32); isFloat {
-			limitedContrapeso := math.Max(0, math.Min(100, float64(contrapesoFloat)))
-			data["portaMontanteContrapesoDirectoValue"] = float32(limitedContrapeso)
-		}
-	}
-
-	if contrapesoMontanteEsquerdo, ok := values["PortaMontante_ContraPesoEsquerdo"]; ok {
-		if contrapesoFloat, isFloat := contrapesoMontanteEsquerdo.(float32); isFloat {
-			limitedContrapeso := math.Max(0, math.Min(100, float64(contrapesoFloat)))
-			data["portaMontanteContrapesoEsquerdoValue"] = float32(limitedContrapeso)
-		}
-	}
-
-	// ✅ MOTORES DAS PORTAS (FALTAVAM)
-	if motorJusanteDireito, ok := values["PortaJusante_MotorDireita"]; ok {
-		if motorInt, isInt := motorJusanteDireito.(int16); isInt {
-			limitedMotor := math.Max(0, math.Min(2, float64(motorInt)))
-			data["motorDireitoValue"] = int(limitedMotor)
-		}
-	}
-
-	if motorJusanteEsquerdo, ok := values["PortaJusante_MotorEsquerda"]; ok {
-		if motorInt, isInt := motorJusanteEsquerdo.(int16); isInt {
-			limitedMotor := math.Max(0, math.Min(2, float64(motorInt)))
-			data["motorEsquerdoValue"] = int(limitedMotor)
-		}
-	}
-
-	if motorMontanteDireito, ok := values["PortaMontante_MotorDireita"]; ok {
-		if motorInt, isInt := motorMontanteDireito.(int16); isInt {
-			limitedMotor := math.Max(0, math.Min(2, float64(motorInt)))
-			data["portaMontanteMotorDireitoValue"] = int(limitedMotor)
-		}
-	}
-
-	if motorMontanteEsquerdo, ok := values["PortaMontante_MotorEsquerda"]; ok {
-		if motorInt, isInt := motorMontanteEsquerdo.(int16); isInt {
-			limitedMotor := math.Max(0, math.Min(2, float64(motorInt)))
-			data["portaMontanteMotorEsquerdoValue"] = int(limitedMotor)
-		}
-	}
-
-	// ✅ COTAS CALCULADAS (baseadas nos níveis)
-	if nivelCaldeira, ok := values["Eclusa_Nivel_Caldeira"]; ok {
-		if nivelFloat, isFloat := nivelCaldeira.(float32); isFloat {
-			// Converte percentual (0-100) para cota em metros (assumindo faixa de 0-25m)
-			cotaCalculada := float64(nivelFloat) * 25.0 / 100.0
-			data["cotaCaldeiraValue"] = float32(cotaCalculada)
-		}
-	}
-
-	if nivelMontante, ok := values["Eclusa_Nivel_Montante"]; ok {
-		if nivelFloat, isFloat := nivelMontante.(float32); isFloat {
-			// Converte percentual para cota em metros
-			cotaCalculada := float64(nivelFloat) * 25.0 / 100.0
-			data["cotaMontanteValue"] = float32(cotaCalculada)
-		}
-	}
-
-	if nivelJusante, ok := values["Eclusa_Nivel_Jusante"]; ok {
-		if nivelFloat, isFloat := nivelJusante.(float32); isFloat {
-			// Converte percentual para cota em metros
-			cotaCalculada := float64(nivelFloat) * 25.0 / 100.0
-			data["cotaJusanteValue"] = float32(cotaCalculada)
-		}
-	}
-
-	// ✅ RADAR DISTÂNCIA LEGADO (compatibilidade)
-	if radarCaldeiraDistancia, ok := values["Eclusa_Radar_Caldeira_Distancia"]; ok {
-		if radarFloat, isFloat := radarCaldeiraDistancia.(float32); isFloat {
-			data["radarDistanciaValue"] = radarFloat // Para compatibilidade antiga
-		}
-	}
+	s7.mutex.RLock()
+	fields := s7.config.WebSocketFields
+	s7.mutex.RUnlock()
 
-	// SEMÁFOROS DA ECLUSA
-	semaforos := make(map[string]bool)
-	for i := 0; i <= 3; i++ {
-		tagNameVerde := fmt.Sprintf("Eclusa_Semaforo_verde_%d", i)
-		if value, ok := values[tagNameVerde]; ok {
-			if boolVal, isBool := value.(bool); isBool {
-				semaforos[tagNameVerde] = boolVal
-			} else {
-				semaforos[tagNameVerde] = false
-			}
-		} else {
-			semaforos[tagNameVerde] = false
-		}
+	applyWebSocketFields(fields, values, data)
 
-		tagNameVermelho := fmt.Sprintf("Eclusa_Semaforo_vermelho_%d", i)
-		if value, ok := values[tagNameVermelho]; ok {
-			if boolVal, isBool := value.(bool); isBool {
-				semaforos[tagNameVermelho] = boolVal
-			} else {
-				semaforos[tagNameVermelho] = false
-			}
-		} else {
-			semaforos[tagNameVermelho] = false
-		}
+	// Valores tipados de todos os tags (DINT, STRING, DTL...) pelo nome do tag
+	tags := make(map[string]interface{}, len(values))
+	for name, value := range values {
+		tags[name] = jsonSafeValue(value)
 	}
-	data["semaforos"] = semaforos
+	data["tags"] = tags
 
-	// PROCESSA PipeSystem array [0..23]
-	for i := 0; i < 24; i++ {
-		tagName := fmt.Sprintf("PipeSystem[%d]", i)
-		if value, ok := values[tagName]; ok {
-			if boolVal, isBool := value.(bool); isBool {
-				data[fmt.Sprintf("pipe_system_%d", i)] = boolVal
-			} else {
-				data[fmt.Sprintf("pipe_system_%d", i)] = false
-			}
-		} else {
-			data[fmt.Sprintf("pipe_system_%d", i)] = false
-		}
-	}
+	// Qualidade, hora da leitura e hora da última mudança de cada tag
+	data["quality"] = s7.qualityPayload()
 
-	// PROCESSA ValvulasOnOFF array [0..5]
-	for i := 0; i < 6; i++ {
-		tagName := fmt.Sprintf("ValvulasOnOFF[%d]", i)
-		if value, ok := values[tagName]; ok {
-			if intVal, isInt := value.(int16); isInt {
-				data[fmt.Sprintf("valvulas_onoff_%d", i)] = intVal
-			} else {
-				data[fmt.Sprintf("valvulas_onoff_%d", i)] = int16(0)
-			}
-		} else {
-			data[fmt.Sprintf("valvulas_onoff_%d", i)] = int16(0)
-		}
-	}
+	s7.mutex.RLock()
+	connected := s7.isConnected
+	lockName := s7.config.Name
+	s7.mutex.RUnlock()
 
+	data["lock_id"] = s7.lockID
+	data["lock_name"] = lockName
 	data["timestamp"] = time.Now().Unix()
-	data["connected"] = true
+	data["connected"] = connected
 
 	return data
 }
@@ -665,7 +602,9 @@ func (s7 *S7PLCConnector) SendCurrentValues(clientSend chan []byte) {
 	s7.currentMutex.RLock()
 	currentValues := make(map[string]interface{})
 	for k, v := range s7.currentValues {
-		currentValues[k] = v
+		if v.Value != nil {
+			currentValues[k] = v.Value
+		}
 	}
 	hasValues := len(currentValues) > 0 && s7.plcReadAtLeastOnce
 	s7.currentMutex.RUnlock()
@@ -673,10 +612,10 @@ func (s7 *S7PLCConnector) SendCurrentValues(clientSend chan []byte) {
 	var message map[string]interface{}
 
 	if hasValues {
-		log.Printf("📡 Enviando valores ATUAIS do S7 PLC para novo cliente")
+		log.Printf("📡 [%s] Enviando valores ATUAIS do S7 PLC para novo cliente", s7.lockID)
 		message = s7.buildWebSocketMessage(currentValues)
 	} else {
-		log.Printf("📡 S7 PLC ainda não foi lido - aguardando dados...")
+		log.Printf("📡 [%s] S7 PLC ainda não foi lido - aguardando dados...", s7.lockID)
 		message = s7.buildWebSocketMessage(map[string]interface{}{})
 	}
 
@@ -696,17 +635,111 @@ func (s7 *S7PLCConnector) GetStatus() map[string]interface{} {
 	defer s7.mutex.RUnlock()
 
 	return map[string]interface{}{
+		"id":            s7.lockID,
+		"name":          s7.config.Name,
 		"connected":     s7.isConnected,
 		"ip":            s7.config.PLCConfig.IP,
 		"db":            s7.config.PLCConfig.DBNumber,
 		"tags_count":    len(s7.config.Tags),
 		"read_at_least_once": s7.plcReadAtLeastOnce,
+		"read_blocks":   s7.readBlockCount(),
+		"scan_classes":  s7.scanClassStatus(),
+		"driver":        s7.driver.Name(),
+		"driver_status": s7.driver.Status(),
+		"stats":         s7.stats.snapshot(time.Now()),
+	}
+}
+
+// readBlockCount soma os blocos dos planos de leitura; chamado com mutex travado
+func (s7 *S7PLCConnector) readBlockCount() int {
+	count := 0
+	for _, blocks := range s7.readPlans {
+		count += len(blocks)
 	}
+	return count
+}
+
+// scanClassStatus resume intervalo, tags e blocos de cada classe; chamado com mutex travado
+func (s7 *S7PLCConnector) scanClassStatus() map[string]interface{} {
+	intervals := s7.config.scanClassIntervals()
+	status := make(map[string]interface{})
+
+	for class, tags := range s7.config.tagsByScanClass() {
+		status[class] = map[string]interface{}{
+			"interval_ms": intervals[class].Milliseconds(),
+			"tags":        len(tags),
+			"blocks":      len(s7.readPlans[class]),
+		}
+	}
+	return status
+}
+
+// Simulator retorna o driver simulado da eclusa, quando ela usa o simulador
+func (s7 *S7PLCConnector) Simulator() (*SimulatorDriver, bool) {
+	s7.mutex.RLock()
+	defer s7.mutex.RUnlock()
+
+	sim, ok := s7.driver.(*SimulatorDriver)
+	return sim, ok
+}
+
+// simulatorTag resolve tags da eclusa para os cenários do simulador
+func (s7 *S7PLCConnector) simulatorTag(name string) (PLCTag, PLCConfig, bool) {
+	s7.mutex.RLock()
+	defer s7.mutex.RUnlock()
+
+	tag, ok := s7.config.Tags[name]
+	return tag, s7.config.PLCConfig, ok
+}
+
+// SetSimulatedTag grava o valor de um tag direto na memória do simulador
+func (s7 *S7PLCConnector) SetSimulatedTag(name string, value interface{}) error {
+	sim, ok := s7.Simulator()
+	if !ok {
+		return fmt.Errorf("eclusa %s não usa o simulador", s7.lockID)
+	}
+
+	tag, config, ok := s7.simulatorTag(name)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrTagNotFound, name)
+	}
+	return sim.SetTagValue(tag, config, value)
+}
+
+// RunSimulatorScenario inicia um cenário no simulador da eclusa
+func (s7 *S7PLCConnector) RunSimulatorScenario(scenario SimulatorScenario) error {
+	sim, ok := s7.Simulator()
+	if !ok {
+		return fmt.Errorf("eclusa %s não usa o simulador", s7.lockID)
+	}
+	sim.RunScenario(scenario, s7.simulatorTag)
+	return nil
+}
+
+// startScenario carrega o cenário configurado em plc_config.scenario, se houver
+func (s7 *S7PLCConnector) startScenario() {
+	s7.mutex.RLock()
+	filename := s7.config.PLCConfig.Scenario
+	s7.mutex.RUnlock()
+
+	if _, ok := s7.Simulator(); !ok || filename == "" {
+		return
+	}
+
+	scenario, err := LoadSimulatorScenario(filename)
+	if err != nil {
+		log.Printf("⚠️ [%s] Erro ao carregar cenário %s: %v", s7.lockID, filename, err)
+		return
+	}
+	s7.RunSimulatorScenario(scenario)
 }
 
 // Stop para o conector S7
 func (s7 *S7PLCConnector) Stop() {
 	close(s7.stopChan)
-	s7.disconnect()
-	log.Printf("🛑 S7 PLC Connector parado")
+	if sim, ok := s7.Simulator(); ok {
+		sim.StopScenario()
+	}
+	s7.disconnect(nil)
+	log.Printf("🛑 [%s] S7 PLC Connector parado", s7.lockID)
 }
\ No newline at end of file
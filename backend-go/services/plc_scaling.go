@@ -0,0 +1,182 @@
+package services
+
+import (
+	"fmt"
+	"math"
+)
+
+// Políticas de limite em "clamp"
+const (
+	clampNone  = "none"  // padrão: o valor de engenharia pode sair da faixa
+	clampLimit = "limit" // satura o valor em eu_min..eu_max
+)
+
+// hasScaling indica se o valor lido passa por alguma conversão de engenharia
+func (t PLCTag) hasScaling() bool {
+	return (t.Scale != nil && *t.Scale != 0) || t.hasRawRange() || t.EUOffset != 0
+}
+
+// hasRawRange indica se o tag converte linearmente raw_min..raw_max em eu_min..eu_max
+func (t PLCTag) hasRawRange() bool {
+	return t.RawMin != nil && t.RawMax != nil && t.EUMin != nil && t.EUMax != nil
+}
+
+// clamps indica se o valor de engenharia é saturado na faixa eu_min..eu_max
+func (t PLCTag) clamps() bool {
+	return t.Clamp == clampLimit && t.EUMin != nil && t.EUMax != nil
+}
+
+// euRange retorna a faixa de engenharia em ordem crescente
+// (eu_min > eu_max inverte a escala, ex. régua medida de cima para baixo)
+func (t PLCTag) euRange() (float64, float64) {
+	return math.Min(*t.EUMin, *t.EUMax), math.Max(*t.EUMin, *t.EUMax)
+}
+
+// validateScaling verifica faixas, offset e política de limite do tag
+func validateScaling(tag PLCTag, info tagTypeInfo) error {
+	if (tag.RawMin == nil) != (tag.RawMax == nil) {
+		return fmt.Errorf("raw_min e raw_max devem ser informados juntos")
+	}
+	if (tag.EUMin == nil) != (tag.EUMax == nil) {
+		return fmt.Errorf("eu_min e eu_max devem ser informados juntos")
+	}
+	if tag.RawMin != nil {
+		if tag.EUMin == nil {
+			return fmt.Errorf("raw_min/raw_max exigem eu_min/eu_max")
+		}
+		if *tag.RawMin == *tag.RawMax || *tag.EUMin == *tag.EUMax {
+			return fmt.Errorf("faixas bruta e de engenharia não podem ter mínimo igual ao máximo")
+		}
+	}
+
+	switch tag.Clamp {
+	case "", clampNone:
+	case clampLimit:
+		if tag.EUMin == nil {
+			return fmt.Errorf("clamp %s exige eu_min/eu_max", clampLimit)
+		}
+	default:
+		return fmt.Errorf("clamp inválido: %s (use %s ou %s)", tag.Clamp, clampNone, clampLimit)
+	}
+
+	usesNumbers := tag.hasScaling() || tag.EUMin != nil
+	if usesNumbers && !info.isNumeric() {
+		return fmt.Errorf("escala de engenharia só vale para tags numéricos (tipo %s)", tag.Type)
+	}
+	return nil
+}
+
+// scaleTagValue converte o valor bruto lido no valor de engenharia:
+// multiplicador "scale", faixa raw_min..raw_max → eu_min..eu_max, "eu_offset" e, por fim, o clamp.
+// Valores não numéricos e tags sem escala voltam como estão; com só o clamp o tipo lido é mantido.
+func scaleTagValue(tag PLCTag, value interface{}) interface{} {
+	if !tag.hasScaling() {
+		if tag.clamps() {
+			return clampKeepingType(tag, value)
+		}
+		return value
+	}
+	number, ok := toFloat64(value)
+	if !ok {
+		return value
+	}
+
+	if tag.Scale != nil && *tag.Scale != 0 {
+		number *= *tag.Scale
+	}
+	if tag.hasRawRange() {
+		number = *tag.EUMin + (number-*tag.RawMin)*(*tag.EUMax-*tag.EUMin)/(*tag.RawMax-*tag.RawMin)
+	}
+	number += tag.EUOffset
+
+	if tag.clamps() {
+		low, high := tag.euRange()
+		number = math.Max(low, math.Min(high, number))
+	}
+	return number
+}
+
+// clampKeepingType satura o valor em eu_min..eu_max sem convertê-lo em float64, para que
+// tags inteiros (portas, motores) continuem inteiros no JSON e nas transformações do ws_fields
+func clampKeepingType(tag PLCTag, value interface{}) interface{} {
+	number, ok := toFloat64(value)
+	if !ok {
+		return value
+	}
+	low, high := tag.euRange()
+	switch {
+	case number < low:
+		number = low
+	case number > high:
+		number = high
+	default:
+		return value
+	}
+
+	// Inteiros saturam no inteiro mais próximo dentro da faixa
+	switch value.(type) {
+	case float32:
+		return float32(number)
+	case float64:
+		return number
+	}
+	if number == low {
+		number = math.Ceil(number)
+	} else {
+		number = math.Floor(number)
+	}
+	switch value.(type) {
+	case uint8:
+		return uint8(number)
+	case int8:
+		return int8(number)
+	case uint16:
+		return uint16(number)
+	case int16:
+		return int16(number)
+	case uint32:
+		return uint32(number)
+	case int32:
+		return int32(number)
+	case int:
+		return int(number)
+	}
+	return number
+}
+
+// unscaleTagValue converte o valor de escrita (em engenharia) no valor bruto do PLC.
+// Os limites valem para o valor de engenharia e por isso são verificados aqui;
+// com clamp, valores fora de eu_min..eu_max são recusados.
+func unscaleTagValue(tag PLCTag, info tagTypeInfo, value interface{}, limits TagLimits) (interface{}, TagLimits, error) {
+	if !tag.hasScaling() && !tag.clamps() {
+		return value, limits, nil
+	}
+
+	number, ok := value.(float64)
+	if !ok || math.IsNaN(number) || math.IsInf(number, 0) {
+		return nil, limits, fmt.Errorf("%w: esperado número", ErrInvalidTagValue)
+	}
+	if limits.MinValue != nil && number < *limits.MinValue {
+		return nil, limits, fmt.Errorf("%w: %v abaixo do mínimo %v", ErrInvalidTagValue, number, *limits.MinValue)
+	}
+	if limits.MaxValue != nil && number > *limits.MaxValue {
+		return nil, limits, fmt.Errorf("%w: %v acima do máximo %v", ErrInvalidTagValue, number, *limits.MaxValue)
+	}
+	if tag.clamps() {
+		if low, high := tag.euRange(); number < low || number > high {
+			return nil, limits, fmt.Errorf("%w: %v fora da faixa %v..%v", ErrInvalidTagValue, number, low, high)
+		}
+	}
+
+	raw := number - tag.EUOffset
+	if tag.hasRawRange() {
+		raw = *tag.RawMin + (raw-*tag.EUMin)*(*tag.RawMax-*tag.RawMin)/(*tag.EUMax-*tag.EUMin)
+	}
+	if tag.Scale != nil && *tag.Scale != 0 {
+		raw /= *tag.Scale
+	}
+	if info.Base != "real" && info.Base != "lreal" {
+		raw = math.Round(raw)
+	}
+	return raw, TagLimits{}, nil
+}
@@ -0,0 +1,32 @@
+package services
+
+import "testing"
+
+func TestScaleTagValue(t *testing.T) {
+	low, high := 0.0, 100.0
+	rawLow, rawHigh := 0.0, 27648.0
+	clampOnly := PLCTag{Type: "int", EUMin: &low, EUMax: &high, Clamp: clampLimit}
+	scaled := PLCTag{Type: "int", RawMin: &rawLow, RawMax: &rawHigh, EUMin: &low, EUMax: &high, Clamp: clampLimit}
+
+	tests := []struct {
+		name  string
+		tag   PLCTag
+		value interface{}
+		want  interface{}
+	}{
+		{"só clamp mantém o inteiro", clampOnly, int16(42), int16(42)},
+		{"só clamp satura acima", clampOnly, int16(130), int16(100)},
+		{"só clamp satura abaixo", clampOnly, int16(-5), int16(0)},
+		{"só clamp em real", clampOnly, float32(100.5), float32(100)},
+		{"faixa bruta vira float64", scaled, int16(13824), 50.0},
+		{"faixa bruta com clamp", scaled, int16(30000), 100.0},
+		{"sem escala", PLCTag{Type: "int"}, int16(7), int16(7)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scaleTagValue(tt.tag, tt.value); got != tt.want {
+				t.Errorf("obtido %#v, esperado %#v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Classes de varredura padrão (intervalo em ms); scan_classes no tags.json
+// pode alterar esses intervalos ou criar classes novas
+var builtinScanClasses = map[string]int{
+	"fast":   50,
+	"normal": 500,
+	"slow":   5000,
+}
+
+// Classe usada pelos tags sem scan_class (nem no tag nem no grupo)
+const defaultScanClass = "fast"
+
+// Menor intervalo aceito, para não saturar o PLC
+const minScanIntervalMs = 10
+
+// scanClassIntervals junta as classes padrão com as definidas na conexão
+func (c PLCConnection) scanClassIntervals() map[string]time.Duration {
+	intervals := make(map[string]time.Duration, len(builtinScanClasses)+len(c.ScanClasses))
+	for name, ms := range builtinScanClasses {
+		intervals[name] = time.Duration(ms) * time.Millisecond
+	}
+	for name, ms := range c.ScanClasses {
+		intervals[name] = time.Duration(ms) * time.Millisecond
+	}
+	return intervals
+}
+
+// defaultClass retorna a classe dos tags sem scan_class
+func (c PLCConnection) defaultClass() string {
+	if c.DefaultScanClass != "" {
+		return c.DefaultScanClass
+	}
+	return defaultScanClass
+}
+
+// tagScanClass retorna a classe de varredura efetiva de um tag; tags sem scan_class
+// usam a padrão. Classes desconhecidas são recusadas antes, em validateTagScanClass.
+func (c PLCConnection) tagScanClass(tag PLCTag) string {
+	if tag.ScanClass != "" {
+		if _, ok := c.scanClassIntervals()[tag.ScanClass]; ok {
+			return tag.ScanClass
+		}
+	}
+	return c.defaultClass()
+}
+
+// validateScanClasses verifica os intervalos e a classe padrão da conexão
+func (c PLCConnection) validateScanClasses() error {
+	for name, ms := range c.ScanClasses {
+		if name == "" {
+			return fmt.Errorf("scan_classes com nome vazio")
+		}
+		if ms < minScanIntervalMs {
+			return fmt.Errorf("scan_classes.%s: intervalo mínimo é %d ms", name, minScanIntervalMs)
+		}
+	}
+	if _, ok := c.scanClassIntervals()[c.defaultClass()]; !ok {
+		return fmt.Errorf("default_scan_class desconhecida: %s", c.defaultClass())
+	}
+	return nil
+}
+
+// validateTagScanClass verifica se a classe do tag existe na conexão
+func (c PLCConnection) validateTagScanClass(tag PLCTag) error {
+	if tag.ScanClass == "" {
+		return nil
+	}
+	if _, ok := c.scanClassIntervals()[tag.ScanClass]; !ok {
+		return fmt.Errorf("scan_class desconhecida: %s (classes: %v)", tag.ScanClass, c.scanClassNames())
+	}
+	return nil
+}
+
+// scanClassNames lista as classes da conexão em ordem alfabética
+func (c PLCConnection) scanClassNames() []string {
+	names := make([]string, 0)
+	for name := range c.scanClassIntervals() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// tagsByScanClass separa os tags da conexão pela classe de varredura
+func (c PLCConnection) tagsByScanClass() map[string]map[string]PLCTag {
+	classes := make(map[string]map[string]PLCTag)
+	for name, tag := range c.Tags {
+		class := c.tagScanClass(tag)
+		if classes[class] == nil {
+			classes[class] = make(map[string]PLCTag)
+		}
+		classes[class][name] = tag
+	}
+	return classes
+}
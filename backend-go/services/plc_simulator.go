@@ -0,0 +1,367 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Tamanho máximo de cada área simulada (evita crescer sem limite por engano)
+const simulatorMaxAreaSize = 65536
+
+// Menor duração de uma volta de um cenário em loop (soma dos delay_ms), para que
+// um roteiro sem esperas não ocupe a CPU reaplicando os passos sem parar
+const simulatorMinLoopMs = 100
+
+// SimulatorDriver é um PLC em memória: leituras e escritas vão para buffers
+// por área, que também podem ser alterados pela API ou por um cenário.
+type SimulatorDriver struct {
+	lockID string
+	memory map[string][]byte // chave: área (e número do DB)
+	online bool
+	mutex  sync.RWMutex
+
+	// Cenário em execução (nil quando parado)
+	scenarioName string
+	scenarioStop chan struct{}
+}
+
+// SimulatorScenario é um roteiro de valores aplicado passo a passo
+type SimulatorScenario struct {
+	Name  string         `json:"name"`
+	Loop  bool           `json:"loop"`
+	Steps []ScenarioStep `json:"steps"`
+}
+
+// ScenarioStep espera delay_ms e então aplica tags, bytes e estado de comunicação
+type ScenarioStep struct {
+	DelayMs int                    `json:"delay_ms"`
+	Tags    map[string]interface{} `json:"tags,omitempty"`
+	Memory  []SimulatorMemoryWrite `json:"memory,omitempty"`
+	Online  *bool                  `json:"online,omitempty"` // false simula perda de comunicação
+}
+
+// SimulatorMemoryWrite grava bytes crus numa área simulada
+type SimulatorMemoryWrite struct {
+	Area     string `json:"area"`
+	DBNumber int    `json:"db_number"`
+	Start    int    `json:"start"` // byte inicial
+	Data     []int  `json:"data"`
+}
+
+// scenarioTagLookup resolve um tag da eclusa e o plc_config (DB padrão) para o cenário
+type scenarioTagLookup func(name string) (PLCTag, PLCConfig, bool)
+
+func newSimulatorDriver(lockID string) *SimulatorDriver {
+	return &SimulatorDriver{
+		lockID: lockID,
+		memory: make(map[string][]byte),
+		online: true,
+	}
+}
+
+func simulatorAreaKey(area string, dbNumber int) string {
+	if area == areaDB {
+		return fmt.Sprintf("DB%d", dbNumber)
+	}
+	return area
+}
+
+func (d *SimulatorDriver) Name() string {
+	return driverSimulator
+}
+
+func (d *SimulatorDriver) Connect() error {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	if !d.online {
+		return fmt.Errorf("simulador offline")
+	}
+	return nil
+}
+
+func (d *SimulatorDriver) Close() error {
+	return nil
+}
+
+func (d *SimulatorDriver) PDULength() int {
+	return s7DefaultPDULength
+}
+
+func (d *SimulatorDriver) ReadArea(area string, dbNumber int, start int, size int, buffer []byte) error {
+	unit := tagAddress{Area: area}.unitSize()
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if !d.online {
+		return fmt.Errorf("simulador offline")
+	}
+
+	data, err := d.areaBytes(area, dbNumber, start*unit, size*unit)
+	if err != nil {
+		return err
+	}
+	copy(buffer, data)
+	return nil
+}
+
+func (d *SimulatorDriver) WriteArea(area string, dbNumber int, start int, size int, buffer []byte) error {
+	if area == areaInputs || area == areaInputRegisters || area == areaDiscreteInputs {
+		return fmt.Errorf("área de memória %s não aceita escrita", area)
+	}
+	unit := tagAddress{Area: area}.unitSize()
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if !d.online {
+		return fmt.Errorf("simulador offline")
+	}
+
+	data, err := d.areaBytes(area, dbNumber, start*unit, size*unit)
+	if err != nil {
+		return err
+	}
+	copy(data, buffer)
+	return nil
+}
+
+func (d *SimulatorDriver) Status() map[string]interface{} {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	areas := make(map[string]int, len(d.memory))
+	for key, data := range d.memory {
+		areas[key] = len(data)
+	}
+
+	return map[string]interface{}{
+		"online":   d.online,
+		"areas":    areas,
+		"scenario": d.scenarioName,
+	}
+}
+
+// areaBytes retorna a fatia [start, start+size) da área, crescendo-a com zeros se preciso.
+// Deve ser chamado com o mutex travado.
+func (d *SimulatorDriver) areaBytes(area string, dbNumber int, start int, size int) ([]byte, error) {
+	switch area {
+	case areaDB, areaInputs, areaOutputs, areaMerkers, areaTimers, areaCounter,
+		areaHoldingRegisters, areaInputRegisters, areaCoils, areaDiscreteInputs:
+	default:
+		return nil, fmt.Errorf("área de memória desconhecida: %s", area)
+	}
+	if start < 0 || size < 0 || start+size > simulatorMaxAreaSize {
+		return nil, fmt.Errorf("faixa fora da área simulada: %d+%d", start, size)
+	}
+
+	key := simulatorAreaKey(area, dbNumber)
+	data := d.memory[key]
+	if len(data) < start+size {
+		grown := make([]byte, start+size)
+		copy(grown, data)
+		data = grown
+		d.memory[key] = data
+	}
+	return data[start : start+size], nil
+}
+
+// Memory retorna uma cópia dos bytes de uma área simulada
+func (d *SimulatorDriver) Memory(area string, dbNumber int, start int, size int) ([]byte, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	data, err := d.areaBytes(area, dbNumber, start, size)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// SetMemory grava bytes crus numa área simulada (inclusive entradas)
+func (d *SimulatorDriver) SetMemory(area string, dbNumber int, start int, data []byte) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	target, err := d.areaBytes(area, dbNumber, start, len(data))
+	if err != nil {
+		return err
+	}
+	copy(target, data)
+	return nil
+}
+
+// SetTagValue codifica o valor no formato do tag e grava na memória simulada,
+// sem aplicar os limites de escrita (simula o que o PLC teria na memória)
+func (d *SimulatorDriver) SetTagValue(tag PLCTag, config PLCConfig, value interface{}) error {
+	info, addr, err := resolveTag(tag, config)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTagValue, err)
+	}
+
+	value, _, err = unscaleTagValue(tag, info, value, TagLimits{})
+	if err != nil {
+		return err
+	}
+
+	buffer, err := encodeTagValue(info, addr, value, TagLimits{})
+	if err != nil {
+		return err
+	}
+
+	unit := addr.unitSize()
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if info.Base == "bool" {
+		byteOffset, bit := addr.bitLocation()
+		target, err := d.areaBytes(addr.Area, addr.DBNumber, addr.Byte*unit+byteOffset, 1)
+		if err != nil {
+			return err
+		}
+		if buffer[0] != 0 {
+			target[0] |= 1 << bit
+		} else {
+			target[0] &^= 1 << bit
+		}
+		return nil
+	}
+
+	target, err := d.areaBytes(addr.Area, addr.DBNumber, addr.Byte*unit, len(buffer))
+	if err != nil {
+		return err
+	}
+
+	copy(target, buffer)
+	return nil
+}
+
+// SetOnline liga/desliga a comunicação simulada
+func (d *SimulatorDriver) SetOnline(online bool) {
+	d.mutex.Lock()
+	d.online = online
+	d.mutex.Unlock()
+}
+
+// LoadSimulatorScenario lê um arquivo de cenário JSON
+func LoadSimulatorScenario(filename string) (SimulatorScenario, error) {
+	var scenario SimulatorScenario
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return scenario, err
+	}
+	if err := json.Unmarshal(data, &scenario); err != nil {
+		return scenario, err
+	}
+	if err := scenario.Validate(); err != nil {
+		return scenario, err
+	}
+	if scenario.Name == "" {
+		scenario.Name = filename
+	}
+	return scenario, nil
+}
+
+// Validate verifica os passos e a duração de cada volta dos cenários em loop
+func (s SimulatorScenario) Validate() error {
+	if len(s.Steps) == 0 {
+		return fmt.Errorf("cenário sem passos")
+	}
+	total := 0
+	for i, step := range s.Steps {
+		if step.DelayMs < 0 {
+			return fmt.Errorf("passo %d: delay_ms negativo", i)
+		}
+		total += step.DelayMs
+	}
+	if s.Loop && total < simulatorMinLoopMs {
+		return fmt.Errorf("cenário em loop precisa somar ao menos %d ms de delay_ms por volta (soma: %d ms)", simulatorMinLoopMs, total)
+	}
+	return nil
+}
+
+// RunScenario executa o cenário em segundo plano, substituindo o que estiver em execução
+func (d *SimulatorDriver) RunScenario(scenario SimulatorScenario, lookup scenarioTagLookup) {
+	d.StopScenario()
+
+	stop := make(chan struct{})
+	d.mutex.Lock()
+	d.scenarioName = scenario.Name
+	d.scenarioStop = stop
+	d.mutex.Unlock()
+
+	log.Printf("🎬 [%s] Cenário de simulação iniciado: %s (%d passos)", d.lockID, scenario.Name, len(scenario.Steps))
+
+	go func() {
+		defer func() {
+			d.mutex.Lock()
+			if d.scenarioStop == stop {
+				d.scenarioName = ""
+				d.scenarioStop = nil
+			}
+			d.mutex.Unlock()
+		}()
+
+		for {
+			for i, step := range scenario.Steps {
+				select {
+				case <-stop:
+					return
+				case <-time.After(time.Duration(step.DelayMs) * time.Millisecond):
+				}
+				d.applyStep(i, step, lookup)
+			}
+
+			if !scenario.Loop {
+				log.Printf("🎬 [%s] Cenário de simulação concluído: %s", d.lockID, scenario.Name)
+				return
+			}
+		}
+	}()
+}
+
+// StopScenario interrompe o cenário em execução, se houver
+func (d *SimulatorDriver) StopScenario() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.scenarioStop != nil {
+		close(d.scenarioStop)
+		d.scenarioStop = nil
+		d.scenarioName = ""
+	}
+}
+
+func (d *SimulatorDriver) applyStep(index int, step ScenarioStep, lookup scenarioTagLookup) {
+	for _, write := range step.Memory {
+		data := make([]byte, len(write.Data))
+		for i, b := range write.Data {
+			data[i] = byte(b)
+		}
+		if err := d.SetMemory(write.Area, write.DBNumber, write.Start, data); err != nil {
+			log.Printf("⚠️ [%s] Cenário, passo %d: %v", d.lockID, index, err)
+		}
+	}
+
+	for name, value := range step.Tags {
+		tag, config, ok := lookup(name)
+		if !ok {
+			log.Printf("⚠️ [%s] Cenário, passo %d: tag %s não encontrado", d.lockID, index, name)
+			continue
+		}
+		if err := d.SetTagValue(tag, config, value); err != nil {
+			log.Printf("⚠️ [%s] Cenário, passo %d, tag %s: %v", d.lockID, index, name, err)
+		}
+	}
+
+	if step.Online != nil {
+		d.SetOnline(*step.Online)
+	}
+}
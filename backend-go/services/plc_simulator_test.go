@@ -0,0 +1,129 @@
+package services
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestSimulatorScenarioValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		scenario SimulatorScenario
+		wantErr  bool
+	}{
+		{"sem passos", SimulatorScenario{Loop: true}, true},
+		{"sem loop e sem esperas", SimulatorScenario{Steps: []ScenarioStep{{}, {}}}, false},
+		{"loop sem esperas", SimulatorScenario{Loop: true, Steps: []ScenarioStep{{}, {}}}, true},
+		{"loop abaixo do mínimo", SimulatorScenario{Loop: true, Steps: []ScenarioStep{{DelayMs: 50}, {DelayMs: 49}}}, true},
+		{"loop no mínimo", SimulatorScenario{Loop: true, Steps: []ScenarioStep{{DelayMs: 0}, {DelayMs: simulatorMinLoopMs}}}, false},
+		{"delay negativo", SimulatorScenario{Steps: []ScenarioStep{{DelayMs: -1}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.scenario.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("erro %v, esperado erro = %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestSimulatorScan lê os tags pelo conector com o driver simulado e confere a
+// mensagem enviada aos clientes
+func TestSimulatorScan(t *testing.T) {
+	hub := &WebSocketHub{broadcast: make(chan []byte, 4)}
+	conn := PLCConnection{
+		ID:        "sim",
+		Name:      "Eclusa Simulada",
+		PLCConfig: PLCConfig{Driver: driverSimulator, DBNumber: 19},
+		Tags: map[string]PLCTag{
+			"nivel":    {Type: "real", Offset: 0},
+			"contador": {Type: "dint", Offset: 4},
+			"porta":    {Type: "bool", Offset: 8.1},
+			"vazao":    {Type: "real", Offset: 10},
+		},
+		WebSocketFields: []WebSocketField{
+			{Key: "nivelCaldeiraValue", Tag: "nivel", Transform: transformFloat32},
+			{Key: "porta", Tag: "porta", Transform: transformBool, Group: "semaforos"},
+		},
+	}
+	s7 := newS7PLCConnector(conn, hub)
+	if err := s7.connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, value := range map[string]interface{}{"nivel": 12.5, "contador": -7.0, "porta": true} {
+		if err := s7.SetSimulatedTag(name, value); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	sim, _ := s7.Simulator()
+	nan := make([]byte, 4)
+	binary.BigEndian.PutUint32(nan, math.Float32bits(float32(math.NaN())))
+	if err := sim.SetMemory(areaDB, 19, 10, nan); err != nil {
+		t.Fatal(err)
+	}
+
+	s7.readScanClass(defaultScanClass)
+	message := receiveBroadcast(t, hub)
+
+	tags := message["tags"].(map[string]interface{})
+	want := map[string]interface{}{"nivel": 12.5, "contador": -7.0, "porta": true, "vazao": nil}
+	for name, value := range want {
+		if got, ok := tags[name]; !ok || got != value {
+			t.Errorf("tags.%s = %#v, esperado %#v", name, got, value)
+		}
+	}
+	if message["nivelCaldeiraValue"] != 12.5 {
+		t.Errorf("nivelCaldeiraValue = %#v", message["nivelCaldeiraValue"])
+	}
+	if semaforos, _ := message["semaforos"].(map[string]interface{}); semaforos["porta"] != true {
+		t.Errorf("semaforos = %#v", message["semaforos"])
+	}
+	if message["lock_id"] != "sim" || message["lock_name"] != "Eclusa Simulada" || message["connected"] != true {
+		t.Errorf("cabeçalho da mensagem: %v %v %v", message["lock_id"], message["lock_name"], message["connected"])
+	}
+	quality := message["quality"].(map[string]interface{})
+	if got := quality["nivel"].(map[string]interface{})["quality"]; got != QualityGood {
+		t.Errorf("qualidade do nivel = %v", got)
+	}
+	if got := quality["vazao"].(map[string]interface{})["quality"]; got != QualityUncertain {
+		t.Errorf("qualidade da vazao (NaN) = %v", got)
+	}
+
+	// Sem mudança, nada é publicado
+	s7.readScanClass(defaultScanClass)
+	select {
+	case data := <-hub.broadcast:
+		t.Errorf("broadcast sem mudança: %s", data)
+	default:
+	}
+
+	// Perda de comunicação: os últimos valores seguem com qualidade bad-comm
+	sim.SetOnline(false)
+	s7.readScanClass(defaultScanClass)
+	message = receiveBroadcast(t, hub)
+	if got := message["tags"].(map[string]interface{})["nivel"]; got != 12.5 {
+		t.Errorf("nivel após perda de comunicação = %#v", got)
+	}
+	if got := message["quality"].(map[string]interface{})["nivel"].(map[string]interface{})["quality"]; got != QualityBadComm {
+		t.Errorf("qualidade após perda de comunicação = %v", got)
+	}
+}
+
+// receiveBroadcast lê a mensagem enviada ao hub
+func receiveBroadcast(t *testing.T, hub *WebSocketHub) map[string]interface{} {
+	t.Helper()
+	select {
+	case data := <-hub.broadcast:
+		var message map[string]interface{}
+		if err := json.Unmarshal(data, &message); err != nil {
+			t.Fatal(err)
+		}
+		return message
+	default:
+		t.Fatal("nenhum broadcast")
+	}
+	return nil
+}
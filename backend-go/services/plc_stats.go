@@ -0,0 +1,274 @@
+package services
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Reconexão com espera exponencial: 1 s, 2 s, 4 s... até 60 s, com jitter
+// para que várias eclusas não batam no mesmo switch/PLC ao mesmo tempo
+const (
+	reconnectMinDelay = time.Second
+	reconnectMaxDelay = 60 * time.Second
+
+	// Intervalo de verificação da conexão enquanto conectado
+	connectionCheckInterval = time.Second
+
+	// Intervalo de envio das estatísticas pelo WebSocket ("type": "plc_stats")
+	statsBroadcastInterval = 5 * time.Second
+
+	// Leituras guardadas por classe para os percentis de latência
+	latencyWindowSize = 512
+)
+
+// reconnectBackoff calcula a espera entre tentativas de conexão
+type reconnectBackoff struct {
+	min     time.Duration
+	max     time.Duration
+	attempt int
+}
+
+// next retorna a próxima espera: o dobro da anterior (até max), sorteada entre a metade e o total
+func (b *reconnectBackoff) next() time.Duration {
+	delay := b.max
+	if b.attempt < 16 {
+		if d := b.min << uint(b.attempt); d < b.max {
+			delay = d
+		}
+	}
+	b.attempt++
+
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// reset volta à espera mínima (após conectar)
+func (b *reconnectBackoff) reset() {
+	b.attempt = 0
+}
+
+// latencyWindow guarda as últimas durações de leitura de uma classe
+type latencyWindow struct {
+	samples []time.Duration
+	next    int
+	count   int64
+}
+
+func (w *latencyWindow) add(d time.Duration) {
+	if len(w.samples) < latencyWindowSize {
+		w.samples = append(w.samples, d)
+	} else {
+		w.samples[w.next] = d
+		w.next = (w.next + 1) % latencyWindowSize
+	}
+	w.count++
+}
+
+// percentiles retorna p50/p90/p99/max em ms das leituras da janela
+func (w *latencyWindow) percentiles() map[string]interface{} {
+	sorted := make([]time.Duration, len(w.samples))
+	copy(sorted, w.samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	at := func(p float64) float64 {
+		index := int(p * float64(len(sorted)-1))
+		return float64(sorted[index]) / float64(time.Millisecond)
+	}
+
+	return map[string]interface{}{
+		"scans":   w.count,
+		"samples": len(sorted),
+		"p50_ms":  at(0.50),
+		"p90_ms":  at(0.90),
+		"p99_ms":  at(0.99),
+		"max_ms":  at(1),
+	}
+}
+
+// connectionStats acumula a saúde da comunicação com o PLC de uma eclusa
+type connectionStats struct {
+	mutex sync.Mutex
+
+	connectAttempts int64
+	connectFailures int64
+	disconnects     int64
+	readErrors      int64
+	lastError       string
+	lastErrorAt     time.Time
+	connectedSince  time.Time
+	lastGoodRead    time.Time
+	nextRetry       time.Time
+
+	latency   map[string]*latencyWindow // por classe de varredura
+	tagErrors map[string]int64          // erros de leitura/decodificação por tag
+}
+
+func newConnectionStats() *connectionStats {
+	return &connectionStats{
+		latency:   make(map[string]*latencyWindow),
+		tagErrors: make(map[string]int64),
+	}
+}
+
+// recordConnect registra uma tentativa de conexão
+func (s *connectionStats) recordConnect(err error, now time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.connectAttempts++
+	if err != nil {
+		s.connectFailures++
+		s.lastError = err.Error()
+		s.lastErrorAt = now
+		return
+	}
+	s.connectedSince = now
+	s.nextRetry = time.Time{}
+}
+
+// recordRetry registra quando será a próxima tentativa de conexão
+func (s *connectionStats) recordRetry(at time.Time) {
+	s.mutex.Lock()
+	s.nextRetry = at
+	s.mutex.Unlock()
+}
+
+// recordDisconnect registra a perda da conexão (err nil: desconexão pedida)
+func (s *connectionStats) recordDisconnect(err error, now time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.connectedSince.IsZero() {
+		s.disconnects++
+	}
+	s.connectedSince = time.Time{}
+	if err != nil {
+		s.lastError = err.Error()
+		s.lastErrorAt = now
+	}
+}
+
+// recordScan registra a duração de uma varredura completa e bem-sucedida da classe
+func (s *connectionStats) recordScan(class string, duration time.Duration, now time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	window, ok := s.latency[class]
+	if !ok {
+		window = &latencyWindow{}
+		s.latency[class] = window
+	}
+	window.add(duration)
+	s.lastGoodRead = now
+}
+
+// recordReadError registra uma leitura que falhou e os tags afetados
+func (s *connectionStats) recordReadError(err error, tags []string, now time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.readErrors++
+	s.lastError = err.Error()
+	s.lastErrorAt = now
+	for _, name := range tags {
+		s.tagErrors[name]++
+	}
+}
+
+// pruneTags esquece os contadores de tags que saíram da configuração
+func (s *connectionStats) pruneTags(tags map[string]PLCTag) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for name := range s.tagErrors {
+		if _, ok := tags[name]; !ok {
+			delete(s.tagErrors, name)
+		}
+	}
+}
+
+// snapshot monta as estatísticas para /api/plc/status e o WebSocket
+func (s *connectionStats) snapshot(now time.Time) map[string]interface{} {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stats := map[string]interface{}{
+		"connect_attempts": s.connectAttempts,
+		"connect_failures": s.connectFailures,
+		"disconnects":      s.disconnects,
+		"read_errors":      s.readErrors,
+		"last_error":       s.lastError,
+		"uptime_seconds":   0.0,
+	}
+	if !s.lastErrorAt.IsZero() {
+		stats["last_error_at"] = s.lastErrorAt.Unix()
+	}
+	if !s.connectedSince.IsZero() {
+		stats["connected_since"] = s.connectedSince.Unix()
+		stats["uptime_seconds"] = now.Sub(s.connectedSince).Seconds()
+	}
+	if !s.lastGoodRead.IsZero() {
+		stats["last_good_read"] = s.lastGoodRead.UnixMilli()
+		stats["seconds_since_last_good_read"] = now.Sub(s.lastGoodRead).Seconds()
+	}
+	if !s.nextRetry.IsZero() {
+		stats["next_retry"] = s.nextRetry.Unix()
+	}
+
+	latency := make(map[string]interface{}, len(s.latency))
+	for class, window := range s.latency {
+		latency[class] = window.percentiles()
+	}
+	stats["read_latency"] = latency
+
+	tagErrors := make(map[string]int64, len(s.tagErrors))
+	for name, count := range s.tagErrors {
+		tagErrors[name] = count
+	}
+	stats["tag_errors"] = tagErrors
+
+	return stats
+}
+
+// Stats retorna as estatísticas de comunicação da eclusa
+func (s7 *S7PLCConnector) Stats() map[string]interface{} {
+	stats := s7.stats.snapshot(time.Now())
+
+	s7.mutex.RLock()
+	stats["connected"] = s7.isConnected
+	s7.mutex.RUnlock()
+
+	return stats
+}
+
+// notifyStats pede o envio imediato das estatísticas (conexão/desconexão)
+func (s7 *S7PLCConnector) notifyStats() {
+	select {
+	case s7.statsChanged <- struct{}{}:
+	default:
+	}
+}
+
+// statsLoop envia as estatísticas aos clientes periodicamente e a cada mudança de conexão
+func (s7 *S7PLCConnector) statsLoop() {
+	ticker := time.NewTicker(statsBroadcastInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s7.stopChan:
+			return
+		case <-ticker.C:
+		case <-s7.statsChanged:
+		}
+
+		s7.hub.BroadcastMessage(map[string]interface{}{
+			"type":      "plc_stats",
+			"lock_id":   s7.lockID,
+			"timestamp": time.Now().Unix(),
+			"stats":     s7.Stats(),
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+)
+
+var (
+	// ErrTagNotFound indica que o tag não existe na configuração da eclusa
+	ErrTagNotFound = errors.New("tag não encontrado")
+	// ErrInvalidTagValue indica valor incompatível com o tipo ou limites do tag
+	ErrInvalidTagValue = errors.New("valor inválido para o tag")
+	// ErrPLCNotConnected indica que o PLC não está conectado
+	ErrPLCNotConnected = errors.New("S7 PLC não conectado")
+)
+
+// TagLimits são os limites de escrita de um tag (nil = sem limite)
+type TagLimits struct {
+	MinValue *float64
+	MaxValue *float64
+}
+
+// Tag retorna a definição de um tag da eclusa
+func (s7 *S7PLCConnector) Tag(name string) (PLCTag, bool) {
+	s7.mutex.RLock()
+	defer s7.mutex.RUnlock()
+
+	tag, ok := s7.config.Tags[name]
+	return tag, ok
+}
+
+// WriteTag escreve um valor no PLC validando tipo e limites do tag.
+// Bits são escritos com leitura-modificação-escrita do byte que os contém.
+func (s7 *S7PLCConnector) WriteTag(name string, value interface{}) error {
+	tag, ok := s7.Tag(name)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrTagNotFound, name)
+	}
+	if tag.isComputed() {
+		return fmt.Errorf("%w: tag calculado não aceita escrita", ErrInvalidTagValue)
+	}
+
+	// Os tags do banco já trazem min_value/max_value da tabela tags
+	limits := TagLimits{MinValue: tag.MinValue, MaxValue: tag.MaxValue}
+
+	s7.mutex.RLock()
+	config := s7.config.PLCConfig
+	s7.mutex.RUnlock()
+
+	info, addr, err := resolveTag(tag, config)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTagValue, err)
+	}
+	if addr.Area == areaInputs || addr.Area == areaInputRegisters || addr.Area == areaDiscreteInputs {
+		return fmt.Errorf("%w: entradas (%s) são somente leitura", ErrInvalidTagValue, addr)
+	}
+
+	raw, limits, err := unscaleTagValue(tag, info, value, limits)
+	if err != nil {
+		return err
+	}
+
+	buffer, err := encodeTagValue(info, addr, raw, limits)
+	if err != nil {
+		return err
+	}
+
+	s7.ioMutex.Lock()
+	defer s7.ioMutex.Unlock()
+
+	if !s7.isConnected {
+		return ErrPLCNotConnected
+	}
+
+	if info.Base == "bool" {
+		err = s7.writeBit(addr, buffer[0] != 0)
+	} else {
+		units := len(buffer) / addr.unitSize()
+		err = s7.driver.WriteArea(addr.Area, addr.DBNumber, addr.Byte, units, buffer)
+	}
+	if err != nil {
+		s7.disconnect(err)
+		return err
+	}
+
+	log.Printf("✍️ [%s] Tag %s (%s) escrito: %v", s7.lockID, name, addr, value)
+	return nil
+}
+
+// bitWriter é implementado pelos drivers que escrevem um bit isolado
+// (coils Modbus), sem leitura-modificação-escrita no conector
+type bitWriter interface {
+	WriteBit(area string, dbNumber int, index int, bit int, value bool) error
+}
+
+// bitReader é implementado pelos drivers que leem bits isolados (coils Modbus),
+// para não pedir bits além dos configurados. O bit 0 do buffer é o bit first.
+type bitReader interface {
+	ReadBits(area string, first int, count int, buffer []byte) error
+}
+
+// writeBit escreve um bit; deve ser chamado com ioMutex travado
+func (s7 *S7PLCConnector) writeBit(addr tagAddress, value bool) error {
+	if writer, ok := s7.driver.(bitWriter); ok {
+		return writer.WriteBit(addr.Area, addr.DBNumber, addr.Byte, addr.Bit, value)
+	}
+
+	// Leitura-modificação-escrita da unidade (byte ou registrador) que contém o bit
+	current := make([]byte, addr.unitSize())
+	if err := s7.driver.ReadArea(addr.Area, addr.DBNumber, addr.Byte, 1, current); err != nil {
+		return err
+	}
+
+	byteOffset, bit := addr.bitLocation()
+	if value {
+		current[byteOffset] |= 1 << bit
+	} else {
+		current[byteOffset] &^= 1 << bit
+	}
+	return s7.driver.WriteArea(addr.Area, addr.DBNumber, addr.Byte, 1, current)
+}
+
+// encodeTagValue codifica o valor e ajusta a ordem das palavras do endereço
+func encodeTagValue(info tagTypeInfo, addr tagAddress, value interface{}, limits TagLimits) ([]byte, error) {
+	buffer, err := encodeS7Value(info, value, limits)
+	if err != nil {
+		return nil, err
+	}
+	if addr.WordSwap {
+		buffer = swapWords(buffer)
+	}
+	return buffer, nil
+}
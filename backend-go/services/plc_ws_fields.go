@@ -0,0 +1,144 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Conversões aceitas em "transform" de ws_fields
+const (
+	transformRaw     = "raw" // padrão: valor tipado do tag
+	transformFloat32 = "float32"
+	transformInt     = "int"
+	transformInt16   = "int16"
+	transformBool    = "bool"
+)
+
+// Marcador do índice em key/tag de campos com "range"
+const wsFieldIndex = "{i}"
+
+// WebSocketField mapeia um tag para um campo da mensagem do WebSocket.
+// Ex: {"key": "nivelCaldeiraValue", "tag": "Eclusa_Nivel_Caldeira", "transform": "float32", "default": 0}
+type WebSocketField struct {
+	Key       string      `json:"key"`
+	Tag       string      `json:"tag"`
+	Transform string      `json:"transform,omitempty"`
+	Default   interface{} `json:"default,omitempty"` // usado quando o valor não converte (sem default o campo é omitido)
+
+	// EmitMissing envia o default também quando o tag ainda não tem valor publicado
+	EmitMissing bool `json:"emit_missing,omitempty"`
+
+	// Group coloca o campo dentro de um objeto (ex: "semaforos")
+	Group string `json:"group,omitempty"`
+
+	// Range expande o campo para cada índice de [início, fim], trocando {i} em key e tag
+	// (ex: "pipe_system_{i}" ← "PipeSystem[{i}]", range [0, 23])
+	Range []int `json:"range,omitempty"`
+}
+
+// validateWebSocketFields verifica o mapeamento de campos de uma eclusa
+func validateWebSocketFields(fields []WebSocketField) error {
+	for i, field := range fields {
+		if field.Key == "" || field.Tag == "" {
+			return fmt.Errorf("ws_fields[%d]: key e tag são obrigatórios", i)
+		}
+
+		switch field.Transform {
+		case "", transformRaw, transformFloat32, transformInt, transformInt16, transformBool:
+		default:
+			return fmt.Errorf("ws_fields[%d]: transform inválido: %s", i, field.Transform)
+		}
+
+		if field.Default != nil {
+			if _, ok := applyTransform(field.Transform, field.Default); !ok {
+				return fmt.Errorf("ws_fields[%d]: default incompatível com transform %s", i, field.Transform)
+			}
+		}
+
+		if field.Range != nil {
+			if len(field.Range) != 2 || field.Range[0] < 0 || field.Range[0] > field.Range[1] {
+				return fmt.Errorf("ws_fields[%d]: range deve ser [início, fim]", i)
+			}
+			if !strings.Contains(field.Key, wsFieldIndex) || !strings.Contains(field.Tag, wsFieldIndex) {
+				return fmt.Errorf("ws_fields[%d]: key e tag com range precisam de %s", i, wsFieldIndex)
+			}
+		}
+	}
+	return nil
+}
+
+// applyTransform converte um valor para o tipo do campo
+func applyTransform(transform string, value interface{}) (interface{}, bool) {
+	switch transform {
+	case transformFloat32:
+		number, ok := toFloat64(value)
+		return float32(number), ok
+	case transformInt:
+		number, ok := toFloat64(value)
+		return int(number), ok
+	case transformInt16:
+		number, ok := toFloat64(value)
+		return int16(number), ok
+	case transformBool:
+		if b, ok := value.(bool); ok {
+			return b, true
+		}
+		number, ok := toFloat64(value)
+		return number != 0, ok
+	}
+	if value == nil {
+		return nil, false
+	}
+	return jsonSafeValue(value), true
+}
+
+// expand devolve os pares (key, tag) do campo, um por índice quando há range
+func (f WebSocketField) expand() [][2]string {
+	if f.Range == nil {
+		return [][2]string{{f.Key, f.Tag}}
+	}
+
+	pairs := make([][2]string, 0, f.Range[1]-f.Range[0]+1)
+	for i := f.Range[0]; i <= f.Range[1]; i++ {
+		index := strconv.Itoa(i)
+		pairs = append(pairs, [2]string{
+			strings.ReplaceAll(f.Key, wsFieldIndex, index),
+			strings.ReplaceAll(f.Tag, wsFieldIndex, index),
+		})
+	}
+	return pairs
+}
+
+// applyWebSocketFields preenche data com os campos mapeados a partir dos valores dos tags
+func applyWebSocketFields(fields []WebSocketField, values map[string]interface{}, data map[string]interface{}) {
+	for _, field := range fields {
+		target := data
+		if field.Group != "" {
+			group, ok := data[field.Group].(map[string]interface{})
+			if !ok {
+				group = make(map[string]interface{})
+				data[field.Group] = group
+			}
+			target = group
+		}
+
+		for _, pair := range field.expand() {
+			key, tag := pair[0], pair[1]
+
+			value, exists := values[tag]
+			if !exists && !field.EmitMissing {
+				continue
+			}
+			if exists {
+				if converted, ok := applyTransform(field.Transform, value); ok {
+					target[key] = converted
+					continue
+				}
+			}
+			if field.Default != nil {
+				target[key], _ = applyTransform(field.Transform, field.Default)
+			}
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"backend-go/models"
+)
+
+// Sequência de eventos: os conectores entregam cada leitura dos tags digitais e de estado
+// ao gravador, que enfileira uma linha em tag_events a cada mudança de valor e grava em
+// lotes. O último valor de cada tag é retomado do banco ao iniciar, então uma mudança
+// ocorrida com o serviço parado aparece na primeira leitura.
+
+const (
+	eventFlushInterval       = time.Second
+	eventBatchSize           = 500
+	eventMaxPending          = 50000 // banco fora do ar: acima disso os eventos mais antigos são descartados
+	eventMaintenanceInterval = 24 * time.Hour
+	eventRetentionDays       = 365
+)
+
+// EventRecorder grava as transições dos tags com eventos ativos
+type EventRecorder struct {
+	mutex   sync.Mutex
+	last    map[historyKey]float64
+	pending []models.TagEvent
+	dropped int
+
+	flushNow chan struct{}
+}
+
+var (
+	globalEventRecorder *EventRecorder
+	eventRecorderOnce   sync.Once
+)
+
+// GetEventRecorder retorna instância singleton do gravador da sequência de eventos
+func GetEventRecorder() *EventRecorder {
+	eventRecorderOnce.Do(func() {
+		globalEventRecorder = &EventRecorder{
+			last:     make(map[historyKey]float64),
+			flushNow: make(chan struct{}, 1),
+		}
+
+		db := tagDB()
+		if db == nil {
+			log.Printf("⚠️ Banco indisponível: sequência de eventos desativada")
+			return
+		}
+
+		// Último valor conhecido de cada tag
+		var rows []models.TagEvent
+		err := db.Raw(`SELECT DISTINCT ON (plc_id, tag_name) plc_id, tag_name, new_value
+			FROM tag_events ORDER BY plc_id, tag_name, timestamp DESC, id DESC`).Scan(&rows).Error
+		if err != nil {
+			log.Printf("⚠️ Erro ao carregar últimos eventos: %v", err)
+		}
+		for _, row := range rows {
+			globalEventRecorder.last[historyKey{PLCID: row.PLCID, TagName: row.TagName}] = row.NewValue
+		}
+
+		go globalEventRecorder.flushLoop()
+		go globalEventRecorder.maintenanceLoop()
+		log.Printf("🚦 Sequência de eventos iniciada (%d tags conhecidos)", len(rows))
+	})
+	return globalEventRecorder
+}
+
+// eventsEnabled indica se as transições do tag vão para a sequência de eventos
+func (t PLCTag) eventsEnabled() bool {
+	if t.Events != nil {
+		return *t.Events
+	}
+	return strings.EqualFold(t.Type, "bool")
+}
+
+// isInteger indica se o tipo é inteiro (tags de estado aceitam eventos)
+func (t tagTypeInfo) isInteger() bool {
+	switch t.Base {
+	case "byte", "usint", "sint", "word", "uint", "int", "dword", "udint", "dint":
+		return true
+	}
+	return false
+}
+
+// validateEventPolicy verifica events e states do tag
+func validateEventPolicy(tag PLCTag, info tagTypeInfo) error {
+	if tag.Events != nil && *tag.Events && info.Base != "bool" && !info.isInteger() {
+		return fmt.Errorf("events só vale para tags bool ou inteiros (tipo %s)", tag.Type)
+	}
+	if len(tag.States) == 0 {
+		return nil
+	}
+	if !tag.eventsEnabled() {
+		return fmt.Errorf("states exige events ativo")
+	}
+	for key := range tag.States {
+		if info.Base == "bool" {
+			if key != "true" && key != "false" {
+				return fmt.Errorf("states de tag bool usa as chaves \"true\" e \"false\" (recebido %q)", key)
+			}
+		} else if _, err := strconv.ParseInt(key, 10, 64); err != nil {
+			return fmt.Errorf("states: chave %q não é um valor inteiro", key)
+		}
+	}
+	return nil
+}
+
+// eventText retorna o rótulo do valor em states, ou o próprio valor
+func (t PLCTag) eventText(value interface{}, number float64) string {
+	key := strconv.FormatFloat(number, 'f', -1, 64)
+	if b, ok := value.(bool); ok {
+		key = strconv.FormatBool(b)
+	}
+	if label, ok := t.States[key]; ok {
+		return label
+	}
+	return key
+}
+
+// Record recebe uma leitura do tag e enfileira um evento quando o valor mudou.
+// Leituras com qualidade ruim não mudam o último valor conhecido.
+func (r *EventRecorder) Record(lockID string, name string, tag PLCTag, value interface{}, quality string, at time.Time) {
+	if !tag.eventsEnabled() || tagDB() == nil || !usableQuality(quality) {
+		return
+	}
+	number, ok := historyValue(value)
+	if !ok {
+		return
+	}
+
+	key := historyKey{PLCID: lockID, TagName: name}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	last, exists := r.last[key]
+	r.last[key] = number
+	if !exists || last == number {
+		return
+	}
+
+	oldValue := interface{}(last)
+	if _, isBool := value.(bool); isBool {
+		oldValue = last != 0
+	}
+	r.pending = append(r.pending, models.TagEvent{
+		PLCID:     lockID,
+		TagName:   name,
+		OldValue:  last,
+		NewValue:  number,
+		OldText:   tag.eventText(oldValue, last),
+		NewText:   tag.eventText(value, number),
+		Quality:   quality,
+		Timestamp: at,
+	})
+	if len(r.pending) > eventMaxPending {
+		excess := len(r.pending) - eventMaxPending
+		r.pending = r.pending[excess:]
+		r.dropped += excess
+	}
+	if len(r.pending) >= eventBatchSize {
+		select {
+		case r.flushNow <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// flushLoop grava os eventos pendentes a cada eventFlushInterval ou quando um lote enche
+func (r *EventRecorder) flushLoop() {
+	ticker := time.NewTicker(eventFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-r.flushNow:
+		}
+		r.flush()
+	}
+}
+
+// flush grava os eventos pendentes na ordem de detecção. Em caso de erro eles voltam para a fila.
+func (r *EventRecorder) flush() {
+	r.mutex.Lock()
+	rows := r.pending
+	r.pending = nil
+	dropped := r.dropped
+	r.dropped = 0
+	r.mutex.Unlock()
+
+	if dropped > 0 {
+		log.Printf("⚠️ Sequência de eventos: %d eventos descartados (fila cheia)", dropped)
+	}
+	if len(rows) == 0 {
+		return
+	}
+
+	if err := tagDB().CreateInBatches(rows, eventBatchSize).Error; err != nil {
+		log.Printf("❌ Erro ao gravar eventos (%d pendentes): %v", len(rows), err)
+
+		r.mutex.Lock()
+		r.pending = append(rows, r.pending...)
+		if len(r.pending) > eventMaxPending {
+			excess := len(r.pending) - eventMaxPending
+			r.pending = r.pending[excess:]
+			r.dropped += excess
+		}
+		r.mutex.Unlock()
+	}
+}
+
+// maintenanceLoop apaga os eventos mais antigos que eventRetentionDays
+func (r *EventRecorder) maintenanceLoop() {
+	ticker := time.NewTicker(eventMaintenanceInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		cutoff := time.Now().AddDate(0, 0, -eventRetentionDays)
+		result := tagDB().Where("timestamp < ?", cutoff).Delete(&models.TagEvent{})
+		if result.Error != nil {
+			log.Printf("❌ Erro ao apagar eventos antigos: %v", result.Error)
+		} else if result.RowsAffected > 0 {
+			log.Printf("🧹 Sequência de eventos: %d eventos antigos apagados", result.RowsAffected)
+		}
+	}
+}
@@ -0,0 +1,370 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"backend-go/models"
+	"gorm.io/gorm"
+)
+
+// Gravação do histórico: os conectores entregam cada leitura ao gravador, que aplica a
+// banda morta do histórico e o intervalo máximo sem gravar e grava as linhas em lotes.
+// Uma rotina de manutenção agrega os dados brutos antigos por hora, os horários antigos
+// por dia, e apaga o que passou da retenção de cada tag.
+
+const (
+	historyFlushInterval       = 5 * time.Second
+	historyBatchSize           = 500
+	historyMaxPending          = 50000 // banco fora do ar: acima disso as linhas mais antigas são descartadas
+	historyMaintenanceDelay    = time.Minute
+	historyMaintenanceInterval = time.Hour
+
+	historyDefaultMaxInterval   = 10 * time.Minute
+	historyDefaultRawDays       = 7
+	historyHourlyDays           = 90 // agregados horários viram diários depois disso
+	historyDefaultRetentionDays = 365
+)
+
+// historyKey identifica o histórico de um tag
+type historyKey struct {
+	PLCID   string
+	TagName string
+}
+
+// historyPoint é a última leitura gravada de um tag e quando o tag foi visto pela última vez
+type historyPoint struct {
+	Value    float64
+	At       time.Time
+	LastSeen time.Time
+}
+
+// HistoryRecorder grava o histórico dos tags com "history" ativo
+type HistoryRecorder struct {
+	mutex   sync.Mutex
+	last    map[historyKey]historyPoint
+	pending []models.TagHistory
+	dropped int
+
+	// IDs da tabela tags, para preencher tag_id quando o tag está no banco
+	tagIDs map[historyKey]uint
+
+	flushNow chan struct{}
+}
+
+var (
+	globalHistoryRecorder *HistoryRecorder
+	historyRecorderOnce   sync.Once
+)
+
+// GetHistoryRecorder retorna instância singleton do gravador de histórico
+func GetHistoryRecorder() *HistoryRecorder {
+	historyRecorderOnce.Do(func() {
+		globalHistoryRecorder = &HistoryRecorder{
+			last:     make(map[historyKey]historyPoint),
+			tagIDs:   make(map[historyKey]uint),
+			flushNow: make(chan struct{}, 1),
+		}
+
+		if tagDB() == nil {
+			log.Printf("⚠️ Banco indisponível: histórico de tags desativado")
+			return
+		}
+		go globalHistoryRecorder.flushLoop()
+		go globalHistoryRecorder.maintenanceLoop()
+		log.Printf("📈 Gravador de histórico de tags iniciado")
+	})
+	return globalHistoryRecorder
+}
+
+// historyMaxInterval retorna o intervalo máximo sem gravar do tag
+func (t PLCTag) historyMaxInterval() time.Duration {
+	if t.HistoryMaxIntervalS > 0 {
+		return time.Duration(t.HistoryMaxIntervalS) * time.Second
+	}
+	return historyDefaultMaxInterval
+}
+
+// historyRawDays retorna por quantos dias os dados brutos do tag são mantidos
+func (t PLCTag) historyRawDays() int {
+	if t.HistoryRawDays > 0 {
+		return t.HistoryRawDays
+	}
+	return historyDefaultRawDays
+}
+
+// historyRetentionDays retorna por quantos dias o histórico do tag é mantido
+func (t PLCTag) historyRetentionDays() int {
+	if t.HistoryRetentionDays > 0 {
+		return t.HistoryRetentionDays
+	}
+	return historyDefaultRetentionDays
+}
+
+// validateHistoryPolicy verifica a configuração de histórico do tag
+func validateHistoryPolicy(tag PLCTag, info tagTypeInfo) error {
+	if tag.HistoryDeadband < 0 {
+		return fmt.Errorf("history_deadband negativo")
+	}
+	if tag.HistoryMaxIntervalS < 0 || tag.HistoryRawDays < 0 || tag.HistoryRetentionDays < 0 {
+		return fmt.Errorf("history_max_interval_s, history_raw_days e history_retention_days não podem ser negativos")
+	}
+	if tag.historyRawDays() > tag.historyRetentionDays() {
+		return fmt.Errorf("history_raw_days (%d) maior que history_retention_days (%d)", tag.historyRawDays(), tag.historyRetentionDays())
+	}
+	if tag.History && info.Base != "bool" && !info.isNumeric() {
+		return fmt.Errorf("histórico só vale para tags bool ou numéricos (tipo %s)", tag.Type)
+	}
+	return nil
+}
+
+// historyValue converte o valor do tag para a coluna value (bool vira 0/1)
+func historyValue(value interface{}) (float64, bool) {
+	if b, ok := value.(bool); ok {
+		if b {
+			return 1, true
+		}
+		return 0, true
+	}
+	number, ok := toFloat64(value)
+	if !ok || math.IsNaN(number) || math.IsInf(number, 0) {
+		return 0, false
+	}
+	return number, true
+}
+
+// Record recebe uma leitura do tag e a enfileira quando ela sai da banda morta do histórico,
+// quando passou o intervalo máximo sem gravar ou quando o tag volta de uma falha de leitura
+// (nenhuma leitura boa por mais de staleAfter). Leituras com qualidade ruim não são gravadas.
+func (r *HistoryRecorder) Record(lockID string, name string, tag PLCTag, value interface{}, quality string, at time.Time, staleAfter time.Duration) {
+	if !tag.History || tagDB() == nil {
+		return
+	}
+	if quality != QualityGood && quality != QualityUncertain {
+		return
+	}
+	number, ok := historyValue(value)
+	if !ok {
+		return
+	}
+
+	key := historyKey{PLCID: lockID, TagName: name}
+	band := tag
+	band.Deadband = tag.HistoryDeadband
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	last, exists := r.last[key]
+	record := !exists ||
+		exceedsDeadband(band, last.Value, number) ||
+		at.Sub(last.At) >= tag.historyMaxInterval() ||
+		(staleAfter > 0 && at.Sub(last.LastSeen) > staleAfter)
+
+	if !record {
+		last.LastSeen = at
+		r.last[key] = last
+		return
+	}
+	r.last[key] = historyPoint{Value: number, At: at, LastSeen: at}
+
+	r.pending = append(r.pending, models.TagHistory{
+		PLCID:     lockID,
+		TagName:   name,
+		Value:     number,
+		Quality:   quality,
+		Timestamp: at,
+	})
+	if len(r.pending) > historyMaxPending {
+		excess := len(r.pending) - historyMaxPending
+		r.pending = r.pending[excess:]
+		r.dropped += excess
+	}
+	if len(r.pending) >= historyBatchSize {
+		select {
+		case r.flushNow <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// flushLoop grava as linhas pendentes a cada historyFlushInterval ou quando um lote enche
+func (r *HistoryRecorder) flushLoop() {
+	ticker := time.NewTicker(historyFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-r.flushNow:
+		}
+		r.flush()
+	}
+}
+
+// flush grava as linhas pendentes em lotes. Em caso de erro elas voltam para a fila.
+func (r *HistoryRecorder) flush() {
+	r.mutex.Lock()
+	rows := r.pending
+	r.pending = nil
+	dropped := r.dropped
+	r.dropped = 0
+	for i := range rows {
+		if id, ok := r.tagIDs[historyKey{PLCID: rows[i].PLCID, TagName: rows[i].TagName}]; ok {
+			rows[i].TagID = &id
+		}
+	}
+	r.mutex.Unlock()
+
+	if dropped > 0 {
+		log.Printf("⚠️ Histórico: %d leituras descartadas (fila cheia)", dropped)
+	}
+	if len(rows) == 0 {
+		return
+	}
+
+	if err := tagDB().CreateInBatches(rows, historyBatchSize).Error; err != nil {
+		log.Printf("❌ Erro ao gravar histórico (%d leituras pendentes): %v", len(rows), err)
+
+		r.mutex.Lock()
+		r.pending = append(rows, r.pending...)
+		if len(r.pending) > historyMaxPending {
+			excess := len(r.pending) - historyMaxPending
+			r.pending = r.pending[excess:]
+			r.dropped += excess
+		}
+		r.mutex.Unlock()
+	}
+}
+
+// maintenanceLoop agrega e apaga o histórico antigo a cada historyMaintenanceInterval
+func (r *HistoryRecorder) maintenanceLoop() {
+	time.Sleep(historyMaintenanceDelay)
+	for {
+		r.maintain(time.Now())
+		time.Sleep(historyMaintenanceInterval)
+	}
+}
+
+// maintain aplica a política de cada tag que tem histórico gravado.
+// Tags que saíram da configuração usam os prazos padrão.
+func (r *HistoryRecorder) maintain(now time.Time) {
+	db := tagDB()
+	r.loadTagIDs(db)
+
+	policies := make(map[historyKey]PLCTag)
+	for _, conn := range GetPLCManager().currentConnections() {
+		for name, tag := range conn.Tags {
+			policies[historyKey{PLCID: conn.ID, TagName: name}] = tag
+		}
+	}
+
+	keys, err := historyKeys(db)
+	if err != nil {
+		log.Printf("❌ Erro ao listar histórico para manutenção: %v", err)
+		return
+	}
+
+	for _, key := range keys {
+		if err := downsampleHistory(db, key, policies[key], now); err != nil {
+			log.Printf("❌ Erro na manutenção do histórico de %s/%s: %v", key.PLCID, key.TagName, err)
+		}
+	}
+}
+
+// loadTagIDs atualiza o mapa de IDs da tabela tags
+func (r *HistoryRecorder) loadTagIDs(db *gorm.DB) {
+	var tags []models.Tag
+	if err := db.Select("id", "plc_id", "name").Find(&tags).Error; err != nil {
+		log.Printf("⚠️ Erro ao carregar IDs dos tags para o histórico: %v", err)
+		return
+	}
+
+	ids := make(map[historyKey]uint, len(tags))
+	for _, tag := range tags {
+		ids[historyKey{PLCID: tag.PLCID, TagName: tag.Name}] = tag.ID
+	}
+
+	r.mutex.Lock()
+	r.tagIDs = ids
+	r.mutex.Unlock()
+}
+
+// historyKeys lista os tags com dados brutos ou agregados
+func historyKeys(db *gorm.DB) ([]historyKey, error) {
+	var keys []historyKey
+	err := db.Raw(`SELECT plc_id, tag_name FROM tag_histories
+		UNION SELECT plc_id, tag_name FROM tag_history_aggregates`).Scan(&keys).Error
+	return keys, err
+}
+
+// downsampleHistory agrega por hora os dados brutos mais antigos que history_raw_days,
+// agrega por dia os horários mais antigos que historyHourlyDays e apaga tudo o que
+// passou de history_retention_days. Cada etapa roda numa transação.
+func downsampleHistory(db *gorm.DB, key historyKey, tag PLCTag, now time.Time) error {
+	retention := now.AddDate(0, 0, -tag.historyRetentionDays())
+	rawCutoff := now.AddDate(0, 0, -tag.historyRawDays()).Truncate(time.Hour)
+	year, month, day := now.AddDate(0, 0, -historyHourlyDays).Date()
+	hourlyCutoff := time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("plc_id = ? AND tag_name = ? AND timestamp < ?", key.PLCID, key.TagName, retention).
+			Delete(&models.TagHistory{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("plc_id = ? AND tag_name = ? AND bucket_start < ?", key.PLCID, key.TagName, retention).
+			Delete(&models.TagHistoryAggregate{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Exec(`INSERT INTO tag_history_aggregates
+			(plc_id, tag_name, resolution, bucket_start, min, max, avg, first, last, count)
+			SELECT plc_id, tag_name, ?, date_trunc('hour', "timestamp") AS bucket,
+				MIN(value), MAX(value), AVG(value),
+				(ARRAY_AGG(value ORDER BY "timestamp"))[1],
+				(ARRAY_AGG(value ORDER BY "timestamp" DESC))[1],
+				COUNT(*)
+			FROM tag_histories
+			WHERE plc_id = ? AND tag_name = ? AND "timestamp" < ?
+			GROUP BY plc_id, tag_name, bucket
+			`+historyAggregateConflict,
+			models.HistoryResolutionHour, key.PLCID, key.TagName, rawCutoff).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("plc_id = ? AND tag_name = ? AND timestamp < ?", key.PLCID, key.TagName, rawCutoff).
+			Delete(&models.TagHistory{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Exec(`INSERT INTO tag_history_aggregates
+			(plc_id, tag_name, resolution, bucket_start, min, max, avg, first, last, count)
+			SELECT plc_id, tag_name, ?, date_trunc('day', bucket_start) AS bucket,
+				MIN(min), MAX(max), SUM(avg * count) / SUM(count),
+				(ARRAY_AGG(first ORDER BY bucket_start))[1],
+				(ARRAY_AGG(last ORDER BY bucket_start DESC))[1],
+				SUM(count)
+			FROM tag_history_aggregates
+			WHERE plc_id = ? AND tag_name = ? AND resolution = ? AND bucket_start < ?
+			GROUP BY plc_id, tag_name, bucket
+			`+historyAggregateConflict,
+			models.HistoryResolutionDay, key.PLCID, key.TagName, models.HistoryResolutionHour, hourlyCutoff).Error; err != nil {
+			return err
+		}
+		return tx.Where("plc_id = ? AND tag_name = ? AND resolution = ? AND bucket_start < ?",
+			key.PLCID, key.TagName, models.HistoryResolutionHour, hourlyCutoff).
+			Delete(&models.TagHistoryAggregate{}).Error
+	})
+}
+
+// historyAggregateConflict junta um agregado novo a um já existente no mesmo intervalo
+// (leituras que chegaram depois de o intervalo ter sido agregado)
+const historyAggregateConflict = `ON CONFLICT (plc_id, tag_name, resolution, bucket_start) DO UPDATE SET
+	min = LEAST(tag_history_aggregates.min, EXCLUDED.min),
+	max = GREATEST(tag_history_aggregates.max, EXCLUDED.max),
+	avg = (tag_history_aggregates.avg * tag_history_aggregates.count + EXCLUDED.avg * EXCLUDED.count)
+		/ (tag_history_aggregates.count + EXCLUDED.count),
+	last = EXCLUDED.last,
+	count = tag_history_aggregates.count + EXCLUDED.count`
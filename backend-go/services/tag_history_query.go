@@ -0,0 +1,276 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Consulta do histórico gravado pelo HistoryRecorder: agregação em intervalos fixos
+// (dados brutos e agregados horários/diários juntos) ou pontos brutos reduzidos por LTTB.
+
+const (
+	HistoryMaxBuckets      = 5000
+	HistoryDefaultBuckets  = 500
+	HistoryDefaultRawLimit = 2000
+	HistoryMaxRawLimit     = 20000
+	HistoryMaxTags         = 20
+
+	// Acima de limit × historyM4Factor pontos, o banco já devolve só o primeiro, o último,
+	// o mínimo e o máximo de cada intervalo (M4) antes do LTTB
+	historyM4Factor = 4
+)
+
+var (
+	// ErrHistoryUnavailable indica que não há banco para consultar o histórico
+	ErrHistoryUnavailable = errors.New("histórico indisponível (sem banco de dados)")
+
+	// ErrInvalidHistoryQuery indica parâmetros de consulta inválidos
+	ErrInvalidHistoryQuery = errors.New("consulta de histórico inválida")
+)
+
+// HistoryQuery descreve uma consulta ao histórico de tags de uma eclusa
+type HistoryQuery struct {
+	LockID string
+	Tags   []string
+	From   time.Time // inclusivo
+	To     time.Time // exclusivo
+	Bucket time.Duration
+	Limit  int // pontos por tag no modo bruto
+}
+
+// HistoryBucket resume os valores de um tag num intervalo que começa em Time
+type HistoryBucket struct {
+	Time  time.Time `json:"time"`
+	Min   float64   `json:"min"`
+	Max   float64   `json:"max"`
+	Avg   float64   `json:"avg"`
+	First float64   `json:"first"`
+	Last  float64   `json:"last"`
+	Count int64     `json:"count"`
+}
+
+// HistorySample é um ponto bruto do histórico
+type HistorySample struct {
+	Time    time.Time `json:"time"`
+	Value   float64   `json:"value"`
+	Quality string    `json:"quality"`
+}
+
+// HistoryRawSeries são os pontos brutos de um tag, reduzidos a Limit quando necessário
+type HistoryRawSeries struct {
+	Points    []HistorySample `json:"points"`
+	Total     int64           `json:"total"`     // pontos gravados no período
+	Decimated bool            `json:"decimated"` // true quando Points é uma redução de Total
+}
+
+// AutoHistoryBucket escolhe um intervalo para cerca de HistoryDefaultBuckets pontos
+func AutoHistoryBucket(from, to time.Time) time.Duration {
+	bucket := (to.Sub(from) / HistoryDefaultBuckets).Truncate(time.Second)
+	if bucket < time.Second {
+		return time.Second
+	}
+	return bucket
+}
+
+// validate verifica período, intervalo e quantidade de tags
+func (q HistoryQuery) validate() error {
+	if len(q.Tags) == 0 {
+		return fmt.Errorf("%w: informe ao menos um tag", ErrInvalidHistoryQuery)
+	}
+	if len(q.Tags) > HistoryMaxTags {
+		return fmt.Errorf("%w: no máximo %d tags por consulta", ErrInvalidHistoryQuery, HistoryMaxTags)
+	}
+	if !q.From.Before(q.To) {
+		return fmt.Errorf("%w: from deve ser anterior a to", ErrInvalidHistoryQuery)
+	}
+	return nil
+}
+
+// validateBuckets verifica o tamanho e a quantidade de intervalos
+func (q HistoryQuery) validateBuckets() error {
+	if q.Bucket < time.Second {
+		return fmt.Errorf("%w: bucket mínimo de 1s", ErrInvalidHistoryQuery)
+	}
+	if count := q.To.Sub(q.From) / q.Bucket; count > HistoryMaxBuckets {
+		return fmt.Errorf("%w: período com %d intervalos (máximo %d): aumente o bucket", ErrInvalidHistoryQuery, count, HistoryMaxBuckets)
+	}
+	return nil
+}
+
+// QueryHistoryBuckets agrega o histórico de cada tag em intervalos de q.Bucket a partir de q.From.
+// Períodos já agregados pela manutenção entram com a resolução guardada (hora ou dia):
+// cada agregado cai inteiro no intervalo que contém o seu início.
+func QueryHistoryBuckets(q HistoryQuery) (map[string][]HistoryBucket, error) {
+	if err := q.validate(); err != nil {
+		return nil, err
+	}
+	if err := q.validateBuckets(); err != nil {
+		return nil, err
+	}
+	db := tagDB()
+	if db == nil {
+		return nil, ErrHistoryUnavailable
+	}
+
+	seconds := q.Bucket.Seconds()
+	result := make(map[string][]HistoryBucket, len(q.Tags))
+	for _, name := range q.Tags {
+		buckets := make([]HistoryBucket, 0)
+		err := db.Raw(`WITH src AS (
+				SELECT "timestamp" AS ts, value AS min, value AS max, value AS sum,
+					value AS first, value AS last, 1 AS count
+				FROM tag_histories
+				WHERE plc_id = @lock AND tag_name = @tag AND "timestamp" >= @from AND "timestamp" < @to
+				UNION ALL
+				SELECT bucket_start, min, max, avg * count, first, last, count
+				FROM tag_history_aggregates
+				WHERE plc_id = @lock AND tag_name = @tag AND bucket_start >= @from AND bucket_start < @to
+			)
+			SELECT CAST(@from AS timestamptz) + floor(extract(epoch FROM ts - CAST(@from AS timestamptz)) / @seconds) * @seconds * interval '1 second' AS time,
+				MIN(min) AS min, MAX(max) AS max, SUM(sum) / SUM(count) AS avg,
+				(ARRAY_AGG(first ORDER BY ts))[1] AS first,
+				(ARRAY_AGG(last ORDER BY ts DESC))[1] AS last,
+				SUM(count) AS count
+			FROM src
+			GROUP BY 1
+			ORDER BY 1`,
+			map[string]interface{}{"lock": q.LockID, "tag": name, "from": q.From, "to": q.To, "seconds": seconds},
+		).Scan(&buckets).Error
+		if err != nil {
+			return nil, err
+		}
+		result[name] = buckets
+	}
+	return result, nil
+}
+
+// QueryHistoryRaw retorna os pontos brutos de cada tag. Acima de q.Limit pontos a série
+// é reduzida por LTTB (Largest-Triangle-Three-Buckets), que mantém picos e vales do gráfico;
+// séries muito grandes são antes reduzidas no banco (M4) para não trazer milhões de linhas.
+func QueryHistoryRaw(q HistoryQuery) (map[string]HistoryRawSeries, error) {
+	if err := q.validate(); err != nil {
+		return nil, err
+	}
+	if q.Limit <= 0 {
+		q.Limit = HistoryDefaultRawLimit
+	}
+	if q.Limit < 3 || q.Limit > HistoryMaxRawLimit {
+		return nil, fmt.Errorf("%w: limit deve estar entre 3 e %d", ErrInvalidHistoryQuery, HistoryMaxRawLimit)
+	}
+	db := tagDB()
+	if db == nil {
+		return nil, ErrHistoryUnavailable
+	}
+
+	result := make(map[string]HistoryRawSeries, len(q.Tags))
+	for _, name := range q.Tags {
+		series, err := queryRawSeries(db, q, name)
+		if err != nil {
+			return nil, err
+		}
+		result[name] = series
+	}
+	return result, nil
+}
+
+// queryRawSeries lê os pontos brutos de um tag, reduzindo-os quando passam do limite
+func queryRawSeries(db *gorm.DB, q HistoryQuery, name string) (HistoryRawSeries, error) {
+	series := HistoryRawSeries{Points: make([]HistorySample, 0)}
+	params := map[string]interface{}{"lock": q.LockID, "tag": name, "from": q.From, "to": q.To}
+
+	if err := db.Raw(`SELECT COUNT(*) FROM tag_histories
+		WHERE plc_id = @lock AND tag_name = @tag AND "timestamp" >= @from AND "timestamp" < @to`,
+		params).Scan(&series.Total).Error; err != nil {
+		return series, err
+	}
+	if series.Total == 0 {
+		return series, nil
+	}
+
+	var err error
+	if series.Total <= int64(q.Limit*historyM4Factor) {
+		err = db.Raw(`SELECT "timestamp" AS time, value, quality FROM tag_histories
+			WHERE plc_id = @lock AND tag_name = @tag AND "timestamp" >= @from AND "timestamp" < @to
+			ORDER BY "timestamp"`, params).Scan(&series.Points).Error
+	} else {
+		params["seconds"] = q.To.Sub(q.From).Seconds() / float64(q.Limit)
+		err = db.Raw(`SELECT ts AS time, value, quality FROM (
+				SELECT ts, value, quality,
+					ROW_NUMBER() OVER (PARTITION BY b ORDER BY ts) AS first_rank,
+					ROW_NUMBER() OVER (PARTITION BY b ORDER BY ts DESC) AS last_rank,
+					ROW_NUMBER() OVER (PARTITION BY b ORDER BY value, ts) AS min_rank,
+					ROW_NUMBER() OVER (PARTITION BY b ORDER BY value DESC, ts) AS max_rank
+				FROM (
+					SELECT "timestamp" AS ts, value, quality,
+						floor(extract(epoch FROM "timestamp" - CAST(@from AS timestamptz)) / @seconds) AS b
+					FROM tag_histories
+					WHERE plc_id = @lock AND tag_name = @tag AND "timestamp" >= @from AND "timestamp" < @to
+				) AS raw
+			) AS ranked
+			WHERE first_rank = 1 OR last_rank = 1 OR min_rank = 1 OR max_rank = 1
+			ORDER BY ts`, params).Scan(&series.Points).Error
+	}
+	if err != nil {
+		return series, err
+	}
+
+	if len(series.Points) > q.Limit || int64(len(series.Points)) < series.Total {
+		series.Points = lttb(series.Points, q.Limit)
+		series.Decimated = true
+	}
+	return series, nil
+}
+
+// lttb reduz a série a threshold pontos pelo algoritmo Largest-Triangle-Three-Buckets:
+// mantém o primeiro e o último ponto e, em cada intervalo, o ponto que forma o maior
+// triângulo com o ponto escolhido antes e a média do intervalo seguinte
+func lttb(points []HistorySample, threshold int) []HistorySample {
+	if threshold >= len(points) || threshold < 3 {
+		return points
+	}
+
+	x := func(p HistorySample) float64 { return float64(p.Time.UnixMilli()) }
+
+	sampled := make([]HistorySample, 0, threshold)
+	sampled = append(sampled, points[0])
+
+	every := float64(len(points)-2) / float64(threshold-2)
+	selected := 0
+	for i := 0; i < threshold-2; i++ {
+		// Média do intervalo seguinte
+		nextStart := int(math.Floor(float64(i+1)*every)) + 1
+		nextEnd := int(math.Floor(float64(i+2)*every)) + 1
+		if nextEnd > len(points) {
+			nextEnd = len(points)
+		}
+		avgX, avgY := 0.0, 0.0
+		for _, p := range points[nextStart:nextEnd] {
+			avgX += x(p)
+			avgY += p.Value
+		}
+		if n := float64(nextEnd - nextStart); n > 0 {
+			avgX /= n
+			avgY /= n
+		}
+
+		// Ponto do intervalo atual com o maior triângulo
+		start := int(math.Floor(float64(i)*every)) + 1
+		end := nextStart
+		ax, ay := x(points[selected]), points[selected].Value
+		maxArea := -1.0
+		for j := start; j < end; j++ {
+			area := math.Abs((ax-avgX)*(points[j].Value-ay) - (ax-x(points[j]))*(avgY-ay))
+			if area > maxArea {
+				maxArea = area
+				selected = j
+			}
+		}
+		sampled = append(sampled, points[selected])
+	}
+
+	return append(sampled, points[len(points)-1])
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func historySeries(values ...float64) []HistorySample {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	points := make([]HistorySample, len(values))
+	for i, value := range values {
+		points[i] = HistorySample{Time: start.Add(time.Duration(i) * time.Second), Value: value}
+	}
+	return points
+}
+
+func TestLTTB(t *testing.T) {
+	short := historySeries(1, 2, 3)
+	if got := lttb(short, 10); !reflect.DeepEqual(got, short) {
+		t.Errorf("série menor que o limite deve voltar inteira")
+	}
+	if got := lttb(historySeries(1, 2, 3, 4, 5), 2); len(got) != 5 {
+		t.Errorf("limite abaixo de 3 deve devolver a série: %d pontos", len(got))
+	}
+
+	// Série plana com um pico: o pico tem de sobreviver à redução
+	values := make([]float64, 1000)
+	values[537] = 100
+	points := historySeries(values...)
+	got := lttb(points, 50)
+
+	if len(got) != 50 {
+		t.Fatalf("obtido %d pontos, esperado 50", len(got))
+	}
+	if got[0] != points[0] || got[len(got)-1] != points[len(points)-1] {
+		t.Error("primeiro e último ponto devem ser mantidos")
+	}
+	peak := false
+	for i, p := range got {
+		if i > 0 && !p.Time.After(got[i-1].Time) {
+			t.Fatalf("pontos fora de ordem em %d", i)
+		}
+		if p.Value == 100 {
+			peak = true
+		}
+	}
+	if !peak {
+		t.Error("o pico foi descartado")
+	}
+
+	// Senoide: a forma (mínimo e máximo) se mantém
+	values = make([]float64, 5000)
+	for i := range values {
+		values[i] = math.Sin(float64(i) / 100)
+	}
+	got = lttb(historySeries(values...), 200)
+	min, max := 1.0, -1.0
+	for _, p := range got {
+		min, max = math.Min(min, p.Value), math.Max(max, p.Value)
+	}
+	if min > -0.99 || max < 0.99 {
+		t.Errorf("amplitude perdida: min %.3f, max %.3f", min, max)
+	}
+}
+
+func TestAutoHistoryBucket(t *testing.T) {
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	previous := time.Duration(0)
+	for _, span := range []time.Duration{time.Minute, time.Hour, 24 * time.Hour, 30 * 24 * time.Hour, 365 * 24 * time.Hour} {
+		bucket := AutoHistoryBucket(from, from.Add(span))
+		if bucket <= 0 || bucket < previous {
+			t.Errorf("%v: intervalo %v (anterior %v)", span, bucket, previous)
+		}
+		previous = bucket
+	}
+}
@@ -0,0 +1,544 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"backend-go/database"
+	"backend-go/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// tagDB retorna o banco quando ele está disponível (nil em ferramentas sem banco)
+func tagDB() *gorm.DB {
+	return database.GetDB()
+}
+
+// PLCTagFromModel converte um registro da tabela tags na definição usada pelo conector
+func PLCTagFromModel(tag models.Tag) PLCTag {
+	return PLCTag{
+		Type:         tag.Type,
+		Offset:       tag.Offset,
+		Address:      tag.Address,
+		Length:       tag.Length,
+		Description:  tag.Description,
+		MinValue:     tag.MinValue,
+		MaxValue:     tag.MaxValue,
+		Scale:        tag.Scale,
+		WordOrder:    tag.WordOrder,
+		RawMin:       tag.RawMin,
+		RawMax:       tag.RawMax,
+		EUMin:        tag.EUMin,
+		EUMax:        tag.EUMax,
+		EUOffset:     tag.EUOffset,
+		Unit:         tag.Unit,
+		Clamp:        tag.Clamp,
+		Deadband:     tag.Deadband,
+		DeadbandType: tag.DeadbandType,
+		MinPublishMs: tag.MinPublishMs,
+		ScanClass:    tag.ScanClass,
+		Expression:   tag.Expression,
+
+		History:              tag.History,
+		HistoryDeadband:      tag.HistoryDeadband,
+		HistoryMaxIntervalS:  tag.HistoryMaxIntervalS,
+		HistoryRawDays:       tag.HistoryRawDays,
+		HistoryRetentionDays: tag.HistoryRetentionDays,
+
+		Alarms: alarmRulesFromJSON(tag.PLCID, tag.Name, tag.Alarms),
+
+		Events: tag.Events,
+		States: statesFromJSON(tag.PLCID, tag.Name, tag.States),
+	}
+}
+
+// alarmRulesFromJSON lê as regras de alarme gravadas na tabela tags
+func alarmRulesFromJSON(lockID string, name string, data string) []AlarmRule {
+	if data == "" {
+		return nil
+	}
+	var rules []AlarmRule
+	if err := json.Unmarshal([]byte(data), &rules); err != nil {
+		log.Printf("⚠️ [%s] Regras de alarme inválidas no tag %s: %v", lockID, name, err)
+		return nil
+	}
+	return rules
+}
+
+// AlarmRulesJSON converte as regras de alarme no texto gravado na tabela tags
+func AlarmRulesJSON(rules []AlarmRule) string {
+	if len(rules) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(rules)
+	return string(data)
+}
+
+// statesFromJSON lê os rótulos dos valores gravados na tabela tags
+func statesFromJSON(lockID string, name string, data string) map[string]string {
+	if data == "" {
+		return nil
+	}
+	var states map[string]string
+	if err := json.Unmarshal([]byte(data), &states); err != nil {
+		log.Printf("⚠️ [%s] states inválido no tag %s: %v", lockID, name, err)
+		return nil
+	}
+	return states
+}
+
+// StatesJSON converte os rótulos dos valores no texto gravado na tabela tags
+func StatesJSON(states map[string]string) string {
+	if len(states) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(states)
+	return string(data)
+}
+
+// ModelFromPLCTag converte uma definição do tags.json num registro da tabela tags
+func ModelFromPLCTag(lockID string, name string, tag PLCTag) models.Tag {
+	return models.Tag{
+		PLCID:        lockID,
+		Name:         name,
+		Type:         tag.Type,
+		Offset:       tag.Offset,
+		Address:      tag.Address,
+		Length:       tag.Length,
+		Description:  tag.Description,
+		MinValue:     tag.MinValue,
+		MaxValue:     tag.MaxValue,
+		Scale:        tag.Scale,
+		WordOrder:    tag.WordOrder,
+		RawMin:       tag.RawMin,
+		RawMax:       tag.RawMax,
+		EUMin:        tag.EUMin,
+		EUMax:        tag.EUMax,
+		EUOffset:     tag.EUOffset,
+		Unit:         tag.Unit,
+		Clamp:        tag.Clamp,
+		Deadband:     tag.Deadband,
+		DeadbandType: tag.DeadbandType,
+		MinPublishMs: tag.MinPublishMs,
+		ScanClass:    tag.ScanClass,
+		Expression:   tag.Expression,
+		IsActive:     true,
+
+		History:              tag.History,
+		HistoryDeadband:      tag.HistoryDeadband,
+		HistoryMaxIntervalS:  tag.HistoryMaxIntervalS,
+		HistoryRawDays:       tag.HistoryRawDays,
+		HistoryRetentionDays: tag.HistoryRetentionDays,
+
+		Alarms: AlarmRulesJSON(tag.Alarms),
+
+		Events: tag.Events,
+		States: StatesJSON(tag.States),
+	}
+}
+
+// ValidatePLCTag verifica tipo, endereço, escala e classe de varredura de um tag
+// para o driver da eclusa antes de gravá-lo
+func ValidatePLCTag(tag PLCTag, conn PLCConnection) error {
+	var info tagTypeInfo
+	var err error
+	if tag.isComputed() {
+		info, err = validateComputedTag(tag, conn)
+	} else {
+		info, _, err = resolveTag(tag, conn.PLCConfig)
+	}
+	if err != nil {
+		return err
+	}
+	if err := validateScaling(tag, info); err != nil {
+		return err
+	}
+	if err := conn.validateTagScanClass(tag); err != nil {
+		return err
+	}
+	if err := validateHistoryPolicy(tag, info); err != nil {
+		return err
+	}
+	if err := validateAlarmRules(tag, info, conn); err != nil {
+		return err
+	}
+	if err := validateEventPolicy(tag, info); err != nil {
+		return err
+	}
+	return validateChangePolicy(tag)
+}
+
+// applyDBTags substitui os tags do arquivo pelos tags cadastrados no banco e valida
+// cada eclusa resultante no seu driver (endereços, expressões, alarmes, eclusagem).
+// Eclusas que nunca tiveram tags no banco continuam usando os tags do tags.json.
+// Tags sem scan_class própria herdam a do grupo. Com erro as conexões não mudam.
+func applyDBTags(connections []PLCConnection) error {
+	merged := append([]PLCConnection(nil), connections...)
+	if err := loadDBTags(merged, true); err != nil {
+		return err
+	}
+	for _, conn := range merged {
+		if err := validatePLCConnection(conn); err != nil {
+			return fmt.Errorf("eclusa %s: %v", conn.ID, err)
+		}
+	}
+
+	copy(connections, merged)
+	return nil
+}
+
+// loadDBTags carrega os tags ativos do banco nas conexões, opcionalmente
+// aplicando a classe de varredura dos grupos
+func loadDBTags(connections []PLCConnection, inheritGroups bool) error {
+	db := tagDB()
+	if db == nil {
+		return nil
+	}
+
+	managed, err := managedLocks(db)
+	if err != nil {
+		return err
+	}
+
+	var rows []models.Tag
+	if err := db.Where("is_active = ?", true).Find(&rows).Error; err != nil {
+		return err
+	}
+
+	groupClasses := make(map[uint]string)
+	if inheritGroups {
+		if groupClasses, err = groupScanClasses(); err != nil {
+			return err
+		}
+	}
+
+	byLock := make(map[string]map[string]PLCTag)
+	for _, row := range rows {
+		if byLock[row.PLCID] == nil {
+			byLock[row.PLCID] = make(map[string]PLCTag)
+		}
+		tag := PLCTagFromModel(row)
+		if tag.ScanClass == "" {
+			tag.ScanClass = groupClasses[row.ID]
+		}
+		byLock[row.PLCID][row.Name] = tag
+	}
+
+	for i := range connections {
+		tags, ok := byLock[connections[i].ID]
+		if !ok && !managed[connections[i].ID] {
+			continue
+		}
+		if tags == nil {
+			// Mantida pelo banco e sem tags ativos: fica vazia
+			tags = make(map[string]PLCTag)
+		}
+		connections[i].Tags = tags
+	}
+
+	return nil
+}
+
+// managedLocks retorna as eclusas cujos tags são mantidos no banco
+func managedLocks(db *gorm.DB) (map[string]bool, error) {
+	var ids []string
+	if err := db.Model(&models.ManagedLock{}).Pluck("plc_id", &ids).Error; err != nil {
+		return nil, err
+	}
+
+	managed := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		managed[id] = true
+	}
+	return managed, nil
+}
+
+// markManaged marca as eclusas como mantidas pelo banco
+func markManaged(db *gorm.DB, lockIDs ...string) error {
+	for _, id := range lockIDs {
+		err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.ManagedLock{PLCID: id}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SeedLockTags passa a eclusa para os tags do banco antes do primeiro tag gravado
+// pela API. Uma eclusa sem registros recebe antes os tags em uso (tags.json),
+// para que um único tag nunca substitua os do arquivo.
+func SeedLockTags(lockID string) error {
+	db := tagDB()
+	if db == nil {
+		return fmt.Errorf("banco de dados não disponível")
+	}
+	conn, err := liveConnection(lockID)
+	if err != nil {
+		return err
+	}
+	_, err = seedLock(db, GetPLCManager().currentConnections(), conn)
+	return err
+}
+
+// ValidateTagChange valida o tag no driver da eclusa e, se ativo, a eclusa inteira
+// com a mudança aplicada: tags calculados, alarmes e eclusagem que dependem dele.
+// previous é o nome atual do tag na eclusa (vazio na criação).
+func ValidateTagChange(lockID string, previous string, name string, tag PLCTag, active bool) error {
+	conn, err := liveConnection(lockID)
+	if err != nil {
+		return err
+	}
+	if err := ValidatePLCTag(tag, conn); err != nil {
+		return err
+	}
+
+	tags := managedTags(conn)
+	delete(tags, previous)
+	if active {
+		tags[name] = tag
+	}
+	conn.Tags = tags
+	return validatePLCConnection(conn)
+}
+
+// ValidateTagRemoval valida a eclusa sem o tag (exclusão, desativação ou troca de eclusa)
+func ValidateTagRemoval(lockID string, name string) error {
+	conn, err := liveConnection(lockID)
+	if err != nil {
+		return err
+	}
+
+	tags := managedTags(conn)
+	delete(tags, name)
+	conn.Tags = tags
+	return validatePLCConnection(conn)
+}
+
+// ValidateGroupScanClass verifica se a classe de um grupo existe na eclusa de cada
+// tag membro que a herdaria (os sem scan_class própria). Tags de eclusas que não
+// estão configuradas são ignorados, como no carregamento.
+func ValidateGroupScanClass(class string, tagIDs []uint) error {
+	if class == "" || len(tagIDs) == 0 || tagDB() == nil {
+		return nil
+	}
+
+	var rows []models.Tag
+	if err := tagDB().Where("id IN ?", tagIDs).Find(&rows).Error; err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if row.ScanClass != "" {
+			continue
+		}
+		conn, err := liveConnection(row.PLCID)
+		if err != nil {
+			continue
+		}
+		if err := conn.validateTagScanClass(PLCTag{ScanClass: class}); err != nil {
+			return fmt.Errorf("tag %s (eclusa %s): %v", row.Name, row.PLCID, err)
+		}
+	}
+	return nil
+}
+
+// liveConnection copia a configuração em uso de uma eclusa
+func liveConnection(lockID string) (PLCConnection, error) {
+	connector, ok := GetPLCManager().Connector(lockID)
+	if !ok {
+		return PLCConnection{}, fmt.Errorf("eclusa %s não configurada", lockID)
+	}
+
+	connector.mutex.RLock()
+	defer connector.mutex.RUnlock()
+	return connector.config, nil
+}
+
+// managedTags copia os tags em uso da eclusa. Eclusas ainda no tags.json são
+// semeadas no banco antes da primeira gravação, então os tags em uso são sempre
+// a base da validação.
+func managedTags(conn PLCConnection) map[string]PLCTag {
+	tags := make(map[string]PLCTag, len(conn.Tags))
+	for name, tag := range conn.Tags {
+		tags[name] = tag
+	}
+	return tags
+}
+
+// groupScanClasses retorna a classe de varredura herdada por tag (ID do tag → classe).
+// Um tag em vários grupos com classe herda a do grupo de menor ID.
+func groupScanClasses() (map[uint]string, error) {
+	var rows []struct {
+		TagID     uint
+		ScanClass string
+	}
+	err := tagDB().Table("tag_group_members").
+		Select("tag_group_members.tag_id, tag_groups.scan_class").
+		Joins("JOIN tag_groups ON tag_groups.id = tag_group_members.group_id").
+		Where("tag_groups.is_active = ? AND tag_groups.scan_class <> ''", true).
+		Order("tag_groups.id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	classes := make(map[uint]string)
+	for _, row := range rows {
+		if _, ok := classes[row.TagID]; !ok {
+			classes[row.TagID] = row.ScanClass
+		}
+	}
+	return classes, nil
+}
+
+// seedTagsFromFile importa os tags do tags.json de cada eclusa que ainda não tem
+// registros no banco e a marca como mantida pelo banco
+func seedTagsFromFile(connections []PLCConnection) {
+	db := tagDB()
+	if db == nil {
+		return
+	}
+
+	for _, conn := range connections {
+		// Conectores novos ainda não existem: valida contra as próprias conexões do arquivo
+		created, err := seedLock(db, connections, conn)
+		if err != nil {
+			log.Printf("⚠️ [%s] Erro ao importar tags do tags.json para o banco: %v", conn.ID, err)
+			continue
+		}
+		if created > 0 {
+			log.Printf("📥 [%s] %d tags importados do tags.json para o banco", conn.ID, created)
+		}
+	}
+}
+
+// seedLock marca a eclusa como mantida pelo banco. Sem registros no banco, importa
+// antes os tags da conexão; com registros, eles já são a fonte dos tags.
+func seedLock(db *gorm.DB, live []PLCConnection, conn PLCConnection) (int, error) {
+	managed, err := managedLocks(db)
+	if err != nil || managed[conn.ID] {
+		return 0, err
+	}
+
+	var count int64
+	if err := db.Model(&models.Tag{}).Where("plc_id = ?", conn.ID).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	if count > 0 {
+		return 0, markManaged(db, conn.ID)
+	}
+
+	created, _, err := importTags(live, []PLCConnection{conn}, false)
+	return created, err
+}
+
+// ImportTags grava os tags das conexões no banco (formato tags.json).
+// Tags existentes são atualizados; com replace, os tags ausentes do arquivo são desativados.
+// Cada eclusa é validada com a configuração em uso (o plc_config do arquivo é ignorado)
+// e os tags que ela terá depois da importação.
+func ImportTags(connections []PLCConnection, replace bool) (created int, updated int, err error) {
+	return importTags(GetPLCManager().currentConnections(), connections, replace)
+}
+
+func importTags(live []PLCConnection, connections []PLCConnection, replace bool) (created int, updated int, err error) {
+	db := tagDB()
+	if db == nil {
+		return 0, 0, fmt.Errorf("banco de dados não disponível")
+	}
+
+	managed, err := managedLocks(db)
+	if err != nil {
+		return 0, 0, err
+	}
+	byID := make(map[string]PLCConnection, len(live))
+	for _, conn := range live {
+		byID[conn.ID] = conn
+	}
+
+	for _, conn := range connections {
+		merged, ok := byID[conn.ID]
+		if !ok {
+			return 0, 0, fmt.Errorf("eclusa %s não configurada", conn.ID)
+		}
+
+		tags := make(map[string]PLCTag)
+		if managed[conn.ID] && !replace {
+			for name, tag := range merged.Tags {
+				tags[name] = tag
+			}
+		}
+		for name, tag := range conn.Tags {
+			tags[name] = tag
+		}
+		merged.Tags = tags
+
+		if err := validatePLCConnection(merged); err != nil {
+			return 0, 0, fmt.Errorf("eclusa %s: %v", conn.ID, err)
+		}
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, conn := range connections {
+			if err := markManaged(tx, conn.ID); err != nil {
+				return err
+			}
+
+			names := make([]string, 0, len(conn.Tags))
+
+			for name, tag := range conn.Tags {
+				names = append(names, name)
+				row := ModelFromPLCTag(conn.ID, name, tag)
+
+				var existing models.Tag
+				result := tx.Where("plc_id = ? AND name = ?", conn.ID, name).Limit(1).Find(&existing)
+				if result.Error != nil {
+					return result.Error
+				}
+
+				if result.RowsAffected == 0 {
+					if err := tx.Create(&row).Error; err != nil {
+						return err
+					}
+					created++
+					continue
+				}
+
+				row.ID = existing.ID
+				if row.Unit == "" {
+					row.Unit = existing.Unit
+				}
+				row.CreatedAt = existing.CreatedAt
+				if err := tx.Save(&row).Error; err != nil {
+					return err
+				}
+				updated++
+			}
+
+			if replace {
+				query := tx.Model(&models.Tag{}).Where("plc_id = ?", conn.ID)
+				if len(names) > 0 {
+					query = query.Where("name NOT IN ?", names)
+				}
+				if err := query.Update("is_active", false).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+
+	return created, updated, err
+}
+
+// ExportConfig gera o tags.json com as conexões atuais e os tags ativos do banco
+func ExportConfig() (PLCConfigFile, error) {
+	connections := GetPLCManager().currentConnections()
+
+	// Sem herança dos grupos: o arquivo exportado guarda só a classe própria de cada tag
+	if err := loadDBTags(connections, false); err != nil {
+		return PLCConfigFile{}, err
+	}
+
+	return PLCConfigFile{PLCs: connections}, nil
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"backend-go/models"
+)
+
+// Comandos enviados pelos clientes do WebSocket. Exigem conexão autenticada (?token=)
+// com a permissão do comando, conferida a cada comando com o token ainda válido e o
+// usuário (bloqueio e role) recarregado do banco; a resposta vai só para o cliente que enviou
+// ("type": "command_result", com o "id" do comando quando informado).
+
+// Permissão para reconhecer e comentar alarmes
+const permissionAlarmAck = "alarms.ack"
+
+var (
+	errWebSocketUnauthenticated = errors.New("conexão sem autenticação (conecte com ?token=)")
+	errWebSocketForbidden       = errors.New("permissão insuficiente para esta ação")
+	errWebSocketSession         = errors.New("sessão inválida")
+	errUnknownLock              = errors.New("eclusa não encontrada")
+)
+
+// clientCommand é uma mensagem de comando do cliente
+type clientCommand struct {
+	Type    string `json:"type"`
+	ID      string `json:"id,omitempty"` // devolvido na resposta, para o cliente casar a resposta
+	LockID  string `json:"lock_id"`
+	RuleID  string `json:"rule_id"`
+	Comment string `json:"comment"`
+}
+
+// handleCommand interpreta e executa um comando recebido do cliente
+func (c *WebSocketClient) handleCommand(message []byte) {
+	var command clientCommand
+	if err := json.Unmarshal(message, &command); err != nil || command.Type == "" {
+		log.Printf("📨 Mensagem recebida: %s", string(message))
+		return
+	}
+
+	var result interface{}
+	var err error
+	switch command.Type {
+	case "alarm_ack", "alarm_ack_all", "alarm_comment":
+		result, err = c.alarmCommand(command)
+	default:
+		c.reply(command, nil, "comando desconhecido: "+command.Type)
+		return
+	}
+
+	if err != nil {
+		c.reply(command, nil, err.Error())
+		return
+	}
+	c.reply(command, result, "")
+}
+
+// alarmCommand reconhece ou comenta alarmes em nome do usuário da conexão
+func (c *WebSocketClient) alarmCommand(command clientCommand) (interface{}, error) {
+	user, err := c.currentUser()
+	if err != nil {
+		return nil, err
+	}
+	if !user.HasPermission(permissionAlarmAck) {
+		return nil, errWebSocketForbidden
+	}
+	if _, ok := GetPLCManager().Connector(command.LockID); !ok {
+		return nil, errUnknownLock
+	}
+
+	engine := GetAlarmEngine()
+	switch command.Type {
+	case "alarm_ack":
+		return engine.Acknowledge(command.LockID, command.RuleID, user.Username, command.Comment)
+	case "alarm_ack_all":
+		return engine.AcknowledgeAll(command.LockID, user.Username, command.Comment), nil
+	default:
+		return engine.Comment(command.LockID, command.RuleID, user.Username, command.Comment)
+	}
+}
+
+// currentUser revalida o token da conexão e recarrega o usuário do banco, para que
+// token expirado, bloqueio ou troca de role valham sem reconectar
+func (c *WebSocketClient) currentUser() (models.User, error) {
+	if c.token == "" {
+		return models.User{}, errWebSocketUnauthenticated
+	}
+	user, err := c.authenticate(c.token)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%w: %v", errWebSocketSession, err)
+	}
+	return user, nil
+}
+
+// reply envia o resultado de um comando apenas para este cliente
+func (c *WebSocketClient) reply(command clientCommand, result interface{}, errMessage string) {
+	response := map[string]interface{}{
+		"type":    "command_result",
+		"command": command.Type,
+		"ok":      errMessage == "",
+	}
+	if command.ID != "" {
+		response["id"] = command.ID
+	}
+	if errMessage != "" {
+		response["error"] = errMessage
+	} else if result != nil {
+		response["result"] = result
+	}
+
+	data, err := json.Marshal(response)
+	if err != nil {
+		return
+	}
+	select {
+	case c.send <- data:
+	default:
+		log.Printf("⚠️ Canal do cliente %s cheio: resposta de %s descartada", c.remoteAddr, command.Type)
+	}
+}
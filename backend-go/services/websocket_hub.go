@@ -37,14 +37,20 @@ type WebSocketHub struct {
 
 // WebSocketClient representa uma conexão de cliente
 type WebSocketClient struct {
-	hub        *WebSocketHub
-	conn       *websocket.Conn
-	send       chan []byte
-	lastPong   time.Time
-	userAgent  string
-	remoteAddr string
+	hub          *WebSocketHub
+	conn         *websocket.Conn
+	send         chan []byte
+	lastPong     time.Time
+	userAgent    string
+	remoteAddr   string
+	token        string                 // token da conexão (vazio = somente leitura)
+	authenticate WebSocketAuthenticator // revalida o token e recarrega o usuário a cada comando
 }
 
+// WebSocketAuthenticator valida o token e carrega o usuário com o seu role do banco,
+// recusando tokens expirados e usuários bloqueados
+type WebSocketAuthenticator func(token string) (models.User, error)
+
 var (
 	globalHub *WebSocketHub
 	hubOnce   sync.Once
@@ -91,8 +97,17 @@ func GetWebSocketHub() *WebSocketHub {
 	return globalHub
 }
 
-// HandleWebSocket gerencia upgrade de conexão HTTP para WebSocket
-func (h *WebSocketHub) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+// HandleWebSocket gerencia upgrade de conexão HTTP para WebSocket.
+// token é o token opcional da conexão: sem ele, ou se recusado, o cliente é só de leitura.
+// Os comandos revalidam o token e o usuário com authenticate a cada envio.
+func (h *WebSocketHub) HandleWebSocket(w http.ResponseWriter, r *http.Request, token string, authenticate WebSocketAuthenticator) {
+	if token != "" {
+		if _, err := authenticate(token); err != nil {
+			log.Printf("⚠️ WebSocket com token rejeitado (%v): conexão só de leitura", err)
+			token = ""
+		}
+	}
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("❌ Erro no upgrade WebSocket: %v", err)
@@ -100,12 +115,14 @@ func (h *WebSocketHub) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 	
 	client := &WebSocketClient{
-		hub:        h,
-		conn:       conn,
-		send:       make(chan []byte, 256),
-		lastPong:   time.Now(),
-		userAgent:  r.UserAgent(),
-		remoteAddr: r.RemoteAddr,
+		hub:          h,
+		conn:         conn,
+		send:         make(chan []byte, 256),
+		lastPong:     time.Now(),
+		userAgent:    r.UserAgent(),
+		remoteAddr:   r.RemoteAddr,
+		token:        token,
+		authenticate: authenticate,
 	}
 	
 	// Configurar pong handler
@@ -134,10 +151,10 @@ func (h *WebSocketHub) run() {
 			clientCount := len(h.clients)
 			h.mutex.Unlock()
 			
-			// Enviar dados atuais do S7 PLC para o novo cliente
+			// Enviar dados atuais de todas as eclusas para o novo cliente
 			go func() {
-				s7Connector := GetS7PLCConnector()
-				s7Connector.SendCurrentValues(client.send)
+				GetPLCManager().SendCurrentValues(client.send)
+				GetAlarmEngine().SendActiveAlarms(client.send)
 			}()
 			
 			log.Printf("✅ Cliente registrado. Total: %d", clientCount)
@@ -168,8 +185,6 @@ func (h *WebSocketHub) broadcastMessage(message []byte) {
 	}
 	h.mutex.RUnlock()
 	
-	log.Printf("📤 HUB: Enviando para %d clientes conectados", len(clients))
-	
 	// Broadcast paralelo para melhor performance
 	var wg sync.WaitGroup
 	for _, client := range clients {
@@ -179,7 +194,6 @@ func (h *WebSocketHub) broadcastMessage(message []byte) {
 			
 			select {
 			case c.send <- message:
-				log.Printf("✅ HUB: Mensagem enviada para cliente %s", c.remoteAddr)
 			default:
 				// Canal cheio, remover cliente
 				log.Printf("❌ HUB: Canal cheio para cliente %s, removendo", c.remoteAddr)
@@ -194,7 +208,6 @@ func (h *WebSocketHub) broadcastMessage(message []byte) {
 	}
 	
 	wg.Wait()
-	log.Printf("📤 HUB: Broadcast concluído")
 }
 
 
@@ -258,6 +271,22 @@ func (h *WebSocketHub) BroadcastTagUpdate(tagName string, value float64) {
 	}
 }
 
+// BroadcastMessage serializa e envia uma mensagem de controle (ex: config_changed)
+func (h *WebSocketHub) BroadcastMessage(message interface{}) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("❌ Erro ao serializar mensagem: %v", err)
+		return
+	}
+
+	select {
+	case h.broadcast <- data:
+		// Sucesso
+	default:
+		log.Printf("⚠️ Canal de broadcast cheio para mensagem de controle")
+	}
+}
+
 // GetStats retorna estatísticas do hub
 func (h *WebSocketHub) GetStats() map[string]interface{} {
 	h.mutex.RLock()
@@ -290,8 +319,8 @@ func (c *WebSocketClient) readPump() {
 			break
 		}
 		
-		// Processar mensagem recebida (se necessário)
-		log.Printf("📨 Mensagem recebida: %s", string(message))
+		// Processar comandos do cliente (ex: reconhecimento de alarmes)
+		c.handleCommand(message)
 		
 		// Resetar timeout
 		c.conn.SetReadDeadline(time.Now().Add(300 * time.Second))
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Escrita de planilhas XLSX em fluxo: o arquivo é um zip de XMLs e a planilha é o último
+// item, escrito linha a linha, então exportações grandes não ficam inteiras na memória.
+// Só o necessário para exportar tabelas: textos, números e datas numa única planilha.
+
+// Partes fixas do pacote XLSX
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>
+</Types>`
+
+	xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`
+
+	xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`
+
+	xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+</Relationships>`
+
+	// Estilo 1: data/hora no formato do idioma; estilo 2: cabeçalho em negrito
+	xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<numFmts count="1"><numFmt numFmtId="164" formatCode="%s"/></numFmts>
+<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>
+<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+<cellXfs count="3"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>
+</styleSheet>`
+
+	xlsxSheetStart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+
+	xlsxSheetEnd = `</sheetData></worksheet>`
+)
+
+// Linhas por planilha aceitas pelo Excel (cabeçalho incluído)
+const xlsxMaxRows = 1048576
+
+// errXLSXRowLimit interrompe a escrita que passaria do limite de linhas da planilha
+var errXLSXRowLimit = fmt.Errorf("planilha excede o limite de %d linhas do XLSX", xlsxMaxRows)
+
+// xlsxEpoch é o dia zero das datas do Excel
+var xlsxEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// xlsxWriter escreve uma planilha XLSX linha a linha
+type xlsxWriter struct {
+	zip   *zip.Writer
+	sheet *bufio.Writer
+	row   int
+}
+
+// newXLSXWriter escreve as partes fixas do pacote e abre a planilha.
+// dateFormat é o formato das células de data/hora (ex: "dd/mm/yyyy hh:mm:ss").
+func newXLSXWriter(w io.Writer, sheetName string, dateFormat string) (*xlsxWriter, error) {
+	archive := zip.NewWriter(w)
+
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", fmt.Sprintf(xlsxWorkbook, xlsxEscape(sheetName))},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/styles.xml", fmt.Sprintf(xlsxStyles, xlsxEscape(dateFormat))},
+	}
+	for _, part := range parts {
+		f, err := archive.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	sheet, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	writer := &xlsxWriter{zip: archive, sheet: bufio.NewWriter(sheet)}
+	_, err = writer.sheet.WriteString(xlsxSheetStart)
+	return writer, err
+}
+
+// xlsxEscape escapa texto para dentro do XML
+func xlsxEscape(text string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(text))
+	return b.String()
+}
+
+// xlsxColumn converte o índice da coluna (0 = A) na letra da referência
+func xlsxColumn(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
+
+// WriteRow escreve uma linha. Aceita string, time.Time, float64, int64, int e nil (célula vazia);
+// NaN e ±Inf também viram célula vazia.
+// bold marca a linha como cabeçalho.
+func (x *xlsxWriter) WriteRow(cells []interface{}, bold bool) error {
+	if x.row >= xlsxMaxRows {
+		return errXLSXRowLimit
+	}
+	x.row++
+	b := x.sheet
+	fmt.Fprintf(b, `<row r="%d">`, x.row)
+
+	for i, cell := range cells {
+		ref := xlsxColumn(i) + strconv.Itoa(x.row)
+		style := ""
+		if bold {
+			style = ` s="2"`
+		}
+
+		switch v := cell.(type) {
+		case nil:
+			continue
+		case string:
+			fmt.Fprintf(b, `<c r="%s" t="inlineStr"%s><is><t xml:space="preserve">%s</t></is></c>`, ref, style, xlsxEscape(v))
+		case time.Time:
+			_, offset := v.Zone()
+			wall := v.Add(time.Duration(offset) * time.Second).UTC()
+			serial := wall.Sub(xlsxEpoch).Hours() / 24
+			fmt.Fprintf(b, `<c r="%s" s="1"><v>%s</v></c>`, ref, strconv.FormatFloat(serial, 'f', -1, 64))
+		case float64:
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				continue // sem representação numérica na planilha, como no WebSocket
+			}
+			fmt.Fprintf(b, `<c r="%s"%s><v>%s</v></c>`, ref, style, strconv.FormatFloat(v, 'f', -1, 64))
+		case int64:
+			fmt.Fprintf(b, `<c r="%s"%s><v>%d</v></c>`, ref, style, v)
+		case int:
+			fmt.Fprintf(b, `<c r="%s"%s><v>%d</v></c>`, ref, style, v)
+		default:
+			fmt.Fprintf(b, `<c r="%s" t="inlineStr"%s><is><t>%s</t></is></c>`, ref, style, xlsxEscape(fmt.Sprint(v)))
+		}
+	}
+
+	_, err := b.WriteString(`</row>`)
+	return err
+}
+
+// Flush envia ao destino o que já foi escrito
+func (x *xlsxWriter) Flush() error {
+	if err := x.sheet.Flush(); err != nil {
+		return err
+	}
+	return x.zip.Flush()
+}
+
+// Close fecha a planilha e o pacote
+func (x *xlsxWriter) Close() error {
+	if _, err := x.sheet.WriteString(xlsxSheetEnd); err != nil {
+		return err
+	}
+	if err := x.sheet.Flush(); err != nil {
+		return err
+	}
+	return x.zip.Close()
+}
@@ -75,6 +75,16 @@ let reconnectTimeout: NodeJS.Timeout | null = null;
 let isConnecting = false;
 let reconnectAttempts = 0;
 
+// ✅ MULTI-ECLUSA: backend envia uma mensagem por eclusa com "lock_id"
+// Padrão "regua", a eclusa padrão do backend; VITE_LOCK_ID escolhe outra
+const LOCK_ID: string = import.meta.env?.VITE_LOCK_ID || 'regua';
+
+// ✅ MENSAGENS DE OUTRA ECLUSA (dados, alarmes, eclusagem...) NÃO SÃO DESTE PAINEL
+// Mensagens sem lock_id (config_changed, command_result) valem para todas
+function isOtherLock(data: any): boolean {
+  return typeof data?.lock_id === 'string' && data.lock_id !== LOCK_ID;
+}
+
 // ✅ CACHE GLOBAL DOS ÚLTIMOS DADOS RECEBIDOS
 let lastReceivedData: any = null;
 
@@ -124,6 +134,17 @@ function connectGlobalWebSocket(url: string) {
     globalWebSocket.onmessage = (event) => {
       try {
         const data = JSON.parse(event.data);
+
+        // ✅ IGNORA DADOS E MENSAGENS DE CONTROLE DE OUTRAS ECLUSAS
+        if (isOtherLock(data)) {
+          return;
+        }
+        
+        // ✅ MENSAGENS DE CONTROLE (config_changed, alarm, lockage...) NÃO SÃO DADOS DO PLC
+        if (data.type) {
+          notifyGlobalListeners(data);
+          return;
+        }
         
         // ✅ SALVA DADOS NO CACHE GLOBAL E MARCA COMO PRONTO
         if (!data.ping) {